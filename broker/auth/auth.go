@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	// SchemeBasic is the scheme of BASIC authentication
+	SchemeBasic = "Basic"
+	// SchemeBearer is the scheme of bearer token authentication
+	SchemeBearer = "Bearer"
+)
+
+var (
+	// ErrNoCredentials is returned when the request doesn't have credentials
+	ErrNoCredentials = errors.New("credentials are not found in the request")
+	// ErrInvalidCredentials is returned when the credentials are not accepted by any verifiers
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal represents an authenticated client such as a platform
+type Principal struct {
+	Name   string
+	Method string
+}
+
+// Verifier verifies credentials of a specific scheme
+type Verifier interface {
+	Scheme() string
+	Verify(credentials string) (*Principal, error)
+}
+
+// Reloader is implemented by verifiers which read their credentials from files
+type Reloader interface {
+	Reload() error
+}
+
+// Authenticator authenticates requests with registered verifiers
+type Authenticator struct {
+	verifiers []Verifier
+}
+
+// NewAuthenticator returns new Authenticator. nil verifiers are ignored
+func NewAuthenticator(verifiers ...Verifier) *Authenticator {
+	a := &Authenticator{}
+	for _, v := range verifiers {
+		if v != nil {
+			a.verifiers = append(a.verifiers, v)
+		}
+	}
+	return a
+}
+
+// Enabled returns true if any verifiers are registered
+func (a *Authenticator) Enabled() bool {
+	return len(a.verifiers) > 0
+}
+
+// Reloaders returns verifiers which can reload their credentials
+func (a *Authenticator) Reloaders() []Reloader {
+	var reloaders []Reloader
+	for _, v := range a.verifiers {
+		if r, ok := v.(Reloader); ok {
+			reloaders = append(reloaders, r)
+		}
+	}
+	return reloaders
+}
+
+// Authenticate verifies the Authorization header of the request
+func (a *Authenticator) Authenticate(req *http.Request) (*Principal, error) {
+	tokens := strings.SplitN(req.Header.Get("Authorization"), " ", 2)
+	if len(tokens) != 2 || tokens[1] == "" {
+		return nil, ErrNoCredentials
+	}
+	scheme, credentials := tokens[0], tokens[1]
+
+	for _, v := range a.verifiers {
+		if !strings.EqualFold(v.Scheme(), scheme) {
+			continue
+		}
+		principal, err := v.Verify(credentials)
+		if err == nil && principal != nil {
+			return principal, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticator(t *testing.T) {
+	a := NewAuthenticator(
+		nil,
+		NewBasicVerifier("platform1", "password1"),
+		NewBasicVerifier("platform2", "password2"),
+	)
+	assert.True(t, a.Enabled())
+	assert.Empty(t, a.Reloaders())
+
+	cases := []struct {
+		name      string
+		header    string
+		principal string
+		err       error
+	}{
+		{name: "no header", header: "", err: ErrNoCredentials},
+		{name: "first credential", header: "Basic " + basicCredentials("platform1", "password1"), principal: "platform1"},
+		{name: "second credential", header: "basic " + basicCredentials("platform2", "password2"), principal: "platform2"},
+		{name: "invalid credential", header: "Basic " + basicCredentials("platform1", "password2"), err: ErrInvalidCredentials},
+		{name: "unsupported scheme", header: "Bearer dummy", err: ErrInvalidCredentials},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			assert.NoError(t, err)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+
+			principal, err := a.Authenticate(req)
+			assert.Equal(t, tc.err, err)
+			if tc.err == nil {
+				assert.Equal(t, tc.principal, principal.Name)
+			}
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.False(t, NewAuthenticator(NewBasicVerifier("", "")).Enabled())
+	})
+}
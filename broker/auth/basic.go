@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const methodBasic = "basic"
+
+type basicVerifier struct {
+	username string
+	password string
+}
+
+// NewBasicVerifier returns Verifier which accepts the single username/password pair.
+// It returns nil if username or password is empty
+func NewBasicVerifier(username, password string) Verifier {
+	if username == "" || password == "" {
+		return nil
+	}
+	return &basicVerifier{username: username, password: password}
+}
+
+func (v *basicVerifier) Scheme() string {
+	return SchemeBasic
+}
+
+func (v *basicVerifier) Verify(credentials string) (*Principal, error) {
+	username, password, err := decodeBasicCredentials(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(username), []byte(v.username)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(v.password)) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{Name: username, Method: methodBasic}, nil
+}
+
+// CredentialsFileVerifier verifies BASIC credentials with bcrypt-hashed passwords in a file.
+// The file has "username:bcrypt-hash" per line(same as `htpasswd -B`), and lines starting with # are ignored.
+type CredentialsFileVerifier struct {
+	path string
+
+	mu       sync.RWMutex
+	modTime  time.Time
+	size     int64
+	hashes   map[string][]byte
+	verified map[string][sha256.Size]byte // cache of verified passwords to avoid bcrypt on every request
+	// dummyHash is compared for unknown usernames, so they take as long as known ones
+	dummyHash []byte
+}
+
+// NewCredentialsFileVerifier reads the credentials file and returns CredentialsFileVerifier
+func NewCredentialsFileVerifier(path string) (*CredentialsFileVerifier, error) {
+	v := &CredentialsFileVerifier{path: path}
+	if err := v.Reload(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Scheme implements Verifier.Scheme
+func (v *CredentialsFileVerifier) Scheme() string {
+	return SchemeBasic
+}
+
+// Verify implements Verifier.Verify
+func (v *CredentialsFileVerifier) Verify(credentials string) (*Principal, error) {
+	username, password, err := decodeBasicCredentials(credentials)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(password))
+
+	v.mu.RLock()
+	hash, ok := v.hashes[username]
+	cached, isCached := v.verified[username]
+	dummyHash := v.dummyHash
+	v.mu.RUnlock()
+
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password)) // nolint
+		return nil, ErrInvalidCredentials
+	}
+	if isCached && subtle.ConstantTimeCompare(cached[:], sum[:]) == 1 {
+		return &Principal{Name: username, Method: methodBasic}, nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	v.mu.Lock()
+	// the file may be reloaded while comparing
+	if current, ok := v.hashes[username]; ok && bytes.Equal(current, hash) {
+		v.verified[username] = sum
+	}
+	v.mu.Unlock()
+
+	return &Principal{Name: username, Method: methodBasic}, nil
+}
+
+// Reload reads the credentials file again if it was modified.
+// The current credentials are kept if the file is invalid
+func (v *CredentialsFileVerifier) Reload() error {
+	info, err := os.Stat(v.path)
+	if err != nil {
+		return err
+	}
+
+	v.mu.RLock()
+	modified := !info.ModTime().Equal(v.modTime) || info.Size() != v.size
+	v.mu.RUnlock()
+	if !modified {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(v.path)
+	if err != nil {
+		return err
+	}
+	hashes, err := parseCredentials(data)
+	if err != nil {
+		return fmt.Errorf("parsing credentials file %q is failed: %s", v.path, err)
+	}
+	dummyHash, err := newDummyHash(hashes)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.modTime = info.ModTime()
+	v.size = info.Size()
+	v.hashes = hashes
+	v.verified = make(map[string][sha256.Size]byte)
+	v.dummyHash = dummyHash
+	return nil
+}
+
+// newDummyHash returns a hash of the random password with the highest cost in hashes
+func newDummyHash(hashes map[string][]byte) ([]byte, error) {
+	cost := bcrypt.DefaultCost
+	if len(hashes) > 0 {
+		cost = bcrypt.MinCost
+	}
+	for _, hash := range hashes {
+		if c, err := bcrypt.Cost(hash); err == nil && c > cost {
+			cost = c
+		}
+	}
+
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+	return bcrypt.GenerateFromPassword(password, cost)
+}
+
+func parseCredentials(data []byte) (map[string][]byte, error) {
+	hashes := make(map[string][]byte)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		tokens := strings.SplitN(line, ":", 2)
+		if len(tokens) != 2 || tokens[0] == "" {
+			return nil, fmt.Errorf("line %d: format must be 'username:bcrypt-hash'", lineNo)
+		}
+		if _, err := bcrypt.Cost([]byte(tokens[1])); err != nil {
+			return nil, fmt.Errorf("line %d: password of %q is not a bcrypt hash: %s", lineNo, tokens[0], err)
+		}
+		if _, exists := hashes[tokens[0]]; exists {
+			return nil, fmt.Errorf("line %d: username %q is duplicated", lineNo, tokens[0])
+		}
+		hashes[tokens[0]] = []byte(tokens[1])
+	}
+	return hashes, scanner.Err()
+}
+
+func decodeBasicCredentials(credentials string) (string, string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(credentials)
+	if err != nil {
+		return "", "", err
+	}
+	tokens := strings.SplitN(string(decoded), ":", 2)
+	if len(tokens) != 2 {
+		return "", "", errors.New("invalid BASIC credentials format")
+	}
+	return tokens[0], tokens[1], nil
+}
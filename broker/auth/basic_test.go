@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func basicCredentials(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+func writeCredentialsFile(t *testing.T, path string, pairs ...string) {
+	content := "# platform credentials\n"
+	for i := 0; i+1 < len(pairs); i += 2 {
+		hash, err := bcrypt.GenerateFromPassword([]byte(pairs[i+1]), bcrypt.MinCost)
+		assert.NoError(t, err)
+		content += pairs[i] + ":" + string(hash) + "\n"
+	}
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+}
+
+func TestBasicVerifier(t *testing.T) {
+	assert.Nil(t, NewBasicVerifier("", "password"))
+	assert.Nil(t, NewBasicVerifier("user", ""))
+
+	v := NewBasicVerifier("user", "password")
+
+	principal, err := v.Verify(basicCredentials("user", "password"))
+	assert.NoError(t, err)
+	assert.Equal(t, "user", principal.Name)
+
+	_, err = v.Verify(basicCredentials("user", "invalid"))
+	assert.Error(t, err)
+
+	_, err = v.Verify("not-base64")
+	assert.Error(t, err)
+}
+
+func TestCredentialsFileVerifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "osbs-auth")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint
+	path := filepath.Join(dir, "credentials")
+
+	writeCredentialsFile(t, path, "platform1", "password1", "platform2", "password2")
+	v, err := NewCredentialsFileVerifier(path)
+	assert.NoError(t, err)
+
+	t.Run("Multiple credentials", func(t *testing.T) {
+		for _, pair := range [][]string{{"platform1", "password1"}, {"platform2", "password2"}} {
+			principal, err := v.Verify(basicCredentials(pair[0], pair[1]))
+			assert.NoError(t, err)
+			assert.Equal(t, pair[0], principal.Name)
+
+			// verified by cache
+			principal, err = v.Verify(basicCredentials(pair[0], pair[1]))
+			assert.NoError(t, err)
+			assert.Equal(t, pair[0], principal.Name)
+		}
+
+		_, err := v.Verify(basicCredentials("platform1", "password2"))
+		assert.Error(t, err)
+		_, err = v.Verify(basicCredentials("unknown", "password1"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Unknown users are compared with the dummy hash", func(t *testing.T) {
+		// the dummy hash has the same cost as hashes in the file not to reveal usernames by response times
+		cost, err := bcrypt.Cost(v.dummyHash)
+		assert.NoError(t, err)
+		assert.Equal(t, bcrypt.MinCost, cost)
+	})
+
+	t.Run("Rotation", func(t *testing.T) {
+		writeCredentialsFile(t, path, "platform1", "rotated")
+		future := time.Now().Add(time.Minute)
+		assert.NoError(t, os.Chtimes(path, future, future))
+		assert.NoError(t, v.Reload())
+
+		_, err := v.Verify(basicCredentials("platform1", "password1"))
+		assert.Error(t, err)
+		_, err = v.Verify(basicCredentials("platform2", "password2"))
+		assert.Error(t, err)
+		_, err = v.Verify(basicCredentials("platform1", "rotated"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Invalid file keeps current credentials", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("platform1:plain-password\n"), 0600))
+		future := time.Now().Add(2 * time.Minute)
+		assert.NoError(t, os.Chtimes(path, future, future))
+		assert.Error(t, v.Reload())
+
+		_, err := v.Verify(basicCredentials("platform1", "rotated"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("File is not found", func(t *testing.T) {
+		_, err := NewCredentialsFileVerifier(filepath.Join(dir, "not-exists"))
+		assert.Error(t, err)
+	})
+}
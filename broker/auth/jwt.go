@@ -0,0 +1,346 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	methodJWT = "jwt"
+	// jwtClockSkew is the allowed difference of clocks between the issuer and the broker
+	jwtClockSkew = time.Minute
+)
+
+// JWTConfig represents the settings of JWTVerifier
+type JWTConfig struct {
+	// JWKSFile is the path of JSON Web Key Set file
+	JWKSFile string
+	// Issuer is the expected "iss" claim. It is not verified if empty
+	Issuer string
+	// Audience is the expected "aud" claim. It is not verified if empty
+	Audience string
+}
+
+// JWTVerifier verifies bearer JWTs with public keys in a JWKS file.
+// Supported algorithms are RS256/RS384/RS512 and ES256/ES384/ES512.
+type JWTVerifier struct {
+	config *JWTConfig
+
+	mu      sync.RWMutex
+	modTime time.Time
+	size    int64
+	keys    map[string]crypto.PublicKey
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+}
+
+// NewJWTVerifier reads the JWKS file and returns JWTVerifier
+func NewJWTVerifier(config *JWTConfig) (*JWTVerifier, error) {
+	v := &JWTVerifier{config: config}
+	if err := v.Reload(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Scheme implements Verifier.Scheme
+func (v *JWTVerifier) Scheme() string {
+	return SchemeBearer
+}
+
+// Verify implements Verifier.Verify
+func (v *JWTVerifier) Verify(credentials string) (*Principal, error) {
+	parts := strings.Split(credentials, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid JWT format")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid JWT header: %s", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT signature: %s", err)
+	}
+
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid JWT claims: %s", err)
+	}
+	if err := v.validateClaims(&claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	name := claims.Subject
+	if name == "" {
+		name = claims.Issuer
+	}
+	return &Principal{Name: name, Method: methodJWT}, nil
+}
+
+// Reload reads the JWKS file again if it was modified.
+// The current keys are kept if the file is invalid
+func (v *JWTVerifier) Reload() error {
+	info, err := os.Stat(v.config.JWKSFile)
+	if err != nil {
+		return err
+	}
+
+	v.mu.RLock()
+	modified := !info.ModTime().Equal(v.modTime) || info.Size() != v.size
+	v.mu.RUnlock()
+	if !modified {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(v.config.JWKSFile)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("parsing JWKS file %q is failed: %s", v.config.JWKSFile, err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.modTime = info.ModTime()
+	v.size = info.Size()
+	v.keys = keys
+	return nil
+}
+
+func (v *JWTVerifier) key(kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("JWK %q is not found", kid)
+	}
+	return key, nil
+}
+
+func (v *JWTVerifier) validateClaims(claims *jwtClaims, now time.Time) error {
+	if claims.ExpiresAt == nil {
+		return errors.New("JWT doesn't have exp claim")
+	}
+	if now.After(time.Unix(*claims.ExpiresAt, 0).Add(jwtClockSkew)) {
+		return errors.New("JWT is expired")
+	}
+	if claims.NotBefore != nil && now.Add(jwtClockSkew).Before(time.Unix(*claims.NotBefore, 0)) {
+		return errors.New("JWT is not valid yet")
+	}
+	if v.config.Issuer != "" && claims.Issuer != v.config.Issuer {
+		return fmt.Errorf("JWT issuer %q is not allowed", claims.Issuer)
+	}
+	if v.config.Audience != "" {
+		var audiences []string
+		if len(claims.Audience) > 0 {
+			var aud string
+			if err := json.Unmarshal(claims.Audience, &aud); err == nil {
+				audiences = []string{aud}
+			} else if err := json.Unmarshal(claims.Audience, &audiences); err != nil {
+				return fmt.Errorf("invalid JWT aud claim: %s", err)
+			}
+		}
+		found := false
+		for _, aud := range audiences {
+			if aud == v.config.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.New("JWT audience is not allowed")
+		}
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("JWT algorithm %q is not supported", alg)
+	}
+	digest := jwtDigest(hash, signed)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("JWT algorithm %q doesn't match the key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, signature); err != nil {
+			return errors.New("invalid JWT signature")
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("JWT algorithm %q doesn't match the key", alg)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != size*2 {
+			return errors.New("invalid JWT signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid JWT signature")
+		}
+	default:
+		return errors.New("unsupported JWK type")
+	}
+	return nil
+}
+
+func jwtDigest(hash crypto.Hash, data []byte) []byte {
+	switch hash {
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512(data)
+		return sum[:]
+	default:
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}
+}
+
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for i, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("keys[%d]: %s", i, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys are found")
+	}
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %s", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid e: %s", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curve %q is not supported", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %s", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %s", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("key type %q is not supported", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("value is empty")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	assert.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	assert.NoError(t, err)
+
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		assert.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		assert.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signed + "." + b64(signature)
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa",
+				"use": "sig",
+				"n":   b64(rsaKey.N.Bytes()),
+				"e":   b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec",
+				"crv": "P-256",
+				"x":   b64(ecKey.X.Bytes()),
+				"y":   b64(ecKey.Y.Bytes()),
+			},
+		},
+	}
+	data, err := json.Marshal(jwks)
+	assert.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "osbs-auth")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint
+	path := filepath.Join(dir, "jwks.json")
+	assert.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+	v, err := NewJWTVerifier(&JWTConfig{
+		JWKSFile: path,
+		Issuer:   "https://issuer.example.com",
+		Audience: "open-service-broker-sacloud",
+	})
+	assert.NoError(t, err)
+
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": "https://issuer.example.com",
+			"sub": "platform1",
+			"aud": []string{"open-service-broker-sacloud"},
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	t.Run("Valid tokens", func(t *testing.T) {
+		principal, err := v.Verify(signJWT(t, "RS256", "rsa", rsaKey, validClaims()))
+		assert.NoError(t, err)
+		assert.Equal(t, "platform1", principal.Name)
+
+		claims := validClaims()
+		claims["aud"] = "open-service-broker-sacloud"
+		principal, err = v.Verify(signJWT(t, "ES256", "ec", ecKey, claims))
+		assert.NoError(t, err)
+		assert.Equal(t, "platform1", principal.Name)
+	})
+
+	t.Run("Invalid tokens", func(t *testing.T) {
+		expired := validClaims()
+		expired["exp"] = time.Now().Add(-time.Hour).Unix()
+		noExp := validClaims()
+		delete(noExp, "exp")
+		otherIssuer := validClaims()
+		otherIssuer["iss"] = "https://other.example.com"
+		otherAudience := validClaims()
+		otherAudience["aud"] = "other"
+
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+
+		tokens := map[string]string{
+			"expired":        signJWT(t, "RS256", "rsa", rsaKey, expired),
+			"without exp":    signJWT(t, "RS256", "rsa", rsaKey, noExp),
+			"other issuer":   signJWT(t, "RS256", "rsa", rsaKey, otherIssuer),
+			"other audience": signJWT(t, "RS256", "rsa", rsaKey, otherAudience),
+			"unknown kid":    signJWT(t, "RS256", "unknown", rsaKey, validClaims()),
+			"wrong key":      signJWT(t, "RS256", "rsa", otherKey, validClaims()),
+			"alg mismatch":   signJWT(t, "ES256", "rsa", rsaKey, validClaims()),
+			"malformed":      "dummy",
+		}
+		for name, token := range tokens {
+			_, err := v.Verify(token)
+			assert.Error(t, err, name)
+		}
+	})
+
+	t.Run("Invalid JWKS", func(t *testing.T) {
+		invalid := filepath.Join(dir, "invalid.json")
+		assert.NoError(t, ioutil.WriteFile(invalid, []byte(`{"keys":[]}`), 0600))
+		_, err := NewJWTVerifier(&JWTConfig{JWKSFile: invalid})
+		assert.Error(t, err)
+	})
+}
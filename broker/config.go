@@ -1,8 +1,20 @@
 package broker
 
+import "time"
+
 // Config represents broker configurations
 type Config struct {
-	Port              int
-	BasicAuthUsername string
-	BasicAuthPassword string
+	Port               int
+	BasicAuthUsername  string
+	BasicAuthPassword  string
+	CredentialsFile    string
+	JWKSFile           string
+	JWTIssuer          string
+	JWTAudience        string
+	AuthReloadInterval time.Duration
+	OrphanTimeout      time.Duration
+	ReconcileInterval  time.Duration
+	ReconcileRemediate bool
+	UsageInterval      time.Duration
+	PublicMetrics      bool
 }
@@ -126,6 +126,16 @@ func binding(w http.ResponseWriter, req *http.Request, instanceID, bindingID str
 		return
 	}
 
+	if ok, current := operationLocks.acquire(req.Context(), instanceID, operations.Binding); !ok {
+		logFields["runningOperation"] = current
+		log.WithFields(logFields).Warn(
+			"binding conflicted: another operation is in progress",
+		)
+		writeResponse(w, http.StatusUnprocessableEntity, generateConcurrencyErrorResponse())
+		return
+	}
+	defer operationLocks.release(instanceID)
+
 	instanceState, err := handler.InstanceState(instanceID)
 	if err != nil {
 		logFields["err"] = err
@@ -143,6 +153,14 @@ func binding(w http.ResponseWriter, req *http.Request, instanceID, bindingID str
 		return
 	}
 
+	if instanceState.IsMigrating() {
+		log.WithFields(logFields).Warn(
+			"binding conflicted: instance is migrating, please try again after",
+		)
+		writeResponse(w, http.StatusUnprocessableEntity, generateConcurrencyErrorResponse())
+		return
+	}
+
 	bindingState, err := handler.BindingState(instanceID, bindingID)
 	if err != nil {
 		logFields["err"] = err
@@ -156,6 +156,21 @@ func TestBinding(t *testing.T) {
 		assert.Equal(t, generateInstanceNotFoundResponse(), w.Body.Bytes())
 	})
 
+	t.Run("Instance is migrating", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		dummyHandler = &dummyServiceHandler{
+			instanceState: &dummyInstanceState{
+				isMigrating: true,
+			},
+		}
+
+		binding(w, req, instanceID, bindingID, dummyHandler)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, generateConcurrencyErrorResponse(), w.Body.Bytes())
+	})
+
 	t.Run("Handler returns error", func(t *testing.T) {
 		w := httptest.NewRecorder()
 
@@ -44,6 +44,10 @@ type dummyServiceHandler struct {
 	deleteBindingErr    error
 	createBindingResult *osb.ServiceBinding
 	validateResult      error
+	confirmProvisionErr error
+
+	deleteInstanceCalled   bool
+	confirmProvisionCalled bool
 }
 
 func (s *dummyServiceHandler) InstanceState(instanceID string) (service.InstanceState, error) {
@@ -69,6 +73,7 @@ func (s *dummyServiceHandler) UpdateInstance(instanceID string) error {
 }
 
 func (s *dummyServiceHandler) DeleteInstance(instanceID string) error {
+	s.deleteInstanceCalled = true
 	return s.deleteInstanceErr
 }
 
@@ -83,6 +88,11 @@ func (s *dummyServiceHandler) DeleteBinding(instanceID, bindingID string) error
 	return s.deleteBindingErr
 }
 
+func (s *dummyServiceHandler) ConfirmProvision(instanceID string) error {
+	s.confirmProvisionCalled = true
+	return s.confirmProvisionErr
+}
+
 func (s *dummyServiceHandler) IsValid() (bool, error) {
 	return s.validateResult == nil, s.validateResult
 }
@@ -91,9 +101,14 @@ type dummyInstanceState struct {
 	isFailed    bool
 	isUp        bool
 	isMigrating bool
+	isDeleting  bool
 	hasDiff     bool
 }
 
+func (s *dummyInstanceState) IsDeleting() bool {
+	return s.isDeleting
+}
+
 func (s *dummyInstanceState) IsFailed() bool {
 	return s.isFailed
 }
@@ -110,6 +125,15 @@ func (s *dummyInstanceState) HasDiff() bool {
 	return s.hasDiff
 }
 
+type dummyDescribedInstanceState struct {
+	dummyInstanceState
+	description string
+}
+
+func (s *dummyDescribedInstanceState) StatusDescription() string {
+	return s.description
+}
+
 type dummyBindingState struct {
 	hasDiff bool
 	binding *osb.ServiceBinding
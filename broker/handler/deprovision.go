@@ -6,6 +6,7 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
 	"github.com/sacloud/open-service-broker-sacloud/service"
 )
 
@@ -81,6 +82,21 @@ func deprovisioning(w http.ResponseWriter, req *http.Request, instanceID string,
 		"instanceID": instanceID,
 	}
 
+	// deprovisioning running in background is taken over, so that stopped deletions can be requested again
+	ok, current := operationLocks.acquire(req.Context(), instanceID, operations.Deprovisioning)
+	if !ok {
+		logFields["runningOperation"] = current
+		log.WithFields(logFields).Warn(
+			"deprovisioning conflicted: another operation is in progress",
+		)
+		writeResponse(w, http.StatusUnprocessableEntity, generateConcurrencyErrorResponse())
+		return
+	}
+	defer operationLocks.release(instanceID)
+
+	// the platform takes care of the instance
+	provisions.forget(instanceID)
+
 	state, err := handler.InstanceState(instanceID)
 	if err != nil {
 		logFields["err"] = err
@@ -99,15 +115,18 @@ func deprovisioning(w http.ResponseWriter, req *http.Request, instanceID string,
 		return
 	}
 
-	if state.IsMigrating() {
+	// deletions requested before are invoked again, because they may be stopped by errors or broker restart
+	deleting := service.IsDeleting(state)
+
+	if state.IsMigrating() && !deleting {
 		log.WithFields(logFields).Warn(
-			"deprovisioning unprocessable: instance is migrating, please try again after",
+			"deprovisioning conflicted: instance is migrating, please try again after",
 		)
-		writeResponse(w, http.StatusBadRequest, generateStateMigratingResponse())
+		writeResponse(w, http.StatusUnprocessableEntity, generateConcurrencyErrorResponse())
 		return
 	}
 
-	if !(state.IsUp() || state.IsFailed()) {
+	if !(state.IsUp() || state.IsFailed() || deleting) {
 		log.WithFields(logFields).Info(
 			"deprovisioning succeeded: Instance already started deprovisioning",
 		)
@@ -116,6 +135,14 @@ func deprovisioning(w http.ResponseWriter, req *http.Request, instanceID string,
 	}
 
 	err = handler.DeleteInstance(instanceID)
+	if e, ok := err.(*osb.InstanceInUseError); ok {
+		logFields["err"] = err
+		log.WithFields(logFields).Info(
+			"deprovisioning refused: instance is used by other resources",
+		)
+		writeResponse(w, http.StatusUnprocessableEntity, generateInstanceInUseResponse(e.Description))
+		return
+	}
 	if err != nil {
 		logFields["err"] = err
 		log.WithFields(logFields).Error(
@@ -124,6 +151,9 @@ func deprovisioning(w http.ResponseWriter, req *http.Request, instanceID string,
 		writeResponse(w, http.StatusInternalServerError, generateEmptyResponse())
 		return
 	}
+	// keep the instance locked until the deletion is completed
+	operationLocks.detach(instanceID)
+
 	log.WithFields(logFields).Info(
 		"deprovisioning accepted: Instance deletion accepted",
 	)
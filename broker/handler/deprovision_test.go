@@ -2,12 +2,15 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
 	"github.com/sacloud/open-service-broker-sacloud/service"
 	"github.com/stretchr/testify/assert"
 )
@@ -108,8 +111,25 @@ func TestDeprovisioning(t *testing.T) {
 		}
 		deprovisioning(w, req, instanceID, dummyHandler)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Equal(t, generateStateMigratingResponse(), w.Body.Bytes())
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, generateConcurrencyErrorResponse(), w.Body.Bytes())
+	})
+
+	t.Run("Another operation in progress", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		operationLocks.acquire(context.Background(), instanceID, operations.Provisioning)
+		defer operationLocks.release(instanceID)
+
+		dummyHandler = &dummyServiceHandler{
+			instanceState: &dummyInstanceState{
+				isUp: true,
+			},
+		}
+		deprovisioning(w, req, instanceID, dummyHandler)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, generateConcurrencyErrorResponse(), w.Body.Bytes())
 	})
 
 	t.Run("Still deprovisioning", func(t *testing.T) {
@@ -140,8 +160,25 @@ func TestDeprovisioning(t *testing.T) {
 		assert.Equal(t, generateEmptyResponse(), w.Body.Bytes())
 	})
 
+	t.Run("Instance is in use", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		dummyHandler = &dummyServiceHandler{
+			instanceState: &dummyInstanceState{
+				isUp: true,
+			},
+			deleteInstanceErr: &osb.InstanceInUseError{Description: "dummy"},
+		}
+
+		deprovisioning(w, req, instanceID, dummyHandler)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{"error": "InstanceInUse", "description": "dummy"}`, w.Body.String())
+	})
+
 	t.Run("Accepted", func(t *testing.T) {
 		w := httptest.NewRecorder()
+		defer operationLocks.releaseBackground(instanceID, operations.Deprovisioning)
 
 		dummyHandler = &dummyServiceHandler{
 			instanceState: &dummyInstanceState{
@@ -153,5 +190,31 @@ func TestDeprovisioning(t *testing.T) {
 
 		assert.Equal(t, http.StatusAccepted, w.Code)
 		assert.Equal(t, generateDeprovisionAcceptedResponse(), w.Body.Bytes())
+
+		t.Run("Provisioning while deprovisioning", func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			provisioning(w, req, instanceID, dummyHandler)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			assert.Equal(t, generateConcurrencyErrorResponse(), w.Body.Bytes())
+		})
+
+		t.Run("Deprovisioning is requested again", func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			// the deletion may be stopped, so it is invoked again
+			dummyHandler = &dummyServiceHandler{
+				instanceState: &dummyInstanceState{
+					isMigrating: true,
+					isDeleting:  true,
+				},
+			}
+			deprovisioning(w, req, instanceID, dummyHandler)
+
+			assert.Equal(t, http.StatusAccepted, w.Code)
+			assert.Equal(t, generateDeprovisionAcceptedResponse(), w.Body.Bytes())
+			assert.True(t, dummyHandler.deleteInstanceCalled)
+		})
 	})
 }
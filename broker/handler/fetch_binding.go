@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/sacloud/open-service-broker-sacloud/service"
+)
+
+// fetchBinding is replaceable for testing
+var fetchBinding = service.FetchBinding
+
+func fetchBindingHandler(w http.ResponseWriter, req *http.Request) (handled bool) {
+
+	instanceID := mux.Vars(req)[reqInstanceID]
+	bindingID := mux.Vars(req)[reqBindingID]
+
+	logFields := log.Fields{
+		"instanceID": instanceID,
+		"bindingID":  bindingID,
+	}
+	log.WithFields(logFields).Debug("received fetching binding request")
+
+	binding, err := fetchBinding(instanceID, bindingID)
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			"fetching binding failed: service returned error",
+		)
+		writeResponse(w, http.StatusInternalServerError, generateEmptyResponse())
+		return
+	}
+
+	if binding == nil {
+		log.WithFields(logFields).Info(
+			"fetching binding failed: binding not found",
+		)
+		writeResponse(w, http.StatusNotFound, generateEmptyResponse())
+		return
+	}
+
+	body, err := json.Marshal(binding)
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			"fetching binding failed: error marshaling response",
+		)
+		writeResponse(w, http.StatusInternalServerError, generateEmptyResponse())
+		return
+	}
+
+	writeResponse(w, http.StatusOK, body)
+	handled = true
+	return
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchBindingHandler(t *testing.T) {
+	defer func(f func(string, string) (*osb.ServiceBindingResource, error)) { fetchBinding = f }(fetchBinding)
+
+	target := fmt.Sprintf("/v2/service_instances/%s/service_bindings/%s", testInstanceID, testInstanceID)
+
+	t.Run("Service returns error", func(t *testing.T) {
+		fetchBinding = func(string, string) (*osb.ServiceBindingResource, error) {
+			return nil, errors.New("dummy")
+		}
+		w := httptest.NewRecorder()
+		fetchBindingHandler(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Binding not exists", func(t *testing.T) {
+		fetchBinding = func(string, string) (*osb.ServiceBindingResource, error) {
+			return nil, nil
+		}
+		w := httptest.NewRecorder()
+		fetchBindingHandler(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Binding exists", func(t *testing.T) {
+		fetchBinding = func(string, string) (*osb.ServiceBindingResource, error) {
+			return &osb.ServiceBindingResource{
+				Credentials: testArbitraryMap,
+				Parameters:  testArbitraryMap,
+			}, nil
+		}
+		w := httptest.NewRecorder()
+		fetchBindingHandler(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"credentials":{"foo":"bar"},"parameters":{"foo":"bar"}}`, w.Body.String())
+	})
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/sacloud/open-service-broker-sacloud/service"
+)
+
+// fetchInstance is replaceable for testing
+var fetchInstance = service.FetchInstance
+
+func fetchInstanceHandler(w http.ResponseWriter, req *http.Request) (handled bool) {
+
+	instanceID := mux.Vars(req)[reqInstanceID]
+	// service_id is optional in fetching instances
+	serviceID := req.URL.Query().Get("service_id")
+
+	logFields := log.Fields{
+		"instanceID": instanceID,
+		"serviceID":  serviceID,
+	}
+	log.WithFields(logFields).Debug("received fetching instance request")
+
+	instance, err := fetchInstance(instanceID, serviceID)
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			"fetching instance failed: service returned error",
+		)
+		writeResponse(w, http.StatusInternalServerError, generateEmptyResponse())
+		return
+	}
+
+	if instance == nil {
+		log.WithFields(logFields).Info(
+			"fetching instance failed: instance not found",
+		)
+		writeResponse(w, http.StatusNotFound, generateEmptyResponse())
+		return
+	}
+
+	body, err := json.Marshal(instance)
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			"fetching instance failed: error marshaling response",
+		)
+		writeResponse(w, http.StatusInternalServerError, generateEmptyResponse())
+		return
+	}
+
+	writeResponse(w, http.StatusOK, body)
+	handled = true
+	return
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchInstanceHandler(t *testing.T) {
+	defer func(f func(string, string) (*osb.ServiceInstanceResource, error)) { fetchInstance = f }(fetchInstance)
+
+	target := fmt.Sprintf("/v2/service_instances/%s", testInstanceID)
+
+	t.Run("Service returns error", func(t *testing.T) {
+		fetchInstance = func(string, string) (*osb.ServiceInstanceResource, error) {
+			return nil, errors.New("dummy")
+		}
+		w := httptest.NewRecorder()
+		fetchInstanceHandler(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Instance not exists", func(t *testing.T) {
+		fetchInstance = func(string, string) (*osb.ServiceInstanceResource, error) {
+			return nil, nil
+		}
+		w := httptest.NewRecorder()
+		fetchInstanceHandler(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Instance exists", func(t *testing.T) {
+		fetchInstance = func(string, string) (*osb.ServiceInstanceResource, error) {
+			return &osb.ServiceInstanceResource{
+				ServiceID:  "service",
+				PlanID:     "plan",
+				Parameters: testArbitraryMap,
+			}, nil
+		}
+		w := httptest.NewRecorder()
+		fetchInstanceHandler(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"service_id":"service","plan_id":"plan","parameters":{"foo":"bar"}}`, w.Body.String())
+	})
+
+	t.Run("Service ID is given", func(t *testing.T) {
+		var serviceID string
+		fetchInstance = func(_, id string) (*osb.ServiceInstanceResource, error) {
+			serviceID = id
+			return nil, nil
+		}
+		w := httptest.NewRecorder()
+		fetchInstanceHandler(w, httptest.NewRequest(http.MethodGet, target+"?service_id=service&plan_id=plan", nil))
+
+		assert.Equal(t, "service", serviceID)
+	})
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/open-service-broker-sacloud/broker/auth"
+)
+
+func newFilterAuth(authenticator *auth.Authenticator) handlerFunc {
+
+	if authenticator == nil || !authenticator.Enabled() {
+
+		log.Warn(
+			`[Init] no credentials are configured, disabled auth filter`)
+
+		return func(w http.ResponseWriter, req *http.Request) bool {
+			// noop
+			return false
+		}
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) bool {
+		logFields := log.Fields{
+			"method": req.Method,
+			"path":   req.URL.Path,
+		}
+
+		principal, err := authenticator.Authenticate(req)
+		if err != nil {
+			logFields["remoteAddr"] = req.RemoteAddr
+			logFields["err"] = err
+			log.WithFields(logFields).Warn("authentication failed")
+
+			http.Error(w, "{}", http.StatusUnauthorized)
+			return true
+		}
+
+		logFields["principal"] = principal.Name
+		logFields["authMethod"] = principal.Method
+		log.WithFields(logFields).Info("request authenticated")
+
+		return false
+	}
+}
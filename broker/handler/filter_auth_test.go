@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/sacloud/open-service-broker-sacloud/broker/auth"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -104,6 +105,20 @@ func TestBasicAuthFilterWithUsernamePasswordValid(t *testing.T) {
 	assert.True(t, handlerCalled)
 }
 
+func TestAuthFilterDisabled(t *testing.T) {
+	a := newFilterAuth(auth.NewAuthenticator(auth.NewBasicVerifier("", "")))
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+	handlerCalled := false
+	handlerChain(a, func(http.ResponseWriter, *http.Request) bool {
+		handlerCalled = true
+		return true
+	})(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, handlerCalled)
+}
+
 func getTestBasicAuthFilter() handlerFunc {
-	return newFilterBasicAuth(testUsername, testPassword)
+	return newFilterAuth(auth.NewAuthenticator(auth.NewBasicVerifier(testUsername, testPassword)))
 }
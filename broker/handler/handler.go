@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/sacloud/open-service-broker-sacloud/broker/auth"
 )
 
 type handlerDefine struct {
@@ -28,7 +29,12 @@ var handlers = []handlerDefine{
 	{
 		path:     "/v2/service_instances/{instance_id}",
 		method:   http.MethodPatch,
-		handlers: []handlerFunc{filterAPIVersion}, // TODO not implements
+		handlers: []handlerFunc{filterAPIVersion, updateHandler},
+	},
+	{
+		path:     "/v2/service_instances/{instance_id}",
+		method:   http.MethodGet,
+		handlers: []handlerFunc{filterAPIVersion, fetchInstanceHandler},
 	},
 	{
 		path:     "/v2/service_instances/{instance_id}/last_operation",
@@ -40,6 +46,11 @@ var handlers = []handlerDefine{
 		method:   http.MethodPut,
 		handlers: []handlerFunc{filterAPIVersion, bindingHandler},
 	},
+	{
+		path:     "/v2/service_instances/{instance_id}/service_bindings/{binding_id}",
+		method:   http.MethodGet,
+		handlers: []handlerFunc{filterAPIVersion, fetchBindingHandler},
+	},
 	{
 		path:     "/v2/service_instances/{instance_id}/service_bindings/{binding_id}",
 		method:   http.MethodDelete,
@@ -52,12 +63,13 @@ var handlers = []handlerDefine{
 	},
 }
 
-// Router returns Handler for handling broker-api-server
-func Router(username, password string) http.Handler {
+// Router returns Handler for handling broker-api-server.
+// Metrics are exposed without authentication only if publicMetrics is true
+func Router(authenticator *auth.Authenticator, publicMetrics bool) http.Handler {
 	router := mux.NewRouter()
 	router.StrictSlash(true)
 
-	authFilter := newFilterBasicAuth(username, password)
+	authFilter := newFilterAuth(authenticator)
 
 	for _, def := range handlers {
 		h := append([]handlerFunc{authFilter}, def.handlers...)
@@ -70,6 +82,13 @@ func Router(username, password string) http.Handler {
 	// add health check(without filters)
 	router.HandleFunc("/healthz", handlerChain(healthHandler)).Methods(http.MethodGet)
 
+	// metrics contain instance IDs, so they require authentication unless they are public
+	metricsHandlers := []handlerFunc{metricsHandler}
+	if !publicMetrics {
+		metricsHandlers = append([]handlerFunc{authFilter}, metricsHandlers...)
+	}
+	router.HandleFunc("/metrics", handlerChain(metricsHandlers...)).Methods(http.MethodGet)
+
 	return router
 }
 
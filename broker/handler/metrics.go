@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/sacloud/open-service-broker-sacloud/util/metrics"
+)
+
+func metricsHandler(w http.ResponseWriter, req *http.Request) bool {
+	metrics.Handler()(w, req)
+	return true
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sacloud/open-service-broker-sacloud/broker/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsRoute(t *testing.T) {
+	authenticator := auth.NewAuthenticator(auth.NewBasicVerifier(testUsername, testPassword))
+
+	t.Run("Authentication is required", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		w := httptest.NewRecorder()
+
+		Router(authenticator, false).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		req.SetBasicAuth(testUsername, testPassword)
+		w = httptest.NewRecorder()
+
+		Router(authenticator, false).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Public metrics", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		w := httptest.NewRecorder()
+
+		Router(authenticator, true).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
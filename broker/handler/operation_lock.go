@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+)
+
+// backgroundOperationTimeout is the period after which an operation that is
+// still running in background(e.g. deprovisioning) is considered abandoned
+const backgroundOperationTimeout = 30 * time.Minute
+
+// queuedOperationTimeout is the maximum time that a queueable operation waits for the running one.
+// Operations waiting longer are rejected with ConcurrencyError not to hold requests forever
+var queuedOperationTimeout = 5 * time.Minute
+
+// queueableOperations are the operations that may wait for each other
+// instead of being rejected with ConcurrencyError.
+// Other combinations are rejected immediately.
+var queueableOperations = map[string]bool{
+	operations.Binding:   true,
+	operations.Unbinding: true,
+}
+
+var operationLocks = newOperationLock()
+
+type runningOperation struct {
+	operation  string
+	background bool
+	startedAt  time.Time
+}
+
+// operationLock serializes mutating operations per instance
+type operationLock struct {
+	mu      sync.Mutex
+	running map[string]*runningOperation
+	// released is closed and replaced when an operation is released, to wake up waiting operations
+	released chan struct{}
+}
+
+func newOperationLock() *operationLock {
+	return &operationLock{
+		running:  make(map[string]*runningOperation),
+		released: make(chan struct{}),
+	}
+}
+
+// acquire tries to start the operation for the instance.
+// If another operation is running and both operations are queueable, acquire blocks until it is released,
+// ctx is done or queuedOperationTimeout is passed.
+// The same operation running in background is taken over so that it can be requested again.
+// Otherwise acquire returns false with the name of the running operation.
+func (l *operationLock) acquire(ctx context.Context, instanceID, operation string) (bool, string) {
+	timer := time.NewTimer(queuedOperationTimeout)
+	defer timer.Stop()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for {
+		current, ok := l.running[instanceID]
+		if ok && current.background &&
+			(current.operation == operation || time.Since(current.startedAt) > backgroundOperationTimeout) {
+			delete(l.running, instanceID)
+			ok = false
+		}
+		if !ok {
+			l.running[instanceID] = &runningOperation{
+				operation: operation,
+				startedAt: time.Now(),
+			}
+			return true, ""
+		}
+		if !(queueableOperations[current.operation] && queueableOperations[operation]) {
+			return false, current.operation
+		}
+
+		released := l.released
+		l.mu.Unlock()
+		expired := false
+		select {
+		case <-released:
+		case <-ctx.Done():
+			expired = true
+		case <-timer.C:
+			expired = true
+		}
+		l.mu.Lock()
+		if expired {
+			return false, current.operation
+		}
+	}
+}
+
+// broadcast wakes up waiting operations. l.mu must be held
+func (l *operationLock) broadcast() {
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// release finishes the running operation for the instance.
+// Operations detached to background are kept until releaseBackground is called.
+func (l *operationLock) release(instanceID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if current, ok := l.running[instanceID]; ok && !current.background {
+		delete(l.running, instanceID)
+		l.broadcast()
+	}
+}
+
+// detach keeps the running operation locked after the request has been finished.
+// The lock is released by releaseBackground when the operation is completed.
+func (l *operationLock) detach(instanceID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if current, ok := l.running[instanceID]; ok {
+		current.background = true
+		current.startedAt = time.Now()
+	}
+}
+
+// releaseBackground releases the lock held by detach if the operation matches
+func (l *operationLock) releaseBackground(instanceID, operation string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current, ok := l.running[instanceID]
+	if ok && current.background && current.operation == operation {
+		delete(l.running, instanceID)
+		l.broadcast()
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationLock(t *testing.T) {
+
+	t.Run("Conflicted operations are rejected", func(t *testing.T) {
+		l := newOperationLock()
+
+		ok, _ := l.acquire(context.Background(), testInstanceID, operations.Provisioning)
+		assert.True(t, ok)
+
+		ok, current := l.acquire(context.Background(), testInstanceID, operations.Binding)
+		assert.False(t, ok)
+		assert.Equal(t, operations.Provisioning, current)
+
+		l.release(testInstanceID)
+
+		ok, _ = l.acquire(context.Background(), testInstanceID, operations.Binding)
+		assert.True(t, ok)
+	})
+
+	t.Run("Queueable operations wait for release", func(t *testing.T) {
+		l := newOperationLock()
+
+		ok, _ := l.acquire(context.Background(), testInstanceID, operations.Binding)
+		assert.True(t, ok)
+
+		acquired := make(chan bool)
+		go func() {
+			ok, _ := l.acquire(context.Background(), testInstanceID, operations.Unbinding)
+			acquired <- ok
+		}()
+
+		select {
+		case <-acquired:
+			t.Fatal("unbinding should wait until binding is released")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		l.release(testInstanceID)
+		assert.True(t, <-acquired)
+	})
+
+	t.Run("Queued operation gives up when the request is canceled", func(t *testing.T) {
+		l := newOperationLock()
+
+		ok, _ := l.acquire(context.Background(), testInstanceID, operations.Binding)
+		assert.True(t, ok)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		acquired := make(chan bool)
+		go func() {
+			ok, _ := l.acquire(ctx, testInstanceID, operations.Unbinding)
+			acquired <- ok
+		}()
+		cancel()
+		assert.False(t, <-acquired)
+
+		// the running operation is kept
+		assert.Equal(t, operations.Binding, l.running[testInstanceID].operation)
+	})
+
+	t.Run("Queued operation gives up after the timeout", func(t *testing.T) {
+		defer func(timeout time.Duration) { queuedOperationTimeout = timeout }(queuedOperationTimeout)
+		queuedOperationTimeout = 100 * time.Millisecond
+
+		l := newOperationLock()
+		ok, _ := l.acquire(context.Background(), testInstanceID, operations.Binding)
+		assert.True(t, ok)
+
+		ok, current := l.acquire(context.Background(), testInstanceID, operations.Unbinding)
+		assert.False(t, ok)
+		assert.Equal(t, operations.Binding, current)
+	})
+
+	t.Run("Detached operation is kept until releaseBackground", func(t *testing.T) {
+		l := newOperationLock()
+
+		ok, _ := l.acquire(context.Background(), testInstanceID, operations.Deprovisioning)
+		assert.True(t, ok)
+		l.detach(testInstanceID)
+		l.release(testInstanceID)
+
+		ok, current := l.acquire(context.Background(), testInstanceID, operations.Provisioning)
+		assert.False(t, ok)
+		assert.Equal(t, operations.Deprovisioning, current)
+
+		l.releaseBackground(testInstanceID, operations.Deprovisioning)
+
+		ok, _ = l.acquire(context.Background(), testInstanceID, operations.Provisioning)
+		assert.True(t, ok)
+	})
+
+	t.Run("Background operation is taken over by the same operation", func(t *testing.T) {
+		l := newOperationLock()
+
+		ok, _ := l.acquire(context.Background(), testInstanceID, operations.Deprovisioning)
+		assert.True(t, ok)
+		l.detach(testInstanceID)
+
+		ok, _ = l.acquire(context.Background(), testInstanceID, operations.Deprovisioning)
+		assert.True(t, ok)
+		assert.False(t, l.running[testInstanceID].background)
+	})
+
+	t.Run("Expired background operation is released", func(t *testing.T) {
+		l := newOperationLock()
+
+		ok, _ := l.acquire(context.Background(), testInstanceID, operations.Deprovisioning)
+		assert.True(t, ok)
+		l.detach(testInstanceID)
+		l.running[testInstanceID].startedAt = time.Now().Add(-backgroundOperationTimeout - time.Second)
+
+		ok, _ = l.acquire(context.Background(), testInstanceID, operations.Provisioning)
+		assert.True(t, ok)
+	})
+}
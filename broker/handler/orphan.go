@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/open-service-broker-sacloud/broker/jobs"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/service"
+)
+
+const (
+	// orphanMitigationInterval is the interval of the orphan mitigation job
+	orphanMitigationInterval = time.Minute
+	// DefaultOrphanTimeout is the default period after which a provisioning
+	// that the platform stopped polling is considered abandoned
+	DefaultOrphanTimeout = time.Hour
+)
+
+var provisions = newProvisionTracker()
+
+// pendingProvisions returns handlers of instances which are tagged as provisioning on SAKURA Cloud.
+// Tracking is derived from resource tags, so provisionings are mitigated even after broker restart.
+// It is replaceable for testing
+var pendingProvisions = func() (map[string]service.Handler, error) {
+	pendings, err := service.PendingProvisions()
+	if err != nil {
+		return nil, err
+	}
+
+	handlers := make(map[string]service.Handler, len(pendings))
+	for _, p := range pendings {
+		handler := service.Factory(operations.Deprovisioning, p.ServiceID, p.PlanID, []byte{})
+		if handler == nil {
+			log.WithFields(log.Fields{
+				"instanceID": p.InstanceID,
+				"serviceID":  p.ServiceID,
+				"planID":     p.PlanID,
+			}).Warn("orphan mitigation skipped: unknown service or plan")
+			continue
+		}
+		handlers[p.InstanceID] = handler
+	}
+	return handlers, nil
+}
+
+type provisionRecord struct {
+	createFailed bool
+	lastPolledAt time.Time
+}
+
+// provisionTracker holds polling statuses of provisionings which are not reported as succeeded to the platform yet.
+// Records are lost on broker restart, then they start again when the orphan mitigation job finds the provisioning
+type provisionTracker struct {
+	mu      sync.Mutex
+	records map[string]*provisionRecord
+}
+
+func newProvisionTracker() *provisionTracker {
+	return &provisionTracker{
+		records: make(map[string]*provisionRecord),
+	}
+}
+
+// track starts tracking the provisioning
+func (t *provisionTracker) track(instanceID string, createFailed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.records[instanceID] = &provisionRecord{
+		createFailed: createFailed,
+		lastPolledAt: time.Now(),
+	}
+}
+
+// touch records that the platform polled the provisioning
+func (t *provisionTracker) touch(instanceID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if record, ok := t.records[instanceID]; ok {
+		record.lastPolledAt = time.Now()
+	}
+}
+
+// forget stops tracking the provisioning
+func (t *provisionTracker) forget(instanceID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.records, instanceID)
+}
+
+// observe returns the record of the provisioning. Provisionings which are not tracked yet are tracked from now
+func (t *provisionTracker) observe(instanceID string) provisionRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	record, ok := t.records[instanceID]
+	if !ok {
+		record = &provisionRecord{lastPolledAt: time.Now()}
+		t.records[instanceID] = record
+	}
+	return *record
+}
+
+// retain forgets provisionings which are not pending anymore
+func (t *provisionTracker) retain(pendings map[string]service.Handler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for instanceID := range t.records {
+		if _, ok := pendings[instanceID]; !ok {
+			delete(t.records, instanceID)
+		}
+	}
+}
+
+func (t *provisionTracker) snapshot() map[string]provisionRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make(map[string]provisionRecord, len(t.records))
+	for id, record := range t.records {
+		res[id] = *record
+	}
+	return res
+}
+
+// confirmProvision removes the provisioning marker of the instance, so the instance isn't mitigated as orphaned
+func confirmProvision(instanceID string, handler service.Handler) error {
+	if confirmer, ok := handler.(service.ProvisionConfirmer); ok {
+		if err := confirmer.ConfirmProvision(instanceID); err != nil {
+			return err
+		}
+	}
+	provisions.forget(instanceID)
+	return nil
+}
+
+// OrphanMitigationJob returns the background job which deletes orphaned instances.
+// An instance is orphaned when its creation returned error, its appliance is failed,
+// or the platform stopped polling its provisioning for longer than timeout.
+func OrphanMitigationJob(timeout time.Duration) *jobs.Job {
+	return &jobs.Job{
+		Name:     "orphan-mitigation",
+		Interval: orphanMitigationInterval,
+		Func: func(ctx context.Context) error {
+			mitigateOrphans(timeout)
+			return nil
+		},
+	}
+}
+
+func mitigateOrphans(timeout time.Duration) {
+	handlers, err := pendingProvisions()
+	if err != nil {
+		log.WithField("err", err).Error(
+			"orphan mitigation failed: listing provisionings returned error",
+		)
+		return
+	}
+	provisions.retain(handlers)
+
+	for instanceID, handler := range handlers {
+		mitigateOrphan(instanceID, handler, provisions.observe(instanceID), timeout)
+	}
+}
+
+func mitigateOrphan(instanceID string, handler service.Handler, record provisionRecord, timeout time.Duration) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+
+	if ok, current := operationLocks.acquire(context.Background(), instanceID, operations.Deprovisioning); !ok {
+		logFields["runningOperation"] = current
+		log.WithFields(logFields).Debug(
+			"orphan mitigation skipped: another operation is in progress",
+		)
+		return
+	}
+	defer operationLocks.release(instanceID)
+
+	state, err := handler.InstanceState(instanceID)
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			"orphan mitigation failed: service handler returned error",
+		)
+		return
+	}
+
+	if state == nil {
+		log.WithFields(logFields).Info(
+			"orphan mitigation: instance is gone, stop tracking",
+		)
+		provisions.forget(instanceID)
+		return
+	}
+
+	var reason string
+	switch {
+	case state.IsMigrating():
+		log.WithFields(logFields).Debug(
+			"orphan mitigation: instance is migrating, check again later",
+		)
+		return
+	case record.createFailed:
+		reason = "instance creation returned error"
+	case state.IsFailed():
+		reason = "instance state is failed"
+	case timeout > 0 && time.Since(record.lastPolledAt) > timeout:
+		reason = "platform stopped polling"
+	default:
+		return
+	}
+
+	logFields["reason"] = reason
+	if !(state.IsUp() || state.IsFailed()) {
+		log.WithFields(logFields).Info(
+			"orphan mitigation: instance is not ready for deletion, check again later",
+		)
+		return
+	}
+
+	if err := handler.DeleteInstance(instanceID); err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			"orphan mitigation failed: service handler returned error",
+		)
+		return
+	}
+
+	log.WithFields(logFields).Warn(
+		"orphan mitigation: orphaned instance deletion accepted",
+	)
+	provisions.forget(instanceID)
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func pendingHandler(h service.Handler) func() (map[string]service.Handler, error) {
+	return func() (map[string]service.Handler, error) {
+		return map[string]service.Handler{testInstanceID: h}, nil
+	}
+}
+
+func TestMitigateOrphans(t *testing.T) {
+	defer func(f func() (map[string]service.Handler, error)) { pendingProvisions = f }(pendingProvisions)
+
+	instanceID := testInstanceID
+	timeout := time.Hour
+
+	t.Run("Creation failed", func(t *testing.T) {
+		defer provisions.forget(instanceID)
+		h := &dummyServiceHandler{
+			instanceState: &dummyInstanceState{isFailed: true},
+		}
+		provisions.track(instanceID, true)
+		pendingProvisions = pendingHandler(h)
+
+		mitigateOrphans(timeout)
+
+		assert.True(t, h.deleteInstanceCalled)
+		assert.Empty(t, provisions.snapshot())
+	})
+
+	t.Run("Instance is gone", func(t *testing.T) {
+		defer provisions.forget(instanceID)
+		h := &dummyServiceHandler{}
+		provisions.track(instanceID, true)
+		pendingProvisions = pendingHandler(h)
+
+		mitigateOrphans(timeout)
+
+		assert.False(t, h.deleteInstanceCalled)
+		assert.Empty(t, provisions.snapshot())
+	})
+
+	t.Run("Instance is failed", func(t *testing.T) {
+		defer provisions.forget(instanceID)
+		h := &dummyServiceHandler{
+			instanceState: &dummyInstanceState{isFailed: true},
+		}
+		provisions.track(instanceID, false)
+		pendingProvisions = pendingHandler(h)
+
+		mitigateOrphans(timeout)
+
+		assert.True(t, h.deleteInstanceCalled)
+		assert.Empty(t, provisions.snapshot())
+	})
+
+	t.Run("Instance is migrating", func(t *testing.T) {
+		defer provisions.forget(instanceID)
+		h := &dummyServiceHandler{
+			instanceState: &dummyInstanceState{isMigrating: true},
+		}
+		provisions.track(instanceID, true)
+		pendingProvisions = pendingHandler(h)
+
+		mitigateOrphans(timeout)
+
+		assert.False(t, h.deleteInstanceCalled)
+		assert.Len(t, provisions.snapshot(), 1)
+	})
+
+	t.Run("Platform is still polling", func(t *testing.T) {
+		defer provisions.forget(instanceID)
+		h := &dummyServiceHandler{
+			instanceState: &dummyInstanceState{isUp: true},
+		}
+		provisions.track(instanceID, false)
+		pendingProvisions = pendingHandler(h)
+
+		mitigateOrphans(timeout)
+
+		assert.False(t, h.deleteInstanceCalled)
+		assert.Len(t, provisions.snapshot(), 1)
+	})
+
+	t.Run("Platform stopped polling", func(t *testing.T) {
+		defer provisions.forget(instanceID)
+		h := &dummyServiceHandler{
+			instanceState: &dummyInstanceState{isUp: true},
+		}
+		provisions.track(instanceID, false)
+		pendingProvisions = pendingHandler(h)
+		provisions.records[instanceID].lastPolledAt = time.Now().Add(-timeout - time.Second)
+
+		mitigateOrphans(timeout)
+
+		assert.True(t, h.deleteInstanceCalled)
+		assert.Empty(t, provisions.snapshot())
+	})
+
+	t.Run("Delete instance is failed", func(t *testing.T) {
+		defer provisions.forget(instanceID)
+		h := &dummyServiceHandler{
+			instanceState:     &dummyInstanceState{isFailed: true},
+			deleteInstanceErr: errors.New("dummy"),
+		}
+		provisions.track(instanceID, false)
+		pendingProvisions = pendingHandler(h)
+
+		mitigateOrphans(timeout)
+
+		assert.True(t, h.deleteInstanceCalled)
+		assert.Len(t, provisions.snapshot(), 1)
+	})
+
+	t.Run("Another operation in progress", func(t *testing.T) {
+		defer provisions.forget(instanceID)
+		operationLocks.acquire(context.Background(), instanceID, operations.Binding)
+		defer operationLocks.release(instanceID)
+
+		h := &dummyServiceHandler{
+			instanceState: &dummyInstanceState{isFailed: true},
+		}
+		provisions.track(instanceID, false)
+		pendingProvisions = pendingHandler(h)
+
+		mitigateOrphans(timeout)
+
+		assert.False(t, h.deleteInstanceCalled)
+		assert.Len(t, provisions.snapshot(), 1)
+	})
+
+	t.Run("Provisioning found after restart", func(t *testing.T) {
+		defer provisions.forget(instanceID)
+		h := &dummyServiceHandler{
+			instanceState: &dummyInstanceState{isUp: true},
+		}
+		pendingProvisions = pendingHandler(h)
+
+		mitigateOrphans(timeout)
+
+		// polling timeout starts when the provisioning is found
+		assert.False(t, h.deleteInstanceCalled)
+		assert.Len(t, provisions.snapshot(), 1)
+	})
+
+	t.Run("Provisioning is not pending anymore", func(t *testing.T) {
+		defer provisions.forget(instanceID)
+		provisions.track(instanceID, false)
+		pendingProvisions = func() (map[string]service.Handler, error) {
+			return map[string]service.Handler{}, nil
+		}
+
+		mitigateOrphans(timeout)
+
+		assert.Empty(t, provisions.snapshot())
+	})
+
+	t.Run("Listing provisionings is failed", func(t *testing.T) {
+		defer provisions.forget(instanceID)
+		provisions.track(instanceID, false)
+		pendingProvisions = func() (map[string]service.Handler, error) {
+			return nil, errors.New("dummy")
+		}
+
+		mitigateOrphans(timeout)
+
+		assert.Len(t, provisions.snapshot(), 1)
+	})
+}
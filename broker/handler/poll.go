@@ -89,6 +89,7 @@ func pollHandler(w http.ResponseWriter, req *http.Request) (handled bool) {
 			return
 		case operations.Deprovisioning:
 			// can't find instance, so we respond 'done'
+			operationLocks.releaseBackground(instanceID, operations.Deprovisioning)
 			log.WithFields(logFields).Info(
 				"polling succeeded: instance is gone(service_id and plan_id are empty)",
 			)
@@ -119,6 +120,10 @@ func polling(w http.ResponseWriter, req *http.Request, operation string, instanc
 		"operation":  operation,
 	}
 
+	if operation == operations.Provisioning {
+		provisions.touch(instanceID)
+	}
+
 	state, err := handler.InstanceState(instanceID)
 	if err != nil {
 		logFields["err"] = err
@@ -131,6 +136,7 @@ func polling(w http.ResponseWriter, req *http.Request, operation string, instanc
 
 	if state == nil {
 		if operation == operations.Deprovisioning {
+			operationLocks.releaseBackground(instanceID, operations.Deprovisioning)
 			log.WithFields(logFields).Info(
 				"polling succeeded: instance is gone",
 			)
@@ -138,6 +144,7 @@ func polling(w http.ResponseWriter, req *http.Request, operation string, instanc
 			return
 		}
 
+		provisions.forget(instanceID)
 		log.WithFields(logFields).Info(
 			"polling failed: instance not found",
 		)
@@ -146,6 +153,18 @@ func polling(w http.ResponseWriter, req *http.Request, operation string, instanc
 
 	}
 
+	if operation == operations.Deprovisioning {
+		if state.IsFailed() || !service.IsDeleting(state) {
+			// the deletion is stopped, so the platform can request it again
+			operationLocks.releaseBackground(instanceID, operations.Deprovisioning)
+			log.WithFields(logFields).Info(
+				"polling failed: instance is not being deleted",
+			)
+			writeResponse(w, http.StatusOK, generateOperationFailedResponse())
+			return
+		}
+	}
+
 	if operation == operations.Provisioning {
 		if state.IsFailed() {
 			log.WithFields(logFields).Info(
@@ -156,9 +175,21 @@ func polling(w http.ResponseWriter, req *http.Request, operation string, instanc
 		}
 
 		if state.IsUp() {
+			if err := confirmProvision(instanceID, handler); err != nil {
+				logFields["err"] = err
+				log.WithFields(logFields).Error(
+					"polling failed: confirming provisioning returned error",
+				)
+				writeResponse(w, http.StatusInternalServerError, generateEmptyResponse())
+				return
+			}
 			log.WithFields(logFields).Info(
 				"polling succeeded: instance fully provisioned",
 			)
+			if d, ok := state.(service.StatusDescriber); ok {
+				writeResponse(w, http.StatusOK, generateOperationSucceededWithDescriptionResponse(d.StatusDescription()))
+				return
+			}
 			writeResponse(w, http.StatusOK, generateOperationSucceededResponse())
 			return
 		}
@@ -2,12 +2,14 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/gorilla/mux"
 	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
 	"github.com/sacloud/open-service-broker-sacloud/service"
 	"github.com/stretchr/testify/assert"
@@ -77,6 +79,24 @@ func TestPollHandler(t *testing.T) {
 		assert.Equal(t, generateInvalidPlanIDResponse(), w.Body.Bytes())
 	})
 
+	t.Run("Deprovisioning without service_id", func(t *testing.T) {
+		operationLocks.acquire(context.Background(), instanceID, operations.Deprovisioning)
+		operationLocks.detach(instanceID)
+
+		url := fmt.Sprintf("%s?operation=%s", target, operations.Deprovisioning)
+		req := httptest.NewRequest(http.MethodGet, url, bytes.NewReader([]byte{}))
+		req = mux.SetURLVars(req, map[string]string{reqInstanceID: instanceID})
+		w := httptest.NewRecorder()
+
+		pollHandler(w, req)
+
+		assert.Equal(t, http.StatusGone, w.Code)
+
+		// the lock held by deprovisioning is released
+		ok, _ := operationLocks.acquire(context.Background(), instanceID, operations.Binding)
+		assert.True(t, ok)
+		operationLocks.release(instanceID)
+	})
 }
 
 func TestPolling(t *testing.T) {
@@ -121,14 +141,45 @@ func TestPolling(t *testing.T) {
 		t.Run("succeeded", func(t *testing.T) {
 			w := httptest.NewRecorder()
 
-			dummyHandler = &dummyServiceHandler{
+			h := &dummyServiceHandler{
 				instanceState: &dummyInstanceState{isUp: true},
 			}
 
-			polling(w, req, operations.Provisioning, instanceID, dummyHandler)
+			polling(w, req, operations.Provisioning, instanceID, h)
 
 			assert.Equal(t, http.StatusOK, w.Code)
 			assert.Equal(t, generateOperationSucceededResponse(), w.Body.Bytes())
+			assert.True(t, h.confirmProvisionCalled)
+		})
+
+		t.Run("confirming is failed", func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			dummyHandler = &dummyServiceHandler{
+				instanceState:       &dummyInstanceState{isUp: true},
+				confirmProvisionErr: errors.New("dummy"),
+			}
+
+			polling(w, req, operations.Provisioning, instanceID, dummyHandler)
+
+			// the platform polls again, then confirming is retried
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+		})
+
+		t.Run("succeeded with description", func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			dummyHandler = &dummyServiceHandler{
+				instanceState: &dummyDescribedInstanceState{
+					dummyInstanceState: dummyInstanceState{isUp: true},
+					description:        "health: up",
+				},
+			}
+
+			polling(w, req, operations.Provisioning, instanceID, dummyHandler)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"state": "succeeded", "description": "health: up"}`, w.Body.String())
 		})
 
 		t.Run("failed", func(t *testing.T) {
@@ -178,7 +229,7 @@ func TestPolling(t *testing.T) {
 			w := httptest.NewRecorder()
 
 			dummyHandler = &dummyServiceHandler{
-				instanceState: &dummyInstanceState{isUp: true},
+				instanceState: &dummyInstanceState{isUp: true, isDeleting: true},
 			}
 
 			polling(w, req, operations.Deprovisioning, instanceID, dummyHandler)
@@ -190,21 +241,49 @@ func TestPolling(t *testing.T) {
 		t.Run("failed", func(t *testing.T) {
 			w := httptest.NewRecorder()
 
+			operationLocks.acquire(context.Background(), instanceID, operations.Deprovisioning)
+			operationLocks.detach(instanceID)
+
 			dummyHandler = &dummyServiceHandler{
-				instanceState: &dummyInstanceState{isFailed: true},
+				instanceState: &dummyInstanceState{isFailed: true, isDeleting: true},
 			}
 
 			polling(w, req, operations.Deprovisioning, instanceID, dummyHandler)
 
 			assert.Equal(t, http.StatusOK, w.Code)
-			assert.Equal(t, generateOperationInProgressResponse(), w.Body.Bytes())
+			assert.Equal(t, generateOperationFailedResponse(), w.Body.Bytes())
+
+			// the lock is released
+			ok, _ := operationLocks.acquire(context.Background(), instanceID, operations.Binding)
+			assert.True(t, ok)
+			operationLocks.release(instanceID)
+		})
+
+		t.Run("not deleting", func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			operationLocks.acquire(context.Background(), instanceID, operations.Deprovisioning)
+			operationLocks.detach(instanceID)
+
+			dummyHandler = &dummyServiceHandler{
+				instanceState: &dummyInstanceState{isUp: true},
+			}
+
+			polling(w, req, operations.Deprovisioning, instanceID, dummyHandler)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, generateOperationFailedResponse(), w.Body.Bytes())
+
+			ok, _ := operationLocks.acquire(context.Background(), instanceID, operations.Binding)
+			assert.True(t, ok)
+			operationLocks.release(instanceID)
 		})
 
 		t.Run("in progress", func(t *testing.T) {
 			w := httptest.NewRecorder()
 
 			dummyHandler = &dummyServiceHandler{
-				instanceState: &dummyInstanceState{},
+				instanceState: &dummyInstanceState{isDeleting: true},
 			}
 
 			polling(w, req, operations.Deprovisioning, instanceID, dummyHandler)
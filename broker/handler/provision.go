@@ -121,6 +121,16 @@ func provisioning(w http.ResponseWriter, req *http.Request, instanceID string, h
 		return
 	}
 
+	if ok, current := operationLocks.acquire(req.Context(), instanceID, operations.Provisioning); !ok {
+		logFields["runningOperation"] = current
+		log.WithFields(logFields).Warn(
+			"provisioning conflicted: another operation is in progress",
+		)
+		writeResponse(w, http.StatusUnprocessableEntity, generateConcurrencyErrorResponse())
+		return
+	}
+	defer operationLocks.release(instanceID)
+
 	state, err := handler.InstanceState(instanceID)
 	if err != nil {
 		logFields["err"] = err
@@ -139,9 +149,12 @@ func provisioning(w http.ResponseWriter, req *http.Request, instanceID string, h
 			log.WithFields(logFields).Error(
 				"provisioning error: error creating SakuraCloud resource",
 			)
+			// the resource may be created partially, so it should be cleaned up by orphan mitigation
+			provisions.track(instanceID, true)
 			writeResponse(w, http.StatusInternalServerError, generateEmptyResponse())
 			return
 		}
+		provisions.track(instanceID, false)
 
 		// Now, creation succeeded
 		log.WithFields(logFields).Info(
@@ -166,6 +179,14 @@ func provisioning(w http.ResponseWriter, req *http.Request, instanceID string, h
 		)
 		writeResponse(w, http.StatusInternalServerError, generateEmptyResponse())
 	case state.IsUp(): // fully provisioned
+		if err := confirmProvision(instanceID, handler); err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Error(
+				"provisioning failed: confirming provisioning returned error",
+			)
+			writeResponse(w, http.StatusInternalServerError, generateEmptyResponse())
+			return
+		}
 		log.WithFields(logFields).Info(
 			"provisioning succeeded: Instance already fully provisioned",
 		)
@@ -2,12 +2,14 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
 	"github.com/sacloud/open-service-broker-sacloud/service"
 	"github.com/stretchr/testify/assert"
 )
@@ -140,6 +142,19 @@ func TestProvisioning(t *testing.T) {
 		assert.Equal(t, generateEmptyResponse(), w.Body.Bytes())
 	})
 
+	t.Run("Another operation in progress", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		operationLocks.acquire(context.Background(), instanceID, operations.Binding)
+		defer operationLocks.release(instanceID)
+
+		dummyHandler = &dummyServiceHandler{}
+		provisioning(w, req, instanceID, dummyHandler)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, generateConcurrencyErrorResponse(), w.Body.Bytes())
+	})
+
 	t.Run("Instance not exists", func(t *testing.T) {
 		t.Run("create failed", func(t *testing.T) {
 			w := httptest.NewRecorder()
@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
 )
 
 var responseAsyncRequired = []byte(
@@ -50,13 +52,24 @@ func generateInvalidPlanIDResponse() []byte {
 	return responseInvalidPlanID
 }
 
-var responseStateMigrating = []byte(
-	`{ "error": "UnprocessableState", "description": "Instance is ` +
-		`migrating. Please try again later." }`,
+var responseConcurrencyError = []byte(
+	`{ "error": "ConcurrencyError", "description": "Another operation for ` +
+		`this Service Instance is in progress." }`,
 )
 
-func generateStateMigratingResponse() []byte {
-	return responseStateMigrating
+func generateConcurrencyErrorResponse() []byte {
+	return responseConcurrencyError
+}
+
+func generateInstanceInUseResponse(description string) []byte {
+	b, err := json.Marshal(&osb.Error{
+		Error:       "InstanceInUse",
+		Description: description,
+	})
+	if err != nil {
+		return generateEmptyResponse()
+	}
+	return b
 }
 
 var responseProvisioningAccepted = []byte(
@@ -91,6 +104,17 @@ func generateOperationSucceededResponse() []byte {
 	return responseSucceeded
 }
 
+func generateOperationSucceededWithDescriptionResponse(description string) []byte {
+	b, err := json.Marshal(&osb.ServiceInstanceLastOperation{
+		State:       operations.StateSucceeded,
+		Description: description,
+	})
+	if err != nil {
+		return responseSucceeded
+	}
+	return b
+}
+
 var responseFailed = []byte(
 	fmt.Sprintf(`{ "state": "%s" }`, operations.StateFailed),
 )
@@ -105,6 +129,15 @@ func generateEmptyResponse() []byte {
 	return responseEmptyJSON
 }
 
+var responsePlanNotUpdateable = []byte(
+	`{ "error": "PlanNotUpdateable", "description": "The plan of the service ` +
+		`instance can't be changed." }`,
+)
+
+func generatePlanNotUpdateableResponse() []byte {
+	return responsePlanNotUpdateable
+}
+
 var responseConflict = []byte(`{ "description": "A service instance exists ` +
 	`with the specified service id" }`)
 
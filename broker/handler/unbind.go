@@ -84,6 +84,16 @@ func unbinding(w http.ResponseWriter, req *http.Request, instanceID, bindingID s
 		"bindingID":  bindingID,
 	}
 
+	if ok, current := operationLocks.acquire(req.Context(), instanceID, operations.Unbinding); !ok {
+		logFields["runningOperation"] = current
+		log.WithFields(logFields).Warn(
+			"unbinding conflicted: another operation is in progress",
+		)
+		writeResponse(w, http.StatusUnprocessableEntity, generateConcurrencyErrorResponse())
+		return
+	}
+	defer operationLocks.release(instanceID)
+
 	instanceState, err := handler.InstanceState(instanceID)
 	if err != nil {
 		logFields["err"] = err
@@ -102,6 +112,14 @@ func unbinding(w http.ResponseWriter, req *http.Request, instanceID, bindingID s
 		return
 	}
 
+	if instanceState.IsMigrating() {
+		log.WithFields(logFields).Warn(
+			"unbinding conflicted: instance is migrating, please try again after",
+		)
+		writeResponse(w, http.StatusUnprocessableEntity, generateConcurrencyErrorResponse())
+		return
+	}
+
 	if !instanceState.IsUp() || instanceState.IsFailed() {
 		log.WithFields(logFields).Error(
 			"unbinding failed: instance state is invalid",
@@ -100,6 +100,21 @@ func TestUnbounding(t *testing.T) {
 		assert.Equal(t, generateInstanceNotFoundResponse(), w.Body.Bytes())
 	})
 
+	t.Run("Instance is migrating", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		dummyHandler = &dummyServiceHandler{
+			instanceState: &dummyInstanceState{
+				isMigrating: true,
+			},
+		}
+
+		unbinding(w, req, instanceID, bindingID, dummyHandler)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, generateConcurrencyErrorResponse(), w.Body.Bytes())
+	})
+
 	t.Run("Invalid instance state", func(t *testing.T) {
 		t.Run("failed state", func(t *testing.T) {
 			w := httptest.NewRecorder()
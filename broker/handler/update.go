@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service"
+)
+
+func updateHandler(w http.ResponseWriter, req *http.Request) (handled bool) {
+
+	//collect parameters
+	instanceID := mux.Vars(req)[reqInstanceID]
+
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+	log.WithFields(logFields).Debug("received updating request")
+
+	bodyBytes, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		logFields["error"] = err
+		log.WithFields(logFields).Error(
+			"pre-updating error: error reading request body",
+		)
+		writeResponse(w, http.StatusInternalServerError, generateEmptyResponse())
+		return
+	}
+	defer req.Body.Close() // nolint
+
+	updateRequest := &osb.ServiceInstanceUpdateRequest{}
+	if err := json.Unmarshal(bodyBytes, updateRequest); err != nil {
+		logFields["error"] = err
+		log.WithFields(logFields).Debug(
+			"bad updating request: error unmarshaling request body",
+		)
+		writeResponse(w, http.StatusBadRequest, generateMalformedRequestResponse())
+		return
+	}
+
+	serviceID := updateRequest.ServiceID
+	if serviceID == "" {
+		logFields["field"] = "service_id" // nolint
+		log.WithFields(logFields).Debug(
+			"bad updating request: required request body field is missing",
+		)
+		writeResponse(w, http.StatusBadRequest, generateServiceIDRequiredResponse())
+		return
+	}
+
+	// plan_id is sent only if the plan is changed
+	planID := updateRequest.PlanID
+	if planID == "" && updateRequest.PreviousValues != nil {
+		planID = updateRequest.PreviousValues.PlanID
+	}
+	if planID == "" {
+		logFields["field"] = "plan_id" // nolint
+		log.WithFields(logFields).Debug(
+			"bad updating request: required request body field is missing",
+		)
+		writeResponse(w, http.StatusBadRequest, generatePlanIDRequiredResponse())
+		return
+	}
+
+	svc, ok := service.CurrentCatalog.FindService(serviceID)
+	if !ok {
+		logFields["serviceID"] = serviceID
+		log.WithFields(logFields).Debug(
+			"bad updating request: invalid serviceID",
+		)
+		writeResponse(w, http.StatusBadRequest, generateInvalidServiceIDResponse())
+		return
+	}
+
+	_, ok = svc.FindPlan(planID)
+	if !ok {
+		logFields["serviceID"] = serviceID
+		logFields["planID"] = planID
+		log.WithFields(logFields).Debug(
+			"bad updating request: invalid planID for service",
+		)
+		writeResponse(w, http.StatusBadRequest, generateInvalidPlanIDResponse())
+		return
+	}
+
+	if prev := updateRequest.PreviousValues; !svc.PlanUpdateable && prev != nil && prev.PlanID != "" && prev.PlanID != planID {
+		logFields["planID"] = planID
+		logFields["previousPlanID"] = prev.PlanID
+		log.WithFields(logFields).Debug(
+			"bad updating request: plan is not updateable",
+		)
+		writeResponse(w, http.StatusBadRequest, generatePlanNotUpdateableResponse())
+		return
+	}
+
+	rawParameter, err := json.Marshal(updateRequest.Parameters)
+	if err != nil || updateRequest.Parameters == nil {
+		logFields["field"] = "parameters"
+		log.WithFields(logFields).Debug(
+			"bad updating request: error marshaling request body(parameters field)",
+		)
+		writeResponse(w, http.StatusBadRequest, generateMalformedRequestResponse())
+		return
+	}
+
+	handler := service.Factory(operations.Updating, serviceID, planID, rawParameter)
+	if handler == nil {
+		logFields["field"] = "provisioner"
+		log.WithFields(logFields).Warn(
+			"bad updating request: invalid provisioner",
+		)
+		writeResponse(w, http.StatusBadRequest, generateMalformedRequestResponse())
+		return
+	}
+
+	updating(w, req, instanceID, handler)
+	handled = true
+	return
+}
+
+func updating(w http.ResponseWriter, req *http.Request, instanceID string, handler service.Handler) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+
+	_, err := handler.IsValid()
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Debug(
+			`bad updating request: invalid JSON parameter`)
+		writeResponse(w, http.StatusBadRequest, generateMalformedParameterResponse(err.Error()))
+		return
+	}
+
+	if ok, current := operationLocks.acquire(req.Context(), instanceID, operations.Updating); !ok {
+		logFields["runningOperation"] = current
+		log.WithFields(logFields).Warn(
+			"updating conflicted: another operation is in progress",
+		)
+		writeResponse(w, http.StatusUnprocessableEntity, generateConcurrencyErrorResponse())
+		return
+	}
+	defer operationLocks.release(instanceID)
+
+	state, err := handler.InstanceState(instanceID)
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			"updating failed: service handler returned error",
+		)
+		writeResponse(w, http.StatusInternalServerError, generateEmptyResponse())
+		return
+	}
+
+	if state == nil {
+		log.WithFields(logFields).Info(
+			"bad updating request: instance not found",
+		)
+		writeResponse(w, http.StatusBadRequest, generateInstanceNotFoundResponse())
+		return
+	}
+
+	if !state.IsUp() {
+		log.WithFields(logFields).Info(
+			"updating conflicted: instance is not up",
+		)
+		writeResponse(w, http.StatusUnprocessableEntity, generateConcurrencyErrorResponse())
+		return
+	}
+
+	err = handler.UpdateInstance(instanceID)
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			"updating error: error updating SakuraCloud resource",
+		)
+		writeResponse(w, http.StatusInternalServerError, generateEmptyResponse())
+		return
+	}
+
+	log.WithFields(logFields).Info(
+		"updating succeeded",
+	)
+	writeResponse(w, http.StatusOK, generateEmptyResponse())
+}
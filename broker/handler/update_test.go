@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateHandler(t *testing.T) {
+
+	instanceID := testInstanceID
+	target := fmt.Sprintf("/v2/service_instances/%s", instanceID)
+
+	t.Run("Empty JSON", func(t *testing.T) {
+		body := bytes.NewReader([]byte(`{}`))
+		req := httptest.NewRequest(http.MethodPatch, target, body)
+		w := httptest.NewRecorder()
+
+		updateHandler(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, generateServiceIDRequiredResponse(), w.Body.Bytes())
+	})
+
+	t.Run("Empty plan_id", func(t *testing.T) {
+		body := bytes.NewReader([]byte(fmt.Sprintf(`{"service_id": "%s"}`, service.MariaDBServiceID)))
+		req := httptest.NewRequest(http.MethodPatch, target, body)
+		w := httptest.NewRecorder()
+
+		updateHandler(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, generatePlanIDRequiredResponse(), w.Body.Bytes())
+	})
+
+	t.Run("Changing plan", func(t *testing.T) {
+		strBody := fmt.Sprintf(`{"service_id":"%s","plan_id":"%s","previous_values":{"plan_id":"%s"}}`,
+			service.MariaDBServiceID, service.MariaDBPlan30GID, service.MariaDBPlan10GID)
+		req := httptest.NewRequest(http.MethodPatch, target, bytes.NewReader([]byte(strBody)))
+		w := httptest.NewRecorder()
+
+		updateHandler(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, generatePlanNotUpdateableResponse(), w.Body.Bytes())
+	})
+
+	t.Run("Empty parameters", func(t *testing.T) {
+		strBody := fmt.Sprintf(`{"service_id":"%s","previous_values":{"plan_id":"%s"}}`,
+			service.MariaDBServiceID, service.MariaDBPlan10GID)
+		req := httptest.NewRequest(http.MethodPatch, target, bytes.NewReader([]byte(strBody)))
+		w := httptest.NewRecorder()
+
+		updateHandler(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, generateMalformedRequestResponse(), w.Body.Bytes())
+	})
+}
+
+func TestUpdating(t *testing.T) {
+
+	instanceID := testInstanceID
+	target := fmt.Sprintf("/v2/service_instances/%s", instanceID)
+	req := httptest.NewRequest(http.MethodPatch, target, bytes.NewReader([]byte(`{}`)))
+
+	t.Run("Validation failed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		expectErr := errors.New("dummy")
+		dummyHandler = &dummyServiceHandler{
+			validateResult: expectErr,
+		}
+
+		updating(w, req, instanceID, dummyHandler)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, generateMalformedParameterResponse(expectErr.Error()), w.Body.Bytes())
+	})
+
+	t.Run("Another operation in progress", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		operationLocks.acquire(context.Background(), instanceID, operations.Binding)
+		defer operationLocks.release(instanceID)
+
+		dummyHandler = &dummyServiceHandler{}
+		updating(w, req, instanceID, dummyHandler)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, generateConcurrencyErrorResponse(), w.Body.Bytes())
+	})
+
+	t.Run("Instance not exists", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		dummyHandler = &dummyServiceHandler{}
+		updating(w, req, instanceID, dummyHandler)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, generateInstanceNotFoundResponse(), w.Body.Bytes())
+	})
+
+	t.Run("Instance is not up", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		dummyHandler = &dummyServiceHandler{
+			instanceState: &dummyInstanceState{},
+		}
+		updating(w, req, instanceID, dummyHandler)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, generateConcurrencyErrorResponse(), w.Body.Bytes())
+	})
+
+	t.Run("Update failed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		dummyHandler = &dummyServiceHandler{
+			instanceState:     &dummyInstanceState{isUp: true},
+			updateInstanceErr: errors.New("dummy"),
+		}
+		updating(w, req, instanceID, dummyHandler)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, generateEmptyResponse(), w.Body.Bytes())
+	})
+
+	t.Run("Update succeeded", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		dummyHandler = &dummyServiceHandler{
+			instanceState: &dummyInstanceState{isUp: true},
+		}
+		updating(w, req, instanceID, dummyHandler)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, generateEmptyResponse(), w.Body.Bytes())
+	})
+}
@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Job represents a task that is executed periodically in background
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Func     func(ctx context.Context) error
+}
+
+// Runner runs registered jobs until the context is canceled
+type Runner struct {
+	jobs []*Job
+}
+
+// NewRunner returns new Runner
+func NewRunner(jobs ...*Job) *Runner {
+	return &Runner{jobs: jobs}
+}
+
+// Register adds the job to the runner. It must be called before Start
+func (r *Runner) Register(job *Job) {
+	r.jobs = append(r.jobs, job)
+}
+
+// Start starts all registered jobs in background and returns immediately
+func (r *Runner) Start(ctx context.Context) {
+	for _, job := range r.jobs {
+		if job == nil || job.Func == nil || job.Interval <= 0 {
+			continue
+		}
+		go r.run(ctx, job)
+	}
+}
+
+func (r *Runner) run(ctx context.Context, job *Job) {
+	logFields := log.Fields{
+		"job": job.Name,
+	}
+	log.WithFields(logFields).Debug("background job started")
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.WithFields(logFields).Debug("context canceled; background job stopped")
+			return
+		case <-ticker.C:
+			if err := job.Func(ctx); err != nil {
+				logFields["err"] = err
+				log.WithFields(logFields).Error("background job returned error")
+				delete(logFields, "err")
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	ret := m.Run()
+	os.Exit(ret)
+}
+
+func TestRunner(t *testing.T) {
+
+	t.Run("Should run jobs periodically until canceled", func(t *testing.T) {
+		var count int32
+		r := NewRunner(&Job{
+			Name:     "count",
+			Interval: 10 * time.Millisecond,
+			Func: func(ctx context.Context) error {
+				atomic.AddInt32(&count, 1)
+				return errors.New("dummy") // errors should not stop the job
+			},
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		r.Start(ctx)
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+
+		stopped := atomic.LoadInt32(&count)
+		assert.True(t, stopped > 1)
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, stopped, atomic.LoadInt32(&count))
+	})
+
+	t.Run("Should skip invalid jobs", func(t *testing.T) {
+		r := NewRunner(nil, &Job{Name: "no-interval", Func: func(ctx context.Context) error {
+			t.Fatal("job without interval should not be executed")
+			return nil
+		}})
+		r.Register(&Job{Name: "no-func", Interval: time.Millisecond})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		r.Start(ctx)
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	})
+}
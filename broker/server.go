@@ -4,13 +4,20 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/open-service-broker-sacloud/broker/auth"
 	"github.com/sacloud/open-service-broker-sacloud/broker/handler"
+	"github.com/sacloud/open-service-broker-sacloud/broker/jobs"
+	"github.com/sacloud/open-service-broker-sacloud/service"
 )
 
-const defaultPort = 8080
+const (
+	defaultPort               = 8080
+	defaultAuthReloadInterval = 30 * time.Second
+)
 
 // Broker is interface of broker-api-server
 type Broker interface {
@@ -56,16 +63,68 @@ func (b *broker) Start(ctx context.Context) error {
 
 func (b *broker) start(ctx context.Context) error {
 
-	var username, password string
+	orphanTimeout := handler.DefaultOrphanTimeout
 	if b.config != nil {
-		username = b.config.BasicAuthUsername
-		password = b.config.BasicAuthPassword
+		orphanTimeout = b.config.OrphanTimeout
+	}
+
+	authenticator, err := b.authenticator()
+	if err != nil {
+		return err
+	}
+
+	// Start background jobs
+	runner := jobs.NewRunner(
+		handler.OrphanMitigationJob(orphanTimeout),
+	)
+	if b.config != nil && b.config.ReconcileInterval > 0 {
+		runner.Register(reconcileJob(b.config.ReconcileInterval, b.config.ReconcileRemediate))
+	}
+	if b.config != nil && b.config.UsageInterval > 0 {
+		runner.Register(usageJob(b.config.UsageInterval))
 	}
+	if reloaders := authenticator.Reloaders(); len(reloaders) > 0 {
+		interval := defaultAuthReloadInterval
+		if b.config.AuthReloadInterval > 0 {
+			interval = b.config.AuthReloadInterval
+		}
+		runner.Register(authReloadJob(interval, reloaders))
+	}
+	runner.Start(ctx)
 
-	b.router = handler.Router(username, password)
+	b.router = handler.Router(authenticator, b.config != nil && b.config.PublicMetrics)
 	return b.handler(ctx)
 }
 
+func (b *broker) authenticator() (*auth.Authenticator, error) {
+	if b.config == nil {
+		return auth.NewAuthenticator(), nil
+	}
+
+	verifiers := []auth.Verifier{
+		auth.NewBasicVerifier(b.config.BasicAuthUsername, b.config.BasicAuthPassword),
+	}
+	if b.config.CredentialsFile != "" {
+		v, err := auth.NewCredentialsFileVerifier(b.config.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		verifiers = append(verifiers, v)
+	}
+	if b.config.JWKSFile != "" {
+		v, err := auth.NewJWTVerifier(&auth.JWTConfig{
+			JWKSFile: b.config.JWKSFile,
+			Issuer:   b.config.JWTIssuer,
+			Audience: b.config.JWTAudience,
+		})
+		if err != nil {
+			return nil, err
+		}
+		verifiers = append(verifiers, v)
+	}
+	return auth.NewAuthenticator(verifiers...), nil
+}
+
 func (b *broker) listenAndServe(ctx context.Context) error {
 	errChan := make(chan error)
 	port := defaultPort
@@ -102,3 +161,66 @@ func (b *broker) listenAndServe(ctx context.Context) error {
 		return ctx.Err()
 	}
 }
+
+func reconcileJob(interval time.Duration, remediate bool) *jobs.Job {
+	return &jobs.Job{
+		Name:     "reconcile",
+		Interval: interval,
+		Func: func(ctx context.Context) error {
+			report, err := service.Reconcile(remediate)
+			if err != nil {
+				return err
+			}
+			log.WithFields(log.Fields{
+				"orphans":         len(report.Orphans),
+				"missing":         len(report.Missing),
+				"drifts":          len(report.Drifts),
+				"missingBindings": len(report.MissingBindings),
+			}).Info("reconcile completed")
+			return nil
+		},
+	}
+}
+
+func usageJob(interval time.Duration) *jobs.Job {
+	return &jobs.Job{
+		Name:     "usage",
+		Interval: interval,
+		Func: func(ctx context.Context) error {
+			usages, err := service.ListInstanceUsage()
+			if err != nil {
+				return err
+			}
+			warnings := 0
+			for _, usage := range usages {
+				if len(usage.Warnings) > 0 {
+					warnings++
+				}
+			}
+			log.WithFields(log.Fields{
+				"instances": len(usages),
+				"warnings":  warnings,
+			}).Info("reading usage completed")
+			return nil
+		},
+	}
+}
+
+func authReloadJob(interval time.Duration, reloaders []auth.Reloader) *jobs.Job {
+	return &jobs.Job{
+		Name:     "auth-reload",
+		Interval: interval,
+		Func: func(ctx context.Context) error {
+			var errs []string
+			for _, r := range reloaders {
+				if err := r.Reload(); err != nil {
+					errs = append(errs, err.Error())
+				}
+			}
+			if len(errs) > 0 {
+				return fmt.Errorf("reloading credentials is failed: %s", strings.Join(errs, ", "))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/service"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"gopkg.in/urfave/cli.v2"
+)
+
+var cliCommands = []*cli.Command{
+	{
+		Name:  "instances",
+		Usage: "Manage instances owned by the broker",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List instances",
+				Before: beforeAdminCommand,
+				Action: cmdInstancesList,
+			},
+			{
+				Name:      "show",
+				Usage:     "Show the instance with requested parameters, drift and actual parameters",
+				ArgsUsage: "<instance-id>",
+				Before:    beforeAdminCommand,
+				Action:    cmdInstancesShow,
+			},
+			{
+				Name:      "usage",
+				Usage:     "Show disk, memory, CPU and network usage of database instances from activity monitors",
+				ArgsUsage: "[<instance-id>]",
+				Before:    beforeAdminCommand,
+				Action:    cmdInstancesUsage,
+			},
+		},
+	},
+	{
+		Name:  "bindings",
+		Usage: "Manage bindings of database instances",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "list",
+				Usage:     "List bindings of the database instance",
+				ArgsUsage: "<instance-id>",
+				Before:    beforeAdminCommand,
+				Action:    cmdBindingsList,
+			},
+			{
+				Name:      "limits",
+				Usage:     "Change resource limits of the binding. Unspecified limits are reset to defaults of the plan",
+				ArgsUsage: "<instance-id> <binding-id>",
+				Before:    beforeAdminCommand,
+				Action:    cmdBindingsLimits,
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "max-connections",
+						Usage: "Maximum number of connections of the binding user. 0 means unlimited",
+					},
+					&cli.IntFlag{
+						Name:  "max-query-time",
+						Usage: "Maximum execution time of queries in seconds. 0 means unlimited",
+					},
+					&cli.IntFlag{
+						Name:  "max-queries-per-hour",
+						Usage: "Maximum number of queries per hour(MariaDB only). 0 means unlimited",
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:  "catalog",
+		Usage: "Manage the service catalog",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "dump",
+				Usage:  "Print the service catalog as JSON",
+				Action: cmdCatalogDump,
+			},
+		},
+	},
+	{
+		Name:   "reconcile",
+		Usage:  "Detect drift between the broker and SAKURA Cloud resources",
+		Before: beforeAdminCommand,
+		Action: cmdReconcile,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "remediate",
+				Usage: "Delete orphaned resources and broken binding records",
+			},
+		},
+	},
+	{
+		Name:  "orphans",
+		Usage: "Manage orphaned resources",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "cleanup",
+				Usage:  "Delete orphaned resources and wait for the deletion",
+				Before: beforeAdminCommand,
+				Action: cmdOrphansCleanup,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only print orphaned resources",
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:  "users",
+		Usage: "Manage database users created for bindings",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "sweep",
+				Usage:  "Print users left after unbinding, and delete them with --remediate",
+				Before: beforeAdminCommand,
+				Action: cmdUsersSweep,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "remediate",
+						Usage: "Delete leaked users. Without it, leaked users are only printed",
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:  "migrations",
+		Usage: "Manage meta tables in database instances",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "status",
+				Usage:  "Show schema versions of meta tables",
+				Before: beforeAdminCommand,
+				Action: cmdMigrationsStatus,
+			},
+		},
+	},
+	{
+		Name:      "rotate-credentials",
+		Usage:     "Generate new password of the database binding and print new credentials",
+		ArgsUsage: "<instance-id> <binding-id>",
+		Before:    beforeAdminCommand,
+		Action:    cmdRotateCredentials,
+	},
+}
+
+func beforeAdminCommand(c *cli.Context) error {
+	errs := cfg.Validate()
+	if len(errs) > 0 {
+		return flattenErrors(errs...)
+	}
+
+	// keep stdout for command results
+	initLogger(os.Stderr)
+
+	if err := initService(); err != nil {
+		return err
+	}
+
+	// the file is owned by the broker, so admin commands don't write it
+	if cfg.KnownInstancesFile != "" {
+		return service.LoadKnownInstances(cfg.KnownInstancesFile, false)
+	}
+	return nil
+}
+
+func cmdInstancesList(c *cli.Context) error {
+	instances, err := service.ListInstances()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE ID\tSERVICE\tPLAN\tSTATUS\tIP ADDRESS\tRESOURCE ID")
+	for _, instance := range instances {
+		status := instance.Status
+		if instance.Deleting {
+			status = "deleting"
+		} else if status == "" {
+			status = instance.Availability
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			instance.InstanceID,
+			instance.ServiceName,
+			instance.PlanName,
+			status,
+			instance.IPAddress,
+			instance.ResourceID,
+		)
+	}
+	return w.Flush()
+}
+
+func cmdInstancesShow(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("instance-id is required")
+	}
+
+	instance, err := service.ShowInstance(c.Args().First())
+	if err != nil {
+		return err
+	}
+	return writeJSON(c.App.Writer, instance)
+}
+
+func cmdInstancesUsage(c *cli.Context) error {
+	if c.NArg() == 1 {
+		usage, err := service.ShowInstanceUsage(c.Args().First())
+		if err != nil {
+			return err
+		}
+		return writeJSON(c.App.Writer, usage)
+	}
+
+	usages, err := service.ListInstanceUsage()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE ID\tSERVICE\tPLAN\tDISK USED\tDISK USAGE\tWARNING")
+	for _, usage := range usages {
+		diskUsed, ratio := "-", "-"
+		if usage.Monitor != nil && usage.Monitor.DiskUsedBytes != nil {
+			diskUsed = fmt.Sprintf("%.1fGiB", *usage.Monitor.DiskUsedBytes/(1024*1024*1024))
+		}
+		if usage.DiskUsageRatio != nil {
+			ratio = fmt.Sprintf("%.0f%%", *usage.DiskUsageRatio*100)
+		}
+		warning := strings.Join(usage.Warnings, "; ")
+		if usage.Error != "" {
+			warning = usage.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			usage.InstanceID,
+			usage.ServiceName,
+			usage.PlanName,
+			diskUsed,
+			ratio,
+			warning,
+		)
+	}
+	return w.Flush()
+}
+
+func cmdBindingsList(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("instance-id is required")
+	}
+
+	bindings, err := service.ListBindings(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "BINDING ID\tUSERNAME\tDATABASE")
+	for _, binding := range bindings {
+		database := "exists"
+		if !binding.DatabaseExists {
+			database = "missing"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", binding.BindingID, binding.Username, database)
+	}
+	return w.Flush()
+}
+
+func cmdBindingsLimits(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return errors.New("instance-id and binding-id are required")
+	}
+
+	// unset flags are replaced with defaults of the plan, and zero means unlimited
+	limit := func(name string) *int {
+		if !c.IsSet(name) {
+			return nil
+		}
+		return params.Limit(c.Int(name))
+	}
+	limits, err := service.UpdateBindingLimits(c.Args().Get(0), c.Args().Get(1), params.DatabaseBindingLimits{
+		MaxConnections:    limit("max-connections"),
+		MaxQueryTime:      limit("max-query-time"),
+		MaxQueriesPerHour: limit("max-queries-per-hour"),
+	})
+	if err != nil {
+		return err
+	}
+	return writeJSON(c.App.Writer, limits)
+}
+
+func cmdCatalogDump(c *cli.Context) error {
+	return writeJSON(c.App.Writer, service.CurrentCatalog)
+}
+
+func cmdReconcile(c *cli.Context) error {
+	report, err := service.Reconcile(c.Bool("remediate"))
+	if err != nil {
+		return err
+	}
+
+	var items []*service.ReconcileItem
+	for _, list := range [][]*service.ReconcileItem{
+		report.Orphans,
+		report.Missing,
+		report.Drifts,
+		report.MissingBindings,
+	} {
+		items = append(items, list...)
+	}
+	if err := writeReconcileItems(c.App.Writer, items); err != nil {
+		return err
+	}
+
+	if c.Bool("remediate") {
+		log.Info("Waiting for deletion of orphaned resources...")
+		iaas.WaitDeletions()
+	}
+	return nil
+}
+
+func cmdOrphansCleanup(c *cli.Context) error {
+	dryRun := c.Bool("dry-run")
+	items, err := service.CleanupOrphans(dryRun)
+	if err != nil {
+		return err
+	}
+	if err := writeReconcileItems(c.App.Writer, items); err != nil {
+		return err
+	}
+
+	if !dryRun {
+		log.Info("Waiting for deletion of orphaned resources...")
+		iaas.WaitDeletions()
+	}
+	return nil
+}
+
+func cmdUsersSweep(c *cli.Context) error {
+	items, err := service.SweepLeakedUsers(!c.Bool("remediate"))
+	if err != nil {
+		return err
+	}
+	return writeReconcileItems(c.App.Writer, items)
+}
+
+func cmdMigrationsStatus(c *cli.Context) error {
+	statuses, err := service.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE ID\tSERVICE\tMETA TABLE\tVERSION\tLATEST\tSTATUS")
+	for _, status := range statuses {
+		metaTable := "exists"
+		if !status.MetaTableExists {
+			metaTable = "missing"
+		}
+		state := "up to date"
+		if status.CurrentVersion < status.LatestVersion {
+			state = "pending"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n",
+			status.InstanceID,
+			status.ServiceName,
+			metaTable,
+			status.CurrentVersion,
+			status.LatestVersion,
+			state,
+		)
+	}
+	return w.Flush()
+}
+
+func cmdRotateCredentials(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return errors.New("instance-id and binding-id are required")
+	}
+
+	binding, err := service.RotateCredentials(c.Args().Get(0), c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	return writeJSON(c.App.Writer, binding)
+}
+
+func writeReconcileItems(out io.Writer, items []*service.ReconcileItem) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tINSTANCE ID\tBINDING ID\tRESOURCE ID\tDETAIL\tREMEDIATED")
+	for _, item := range items {
+		detail := item.Reason
+		if len(item.Attributes) > 0 {
+			detail = "drift: " + strings.Join(item.Attributes, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%t\n",
+			item.ServiceName,
+			item.InstanceID,
+			item.BindingID,
+			item.ResourceID,
+			detail,
+			item.Remediated,
+		)
+	}
+	return w.Flush()
+}
+
+func writeJSON(out io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(b))
+	return err
+}
@@ -2,23 +2,43 @@ package main
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/sacloud/open-service-broker-sacloud/broker/handler"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
 	"gopkg.in/urfave/cli.v2"
 	"strings"
 )
 
 type cliConfig struct {
-	AccessToken       string
-	AccessTokenSecret string
-	Zone              string
-	AcceptLanguage    string
-	RetryMax          int
-	RetryIntervalSec  int64
-	APIRootURL        string
-	TraceMode         bool
-	BasicAuthUsername string
-	BasicAuthPassword string
-	LogLevel          string
+	AccessToken        string
+	AccessTokenSecret  string
+	Zone               string
+	AcceptLanguage     string
+	RetryMax           int
+	RetryIntervalSec   int64
+	APIRootURL         string
+	TraceMode          bool
+	BasicAuthUsername  string
+	BasicAuthPassword  string
+	CredentialsFile    string
+	JWKSFile           string
+	JWTIssuer          string
+	JWTAudience        string
+	AuthReloadInterval time.Duration
+	LogLevel           string
+	OrphanTimeout      time.Duration
+	ReconcileInterval  time.Duration
+	ReconcileRemediate bool
+	UsageInterval      time.Duration
+	PublicMetrics      bool
+	KnownInstancesFile string
+	DBPlanConfigFile   string
+	CredentialFormats  string
+	DNSParentZone      string
+
+	PasswordSecret          string
+	PreviousPasswordSecrets string
 }
 
 var cfg = &cliConfig{}
@@ -90,6 +110,109 @@ var cliFlags = []cli.Flag{
 		EnvVars:     []string{"BASIC_AUTH_PASSWORD"},
 		Destination: &cfg.BasicAuthPassword,
 	},
+	&cli.StringFlag{
+		Name:        "credentials-file",
+		Usage:       "Path of the file which has 'username:bcrypt-hash' per line",
+		EnvVars:     []string{"OSBS_CREDENTIALS_FILE"},
+		Destination: &cfg.CredentialsFile,
+	},
+	&cli.StringFlag{
+		Name:        "jwks-file",
+		Usage:       "Path of the JWKS file to validate bearer JWTs",
+		EnvVars:     []string{"OSBS_JWKS_FILE"},
+		Destination: &cfg.JWKSFile,
+	},
+	&cli.StringFlag{
+		Name:        "jwt-issuer",
+		Usage:       "Expected issuer(iss) of bearer JWTs",
+		EnvVars:     []string{"OSBS_JWT_ISSUER"},
+		Destination: &cfg.JWTIssuer,
+	},
+	&cli.StringFlag{
+		Name:        "jwt-audience",
+		Usage:       "Expected audience(aud) of bearer JWTs",
+		EnvVars:     []string{"OSBS_JWT_AUDIENCE"},
+		Destination: &cfg.JWTAudience,
+	},
+	&cli.DurationFlag{
+		Name:        "auth-reload-interval",
+		Usage:       "Interval of reloading credentials-file and jwks-file",
+		EnvVars:     []string{"OSBS_AUTH_RELOAD_INTERVAL"},
+		Value:       30 * time.Second,
+		Destination: &cfg.AuthReloadInterval,
+	},
+	&cli.DurationFlag{
+		Name:        "orphan-timeout",
+		Usage:       "Period to wait for polling before deleting an abandoned instance(0: disabled)",
+		EnvVars:     []string{"OSBS_ORPHAN_TIMEOUT"},
+		Value:       handler.DefaultOrphanTimeout,
+		Destination: &cfg.OrphanTimeout,
+	},
+	&cli.DurationFlag{
+		Name:        "reconcile-interval",
+		Usage:       "Interval of detecting drift between broker and SAKURA Cloud resources(0: disabled)",
+		EnvVars:     []string{"OSBS_RECONCILE_INTERVAL"},
+		Value:       10 * time.Minute,
+		Destination: &cfg.ReconcileInterval,
+	},
+	&cli.BoolFlag{
+		Name:        "reconcile-remediate",
+		Usage:       "Delete orphaned resources and broken binding records detected by reconciler",
+		EnvVars:     []string{"OSBS_RECONCILE_REMEDIATE"},
+		Destination: &cfg.ReconcileRemediate,
+		Value:       false,
+	},
+	&cli.DurationFlag{
+		Name:        "usage-interval",
+		Usage:       "Interval of reading activity monitors of instances for usage metrics(0: disabled)",
+		EnvVars:     []string{"OSBS_USAGE_INTERVAL"},
+		Value:       5 * time.Minute,
+		Destination: &cfg.UsageInterval,
+	},
+	&cli.BoolFlag{
+		Name:        "public-metrics",
+		Usage:       "Expose /metrics without authentication",
+		EnvVars:     []string{"OSBS_PUBLIC_METRICS"},
+		Destination: &cfg.PublicMetrics,
+		Value:       false,
+	},
+	&cli.StringFlag{
+		Name:        "known-instances-file",
+		Usage:       "Path of the JSON file which keeps instances known to the broker. Unknown resources are reported by reconciler only if it is set",
+		EnvVars:     []string{"OSBS_KNOWN_INSTANCES_FILE"},
+		Destination: &cfg.KnownInstancesFile,
+	},
+	&cli.StringFlag{
+		Name:        "db-plan-config",
+		Aliases:     []string{"db-tls-config"},
+		Usage:       "Path of the JSON file which defines TLS settings and binding defaults of database plans",
+		EnvVars:     []string{"OSBS_DB_PLAN_CONFIG", "OSBS_DB_TLS_CONFIG"},
+		Destination: &cfg.DBPlanConfigFile,
+	},
+	&cli.StringFlag{
+		Name:        "credential-templates",
+		Usage:       "Path of the JSON file which defines credential formats of bindings per service",
+		EnvVars:     []string{"OSBS_CREDENTIAL_TEMPLATES"},
+		Destination: &cfg.CredentialFormats,
+	},
+	&cli.StringFlag{
+		Name:        "dns-parent-zone",
+		Usage:       "Zone in which subdomains of sacloud-dns instances are delegated. The zone must be managed in SAKURA Cloud DNS",
+		EnvVars:     []string{"OSBS_DNS_PARENT_ZONE"},
+		Destination: &cfg.DNSParentZone,
+	},
+	&cli.StringFlag{
+		Name:        "password-secret",
+		Usage:       "Secret(at least 32 characters) which keys to encrypt binding passwords stored in PostgreSQL appliances are derived from. Required to use PostgreSQL",
+		EnvVars:     []string{"OSBS_PASSWORD_SECRET"},
+		Destination: &cfg.PasswordSecret,
+	},
+	&cli.StringFlag{
+		Name:        "previous-password-secrets",
+		Usage:       "Comma separated secrets which were used as --password-secret. Passwords encrypted with them are encrypted again with --password-secret",
+		EnvVars:     []string{"OSBS_PREVIOUS_PASSWORD_SECRETS"},
+		Destination: &cfg.PreviousPasswordSecrets,
+	},
 	&cli.StringFlag{
 		Name:        "log-level",
 		Usage:       "Log level[INFO/WARN/DEBUG] default:INFO",
@@ -106,8 +229,10 @@ func (o *cliConfig) Validate() []error {
 		func() error { return o.validateRequired("token", o.AccessToken) },
 		func() error { return o.validateRequired("secret", o.AccessTokenSecret) },
 		func() error { return o.validateRequired("zone", o.Zone) },
+		func() error { return o.validatePasswordSecrets() },
 		func() error { return o.validateRequired("log-level", o.LogLevel) },
 		func() error { return o.validateInStrings("log-level", o.LogLevel, "INFO", "WARN", "DEBUG") },
+		func() error { return o.validateDNSZone("dns-parent-zone", o.DNSParentZone) },
 	}
 
 	for _, v := range validators {
@@ -127,6 +252,22 @@ func (o *cliConfig) validateRequired(name, v string) error {
 	return nil
 }
 
+// validatePasswordSecrets rejects previous secrets without the current one.
+// The secret itself is optional because only PostgreSQL bindings need it
+func (o *cliConfig) validatePasswordSecrets() error {
+	if o.PasswordSecret == "" && o.PreviousPasswordSecrets != "" {
+		return fmt.Errorf("[Option] --%s is required with --%s", "password-secret", "previous-password-secrets")
+	}
+	return nil
+}
+
+func (o *cliConfig) validateDNSZone(name, v string) error {
+	if v != "" && !params.ValidDNSZone(v) {
+		return fmt.Errorf("[Option] --%s must be a domain name without the trailing dot", name)
+	}
+	return nil
+}
+
 func (o *cliConfig) validateInStrings(name, v string, allows ...string) error {
 	if v == "" {
 		return nil
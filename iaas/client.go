@@ -1,6 +1,7 @@
 package iaas
 
 import (
+	"sync"
 	"time"
 
 	"fmt"
@@ -12,7 +13,36 @@ import (
 	"github.com/sacloud/open-service-broker-sacloud/version"
 )
 
-var mutex = mutexkv.NewMutexKV()
+var (
+	mutex     = mutexkv.NewMutexKV()
+	deletions sync.WaitGroup
+
+	backgroundMu   sync.Mutex
+	backgroundJobs = make(map[string]bool)
+)
+
+// runInBackground runs the job in background unless the job with the same key is already running in this process.
+// Jobs are waited on shutdown of the broker like deletions
+func runInBackground(key string, job func()) {
+	backgroundMu.Lock()
+	defer backgroundMu.Unlock()
+
+	if backgroundJobs[key] {
+		return
+	}
+	backgroundJobs[key] = true
+
+	deletions.Add(1)
+	go func() {
+		defer deletions.Done()
+		defer func() {
+			backgroundMu.Lock()
+			defer backgroundMu.Unlock()
+			delete(backgroundJobs, key)
+		}()
+		job()
+	}()
+}
 
 // ClientConfig represents SAKURA Cloud API client config
 type ClientConfig struct {
@@ -31,21 +61,107 @@ type Client interface {
 	AuthStatus() (*sacloud.AuthStatus, error)
 	MariaDB() DatabaseAPI
 	PostgreSQL() DatabaseAPI
+	NFS() NFSAPI
+	DNS() DNSAPI
+	SimpleMonitor() SimpleMonitorAPI
+	Switch() SwitchAPI
+	VPCRouter() VPCRouterAPI
+	GSLB() GSLBAPI
 }
 
 // DatabaseAPI is SAKURA Cloud Database API interface
 type DatabaseAPI interface {
+	List() ([]sacloud.Database, error)
 	Read(instanceID string) (*sacloud.Database, error)
 	Create(instanceID string, param *params.DatabaseCreateParameter) (*sacloud.Database, error)
+	UpdateAllowNetworks(instanceID string, networks []string) (*sacloud.Database, error)
+	Monitor(instanceID string) (*DatabaseMonitor, error)
+	ConfirmProvisioned(instanceID string) error
+	Delete(instanceID string) error
+}
+
+// NFSAPI is SAKURA Cloud NFS appliance API interface
+type NFSAPI interface {
+	List() ([]sacloud.NFS, error)
+	Read(instanceID string) (*sacloud.NFS, error)
+	Create(instanceID string, param *params.NFSCreateParameter) (*sacloud.NFS, error)
+	ConfirmProvisioned(instanceID string) error
+	Delete(instanceID string) error
+}
+
+// DNSAPI is SAKURA Cloud DNS API interface
+type DNSAPI interface {
+	List() ([]sacloud.DNS, error)
+	Read(instanceID string) (*sacloud.DNS, error)
+	Create(instanceID string, param *params.DNSCreateParameter) (*sacloud.DNS, error)
+	UpdateRecords(instanceID string, add, remove []sacloud.DNSRecordSet, check func(zone *sacloud.DNS) error) (*sacloud.DNS, error)
+	ConfirmProvisioned(instanceID string) error
+	Delete(instanceID string) error
+}
+
+// SimpleMonitorAPI is SAKURA Cloud Simple Monitor API interface
+type SimpleMonitorAPI interface {
+	List() ([]sacloud.SimpleMonitor, error)
+	Read(instanceID string) (*sacloud.SimpleMonitor, error)
+	Create(instanceID string, param *params.SimpleMonitorParameter) (*sacloud.SimpleMonitor, error)
+	Update(instanceID string, param *params.SimpleMonitorParameter) (*sacloud.SimpleMonitor, error)
+	Health(instanceID string) (*SimpleMonitorHealth, error)
+	ConfirmProvisioned(instanceID string) error
+	Delete(instanceID string) error
+}
+
+// SwitchAPI is SAKURA Cloud Switch API interface
+type SwitchAPI interface {
+	List() ([]sacloud.Switch, error)
+	Read(instanceID string) (*sacloud.Switch, error)
+	Create(instanceID string, param *params.SwitchCreateParameter) (*sacloud.Switch, error)
+	ResumeConnecting() error
+	ConfirmProvisioned(instanceID string) error
+	Delete(instanceID string) error
+}
+
+// VPCRouterAPI is SAKURA Cloud VPC Router API interface
+type VPCRouterAPI interface {
+	List() ([]sacloud.VPCRouter, error)
+	Read(instanceID string) (*sacloud.VPCRouter, error)
+	Create(instanceID string, param *params.VPCRouterCreateParameter) (*sacloud.VPCRouter, error)
+	Update(instanceID string, config *params.VPCRouterConfigParameter) (*sacloud.VPCRouter, error)
+	ResumeConfiguring() error
+	SiteToSiteConnectionDetails(instanceID string) (*sacloud.SiteToSiteConnectionInfo, error)
+	ConfirmProvisioned(instanceID string) error
+	Delete(instanceID string) error
+}
+
+// GSLBAPI is SAKURA Cloud GSLB API interface
+type GSLBAPI interface {
+	List() ([]sacloud.GSLB, error)
+	Read(instanceID string) (*sacloud.GSLB, error)
+	Create(instanceID string, param *params.GSLBCreateParameter) (*sacloud.GSLB, error)
+	AddServer(instanceID, bindingKey string, server sacloud.GSLBServer) (*sacloud.GSLB, error)
+	RemoveServer(instanceID, bindingKey string) (*sacloud.GSLB, error)
+	ConfirmProvisioned(instanceID string) error
 	Delete(instanceID string) error
 }
 
 const markerTag = "@open-service-broker-sacloud"
 
+// ProvisioningMarkerTag is the tag which is added to resources until the platform is notified of the completion of provisioning.
+// Resources which keep it are cleaned up by orphan mitigation even after broker restart
+const ProvisioningMarkerTag = markerTag + "-provisioning"
+
+// DeletingMarkerTag is the tag which is added to resources that deletion is requested
+const DeletingMarkerTag = markerTag + "-deleting"
+
 type client struct {
-	rawClient  *api.Client
-	mariaDB    *dbApplianceClient
-	postgreSQL *dbApplianceClient
+	rawClient     *api.Client
+	mariaDB       *dbApplianceClient
+	postgreSQL    *dbApplianceClient
+	nfs           *nfsClient
+	dns           *dnsClient
+	simpleMonitor *simpleMonitorClient
+	sw            *switchClient
+	vpcRouter     *vpcRouterClient
+	gslb          *gslbClient
 }
 
 // NewClient returns SAKURA Cloud API client
@@ -78,6 +194,12 @@ func NewClient(cfg *ClientConfig) Client {
 		client:          client,
 		createParamFunc: sacloud.NewCreatePostgreSQLDatabaseValue,
 	}
+	client.nfs = &nfsClient{client: client}
+	client.dns = &dnsClient{client: client}
+	client.simpleMonitor = &simpleMonitorClient{client: client}
+	client.sw = &switchClient{client: client}
+	client.vpcRouter = &vpcRouterClient{client: client}
+	client.gslb = &gslbClient{client: client}
 	return client
 }
 
@@ -92,3 +214,27 @@ func (c *client) MariaDB() DatabaseAPI {
 func (c *client) PostgreSQL() DatabaseAPI {
 	return c.postgreSQL
 }
+
+func (c *client) NFS() NFSAPI {
+	return c.nfs
+}
+
+func (c *client) DNS() DNSAPI {
+	return c.dns
+}
+
+func (c *client) SimpleMonitor() SimpleMonitorAPI {
+	return c.simpleMonitor
+}
+
+func (c *client) Switch() SwitchAPI {
+	return c.sw
+}
+
+func (c *client) VPCRouter() VPCRouterAPI {
+	return c.vpcRouter
+}
+
+func (c *client) GSLB() GSLBAPI {
+	return c.gslb
+}
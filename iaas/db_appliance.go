@@ -1,6 +1,7 @@
 package iaas
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -19,6 +20,26 @@ type dbApplianceClient struct {
 	createParamFunc func() *sacloud.CreateDatabaseValue
 }
 
+func (c *dbApplianceClient) List() ([]sacloud.Database, error) {
+	client := c.getRawClient()
+	results, err := client.Database.Reset().WithTag(markerTag).Limit(10000).Find()
+	if err != nil {
+		return nil, err
+	}
+
+	databaseName := c.createParamFunc().DatabaseName
+	var dbs []sacloud.Database
+	for _, db := range results.Databases {
+		if db.Remark == nil || db.Remark.DBConf == nil || db.Remark.DBConf.Common == nil {
+			continue
+		}
+		if db.Remark.DBConf.Common.DatabaseName == databaseName {
+			dbs = append(dbs, db)
+		}
+	}
+	return dbs, nil
+}
+
 func (c *dbApplianceClient) Read(instanceID string) (*sacloud.Database, error) {
 	client := c.getRawClient()
 	results, err := client.Database.Reset().WithNameLike(instanceID).Find()
@@ -56,7 +77,15 @@ func (c *dbApplianceClient) Create(instanceID string, param *params.DatabaseCrea
 	p.ServicePort = fmt.Sprintf("%d", param.Port)
 	p.SourceNetwork = param.AllowNetworks
 
-	p.Tags = []string{markerTag}
+	p.Tags = []string{markerTag, ProvisioningMarkerTag}
+
+	// keep the requested parameter for detecting drift.
+	// The description is internal to the broker, see DesiredDatabaseParameter
+	desired, err := json.Marshal(param)
+	if err != nil {
+		return nil, err
+	}
+	p.Description = string(desired)
 
 	p.Name = instanceID
 	createArgs := sacloud.CreateNewDatabase(p)
@@ -64,6 +93,57 @@ func (c *dbApplianceClient) Create(instanceID string, param *params.DatabaseCrea
 	return client.Database.Create(createArgs)
 }
 
+// UpdateAllowNetworks replaces source networks which are allowed to connect the database, and applies them
+func (c *dbApplianceClient) UpdateAllowNetworks(instanceID string, networks []string) (*sacloud.Database, error) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+		"networks":   networks,
+	}
+	log.WithFields(logFields).Debug("IaaS update allowNetworks start")
+
+	db, err := c.Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if db.Settings == nil || db.Settings.DBConf == nil || db.Settings.DBConf.Common == nil {
+		return nil, errors.New("database settings are not available")
+	}
+
+	strID := db.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	db.Settings.DBConf.Common.SourceNetwork = sacloud.SourceNetwork(networks)
+	if _, err := client.Database.UpdateSetting(db.ID, db); err != nil {
+		return nil, err
+	}
+	if _, err := client.Database.Config(db.ID); err != nil {
+		return nil, err
+	}
+
+	// follow the requested parameter so that the update isn't reported as drift
+	desired, err := DesiredDatabaseParameter(db)
+	if err != nil {
+		return nil, err
+	}
+	if desired != nil {
+		desired.AllowNetworks = networks
+		data, err := json.Marshal(desired)
+		if err != nil {
+			return nil, err
+		}
+		db.Description = string(data)
+		if db, err = client.Database.Update(db.ID, db); err != nil {
+			return nil, err
+		}
+	}
+
+	log.WithFields(logFields).Debug("IaaS update allowNetworks finished")
+	return db, nil
+}
+
 func (c *dbApplianceClient) Delete(instanceID string) error {
 
 	logFields := log.Fields{
@@ -76,10 +156,43 @@ func (c *dbApplianceClient) Delete(instanceID string) error {
 		return err
 	}
 
-	go c.delete(instanceID, db.ID)
+	// mark the resource so that the deletion can be resumed after broker crash.
+	// Failing to mark doesn't stop the deletion, the platform requests it again if it isn't completed
+	if !db.HasTag(DeletingMarkerTag) {
+		db.AppendTag(DeletingMarkerTag)
+		if _, err := c.getRawClient().Database.Update(db.ID, db); err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Warn(
+				`IaaS delete instance: marking as deleting is failed`)
+		}
+	}
+
+	// the deletion is started again if it was stopped by errors
+	runInBackground("delete/"+db.GetStrID(), func() {
+		c.delete(instanceID, db.ID)
+	})
 	return nil
 }
 
+// WaitDeletions blocks until all deletions started by this process are completed
+func WaitDeletions() {
+	deletions.Wait()
+}
+
+// DesiredDatabaseParameter returns the parameter which was requested at the creation of the database.
+// It is kept as JSON of params.DatabaseCreateParameter in the description of the database.
+// The format is internal to the broker, so it isn't guaranteed to be compatible between versions
+func DesiredDatabaseParameter(db *sacloud.Database) (*params.DatabaseCreateParameter, error) {
+	if db == nil || db.Description == "" {
+		return nil, nil
+	}
+	var p params.DatabaseCreateParameter
+	if err := json.Unmarshal([]byte(db.Description), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
 func (c *dbApplianceClient) delete(instanceID string, id int64) {
 	logFields := log.Fields{
 		"instanceID": instanceID,
@@ -209,3 +322,29 @@ func (c *dbApplianceClient) stopAndDelete(instanceID string, db *sacloud.Databas
 	}
 
 }
+
+// ConfirmProvisioned removes the provisioning marker from the database
+func (c *dbApplianceClient) ConfirmProvisioned(instanceID string) error {
+	db, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+	if !db.HasTag(ProvisioningMarkerTag) {
+		return nil
+	}
+
+	strID := db.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	// refresh not to overwrite tags changed by other operations
+	db, err = client.Database.Read(db.ID)
+	if err != nil {
+		return err
+	}
+	db.RemoveTag(ProvisioningMarkerTag)
+	_, err = client.Database.Update(db.ID, db)
+	return err
+}
@@ -0,0 +1,110 @@
+package iaas
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/sacloud"
+)
+
+// monitorWindow is the period of activity monitors to read.
+// SAKURA Cloud aggregates activity monitors every 5 minutes
+const monitorWindow = 30 * time.Minute
+
+// DatabaseMonitor represents the latest activity monitor values of the database appliance.
+// Values which are not reported in the window are nil
+type DatabaseMonitor struct {
+	Time             time.Time `json:"time"`
+	DiskUsedBytes    *float64  `json:"disk_used_bytes,omitempty"`
+	DiskTotalBytes   *float64  `json:"disk_total_bytes,omitempty"`
+	MemoryUsedBytes  *float64  `json:"memory_used_bytes,omitempty"`
+	MemoryTotalBytes *float64  `json:"memory_total_bytes,omitempty"`
+	CPUTime          *float64  `json:"cpu_time,omitempty"`
+	ReceiveBPS       *float64  `json:"receive_bps,omitempty"`
+	SendBPS          *float64  `json:"send_bps,omitempty"`
+}
+
+// Monitor reads activity monitors of the database appliance
+func (c *dbApplianceClient) Monitor(instanceID string) (*DatabaseMonitor, error) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+	log.WithFields(logFields).Debug("IaaS monitor start")
+
+	db, err := c.Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.getRawClient()
+	end := time.Now()
+	start := end.Add(-monitorWindow)
+	req := sacloud.NewResourceMonitorRequest(&start, &end)
+
+	dbValues, err := client.Database.MonitorDatabase(db.ID, req)
+	if err != nil {
+		return nil, err
+	}
+	cpuValues, err := client.Database.MonitorCPU(db.ID, req)
+	if err != nil {
+		return nil, err
+	}
+	nicValues, err := client.Database.MonitorInterface(db.ID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	monitor := &DatabaseMonitor{}
+	// disk and memory sizes are reported in KiB
+	for _, v := range []struct {
+		values *sacloud.MonitorValues
+		field  func(*sacloud.MonitorValue) *float64
+		dest   **float64
+		scale  float64
+	}{
+		{dbValues, func(v *sacloud.MonitorValue) *float64 { return v.UsedDisk1Size }, &monitor.DiskUsedBytes, 1024},
+		{dbValues, func(v *sacloud.MonitorValue) *float64 { return v.TotalDisk1Size }, &monitor.DiskTotalBytes, 1024},
+		{dbValues, func(v *sacloud.MonitorValue) *float64 { return v.UsedMemorySize }, &monitor.MemoryUsedBytes, 1024},
+		{dbValues, func(v *sacloud.MonitorValue) *float64 { return v.TotalMemorySize }, &monitor.MemoryTotalBytes, 1024},
+		{cpuValues, func(v *sacloud.MonitorValue) *float64 { return v.CPUTime }, &monitor.CPUTime, 1},
+		{nicValues, func(v *sacloud.MonitorValue) *float64 { return v.Receive }, &monitor.ReceiveBPS, 1},
+		{nicValues, func(v *sacloud.MonitorValue) *float64 { return v.Send }, &monitor.SendBPS, 1},
+	} {
+		t, value, ok := latestMonitorValue(v.values, v.field)
+		if !ok {
+			continue
+		}
+		scaled := value * v.scale
+		*v.dest = &scaled
+		if t.After(monitor.Time) {
+			monitor.Time = t
+		}
+	}
+
+	log.WithFields(logFields).Debug("IaaS monitor finished")
+	return monitor, nil
+}
+
+// latestMonitorValue returns the newest value of the field in monitor values
+func latestMonitorValue(values *sacloud.MonitorValues, field func(*sacloud.MonitorValue) *float64) (time.Time, float64, bool) {
+	var latest time.Time
+	var value float64
+	found := false
+	if values == nil {
+		return latest, value, false
+	}
+
+	for key, v := range *values {
+		if v == nil || field(v) == nil {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, key)
+		if err != nil {
+			continue
+		}
+		if !found || t.After(latest) {
+			latest, value, found = t, *field(v), true
+		}
+	}
+	return latest, value, found
+}
@@ -0,0 +1,312 @@
+package iaas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/api"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+)
+
+// dnsDelegationTTL is the TTL of NS records which delegate subdomains
+const dnsDelegationTTL = 3600
+
+// dnsDescription is stored in the description of zones.
+// Zones are named with the zone name, so the instance ID is kept here
+type dnsDescription struct {
+	InstanceID string `json:"instanceID"`
+	*params.DNSCreateParameter
+}
+
+type dnsClient struct {
+	*client
+}
+
+func (c *dnsClient) List() ([]sacloud.DNS, error) {
+	client := c.getRawClient()
+	results, err := client.DNS.Reset().WithTag(markerTag).Limit(10000).Find()
+	if err != nil {
+		return nil, err
+	}
+	return results.CommonServiceDNSItems, nil
+}
+
+func (c *dnsClient) Read(instanceID string) (*sacloud.DNS, error) {
+	zones, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []sacloud.DNS
+	for _, zone := range zones {
+		desc, err := readDNSDescription(&zone)
+		if err != nil || desc == nil {
+			continue
+		}
+		if desc.InstanceID == instanceID {
+			found = append(found, zone)
+		}
+	}
+	if len(found) == 0 {
+		return nil, api.NewError(http.StatusNotFound, &sacloud.ResultErrorValue{})
+	}
+	if len(found) > 1 {
+		return nil, errors.New("Multiple resources with the same instance ID is exists")
+	}
+	return &found[0], nil
+}
+
+func (c *dnsClient) Create(instanceID string, param *params.DNSCreateParameter) (*sacloud.DNS, error) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+		"zone":       param.ZoneName(),
+	}
+	log.WithFields(logFields).Debug("IaaS create DNS start")
+
+	client := c.getRawClient()
+
+	exists, err := c.findZone(param.ZoneName())
+	if err != nil {
+		return nil, err
+	}
+	if exists != nil {
+		return nil, fmt.Errorf("zone %q already exists", param.ZoneName())
+	}
+
+	desc, err := json.Marshal(&dnsDescription{InstanceID: instanceID, DNSCreateParameter: param})
+	if err != nil {
+		return nil, err
+	}
+
+	zone := sacloud.CreateNewDNS(param.ZoneName())
+	zone.Description = string(desc)
+	zone.Tags = []string{markerTag, ProvisioningMarkerTag}
+	created, err := client.DNS.Create(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	if param.Subdomain != "" {
+		err := c.updateParentZone(param, func(parent *sacloud.DNS) {
+			for _, ns := range created.Status.NS {
+				parent.AddRecord(parent.CreateNewRecord(param.Subdomain, "NS", fqdn(ns), dnsDelegationTTL))
+			}
+		})
+		if err != nil {
+			// the zone is useless without the delegation
+			if _, e := client.DNS.Delete(created.ID); e != nil {
+				logFields["err"] = e
+				log.WithFields(logFields).Error("IaaS create DNS error: deleting the zone is failed")
+			}
+			return nil, fmt.Errorf("delegating subdomain is failed: %s", err)
+		}
+	}
+
+	log.WithFields(logFields).Debug("IaaS create DNS finished")
+	return created, nil
+}
+
+// UpdateRecords removes and adds records of the zone of the instance.
+// check is called with the latest zone while the zone is locked, and the zone is not updated if it returns error
+func (c *dnsClient) UpdateRecords(instanceID string, add, remove []sacloud.DNSRecordSet, check func(zone *sacloud.DNS) error) (*sacloud.DNS, error) {
+	zone, err := c.Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	strID := zone.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	// refresh to apply changes to the latest records
+	zone, err = client.DNS.Read(zone.ID)
+	if err != nil {
+		return nil, err
+	}
+	if check != nil {
+		if err := check(zone); err != nil {
+			return nil, err
+		}
+	}
+
+	records := []sacloud.DNSRecordSet{}
+	for _, r := range zone.Settings.DNS.ResourceRecordSets {
+		if !containsDNSRecord(remove, r) {
+			records = append(records, r)
+		}
+	}
+	zone.Settings.DNS.ResourceRecordSets = records
+	for i := range add {
+		zone.AddRecord(&add[i])
+	}
+
+	return client.DNS.Update(zone.ID, zone)
+}
+
+func (c *dnsClient) Delete(instanceID string) error {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+	log.WithFields(logFields).Debug("IaaS delete DNS start")
+
+	zone, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+	desc, err := readDNSDescription(zone)
+	if err != nil {
+		return err
+	}
+
+	if desc != nil && desc.DNSCreateParameter != nil && desc.Subdomain != "" {
+		err := c.updateParentZone(desc.DNSCreateParameter, func(parent *sacloud.DNS) {
+			// remove only records added by Create, which point to name servers of the zone
+			records := []sacloud.DNSRecordSet{}
+			for _, r := range parent.Settings.DNS.ResourceRecordSets {
+				if !isDelegationRecord(r, desc.Subdomain, zone.Status.NS) {
+					records = append(records, r)
+				}
+			}
+			parent.Settings.DNS.ResourceRecordSets = records
+		})
+		if err != nil {
+			return fmt.Errorf("removing delegation of subdomain is failed: %s", err)
+		}
+	}
+
+	if _, err := c.getRawClient().DNS.Delete(zone.ID); err != nil {
+		return err
+	}
+
+	log.WithFields(logFields).Debug("IaaS delete DNS finished")
+	return nil
+}
+
+func (c *dnsClient) updateParentZone(param *params.DNSCreateParameter, update func(parent *sacloud.DNS)) error {
+	parent, err := c.findZone(param.ParentZone)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return fmt.Errorf("parent zone %q is not found", param.ParentZone)
+	}
+
+	strID := parent.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+	parent, err = client.DNS.Read(parent.ID)
+	if err != nil {
+		return err
+	}
+	update(parent)
+	_, err = client.DNS.Update(parent.ID, parent)
+	return err
+}
+
+// findZone returns the zone which has the name. It returns nil if not found
+func (c *dnsClient) findZone(name string) (*sacloud.DNS, error) {
+	client := c.getRawClient()
+	results, err := client.DNS.Reset().WithNameLike(name).Limit(10000).Find()
+	if err != nil {
+		return nil, err
+	}
+	for i := range results.CommonServiceDNSItems {
+		if results.CommonServiceDNSItems[i].Status.Zone == name {
+			return &results.CommonServiceDNSItems[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// DesiredDNSParameter returns the parameter which was requested at the creation of the zone
+func DesiredDNSParameter(zone *sacloud.DNS) (*params.DNSCreateParameter, error) {
+	desc, err := readDNSDescription(zone)
+	if err != nil || desc == nil {
+		return nil, err
+	}
+	return desc.DNSCreateParameter, nil
+}
+
+// DNSInstanceID returns the instance ID of the zone. It returns empty if the zone isn't created by the broker
+func DNSInstanceID(zone *sacloud.DNS) string {
+	desc, err := readDNSDescription(zone)
+	if err != nil || desc == nil {
+		return ""
+	}
+	return desc.InstanceID
+}
+
+func readDNSDescription(zone *sacloud.DNS) (*dnsDescription, error) {
+	if zone == nil || zone.Description == "" {
+		return nil, nil
+	}
+	var desc dnsDescription
+	if err := json.Unmarshal([]byte(zone.Description), &desc); err != nil {
+		return nil, err
+	}
+	return &desc, nil
+}
+
+func containsDNSRecord(records []sacloud.DNSRecordSet, r sacloud.DNSRecordSet) bool {
+	for _, v := range records {
+		if v.Name == r.Name && v.Type == r.Type && v.RData == r.RData {
+			return true
+		}
+	}
+	return false
+}
+
+// isDelegationRecord returns true if the record is the NS record of the subdomain which points to one of name servers
+func isDelegationRecord(r sacloud.DNSRecordSet, subdomain string, nameServers []string) bool {
+	if r.Type != "NS" || r.Name != subdomain {
+		return false
+	}
+	for _, ns := range nameServers {
+		if strings.EqualFold(fqdn(r.RData), fqdn(ns)) {
+			return true
+		}
+	}
+	return false
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// ConfirmProvisioned removes the provisioning marker from the DNS zone
+func (c *dnsClient) ConfirmProvisioned(instanceID string) error {
+	zone, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+	if !zone.HasTag(ProvisioningMarkerTag) {
+		return nil
+	}
+
+	strID := zone.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	// refresh not to overwrite tags changed by other operations
+	zone, err = client.DNS.Read(zone.ID)
+	if err != nil {
+		return err
+	}
+	zone.RemoveTag(ProvisioningMarkerTag)
+	_, err = client.DNS.Update(zone.ID, zone)
+	return err
+}
@@ -0,0 +1,222 @@
+package iaas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/api"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+)
+
+type gslbClient struct {
+	*client
+}
+
+func (c *gslbClient) List() ([]sacloud.GSLB, error) {
+	client := c.getRawClient()
+	results, err := client.GSLB.Reset().WithTag(markerTag).Limit(10000).Find()
+	if err != nil {
+		return nil, err
+	}
+	return results.CommonServiceGSLBItems, nil
+}
+
+func (c *gslbClient) Read(instanceID string) (*sacloud.GSLB, error) {
+	client := c.getRawClient()
+	results, err := client.GSLB.Reset().WithNameLike(instanceID).Find()
+	if err != nil {
+		return nil, err
+	}
+	if len(results.CommonServiceGSLBItems) == 0 {
+		return nil, api.NewError(http.StatusNotFound, &sacloud.ResultErrorValue{})
+	}
+
+	if len(results.CommonServiceGSLBItems) > 1 {
+		return nil, errors.New("Multiple resources with the same instance ID is exists")
+	}
+
+	return &results.CommonServiceGSLBItems[0], nil
+}
+
+func (c *gslbClient) Create(instanceID string, param *params.GSLBCreateParameter) (*sacloud.GSLB, error) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+		"protocol":   param.HealthCheck.Protocol,
+	}
+	log.WithFields(logFields).Debug("IaaS create GSLB start")
+
+	client := c.getRawClient()
+
+	g := client.GSLB.New(instanceID)
+	g.Tags = []string{markerTag, ProvisioningMarkerTag}
+	applyGSLBParameter(g, param)
+
+	created, err := client.GSLB.Create(g)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(logFields).Debug("IaaS create GSLB finished")
+	return created, nil
+}
+
+func applyGSLBParameter(g *sacloud.GSLB, param *params.GSLBCreateParameter) {
+	hc := param.HealthCheck
+	switch hc.Protocol {
+	case "http":
+		g.SetHTTPHealthCheck(hc.HostHeader, hc.Path, hc.ExpectedStatus)
+	case "https":
+		g.SetHTTPSHealthCheck(hc.HostHeader, hc.Path, hc.ExpectedStatus)
+	case "tcp":
+		g.SetTCPHealthCheck(hc.Port)
+	case "ping":
+		g.SetPingHealthCheck()
+	}
+	g.SetDelayLoop(param.Interval)
+	g.SetWeightedEnable(param.Weighted == nil || *param.Weighted)
+	g.SetSorryServer(param.SorryServer)
+}
+
+// AddServer registers the server as the endpoint of the binding
+func (c *gslbClient) AddServer(instanceID, bindingKey string, server sacloud.GSLBServer) (*sacloud.GSLB, error) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+		"ipaddress":  server.IPAddress,
+	}
+	log.WithFields(logFields).Debug("IaaS add GSLB server start")
+
+	return c.updateServers(instanceID, func(g *sacloud.GSLB, bindings map[string]string) error {
+		if _, ok := bindings[bindingKey]; ok {
+			return errors.New("the binding already has a server")
+		}
+		for _, s := range g.Settings.GSLB.Servers {
+			if s.IPAddress == server.IPAddress {
+				return fmt.Errorf("server %s is already registered", server.IPAddress)
+			}
+		}
+		if len(g.Settings.GSLB.Servers) >= params.GSLBMaxServers {
+			return fmt.Errorf("GSLB can't have more than %d servers", params.GSLBMaxServers)
+		}
+		g.Settings.GSLB.Servers = append(g.Settings.GSLB.Servers, server)
+		bindings[bindingKey] = server.IPAddress
+		return nil
+	})
+}
+
+// RemoveServer removes the server which is registered by the binding
+func (c *gslbClient) RemoveServer(instanceID, bindingKey string) (*sacloud.GSLB, error) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+	log.WithFields(logFields).Debug("IaaS remove GSLB server start")
+
+	return c.updateServers(instanceID, func(g *sacloud.GSLB, bindings map[string]string) error {
+		ip, ok := bindings[bindingKey]
+		if !ok {
+			return nil
+		}
+		g.Settings.GSLB.DeleteServer(ip)
+		delete(bindings, bindingKey)
+		return nil
+	})
+}
+
+func (c *gslbClient) updateServers(instanceID string, update func(g *sacloud.GSLB, bindings map[string]string) error) (*sacloud.GSLB, error) {
+	g, err := c.Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	strID := g.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	// refresh to apply changes to the latest servers
+	g, err = client.GSLB.Read(g.ID)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := GSLBBindingServers(g)
+	if err != nil {
+		return nil, fmt.Errorf("reading servers of bindings is failed: %s", err)
+	}
+
+	if err := update(g, bindings); err != nil {
+		return nil, err
+	}
+
+	desc, err := json.Marshal(bindings)
+	if err != nil {
+		return nil, err
+	}
+	g.Description = string(desc)
+	return client.GSLB.Update(g.ID, g)
+}
+
+func (c *gslbClient) Delete(instanceID string) error {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+	log.WithFields(logFields).Debug("IaaS delete GSLB start")
+
+	g, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+
+	strID := g.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	if _, err := c.getRawClient().GSLB.Delete(g.ID); err != nil {
+		return err
+	}
+
+	log.WithFields(logFields).Debug("IaaS delete GSLB finished")
+	return nil
+}
+
+// GSLBBindingServers returns IP addresses of servers keyed by bindings which registered them.
+// Servers have no description, so they are kept in the description of the GSLB
+func GSLBBindingServers(g *sacloud.GSLB) (map[string]string, error) {
+	bindings := map[string]string{}
+	if g == nil || g.Description == "" {
+		return bindings, nil
+	}
+	if err := json.Unmarshal([]byte(g.Description), &bindings); err != nil {
+		return nil, err
+	}
+	return bindings, nil
+}
+
+// ConfirmProvisioned removes the provisioning marker from the GSLB
+func (c *gslbClient) ConfirmProvisioned(instanceID string) error {
+	g, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+	if !g.HasTag(ProvisioningMarkerTag) {
+		return nil
+	}
+
+	strID := g.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	// refresh not to overwrite tags changed by other operations
+	g, err = client.GSLB.Read(g.ID)
+	if err != nil {
+		return err
+	}
+	g.RemoveTag(ProvisioningMarkerTag)
+	_, err = client.GSLB.Update(g.ID, g)
+	return err
+}
@@ -0,0 +1,193 @@
+package iaas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/api"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+)
+
+// NFSExportPath is the path which NFS appliances export
+const NFSExportPath = "/export"
+
+type nfsClient struct {
+	*client
+}
+
+func (c *nfsClient) List() ([]sacloud.NFS, error) {
+	client := c.getRawClient()
+	results, err := client.NFS.Reset().WithTag(markerTag).Limit(10000).Find()
+	if err != nil {
+		return nil, err
+	}
+	return results.NFS, nil
+}
+
+func (c *nfsClient) Read(instanceID string) (*sacloud.NFS, error) {
+	client := c.getRawClient()
+	results, err := client.NFS.Reset().WithNameLike(instanceID).Find()
+	if err != nil {
+		return nil, err
+	}
+	if len(results.NFS) == 0 {
+		return nil, api.NewError(http.StatusNotFound, &sacloud.ResultErrorValue{})
+	}
+
+	if len(results.NFS) > 1 {
+		return nil, errors.New("Multiple resources with the same instance ID is exists")
+	}
+
+	return &results.NFS[0], nil
+}
+
+func (c *nfsClient) Create(instanceID string, param *params.NFSCreateParameter) (*sacloud.NFS, error) {
+
+	client := c.getRawClient()
+
+	p := sacloud.NewCreateNFSValue()
+	p.Plan = sacloud.NFSPlan(param.PlanID)
+	p.SwitchID = fmt.Sprintf("%d", param.SwitchID)
+	p.IPAddress = param.IPAddress
+	p.MaskLen = int(param.MaskLen)
+	p.DefaultRoute = param.DefaultRoute
+
+	p.Tags = []string{markerTag, ProvisioningMarkerTag}
+
+	// keep the requested parameter for detecting drift
+	desired, err := json.Marshal(param)
+	if err != nil {
+		return nil, err
+	}
+	p.Description = string(desired)
+
+	p.Name = instanceID
+
+	return client.NFS.Create(sacloud.NewNFS(p))
+}
+
+func (c *nfsClient) Delete(instanceID string) error {
+
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+	log.WithFields(logFields).Debug("IaaS delete NFS start")
+
+	nfs, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+
+	// mark the resource so that the deletion can be resumed after broker crash.
+	// Failing to mark doesn't stop the deletion, the platform requests it again if it isn't completed
+	if !nfs.HasTag(DeletingMarkerTag) {
+		nfs.AppendTag(DeletingMarkerTag)
+		if _, err := c.getRawClient().NFS.Update(nfs.ID, nfs); err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Warn(
+				`IaaS delete instance: marking as deleting is failed`)
+		}
+	}
+
+	// the deletion is started again if it was stopped by errors
+	runInBackground("delete/"+nfs.GetStrID(), func() {
+		c.delete(instanceID, nfs.ID)
+	})
+	return nil
+}
+
+func (c *nfsClient) delete(instanceID string, id int64) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+
+	strID := fmt.Sprintf("%d", id)
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	nfs, err := client.NFS.Read(id)
+	if err != nil {
+		if e, ok := err.(api.Error); ok && e.ResponseCode() == http.StatusNotFound {
+			return
+		}
+
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			`IaaS delete NFS error: Reading NFS is failed`)
+		return
+	}
+
+	if nfs.IsMigrating() {
+		if err := client.NFS.SleepUntilUp(id, client.DefaultTimeoutDuration); err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Error(
+				`IaaS delete NFS error: migrate wait timed out`)
+			return
+		}
+	}
+
+	if nfs.IsUp() {
+		if _, err := client.NFS.Stop(id); err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Error(
+				`IaaS delete NFS error: error stopping NFS`)
+			return
+		}
+		if err := client.NFS.SleepUntilDown(id, client.DefaultTimeoutDuration); err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Error(
+				`IaaS delete NFS error: shutdown wait timed out`)
+			return
+		}
+	}
+
+	if _, err := client.NFS.Delete(id); err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			`IaaS delete NFS error: NFS Delete API is failed`)
+	}
+}
+
+// DesiredNFSParameter returns the parameter which was requested at the creation of the NFS
+func DesiredNFSParameter(nfs *sacloud.NFS) (*params.NFSCreateParameter, error) {
+	if nfs == nil || nfs.Description == "" {
+		return nil, nil
+	}
+	var p params.NFSCreateParameter
+	if err := json.Unmarshal([]byte(nfs.Description), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ConfirmProvisioned removes the provisioning marker from the NFS
+func (c *nfsClient) ConfirmProvisioned(instanceID string) error {
+	nfs, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+	if !nfs.HasTag(ProvisioningMarkerTag) {
+		return nil
+	}
+
+	strID := nfs.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	// refresh not to overwrite tags changed by other operations
+	nfs, err = client.NFS.Read(nfs.ID)
+	if err != nil {
+		return err
+	}
+	nfs.RemoveTag(ProvisioningMarkerTag)
+	_, err = client.NFS.Update(nfs.ID, nfs)
+	return err
+}
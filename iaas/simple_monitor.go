@@ -0,0 +1,280 @@
+package iaas
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/api"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+)
+
+// Health statuses of simple monitors
+const (
+	SimpleMonitorHealthUp      = "up"
+	SimpleMonitorHealthDown    = "down"
+	SimpleMonitorHealthUnknown = "unknown"
+)
+
+// SimpleMonitorHealth represents the latest result of health checks of the simple monitor
+type SimpleMonitorHealth struct {
+	Status          string     `json:"status"`
+	LastCheckedAt   *time.Time `json:"lastCheckedAt,omitempty"`
+	ResponseTimeSec *float64   `json:"responseTimeSec,omitempty"`
+}
+
+// simpleMonitorDescription is stored in the description of simple monitors.
+// Simple monitors are named with the target, so the instance ID is kept here
+type simpleMonitorDescription struct {
+	InstanceID string `json:"instanceID"`
+	*params.SimpleMonitorParameter
+}
+
+type simpleMonitorClient struct {
+	*client
+}
+
+func (c *simpleMonitorClient) List() ([]sacloud.SimpleMonitor, error) {
+	client := c.getRawClient()
+	results, err := client.SimpleMonitor.Reset().WithTag(markerTag).Limit(10000).Find()
+	if err != nil {
+		return nil, err
+	}
+	return results.SimpleMonitors, nil
+}
+
+func (c *simpleMonitorClient) Read(instanceID string) (*sacloud.SimpleMonitor, error) {
+	monitors, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []sacloud.SimpleMonitor
+	for _, m := range monitors {
+		desc, err := readSimpleMonitorDescription(&m)
+		if err != nil || desc == nil {
+			continue
+		}
+		if desc.InstanceID == instanceID {
+			found = append(found, m)
+		}
+	}
+	if len(found) == 0 {
+		return nil, api.NewError(http.StatusNotFound, &sacloud.ResultErrorValue{})
+	}
+	if len(found) > 1 {
+		return nil, errors.New("Multiple resources with the same instance ID is exists")
+	}
+	return &found[0], nil
+}
+
+func (c *simpleMonitorClient) Create(instanceID string, param *params.SimpleMonitorParameter) (*sacloud.SimpleMonitor, error) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+		"target":     param.Target,
+	}
+	log.WithFields(logFields).Debug("IaaS create SimpleMonitor start")
+
+	m := sacloud.CreateNewSimpleMonitor(param.Target)
+	if err := applySimpleMonitorParameter(m, instanceID, param); err != nil {
+		return nil, err
+	}
+	m.Tags = []string{markerTag, ProvisioningMarkerTag}
+
+	created, err := c.getRawClient().SimpleMonitor.Create(m)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(logFields).Debug("IaaS create SimpleMonitor finished")
+	return created, nil
+}
+
+// Update replaces all settings of the simple monitor with the parameter
+func (c *simpleMonitorClient) Update(instanceID string, param *params.SimpleMonitorParameter) (*sacloud.SimpleMonitor, error) {
+	m, err := c.Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	strID := m.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+	m, err = client.SimpleMonitor.Read(m.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applySimpleMonitorParameter(m, instanceID, param); err != nil {
+		return nil, err
+	}
+	return client.SimpleMonitor.Update(m.ID, m)
+}
+
+func (c *simpleMonitorClient) Delete(instanceID string) error {
+	m, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+	_, err = c.getRawClient().SimpleMonitor.Delete(m.ID)
+	return err
+}
+
+// Health returns the latest result of health checks.
+// Checks are regarded as failed if SAKURA Cloud doesn't report the response time of them
+func (c *simpleMonitorClient) Health(instanceID string) (*SimpleMonitorHealth, error) {
+	m, err := c.Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// read a few checks at least
+	window := monitorWindow
+	if s := m.Settings; s != nil && s.SimpleMonitor != nil {
+		if w := 3 * time.Duration(s.SimpleMonitor.DelayLoop) * time.Second; w > window {
+			window = w
+		}
+	}
+	end := time.Now()
+	start := end.Add(-window)
+	values, err := c.getRawClient().SimpleMonitor.MonitorResponseTimeSec(m.ID, sacloud.NewResourceMonitorRequest(&start, &end))
+	if err != nil {
+		return nil, err
+	}
+	return simpleMonitorHealth(values), nil
+}
+
+func simpleMonitorHealth(values *sacloud.MonitorValues) *SimpleMonitorHealth {
+	health := &SimpleMonitorHealth{Status: SimpleMonitorHealthUnknown}
+	if values == nil {
+		return health
+	}
+
+	var latest time.Time
+	var latestValue *sacloud.MonitorValue
+	for key, v := range *values {
+		t, err := time.Parse(time.RFC3339, key)
+		if err != nil {
+			continue
+		}
+		if latestValue == nil || t.After(latest) {
+			latest = t
+			latestValue = v
+			if latestValue == nil {
+				latestValue = &sacloud.MonitorValue{}
+			}
+		}
+	}
+	if latestValue == nil {
+		return health
+	}
+
+	health.LastCheckedAt = &latest
+	health.ResponseTimeSec = latestValue.ResponseTimeSec
+	health.Status = SimpleMonitorHealthDown
+	if latestValue.ResponseTimeSec != nil {
+		health.Status = SimpleMonitorHealthUp
+	}
+	return health
+}
+
+func applySimpleMonitorParameter(m *sacloud.SimpleMonitor, instanceID string, param *params.SimpleMonitorParameter) error {
+	desc, err := json.Marshal(&simpleMonitorDescription{InstanceID: instanceID, SimpleMonitorParameter: param})
+	if err != nil {
+		return err
+	}
+	m.Description = string(desc)
+	m.SetTarget(param.Target)
+
+	var port, status string
+	if param.Port > 0 {
+		port = strconv.Itoa(param.Port)
+	}
+	if param.ExpectedStatus > 0 {
+		status = strconv.Itoa(param.ExpectedStatus)
+	}
+	switch param.Protocol {
+	case "http":
+		m.SetHealthCheckHTTP(port, param.Path, status, param.HostHeader)
+	case "https":
+		m.SetHealthCheckHTTPS(port, param.Path, status, param.HostHeader)
+	case "tcp":
+		m.SetHealthCheckTCP(port)
+	case "ping":
+		m.SetHealthCheckPing()
+	}
+
+	m.SetDelayLoop(param.Interval)
+	if param.NotifyEmail {
+		m.EnableNotifyEmail(false)
+	} else {
+		m.DisableNotifyEmail()
+	}
+	if param.WebhookURL != "" {
+		m.EnableNofitySlack(param.WebhookURL)
+	} else {
+		m.DisableNotifySlack()
+	}
+	return nil
+}
+
+// DesiredSimpleMonitorParameter returns the parameter which was requested at the last creation or update
+func DesiredSimpleMonitorParameter(m *sacloud.SimpleMonitor) (*params.SimpleMonitorParameter, error) {
+	desc, err := readSimpleMonitorDescription(m)
+	if err != nil || desc == nil {
+		return nil, err
+	}
+	return desc.SimpleMonitorParameter, nil
+}
+
+// SimpleMonitorInstanceID returns the instance ID of the simple monitor.
+// It returns empty if the simple monitor isn't created by the broker
+func SimpleMonitorInstanceID(m *sacloud.SimpleMonitor) string {
+	desc, err := readSimpleMonitorDescription(m)
+	if err != nil || desc == nil {
+		return ""
+	}
+	return desc.InstanceID
+}
+
+func readSimpleMonitorDescription(m *sacloud.SimpleMonitor) (*simpleMonitorDescription, error) {
+	if m == nil || m.Description == "" {
+		return nil, nil
+	}
+	var desc simpleMonitorDescription
+	if err := json.Unmarshal([]byte(m.Description), &desc); err != nil {
+		return nil, err
+	}
+	return &desc, nil
+}
+
+// ConfirmProvisioned removes the provisioning marker from the simple monitor
+func (c *simpleMonitorClient) ConfirmProvisioned(instanceID string) error {
+	m, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+	if !m.HasTag(ProvisioningMarkerTag) {
+		return nil
+	}
+
+	strID := m.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	// refresh not to overwrite tags changed by other operations
+	m, err = client.SimpleMonitor.Read(m.ID)
+	if err != nil {
+		return err
+	}
+	m.RemoveTag(ProvisioningMarkerTag)
+	_, err = client.SimpleMonitor.Update(m.ID, m)
+	return err
+}
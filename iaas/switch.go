@@ -0,0 +1,391 @@
+package iaas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/api"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+)
+
+const (
+	// SwitchConnectingMarkerTag is the tag which is added to switches while they are connected to VPC routers
+	SwitchConnectingMarkerTag = markerTag + "-connecting"
+	// SwitchFailedMarkerTag is the tag which is added to switches that connecting to VPC routers is failed
+	SwitchFailedMarkerTag = markerTag + "-failed"
+)
+
+type switchClient struct {
+	*client
+}
+
+func (c *switchClient) List() ([]sacloud.Switch, error) {
+	client := c.getRawClient()
+	results, err := client.Switch.Reset().WithTag(markerTag).Limit(10000).Find()
+	if err != nil {
+		return nil, err
+	}
+	return results.Switches, nil
+}
+
+func (c *switchClient) Read(instanceID string) (*sacloud.Switch, error) {
+	client := c.getRawClient()
+	results, err := client.Switch.Reset().WithNameLike(instanceID).Find()
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Switches) == 0 {
+		return nil, api.NewError(http.StatusNotFound, &sacloud.ResultErrorValue{})
+	}
+
+	if len(results.Switches) > 1 {
+		return nil, errors.New("Multiple resources with the same instance ID is exists")
+	}
+
+	return &results.Switches[0], nil
+}
+
+func (c *switchClient) Create(instanceID string, param *params.SwitchCreateParameter) (*sacloud.Switch, error) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+		"subnet":     param.Subnet,
+	}
+	log.WithFields(logFields).Debug("IaaS create Switch start")
+
+	client := c.getRawClient()
+
+	// check the VPC router before creating the switch not to leave a failed switch
+	if param.VPCRouterID != 0 {
+		router, err := client.VPCRouter.Read(param.VPCRouterID)
+		if err != nil {
+			return nil, fmt.Errorf("reading the VPC router is failed: %s", err)
+		}
+		if err := checkVPCRouterUpdatable(router, param.AllowRouterRestart); err != nil {
+			return nil, err
+		}
+	}
+
+	// keep the requested parameter for binding credentials and detecting drift
+	desired, err := json.Marshal(param)
+	if err != nil {
+		return nil, err
+	}
+
+	sw := client.Switch.New()
+	sw.Name = instanceID
+	sw.Description = string(desired)
+	sw.Tags = []string{markerTag, ProvisioningMarkerTag}
+	if param.VPCRouterID != 0 {
+		sw.AppendTag(SwitchConnectingMarkerTag)
+	}
+	sw.UserSubnet = &sacloud.Subnet{
+		DefaultRoute:   param.Gateway,
+		NetworkMaskLen: param.MaskLen(),
+	}
+
+	created, err := client.Switch.Create(sw)
+	if err != nil {
+		return nil, err
+	}
+
+	if param.BridgeID != 0 {
+		if _, err := client.Switch.ConnectToBridge(created.ID, param.BridgeID); err != nil {
+			// the switch is useless without the bridge
+			if _, e := client.Switch.Delete(created.ID); e != nil {
+				logFields["err"] = e
+				log.WithFields(logFields).Error("IaaS create Switch error: deleting the switch is failed")
+			}
+			return nil, fmt.Errorf("connecting to the bridge is failed: %s", err)
+		}
+	}
+
+	// VPC routers have to be stopped to connect, so it's done in background.
+	// It's resumed by ResumeConnecting if the broker stops before the completion
+	if param.VPCRouterID != 0 {
+		runInBackground("connect/"+created.GetStrID(), func() {
+			c.connectVPCRouter(instanceID, created.ID, param)
+		})
+	}
+
+	log.WithFields(logFields).Debug("IaaS create Switch finished")
+	return created, nil
+}
+
+// ResumeConnecting connects switches which keep the connecting marker to VPC routers.
+// It's called on startup to resume connections stopped by broker crash
+func (c *switchClient) ResumeConnecting() error {
+	switches, err := c.List()
+	if err != nil {
+		return err
+	}
+
+	for i := range switches {
+		sw := switches[i]
+		if !sw.HasTag(SwitchConnectingMarkerTag) || sw.HasTag(DeletingMarkerTag) {
+			continue
+		}
+		logFields := log.Fields{
+			"instanceID": sw.Name,
+		}
+
+		param, err := DesiredSwitchParameter(&sw)
+		if err != nil || param == nil || param.VPCRouterID == 0 {
+			logFields["err"] = err
+			log.WithFields(logFields).Error(
+				`IaaS resume Switch error: reading requested parameter is failed`)
+			continue
+		}
+
+		log.WithFields(logFields).Info("IaaS resume Switch: connecting to the VPC router")
+		runInBackground("connect/"+sw.GetStrID(), func() {
+			c.connectVPCRouter(sw.Name, sw.ID, param)
+		})
+	}
+	return nil
+}
+
+func (c *switchClient) connectVPCRouter(instanceID string, switchID int64, param *params.SwitchCreateParameter) {
+	logFields := log.Fields{
+		"instanceID":  instanceID,
+		"vpcRouterID": param.VPCRouterID,
+	}
+
+	// the switch may be connected already if the connection is resumed
+	notConnected := func(router *sacloud.VPCRouter) bool {
+		return vpcRouterInterfaceIndex(router, switchID) < 0
+	}
+	err := c.updateVPCRouter(param.VPCRouterID, param.AllowRouterRestart, notConnected, func(client *api.Client, router *sacloud.VPCRouter) error {
+		_, err := client.VPCRouter.AddStandardInterface(router.ID, switchID, param.Gateway, param.MaskLen())
+		return err
+	})
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			`IaaS create Switch error: connecting to the VPC router is failed`)
+	}
+
+	strID := fmt.Sprintf("%d", switchID)
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+	sw, e := client.Switch.Read(switchID)
+	if e != nil {
+		logFields["err"] = e
+		log.WithFields(logFields).Error(
+			`IaaS create Switch error: Reading Switch is failed`)
+		return
+	}
+	sw.RemoveTag(SwitchConnectingMarkerTag)
+	if err != nil {
+		sw.AppendTag(SwitchFailedMarkerTag)
+	}
+	if _, e := client.Switch.Update(sw.ID, sw); e != nil {
+		logFields["err"] = e
+		log.WithFields(logFields).Error(
+			`IaaS create Switch error: updating tags of Switch is failed`)
+	}
+}
+
+// updateVPCRouter stops the VPC router to change interfaces, and boots it again if it was running.
+// Running routers are refused unless allowRestart is true. Nothing is done if required returns false
+func (c *switchClient) updateVPCRouter(routerID int64, allowRestart bool, required func(router *sacloud.VPCRouter) bool,
+	update func(client *api.Client, router *sacloud.VPCRouter) error) error {
+
+	strID := fmt.Sprintf("%d", routerID)
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+	router, err := client.VPCRouter.Read(routerID)
+	if err != nil {
+		return err
+	}
+	if !required(router) {
+		return nil
+	}
+	if err := checkVPCRouterUpdatable(router, allowRestart); err != nil {
+		return err
+	}
+
+	running := router.IsUp()
+	if running {
+		if _, err := client.VPCRouter.Shutdown(routerID); err != nil {
+			return err
+		}
+		if err := client.VPCRouter.SleepUntilDown(routerID, client.DefaultTimeoutDuration); err != nil {
+			return err
+		}
+	}
+
+	err = update(client, router)
+	if err == nil {
+		_, err = client.VPCRouter.Config(routerID)
+	}
+
+	if running {
+		if _, e := client.VPCRouter.Boot(routerID); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// checkVPCRouterUpdatable returns error if the broker can't change interfaces of the VPC router.
+// Routers may not be owned by the broker, so running routers are restarted only if it's allowed
+func checkVPCRouterUpdatable(router *sacloud.VPCRouter, allowRestart bool) error {
+	if !router.IsStandardPlan() {
+		return errors.New("only VPC routers of the standard plan are supported")
+	}
+	if router.IsUp() && !allowRestart {
+		return fmt.Errorf("the VPC router %d is running: stop it or set %q to restart it", router.ID, "allowRouterRestart")
+	}
+	return nil
+}
+
+// vpcRouterInterfaceIndex returns the index of the interface connected to the switch, or -1 if not connected
+func vpcRouterInterfaceIndex(router *sacloud.VPCRouter, switchID int64) int {
+	for i, nic := range router.Interfaces {
+		if nic.Switch != nil && nic.Switch.ID == switchID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *switchClient) Delete(instanceID string) error {
+
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+	log.WithFields(logFields).Debug("IaaS delete Switch start")
+
+	sw, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+
+	// mark the resource so that the deletion can be resumed after broker crash.
+	// Failing to mark doesn't stop the deletion, the platform requests it again if it isn't completed
+	if !sw.HasTag(DeletingMarkerTag) {
+		sw.AppendTag(DeletingMarkerTag)
+		if _, err := c.getRawClient().Switch.Update(sw.ID, sw); err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Warn(
+				`IaaS delete instance: marking as deleting is failed`)
+		}
+	}
+
+	// the deletion is started again if it was stopped by errors
+	runInBackground("delete/"+sw.GetStrID(), func() {
+		c.delete(instanceID, sw.ID)
+	})
+	return nil
+}
+
+func (c *switchClient) delete(instanceID string, id int64) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+
+	strID := fmt.Sprintf("%d", id)
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	sw, err := client.Switch.Read(id)
+	if err != nil {
+		if e, ok := err.(api.Error); ok && e.ResponseCode() == http.StatusNotFound {
+			return
+		}
+
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			`IaaS delete Switch error: Reading Switch is failed`)
+		return
+	}
+
+	desired, err := DesiredSwitchParameter(sw)
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			`IaaS delete Switch error: reading requested parameter is failed`)
+		return
+	}
+
+	if desired != nil && desired.VPCRouterID != 0 {
+		connected := func(router *sacloud.VPCRouter) bool {
+			return vpcRouterInterfaceIndex(router, id) >= 0
+		}
+		err := c.updateVPCRouter(desired.VPCRouterID, desired.AllowRouterRestart, connected, func(client *api.Client, router *sacloud.VPCRouter) error {
+			_, err := client.VPCRouter.DeleteInterfaceAt(router.ID, vpcRouterInterfaceIndex(router, id))
+			return err
+		})
+		if err != nil {
+			if e, ok := err.(api.Error); !ok || e.ResponseCode() != http.StatusNotFound {
+				logFields["err"] = err
+				log.WithFields(logFields).Error(
+					`IaaS delete Switch error: disconnecting from the VPC router is failed`)
+				return
+			}
+		}
+	}
+
+	if sw.Bridge != nil {
+		if _, err := client.Switch.DisconnectFromBridge(id); err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Error(
+				`IaaS delete Switch error: disconnecting from the bridge is failed`)
+			return
+		}
+	}
+
+	if _, err := client.Switch.Delete(id); err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			`IaaS delete Switch error: Switch Delete API is failed`)
+	}
+}
+
+// DesiredSwitchParameter returns the parameter which was requested at the creation of the switch
+func DesiredSwitchParameter(sw *sacloud.Switch) (*params.SwitchCreateParameter, error) {
+	if sw == nil || sw.Description == "" {
+		return nil, nil
+	}
+	var p params.SwitchCreateParameter
+	if err := json.Unmarshal([]byte(sw.Description), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ConfirmProvisioned removes the provisioning marker from the Switch
+func (c *switchClient) ConfirmProvisioned(instanceID string) error {
+	sw, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+	if !sw.HasTag(ProvisioningMarkerTag) {
+		return nil
+	}
+
+	strID := sw.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	// refresh not to overwrite tags changed by other operations
+	sw, err = client.Switch.Read(sw.ID)
+	if err != nil {
+		return err
+	}
+	sw.RemoveTag(ProvisioningMarkerTag)
+	_, err = client.Switch.Update(sw.ID, sw)
+	return err
+}
@@ -0,0 +1,440 @@
+package iaas
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/api"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+)
+
+const (
+	// VPCRouterConfiguringMarkerTag is the tag which is added to VPC routers while interfaces and settings are applied
+	VPCRouterConfiguringMarkerTag = markerTag + "-configuring"
+	// VPCRouterFailedMarkerTag is the tag which is added to VPC routers that configuring is failed
+	VPCRouterFailedMarkerTag = markerTag + "-failed"
+
+	vpcRouterCopyingMaxRetry = 10
+)
+
+type vpcRouterClient struct {
+	*client
+}
+
+func (c *vpcRouterClient) List() ([]sacloud.VPCRouter, error) {
+	client := c.getRawClient()
+	results, err := client.VPCRouter.Reset().WithTag(markerTag).Limit(10000).Find()
+	if err != nil {
+		return nil, err
+	}
+	return results.VPCRouters, nil
+}
+
+func (c *vpcRouterClient) Read(instanceID string) (*sacloud.VPCRouter, error) {
+	client := c.getRawClient()
+	results, err := client.VPCRouter.Reset().WithNameLike(instanceID).Find()
+	if err != nil {
+		return nil, err
+	}
+	if len(results.VPCRouters) == 0 {
+		return nil, api.NewError(http.StatusNotFound, &sacloud.ResultErrorValue{})
+	}
+
+	if len(results.VPCRouters) > 1 {
+		return nil, errors.New("Multiple resources with the same instance ID is exists")
+	}
+
+	return &results.VPCRouters[0], nil
+}
+
+func (c *vpcRouterClient) Create(instanceID string, param *params.VPCRouterCreateParameter) (*sacloud.VPCRouter, error) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+		"plan":       param.PlanID,
+	}
+	log.WithFields(logFields).Debug("IaaS create VPCRouter start")
+
+	client := c.getRawClient()
+
+	router := client.VPCRouter.New()
+	router.Name = instanceID
+	router.Tags = []string{markerTag, ProvisioningMarkerTag, VPCRouterConfiguringMarkerTag}
+
+	switch param.PlanID {
+	case params.VPCRouterPlanStandard:
+		router.SetStandardPlan()
+	case params.VPCRouterPlanPremium, params.VPCRouterPlanHighSpec:
+		setPlan := router.SetPremiumPlan
+		if param.PlanID == params.VPCRouterPlanHighSpec {
+			setPlan = router.SetHighSpecPlan
+		}
+		setPlan(
+			fmt.Sprintf("%d", param.PublicSwitchID),
+			param.PublicVirtualIPAddress,
+			param.PublicIPAddresses[0],
+			param.PublicIPAddresses[1],
+			param.VRID,
+			param.IPAliases,
+		)
+	default:
+		return nil, fmt.Errorf("unknown VPC router plan: %d", param.PlanID)
+	}
+
+	created, err := client.VPCRouter.Create(router)
+	if err != nil {
+		return nil, err
+	}
+
+	// interfaces can be connected after the copy of the router is finished, so it's done in background.
+	// It's resumed by ResumeConfiguring if the broker stops before the completion
+	runInBackground("configure/"+created.GetStrID(), func() {
+		c.configure(instanceID, created.ID, param)
+	})
+
+	log.WithFields(logFields).Debug("IaaS create VPCRouter finished")
+	return created, nil
+}
+
+// configure connects interfaces, applies the configuration and boots the created VPC router
+func (c *vpcRouterClient) configure(instanceID string, id int64, param *params.VPCRouterCreateParameter) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+
+	strID := fmt.Sprintf("%d", id)
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	err := func() error {
+		if err := client.VPCRouter.SleepWhileCopying(id, client.DefaultTimeoutDuration, vpcRouterCopyingMaxRetry); err != nil {
+			return fmt.Errorf("waiting for the copy is failed: %s", err)
+		}
+
+		for i, nic := range param.Interfaces {
+			if _, err := client.VPCRouter.ConnectToSwitch(id, nic.SwitchID, i+1); err != nil {
+				return fmt.Errorf("connecting to switch %d is failed: %s", nic.SwitchID, err)
+			}
+		}
+
+		router, err := client.VPCRouter.Read(id)
+		if err != nil {
+			return err
+		}
+		if !router.HasSetting() {
+			router.Settings = &sacloud.VPCRouterSettings{Router: &sacloud.VPCRouterSetting{}}
+		}
+		setting := router.Settings.Router
+		if len(setting.Interfaces) == 0 {
+			// the public interface of the standard plan has no setting
+			setting.Interfaces = []*sacloud.VPCRouterInterface{nil}
+		}
+		setting.Interfaces = setting.Interfaces[:1]
+		for _, nic := range param.Interfaces {
+			setting.Interfaces = append(setting.Interfaces, vpcRouterInterface(param, &nic))
+		}
+		applyVPCRouterConfig(router, &param.VPCRouterConfigParameter)
+
+		if _, err := client.VPCRouter.UpdateSetting(id, router); err != nil {
+			return fmt.Errorf("updating settings is failed: %s", err)
+		}
+		return applyAndBootVPCRouter(client, id)
+	}()
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			`IaaS create VPCRouter error: configuring VPCRouter is failed`)
+	}
+	c.finishConfiguring(instanceID, id, err)
+}
+
+// ResumeConfiguring resumes configuring VPC routers which keep the configuring marker.
+// It's called on startup to resume configurations stopped by broker crash.
+// The requested parameter isn't kept in the router, so routers which settings are saved are booted,
+// and the others are marked as failed
+func (c *vpcRouterClient) ResumeConfiguring() error {
+	routers, err := c.List()
+	if err != nil {
+		return err
+	}
+
+	for i := range routers {
+		router := routers[i]
+		if !router.HasTag(VPCRouterConfiguringMarkerTag) || router.HasTag(DeletingMarkerTag) {
+			continue
+		}
+		logFields := log.Fields{
+			"instanceID": router.Name,
+		}
+		log.WithFields(logFields).Info("IaaS resume VPCRouter: configuring is resumed")
+
+		runInBackground("configure/"+router.GetStrID(), func() {
+			strID := router.GetStrID()
+			mutex.Lock(strID)
+			defer mutex.Unlock(strID)
+
+			var err error
+			if vpcRouterSettingSaved(&router) {
+				err = applyAndBootVPCRouter(c.getRawClient(), router.ID)
+			} else {
+				err = errors.New("the requested parameter is lost before settings are saved")
+			}
+			if err != nil {
+				logFields["err"] = err
+				log.WithFields(logFields).Error(
+					`IaaS resume VPCRouter error: configuring VPCRouter is failed`)
+			}
+			c.finishConfiguring(router.Name, router.ID, err)
+		})
+	}
+	return nil
+}
+
+// vpcRouterSettingSaved returns true if settings of private interfaces are saved by configure
+func vpcRouterSettingSaved(router *sacloud.VPCRouter) bool {
+	return router.HasSetting() && len(router.Settings.Router.Interfaces) > 1
+}
+
+// applyAndBootVPCRouter applies saved settings and boots the VPC router
+func applyAndBootVPCRouter(client *api.Client, id int64) error {
+	if _, err := client.VPCRouter.Config(id); err != nil {
+		return fmt.Errorf("applying settings is failed: %s", err)
+	}
+	router, err := client.VPCRouter.Read(id)
+	if err != nil {
+		return err
+	}
+	if !router.IsUp() {
+		if _, err := client.VPCRouter.Boot(id); err != nil {
+			return fmt.Errorf("booting is failed: %s", err)
+		}
+	}
+	return client.VPCRouter.SleepUntilUp(id, client.DefaultTimeoutDuration)
+}
+
+// finishConfiguring removes the configuring marker, and marks the VPC router as failed if err is not nil.
+// The caller has to lock the VPC router
+func (c *vpcRouterClient) finishConfiguring(instanceID string, id int64, err error) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+	client := c.getRawClient()
+
+	router, e := client.VPCRouter.Read(id)
+	if e != nil {
+		logFields["err"] = e
+		log.WithFields(logFields).Error(
+			`IaaS create VPCRouter error: Reading VPCRouter is failed`)
+		return
+	}
+	router.RemoveTag(VPCRouterConfiguringMarkerTag)
+	if err != nil {
+		router.AppendTag(VPCRouterFailedMarkerTag)
+	}
+	if _, e := client.VPCRouter.Update(router.ID, router); e != nil {
+		logFields["err"] = e
+		log.WithFields(logFields).Error(
+			`IaaS create VPCRouter error: updating tags of VPCRouter is failed`)
+	}
+}
+
+func vpcRouterInterface(param *params.VPCRouterCreateParameter, nic *params.VPCRouterInterfaceParameter) *sacloud.VPCRouterInterface {
+	if param.IsStandardPlan() {
+		return &sacloud.VPCRouterInterface{
+			IPAddress:      []string{nic.IPAddress},
+			NetworkMaskLen: nic.MaskLen,
+		}
+	}
+	return &sacloud.VPCRouterInterface{
+		IPAddress:        nic.IPAddresses,
+		NetworkMaskLen:   nic.MaskLen,
+		VirtualIPAddress: nic.VirtualIPAddress,
+	}
+}
+
+// applyVPCRouterConfig replaces the configuration of the VPC router except interfaces
+func applyVPCRouterConfig(router *sacloud.VPCRouter, config *params.VPCRouterConfigParameter) {
+	router.InitVPCRouterSetting()
+	setting := router.Settings.Router
+
+	for _, nat := range config.StaticNAT {
+		setting.AddStaticNAT(nat.GlobalAddress, nat.PrivateAddress, nat.Description)
+	}
+	for _, pf := range config.PortForwarding {
+		setting.AddPortForwarding(pf.Protocol, strconv.Itoa(pf.GlobalPort), pf.PrivateAddress,
+			strconv.Itoa(pf.PrivatePort), pf.Description)
+	}
+	for _, rule := range config.Firewall {
+		addRule := setting.AddFirewallRuleReceive
+		if rule.Direction == "send" {
+			addRule = setting.AddFirewallRuleSend
+		}
+		addRule(rule.Interface, rule.Action == "allow", rule.Protocol,
+			rule.SourceNetwork, rule.SourcePort, rule.DestinationNetwork, rule.DestinationPort,
+			rule.Logging, rule.Description)
+	}
+	if vpn := config.SiteToSiteVPN; vpn != nil {
+		setting.AddSiteToSiteIPsecVPN(vpn.LocalPrefix, vpn.Peer, vpn.PreSharedSecret, vpn.RemoteID, vpn.Routes)
+	}
+}
+
+// Update replaces the configuration of the VPC router, and applies it
+func (c *vpcRouterClient) Update(instanceID string, config *params.VPCRouterConfigParameter) (*sacloud.VPCRouter, error) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+	log.WithFields(logFields).Debug("IaaS update VPCRouter start")
+
+	router, err := c.Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	strID := router.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	// read again because the configuration may be applied while waiting for the lock
+	router, err = client.VPCRouter.Read(router.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	applyVPCRouterConfig(router, config)
+	updated, err := client.VPCRouter.UpdateSetting(router.ID, router)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.VPCRouter.Config(router.ID); err != nil {
+		return nil, err
+	}
+
+	log.WithFields(logFields).Debug("IaaS update VPCRouter finished")
+	return updated, nil
+}
+
+// SiteToSiteConnectionDetails returns the parameters of site-to-site VPN connections of the VPC router
+func (c *vpcRouterClient) SiteToSiteConnectionDetails(instanceID string) (*sacloud.SiteToSiteConnectionInfo, error) {
+	router, err := c.Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return c.getRawClient().VPCRouter.SiteToSiteConnectionDetails(router.ID)
+}
+
+func (c *vpcRouterClient) Delete(instanceID string) error {
+
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+	log.WithFields(logFields).Debug("IaaS delete VPCRouter start")
+
+	router, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+
+	// mark the resource so that the deletion can be resumed after broker crash.
+	// Failing to mark doesn't stop the deletion, the platform requests it again if it isn't completed
+	if !router.HasTag(DeletingMarkerTag) {
+		router.AppendTag(DeletingMarkerTag)
+		if _, err := c.getRawClient().VPCRouter.Update(router.ID, router); err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Warn(
+				`IaaS delete instance: marking as deleting is failed`)
+		}
+	}
+
+	// the deletion is started again if it was stopped by errors
+	runInBackground("delete/"+router.GetStrID(), func() {
+		c.delete(instanceID, router.ID)
+	})
+	return nil
+}
+
+func (c *vpcRouterClient) delete(instanceID string, id int64) {
+	logFields := log.Fields{
+		"instanceID": instanceID,
+	}
+
+	strID := fmt.Sprintf("%d", id)
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	router, err := client.VPCRouter.Read(id)
+	if err != nil {
+		if e, ok := err.(api.Error); ok && e.ResponseCode() == http.StatusNotFound {
+			return
+		}
+
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			`IaaS delete VPCRouter error: Reading VPCRouter is failed`)
+		return
+	}
+
+	if router.IsMigrating() {
+		if err := client.VPCRouter.SleepWhileCopying(id, client.DefaultTimeoutDuration, vpcRouterCopyingMaxRetry); err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Error(
+				`IaaS delete VPCRouter error: copy wait timed out`)
+			return
+		}
+	}
+
+	if router.IsUp() {
+		if _, err := client.VPCRouter.Stop(id); err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Error(
+				`IaaS delete VPCRouter error: error stopping VPCRouter`)
+			return
+		}
+		if err := client.VPCRouter.SleepUntilDown(id, client.DefaultTimeoutDuration); err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Error(
+				`IaaS delete VPCRouter error: shutdown wait timed out`)
+			return
+		}
+	}
+
+	if _, err := client.VPCRouter.Delete(id); err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Error(
+			`IaaS delete VPCRouter error: VPCRouter Delete API is failed`)
+	}
+}
+
+// ConfirmProvisioned removes the provisioning marker from the VPCRouter
+func (c *vpcRouterClient) ConfirmProvisioned(instanceID string) error {
+	router, err := c.Read(instanceID)
+	if err != nil {
+		return err
+	}
+	if !router.HasTag(ProvisioningMarkerTag) {
+		return nil
+	}
+
+	strID := router.GetStrID()
+	mutex.Lock(strID)
+	defer mutex.Unlock(strID)
+
+	client := c.getRawClient()
+
+	// refresh not to overwrite tags changed by other operations
+	router, err = client.VPCRouter.Read(router.ID)
+	if err != nil {
+		return err
+	}
+	router.RemoveTag(ProvisioningMarkerTag)
+	_, err = client.VPCRouter.Update(router.ID, router)
+	return err
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"strings"
@@ -33,6 +34,7 @@ func main() {
 		Version:               version.FullVersion(),
 		CommandNotFound:       cmdNotFound,
 		Flags:                 cliFlags,
+		Commands:              cliCommands,
 		Action:                cmdMain,
 	}
 	cli.InitCompletionFlag.Hidden = true
@@ -64,20 +66,7 @@ func cmdMain(c *cli.Context) error {
 	}
 
 	// Initialize log setting
-	log.SetOutput(os.Stdout)
-	formatter := &log.TextFormatter{
-		FullTimestamp: true,
-	}
-	log.SetFormatter(formatter)
-
-	logLevel := log.InfoLevel
-	switch cfg.LogLevel {
-	case "WARN":
-		logLevel = log.WarnLevel
-	case "DEBUG":
-		logLevel = log.DebugLevel
-	}
-	log.SetLevel(logLevel)
+	initLogger(os.Stdout)
 
 	log.WithFields(
 		log.Fields{
@@ -85,21 +74,16 @@ func cmdMain(c *cli.Context) error {
 		},
 	).Info("Start Open Service Broker for SAKURA Cloud")
 
-	// prepare SAKURA cloud API client
-	sacloudAPI := iaas.NewClient(&iaas.ClientConfig{
-		AccessToken:       cfg.AccessToken,
-		AccessTokenSecret: cfg.AccessTokenSecret,
-		Zone:              cfg.Zone,
-		AcceptLanguage:    cfg.AcceptLanguage,
-		RetryMax:          cfg.RetryMax,
-		RetryIntervalSec:  cfg.RetryIntervalSec,
-		APIRootURL:        cfg.APIRootURL,
-		TraceMode:         cfg.TraceMode,
-	})
-	err := service.Initialize(sacloudAPI)
+	err := initService()
 	if err != nil {
 		return err
 	}
+	if cfg.KnownInstancesFile != "" {
+		if err := service.LoadKnownInstances(cfg.KnownInstancesFile, true); err != nil {
+			return err
+		}
+	}
+	service.ResumeOperations()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -118,9 +102,19 @@ func cmdMain(c *cli.Context) error {
 
 	// Start broker(s)
 	brokerCfg := &broker.Config{
-		Port:              8080, // TODO make configurable
-		BasicAuthUsername: cfg.BasicAuthUsername,
-		BasicAuthPassword: cfg.BasicAuthPassword,
+		Port:               8080, // TODO make configurable
+		BasicAuthUsername:  cfg.BasicAuthUsername,
+		BasicAuthPassword:  cfg.BasicAuthPassword,
+		CredentialsFile:    cfg.CredentialsFile,
+		JWKSFile:           cfg.JWKSFile,
+		JWTIssuer:          cfg.JWTIssuer,
+		JWTAudience:        cfg.JWTAudience,
+		AuthReloadInterval: cfg.AuthReloadInterval,
+		OrphanTimeout:      cfg.OrphanTimeout,
+		ReconcileInterval:  cfg.ReconcileInterval,
+		ReconcileRemediate: cfg.ReconcileRemediate,
+		UsageInterval:      cfg.UsageInterval,
+		PublicMetrics:      cfg.PublicMetrics,
 	}
 	b := broker.NewBroker(brokerCfg)
 	if err := b.Start(ctx); err != nil {
@@ -135,6 +129,61 @@ func cmdMain(c *cli.Context) error {
 	return nil
 }
 
+func initLogger(out io.Writer) {
+	log.SetOutput(out)
+	formatter := &log.TextFormatter{
+		FullTimestamp: true,
+	}
+	log.SetFormatter(formatter)
+
+	logLevel := log.InfoLevel
+	switch cfg.LogLevel {
+	case "WARN":
+		logLevel = log.WarnLevel
+	case "DEBUG":
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+}
+
+func initService() error {
+	// prepare SAKURA cloud API client
+	sacloudAPI := iaas.NewClient(&iaas.ClientConfig{
+		AccessToken:       cfg.AccessToken,
+		AccessTokenSecret: cfg.AccessTokenSecret,
+		Zone:              cfg.Zone,
+		AcceptLanguage:    cfg.AcceptLanguage,
+		RetryMax:          cfg.RetryMax,
+		RetryIntervalSec:  cfg.RetryIntervalSec,
+		APIRootURL:        cfg.APIRootURL,
+		TraceMode:         cfg.TraceMode,
+	})
+
+	if cfg.DBPlanConfigFile != "" {
+		if err := service.LoadDatabasePlanConfigs(cfg.DBPlanConfigFile); err != nil {
+			return err
+		}
+	}
+	if cfg.CredentialFormats != "" {
+		if err := service.LoadCredentialTemplates(cfg.CredentialFormats); err != nil {
+			return err
+		}
+	}
+	if cfg.PasswordSecret != "" {
+		var previousSecrets []string
+		if cfg.PreviousPasswordSecrets != "" {
+			previousSecrets = strings.Split(cfg.PreviousPasswordSecrets, ",")
+		}
+		if err := service.SetPasswordSecrets(cfg.PasswordSecret, previousSecrets...); err != nil {
+			return err
+		}
+	} else {
+		log.Warn("--password-secret is not set: bindings of PostgreSQL instances are not available")
+	}
+	service.DNSParentZone = cfg.DNSParentZone
+	return service.Initialize(sacloudAPI)
+}
+
 func flattenErrors(errors ...error) error {
 	if len(errors) == 0 {
 		return nil
@@ -143,5 +192,5 @@ func flattenErrors(errors ...error) error {
 	for _, str := range errors {
 		list = append(list, str.Error())
 	}
-	return fmt.Errorf(strings.Join(list, "\n"))
+	return fmt.Errorf("%s", strings.Join(list, "\n"))
 }
@@ -12,6 +12,9 @@ type Service struct {
 	DashboardClient *DashboardClient `json:"dashboard_client,omitempty"`
 	PlanUpdateable  bool             `json:"plan_updateable,omitempty"` // nolint
 	Plans           []*Plan          `json:"plans"`
+
+	InstancesRetrievable bool `json:"instances_retrievable,omitempty"`
+	BindingsRetrievable  bool `json:"bindings_retrievable,omitempty"`
 }
 
 // FindPlan returns a plan with the specified ID
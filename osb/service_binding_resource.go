@@ -0,0 +1,14 @@
+package osb
+
+// ServiceBindingResource represents object of OpenServiceBroker API
+type ServiceBindingResource struct {
+	Credentials interface{} `json:"credentials,omitempty"`
+
+	SyslogDrainURL string `json:"syslog_drain_url,omitempty"`
+
+	RouteServiceURL string `json:"route_service_url,omitempty"`
+
+	VolumeMounts *[]ServiceBindingVolumeMount `json:"volume_mounts,omitempty"`
+
+	Parameters interface{} `json:"parameters,omitempty"`
+}
@@ -0,0 +1,11 @@
+package osb
+
+// InstanceInUseError represents the error that the instance can't be deprovisioned because other resources use it
+type InstanceInUseError struct {
+	Description string
+}
+
+// Error implements error interface
+func (e *InstanceInUseError) Error() string {
+	return "Instance is in use: " + e.Description
+}
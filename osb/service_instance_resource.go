@@ -0,0 +1,9 @@
+package osb
+
+// ServiceInstanceResource represents object of OpenServiceBroker API
+type ServiceInstanceResource struct {
+	ServiceID    string      `json:"service_id,omitempty"`
+	PlanID       string      `json:"plan_id,omitempty"`
+	DashboardURL string      `json:"dashboard_url,omitempty"`
+	Parameters   interface{} `json:"parameters,omitempty"`
+}
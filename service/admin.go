@@ -0,0 +1,517 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/sacloud/open-service-broker-sacloud/util/random"
+)
+
+var (
+	// bindingUsernamePattern matches names of users created by createBinding
+	bindingUsernamePattern = regexp.MustCompile(`^` + bindingUsernamePrefix + `[a-zA-Z]{16}$`)
+	// legacyBindingUsernamePattern matches names of users created by older versions, which had no prefix.
+	// They are swept only if the dialect tells the broker created them
+	legacyBindingUsernamePattern = regexp.MustCompile(`^[a-zA-Z]{20}$`)
+)
+
+// InstanceInfo represents an instance owned by the broker
+type InstanceInfo struct {
+	InstanceID   string     `json:"instance_id"`
+	ServiceName  string     `json:"service"`
+	PlanName     string     `json:"plan"`
+	ResourceID   string     `json:"resource_id"`
+	Availability string     `json:"availability,omitempty"`
+	Status       string     `json:"status"`
+	IPAddress    string     `json:"ip_address,omitempty"`
+	Deleting     bool       `json:"deleting"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	// Parameter is the requested parameter. It is empty for services which don't keep requested parameters
+	Parameter interface{} `json:"parameter,omitempty"`
+	Drift     []string    `json:"drift,omitempty"`
+	// Actual is the parameter read from the resource
+	Actual interface{} `json:"actual,omitempty"`
+}
+
+// BindingInfo represents a binding of the instance. It doesn't contain the password
+type BindingInfo struct {
+	BindingID      string `json:"binding_id"`
+	Username       string `json:"username"`
+	DatabaseExists bool   `json:"database_exists"`
+}
+
+// MetaSchemaStatus represents the version of meta tables in the instance
+type MetaSchemaStatus struct {
+	InstanceID      string `json:"instance_id"`
+	ServiceName     string `json:"service"`
+	MetaTableExists bool   `json:"meta_table_exists"`
+	CurrentVersion  int    `json:"current_version"`
+	LatestVersion   int    `json:"latest_version"`
+}
+
+// ListInstances returns all instances owned by the broker
+func ListInstances() ([]*InstanceInfo, error) {
+	var instances []*InstanceInfo
+	for _, inv := range inventories() {
+		resources, err := inv.list()
+		if err != nil {
+			return nil, fmt.Errorf("listing %q is failed: %s", inv.service.Name, err)
+		}
+		for _, r := range resources {
+			instances = append(instances, newResourceInstanceInfo(inv, r))
+		}
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].InstanceID < instances[j].InstanceID
+	})
+	return instances, nil
+}
+
+// ShowInstance returns the instance with the requested parameter, its drift and the actual parameter
+func ShowInstance(instanceID string) (*InstanceInfo, error) {
+	inv, r, err := lookupResource(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, fmt.Errorf("instance %q is not found", instanceID)
+	}
+
+	info := newResourceInstanceInfo(inv, r)
+	if inv.requested != nil {
+		requested, drift, err := inv.requested(r)
+		if err != nil {
+			return nil, fmt.Errorf("reading requested parameter is failed: %s", err)
+		}
+		info.Parameter = requested
+		info.Drift = drift
+	}
+
+	actual, err := inv.fetch(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("reading actual parameter is failed: %s", err)
+	}
+	if actual != nil {
+		info.Actual = actual.Parameters
+	}
+	return info, nil
+}
+
+// ListBindings returns bindings of the instance
+func ListBindings(instanceID string) ([]*BindingInfo, error) {
+	target, database, err := findInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	connInfo := adminConnInfo(target.dialect, database)
+	db, err := openDatabase(connInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close() // nolint
+
+	exists, err := metaTableReady(target.dialect, db, connInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error reading meta table: %s", err)
+	}
+	if !exists {
+		return []*BindingInfo{}, nil
+	}
+
+	records, err := target.dialect.listBindings(db, connInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error reading meta table: %s", err)
+	}
+
+	bindings := []*BindingInfo{}
+	for _, record := range records {
+		found, err := target.dialect.existsUserDatabase(db, record.username)
+		if err != nil {
+			return nil, fmt.Errorf("error reading user database: %s", err)
+		}
+		bindings = append(bindings, &BindingInfo{
+			BindingID:      record.bindingID,
+			Username:       record.username,
+			DatabaseExists: found,
+		})
+	}
+	return bindings, nil
+}
+
+// RotateCredentials generates new password of the binding and returns new credentials
+func RotateCredentials(instanceID, bindingID string) (*osb.ServiceBinding, error) {
+	target, database, err := findInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	connInfo := adminConnInfo(target.dialect, database)
+	db, err := openDatabase(connInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close() // nolint
+
+	exists, err := metaTableReady(target.dialect, db, connInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error reading meta table: %s", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("binding %q is not found", bindingID)
+	}
+
+	record, err := target.dialect.readBinding(db, connInfo, bindingID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading meta table: %s", err)
+	}
+	if record == nil {
+		return nil, fmt.Errorf("binding %q is not found", bindingID)
+	}
+
+	record.password = random.String(30)
+	if err := target.dialect.updateBindingPassword(db, connInfo, record); err != nil {
+		return nil, fmt.Errorf("rotating credentials is failed: %s", err)
+	}
+
+	credentials, err := bindingCredentials(target.dialect, connInfo, record)
+	if err != nil {
+		return nil, err
+	}
+	return &osb.ServiceBinding{
+		Credentials: credentials,
+	}, nil
+}
+
+// UpdateBindingLimits changes resource limits of the binding user and returns applied limits.
+// Unset limits are replaced with defaults of the plan
+func UpdateBindingLimits(instanceID, bindingID string, limits params.DatabaseBindingLimits) (*params.DatabaseBindingLimits, error) {
+	if err := limits.Validate(); err != nil {
+		return nil, err
+	}
+
+	target, database, err := findInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	connInfo := adminConnInfo(target.dialect, database)
+	db, err := openDatabase(connInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close() // nolint
+
+	exists, err := metaTableReady(target.dialect, db, connInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error reading meta table: %s", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("binding %q is not found", bindingID)
+	}
+
+	record, err := target.dialect.readBinding(db, connInfo, bindingID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading meta table: %s", err)
+	}
+	if record == nil {
+		return nil, fmt.Errorf("binding %q is not found", bindingID)
+	}
+
+	planID := target.planIDs[int(database.Remark.GetPlanID())]
+	applied := limits.WithDefaults(planBindingDefaults(planID))
+	if err := target.dialect.updateBindingLimits(db, record.username, &applied); err != nil {
+		return nil, fmt.Errorf("updating limits is failed: %s", err)
+	}
+	return &applied, nil
+}
+
+// CleanupOrphans deletes orphaned resources detected by the reconciler.
+// If dryRun is true, orphaned resources are only reported.
+func CleanupOrphans(dryRun bool) ([]*ReconcileItem, error) {
+	report, err := reconcile(&reconcileOptions{remediateOrphans: !dryRun})
+	if err != nil {
+		return nil, err
+	}
+	return report.Orphans, nil
+}
+
+// SweepLeakedUsers deletes users which were created for bindings but are left after unbinding.
+// A user is regarded as leaked if it has the name format of binding users(or the format of older versions
+// and privileges which only the broker grants), and neither the binding record nor the user database exists.
+// If dryRun is true, leaked users are only reported.
+func SweepLeakedUsers(dryRun bool) ([]*ReconcileItem, error) {
+	var items []*ReconcileItem
+	for _, target := range reconcileTargets {
+		dbs, err := target.dialect.databaseAPI().List()
+		if err != nil {
+			return nil, fmt.Errorf("listing %q is failed: %s", target.service.Name, err)
+		}
+		for i := range dbs {
+			database := &dbs[i]
+			if !database.IsUp() || database.HasTag(iaas.DeletingMarkerTag) {
+				continue
+			}
+			leaked, err := sweepLeakedUsers(target, database, dryRun)
+			if err != nil {
+				return nil, fmt.Errorf("sweeping users of %q is failed: %s", database.Name, err)
+			}
+			items = append(items, leaked...)
+		}
+	}
+	return items, nil
+}
+
+func sweepLeakedUsers(target *reconcileTarget, database *sacloud.Database, dryRun bool) ([]*ReconcileItem, error) {
+	connInfo := adminConnInfo(target.dialect, database)
+	db, err := openDatabase(connInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close() // nolint
+
+	exists, err := metaTableReady(target.dialect, db, connInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error reading meta table: %s", err)
+	}
+	if !exists {
+		// the broker never created bindings on this instance
+		return nil, nil
+	}
+
+	// users of bindings being created have no records yet
+	unlock, err := target.dialect.lockUsers(db)
+	if err != nil {
+		return nil, fmt.Errorf("error locking users: %s", err)
+	}
+	defer unlock()
+
+	records, err := target.dialect.listBindings(db, connInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error reading meta table: %s", err)
+	}
+	bound := map[string]bool{connInfo.UserName(): true}
+	for _, record := range records {
+		bound[record.username] = true
+	}
+
+	users, err := target.dialect.listUsers(db)
+	if err != nil {
+		return nil, fmt.Errorf("error reading users: %s", err)
+	}
+	sort.Strings(users)
+
+	var items []*ReconcileItem
+	for _, user := range users {
+		if bound[user] {
+			continue
+		}
+		created, err := createdForBinding(target.dialect, db, connInfo, user)
+		if err != nil {
+			return nil, fmt.Errorf("error reading user %q: %s", user, err)
+		}
+		if !created {
+			continue
+		}
+		found, err := target.dialect.existsUserDatabase(db, user)
+		if err != nil {
+			return nil, fmt.Errorf("error reading user database: %s", err)
+		}
+		if found {
+			continue
+		}
+
+		item := &ReconcileItem{
+			ServiceName: target.service.Name,
+			InstanceID:  database.Name,
+			ResourceID:  database.GetStrID(),
+			Reason:      fmt.Sprintf("user %q is left after unbinding", user),
+		}
+		if !dryRun {
+			if err := target.dialect.dropUser(db, user); err != nil {
+				return nil, err
+			}
+			item.Remediated = true
+		}
+		log.WithFields(item.logFields()).Warn("leaked user detected")
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// createdForBinding returns true if the user was created by createBinding of the current or older versions
+func createdForBinding(dialect databaseFuncs, db *sql.DB, connInfo ConnectionInfo, username string) (bool, error) {
+	if bindingUsernamePattern.MatchString(username) {
+		return true, nil
+	}
+	if !legacyBindingUsernamePattern.MatchString(username) {
+		return false, nil
+	}
+	return dialect.isLegacyBindingUser(db, connInfo, username)
+}
+
+// MigrationStatus returns versions of meta tables in running instances.
+// Meta tables are migrated when the broker connects to the instance, so this doesn't apply migrations
+func MigrationStatus() ([]*MetaSchemaStatus, error) {
+	var statuses []*MetaSchemaStatus
+	for _, target := range reconcileTargets {
+		dbs, err := target.dialect.databaseAPI().List()
+		if err != nil {
+			return nil, fmt.Errorf("listing %q is failed: %s", target.service.Name, err)
+		}
+		for i := range dbs {
+			database := &dbs[i]
+			if !database.IsUp() || database.HasTag(iaas.DeletingMarkerTag) {
+				continue
+			}
+			status, err := metaSchemaStatus(target, database)
+			if err != nil {
+				return nil, fmt.Errorf("reading schema version of %q is failed: %s", database.Name, err)
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].InstanceID < statuses[j].InstanceID
+	})
+	return statuses, nil
+}
+
+func metaSchemaStatus(target *reconcileTarget, database *sacloud.Database) (*MetaSchemaStatus, error) {
+	connInfo := adminConnInfo(target.dialect, database)
+	db, err := openDatabase(connInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close() // nolint
+
+	exists, err := target.dialect.existsMetaTable(db, connInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error reading meta table: %s", err)
+	}
+	current, latest, err := target.dialect.metaSchemaVersion(db, connInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MetaSchemaStatus{
+		InstanceID:      database.Name,
+		ServiceName:     target.service.Name,
+		MetaTableExists: exists,
+		CurrentVersion:  current,
+		LatestVersion:   latest,
+	}, nil
+}
+
+func findInstance(instanceID string) (*reconcileTarget, *sacloud.Database, error) {
+	target, db, err := lookupInstance(instanceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if db == nil {
+		// commands for bindings and meta tables are supported only for databases
+		if inv, r, err := lookupResource(instanceID); err == nil && r != nil {
+			return nil, nil, fmt.Errorf("instance %q is %s, which is not a database", instanceID, inv.service.Name)
+		}
+		return nil, nil, fmt.Errorf("instance %q is not found", instanceID)
+	}
+	return target, db, nil
+}
+
+// lookupResource returns the resource of the instance with its inventory. It returns nil if not found
+func lookupResource(instanceID string) (*inventory, *managedResource, error) {
+	for _, inv := range inventories() {
+		resources, err := inv.list()
+		if err != nil {
+			return nil, nil, fmt.Errorf("listing %q is failed: %s", inv.service.Name, err)
+		}
+		for _, r := range resources {
+			if r.instanceID == instanceID {
+				return inv, r, nil
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// lookupInstance returns the database of the instance. It returns nil if not found
+func lookupInstance(instanceID string) (*reconcileTarget, *sacloud.Database, error) {
+	for _, target := range reconcileTargets {
+		dbs, err := target.dialect.databaseAPI().List()
+		if err != nil {
+			return nil, nil, fmt.Errorf("listing %q is failed: %s", target.service.Name, err)
+		}
+		for i := range dbs {
+			if dbs[i].Name == instanceID {
+				return target, &dbs[i], nil
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+func newInstanceInfo(target *reconcileTarget, db *sacloud.Database) *InstanceInfo {
+	return &InstanceInfo{
+		InstanceID:   db.Name,
+		ServiceName:  target.service.Name,
+		PlanName:     target.planName(db),
+		ResourceID:   db.GetStrID(),
+		Availability: string(db.Availability),
+		Status:       db.GetInstanceStatus(),
+		IPAddress:    databaseIPAddress(db),
+		Deleting:     db.HasTag(iaas.DeletingMarkerTag),
+		CreatedAt:    db.GetCreatedAt(),
+	}
+}
+
+// newResourceInstanceInfo returns the instance of the resource. Databases have details of the appliance
+func newResourceInstanceInfo(inv *inventory, r *managedResource) *InstanceInfo {
+	if inv.target != nil {
+		return newInstanceInfo(inv.target, r.resource.(*sacloud.Database))
+	}
+
+	status := "migrating"
+	switch {
+	case r.failed:
+		status = "failed"
+	case r.up:
+		status = "up"
+	}
+	info := &InstanceInfo{
+		InstanceID:  r.instanceID,
+		ServiceName: inv.service.Name,
+		PlanName:    catalogPlanName(inv.service, r.planID),
+		ResourceID:  r.resourceID,
+		Status:      status,
+		Deleting:    r.hasTag(iaas.DeletingMarkerTag),
+	}
+	if nfs, ok := r.resource.(*sacloud.NFS); ok {
+		info.IPAddress = nfsIPAddress(nfs)
+	}
+	if res, ok := r.resource.(interface {
+		GetCreatedAt() *time.Time
+	}); ok {
+		info.CreatedAt = res.GetCreatedAt()
+	}
+	return info
+}
+
+// catalogPlanName returns the name of the plan in the service. It returns empty if the plan is not found
+func catalogPlanName(service *osb.Service, planID string) string {
+	for _, plan := range service.Plans {
+		if plan.ID == planID {
+			return plan.Name
+		}
+	}
+	return ""
+}
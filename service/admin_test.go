@@ -0,0 +1,259 @@
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmin(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	testDialect := &dummyDBFuncs{}
+	dbAPI := &genericDBDummyAPI{}
+	sacloudAPI = &dummyAPI{dbAPI: dbAPI}
+	defer func() { sacloudAPI = testAPI }()
+
+	orgTargets := reconcileTargets
+	reconcileTargets = []*reconcileTarget{
+		{service: MariaDBService, planIDs: DatabaseIDMap["MariaDB"].PlanIDMap, dialect: testDialect},
+	}
+	defer func() { reconcileTargets = orgTargets }()
+
+	orgInventories := resourceInventories
+	resourceInventories = nil
+	defer func() { resourceInventories = orgInventories }()
+
+	t.Run("ListInstances", func(t *testing.T) {
+		dbAPI.listResult = []sacloud.Database{
+			reconcileTestInstance("instance-b", true),
+			reconcileTestInstance("instance-a", false),
+		}
+
+		instances, err := ListInstances()
+		assert.NoError(t, err)
+		assert.Len(t, instances, 2)
+		assert.Equal(t, "instance-a", instances[0].InstanceID)
+		assert.Equal(t, "instance-b", instances[1].InstanceID)
+		assert.Equal(t, MariaDBService.Name, instances[1].ServiceName)
+		assert.Equal(t, MariaDBPlan10G.Name, instances[1].PlanName)
+		assert.Equal(t, "up", instances[1].Status)
+	})
+
+	t.Run("ShowInstance", func(t *testing.T) {
+		drifted := reconcileTestInstance("drifted", true)
+		drifted.Remark.Switch.ID = "999"
+		dbAPI.listResult = []sacloud.Database{drifted}
+
+		instance, err := ShowInstance("drifted")
+		assert.NoError(t, err)
+		assert.NotNil(t, instance.Parameter)
+		assert.Equal(t, []string{"switchID"}, instance.Drift)
+
+		_, err = ShowInstance("not-exists")
+		assert.Error(t, err)
+	})
+
+	t.Run("Instances of other services", func(t *testing.T) {
+		dbAPI.listResult = nil
+		sacloudAPI = &dummyAPI{
+			dbAPI: dbAPI,
+			switchAPI: &dummySwitchAPI{
+				readResult: &sacloud.Switch{
+					Resource:    sacloud.NewResource(123456789012),
+					Name:        "switch",
+					Description: `{"subnet":"192.2.0.0/24","gateway":"192.2.0.1"}`,
+					UserSubnet:  &sacloud.Subnet{DefaultRoute: "192.2.0.254", NetworkMaskLen: 24},
+				},
+			},
+		}
+		defer func() { sacloudAPI = &dummyAPI{dbAPI: dbAPI} }()
+		for _, inv := range orgInventories {
+			if inv.service == SwitchService {
+				resourceInventories = []*inventory{inv}
+			}
+		}
+		defer func() { resourceInventories = nil }()
+
+		instances, err := ListInstances()
+		assert.NoError(t, err)
+		assert.Len(t, instances, 1)
+		assert.Equal(t, SwitchService.Name, instances[0].ServiceName)
+		assert.Equal(t, SwitchPlanDefault.Name, instances[0].PlanName)
+		assert.Equal(t, "up", instances[0].Status)
+
+		instance, err := ShowInstance("switch")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"gateway"}, instance.Drift)
+		assert.Equal(t, "192.2.0.1", instance.Parameter.(*params.SwitchCreateParameter).Gateway)
+		assert.Equal(t, "192.2.0.254", instance.Actual.(*params.SwitchCreateParameter).Gateway)
+
+		// bindings are managed only for databases
+		_, err = ListBindings("switch")
+		assert.EqualError(t, err, `instance "switch" is sacloud-switch, which is not a database`)
+	})
+
+	t.Run("ListBindings", func(t *testing.T) {
+		defer testDialect.init()
+		dbAPI.listResult = []sacloud.Database{reconcileTestInstance("bound", true)}
+		testDialect.existsMetaTableResult = true
+		testDialect.listBindingsResult = []*databaseBindingRecord{
+			{bindingID: bindingID, username: "user", password: "pass"},
+		}
+		testDialect.existsUserDBResult = true
+
+		bindings, err := ListBindings("bound")
+		assert.NoError(t, err)
+		assert.Equal(t, []*BindingInfo{
+			{BindingID: bindingID, Username: "user", DatabaseExists: true},
+		}, bindings)
+	})
+
+	t.Run("RotateCredentials", func(t *testing.T) {
+		defer testDialect.init()
+		dbAPI.listResult = []sacloud.Database{reconcileTestInstance("bound", true)}
+		testDialect.existsMetaTableResult = true
+		testDialect.readBindingResult = &databaseBindingRecord{
+			bindingID: bindingID, username: "user", password: "pass",
+		}
+
+		binding, err := RotateCredentials("bound", bindingID)
+		assert.NoError(t, err)
+		assert.NotNil(t, testDialect.updatedRecord)
+		assert.NotEqual(t, "pass", testDialect.updatedRecord.password)
+
+		credentials := binding.Credentials.(map[string]string)
+		assert.Equal(t, testDialect.updatedRecord.password, credentials["password"])
+		assert.Equal(t, "user", credentials["username"])
+
+		t.Run("binding is not found", func(t *testing.T) {
+			testDialect.readBindingResult = nil
+			_, err := RotateCredentials("bound", bindingID)
+			assert.Error(t, err)
+		})
+	})
+
+	t.Run("CleanupOrphans", func(t *testing.T) {
+		failed := reconcileTestInstance("failed", false)
+		failed.Availability = sacloud.EAFailed
+		dbAPI.listResult = []sacloud.Database{failed}
+
+		items, err := CleanupOrphans(true)
+		assert.NoError(t, err)
+		assert.Len(t, items, 1)
+		assert.False(t, items[0].Remediated)
+
+		items, err = CleanupOrphans(false)
+		assert.NoError(t, err)
+		assert.Len(t, items, 1)
+		assert.True(t, items[0].Remediated)
+	})
+
+	t.Run("SweepLeakedUsers", func(t *testing.T) {
+		defer testDialect.init()
+		dbAPI.listResult = []sacloud.Database{reconcileTestInstance("bound", true)}
+		testDialect.existsMetaTableResult = true
+		testDialect.listBindingsResult = []*databaseBindingRecord{
+			{bindingID: bindingID, username: "aaaaaaaaaaaaaaaaaaaa", password: "pass"},
+		}
+		testDialect.listUsersResult = []string{
+			"aaaaaaaaaaaaaaaaaaaa", // bound
+			"osbsbbbbbbbbbbbbbbbb", // leaked
+			"cccccccccccccccccccc", // not created by the broker even if it looks random
+			"dddddddddddddddddddd", // leaked by older versions
+			"root",                 // not created by the broker
+		}
+		testDialect.legacyUsers = []string{"dddddddddddddddddddd"}
+
+		items, err := SweepLeakedUsers(true)
+		assert.NoError(t, err)
+		assert.Len(t, items, 2)
+		assert.Contains(t, items[0].Reason, "dddddddddddddddddddd")
+		assert.Contains(t, items[1].Reason, "osbsbbbbbbbbbbbbbbbb")
+		assert.Empty(t, testDialect.droppedUsers)
+
+		items, err = SweepLeakedUsers(false)
+		assert.NoError(t, err)
+		assert.Len(t, items, 2)
+		assert.True(t, items[0].Remediated)
+		assert.Equal(t, []string{"dddddddddddddddddddd", "osbsbbbbbbbbbbbbbbbb"}, testDialect.droppedUsers)
+
+		t.Run("user database exists", func(t *testing.T) {
+			testDialect.droppedUsers = nil
+			testDialect.existsUserDBResult = true
+			items, err := SweepLeakedUsers(false)
+			assert.NoError(t, err)
+			assert.Empty(t, items)
+			assert.Empty(t, testDialect.droppedUsers)
+		})
+	})
+
+	t.Run("UpdateBindingLimits", func(t *testing.T) {
+		defer testDialect.init()
+		dbAPI.listResult = []sacloud.Database{reconcileTestInstance("instance-a", true)}
+		testDialect.existsMetaTableResult = true
+		testDialect.readBindingResult = &databaseBindingRecord{
+			bindingID: "binding-a",
+			username:  "user",
+		}
+
+		databaseBindingDefaults[MariaDBPlan10GID] = params.DatabaseBindingLimits{MaxConnections: params.Limit(50)}
+		defer func() { databaseBindingDefaults = map[string]params.DatabaseBindingLimits{} }()
+
+		limits, err := UpdateBindingLimits("instance-a", "binding-a", params.DatabaseBindingLimits{MaxQueryTime: params.Limit(30)})
+		assert.NoError(t, err)
+		expect := &params.DatabaseBindingLimits{MaxConnections: params.Limit(50), MaxQueryTime: params.Limit(30)}
+		assert.Equal(t, expect, limits)
+		assert.Equal(t, expect, testDialect.updatedLimits)
+
+		t.Run("unlimited", func(t *testing.T) {
+			limits, err := UpdateBindingLimits("instance-a", "binding-a", params.DatabaseBindingLimits{MaxConnections: params.Limit(0)})
+			assert.NoError(t, err)
+			assert.Equal(t, &params.DatabaseBindingLimits{MaxConnections: params.Limit(0)}, limits)
+		})
+
+		t.Run("invalid limits", func(t *testing.T) {
+			testDialect.updatedLimits = nil
+			_, err := UpdateBindingLimits("instance-a", "binding-a", params.DatabaseBindingLimits{MaxConnections: params.Limit(-1)})
+			assert.Error(t, err)
+			assert.Nil(t, testDialect.updatedLimits)
+		})
+
+		t.Run("binding is not found", func(t *testing.T) {
+			testDialect.readBindingResult = nil
+			_, err := UpdateBindingLimits("instance-a", "binding-a", params.DatabaseBindingLimits{})
+			assert.Error(t, err)
+		})
+	})
+
+	t.Run("MigrationStatus", func(t *testing.T) {
+		defer testDialect.init()
+		dbAPI.listResult = []sacloud.Database{
+			reconcileTestInstance("instance-b", true),
+			reconcileTestInstance("instance-a", true),
+		}
+		testDialect.existsMetaTableResult = true
+		testDialect.metaVersionCurrent = 1
+		testDialect.metaVersionLatest = 2
+
+		statuses, err := MigrationStatus()
+		assert.NoError(t, err)
+		assert.Equal(t, []*MetaSchemaStatus{
+			{InstanceID: "instance-a", ServiceName: MariaDBService.Name, MetaTableExists: true, CurrentVersion: 1, LatestVersion: 2},
+			{InstanceID: "instance-b", ServiceName: MariaDBService.Name, MetaTableExists: true, CurrentVersion: 1, LatestVersion: 2},
+		}, statuses)
+
+		t.Run("reading version is failed", func(t *testing.T) {
+			testDialect.metaVersionErr = errors.New("dummy")
+			_, err := MigrationStatus()
+			assert.Error(t, err)
+		})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
 )
 
 var (
@@ -12,6 +13,12 @@ var (
 		Services: []*osb.Service{
 			MariaDBService,
 			PostgreSQLService,
+			NFSService,
+			DNSService,
+			SimpleMonitorService,
+			SwitchService,
+			VPCRouterService,
+			GSLBService,
 		},
 	}
 	// CurrentCatalogData is raw data of API server response
@@ -61,6 +68,60 @@ const (
 	// PostgreSQLPlan1TID plan/mariadb/1tb/id
 	PostgreSQLPlan1TID = "f4bf204d-a73f-4b41-b8e8-1417c47c18dc"
 
+	// NFSServiceID service/nfs/id
+	NFSServiceID = "c10e6c5a-a14b-476b-bbb0-74197b3d74bf"
+
+	// NFSPlan100GID plan/nfs/100g/id
+	NFSPlan100GID = "6504103a-2a50-4628-8ab9-13c8a6398ef5"
+
+	// NFSPlan500GID plan/nfs/500g/id
+	NFSPlan500GID = "ee355425-8881-4a8a-9c29-4e876a487c81"
+
+	// NFSPlan1TID plan/nfs/1t/id
+	NFSPlan1TID = "a7d2798d-2b57-49de-a96b-9a2325624715"
+
+	// NFSPlan2TID plan/nfs/2t/id
+	NFSPlan2TID = "917d233a-90e7-4122-860a-1824fc9396ab"
+
+	// NFSPlan4TID plan/nfs/4t/id
+	NFSPlan4TID = "5052197c-ab13-4b23-b402-add9bf310e0a"
+
+	// DNSServiceID service/dns/id
+	DNSServiceID = "a40837db-1f99-484f-bfd3-b97c476e97cd"
+
+	// DNSPlanZoneID plan/dns/zone/id
+	DNSPlanZoneID = "6ecb9c84-eb85-4c3b-914f-6dbac3bde32e"
+
+	// SimpleMonitorServiceID service/simple-monitor/id
+	SimpleMonitorServiceID = "d6d4bd50-57b7-444a-b7aa-bebd8a651e93"
+
+	// SimpleMonitorPlanDefaultID plan/simple-monitor/default/id
+	SimpleMonitorPlanDefaultID = "1d0e725e-595d-4cb3-9a50-0f75552ad18c"
+
+	// SwitchServiceID service/switch/id
+	SwitchServiceID = "8259c257-bd8f-45d8-8fc4-4b405b865bef"
+
+	// SwitchPlanDefaultID plan/switch/default/id
+	SwitchPlanDefaultID = "b92e76ca-9232-4aa9-af3f-ad36324c1c9e"
+
+	// VPCRouterServiceID service/vpc-router/id
+	VPCRouterServiceID = "fa86ad36-835a-4ae3-8ee6-2d01f7c97c93"
+
+	// VPCRouterPlanStandardID plan/vpc-router/standard/id
+	VPCRouterPlanStandardID = "a7fbfe90-cd8f-4ceb-b4c1-ceab141b0664"
+
+	// VPCRouterPlanPremiumID plan/vpc-router/premium/id
+	VPCRouterPlanPremiumID = "19584b3f-dc4c-4c42-86f9-ef01d0cdba18"
+
+	// VPCRouterPlanHighSpecID plan/vpc-router/highspec/id
+	VPCRouterPlanHighSpecID = "4f6b6130-dad8-4cdd-a7e2-3d3478984ab4"
+
+	// GSLBServiceID service/gslb/id
+	GSLBServiceID = "68fe0c8e-f890-4aa8-b95f-2d7f5dafb983"
+
+	// GSLBPlanDefaultID plan/gslb/default/id
+	GSLBPlanDefaultID = "50d37e6d-616b-4fb4-b16c-b63c49e2f461"
+
 	databaseApplianceParameterJSON = `
     {
     	"$schema": "http://json-schema.org/draft-04/schema#",
@@ -80,17 +141,676 @@ const (
             "ipaddress": {
                 "type": "string"
             },
-            "maskLen": {
-                "type": "integer"
+            "maskLen": {
+                "type": "integer"
+            },
+            "port": {
+                "type": "integer"
+            },
+            "switchID": {
+                "type": "integer"
+            },
+            "username": {
+                "type": "string"
+            }
+        },
+        "additionalProperties": false,
+        "type": "object"
+	}
+    `
+
+	nfsApplianceParameterJSON = `
+    {
+    	"$schema": "http://json-schema.org/draft-04/schema#",
+        "properties": {
+            "defaultRoute": {
+                "type": "string"
+            },
+            "ipaddress": {
+                "type": "string"
+            },
+            "maskLen": {
+                "type": "integer"
+            },
+            "switchID": {
+                "type": "integer"
+            }
+        },
+        "required": ["switchID", "ipaddress", "maskLen"],
+        "additionalProperties": false,
+        "type": "object"
+	}
+    `
+
+	nfsBindingParameterJSON = `
+    {
+    	"$schema": "http://json-schema.org/draft-04/schema#",
+        "properties": {
+            "containerDir": {
+                "type": "string"
+            },
+            "gid": {
+                "type": "integer"
+            },
+            "mode": {
+                "enum": ["r", "rw"],
+                "type": "string"
+            },
+            "uid": {
+                "type": "integer"
+            }
+        },
+        "additionalProperties": false,
+        "type": "object"
+	}
+    `
+
+	dnsParameterJSON = `
+    {
+    	"$schema": "http://json-schema.org/draft-04/schema#",
+        "properties": {
+            "subdomain": {
+                "type": "string"
+            },
+            "zone": {
+                "type": "string"
+            }
+        },
+        "additionalProperties": false,
+        "type": "object"
+	}
+    `
+
+	dnsBindingParameterJSON = `
+    {
+    	"$schema": "http://json-schema.org/draft-04/schema#",
+        "properties": {
+            "records": {
+                "items": {
+                    "properties": {
+                        "name": {
+                            "type": "string"
+                        },
+                        "ttl": {
+                            "type": "integer"
+                        },
+                        "type": {
+                            "enum": ["A", "CNAME", "TXT"],
+                            "type": "string"
+                        },
+                        "value": {
+                            "type": "string"
+                        }
+                    },
+                    "required": ["name", "type", "value"],
+                    "type": "object"
+                },
+                "maxItems": 10,
+                "minItems": 1,
+                "type": "array"
+            }
+        },
+        "required": ["records"],
+        "additionalProperties": false,
+        "type": "object"
+	}
+    `
+
+	simpleMonitorParameterJSON = `
+    {
+    	"$schema": "http://json-schema.org/draft-04/schema#",
+        "properties": {
+            "expectedStatus": {
+                "maximum": 599,
+                "minimum": 100,
+                "type": "integer"
+            },
+            "hostHeader": {
+                "type": "string"
+            },
+            "interval": {
+                "default": 60,
+                "maximum": 3600,
+                "minimum": 60,
+                "type": "integer"
+            },
+            "notifyEmail": {
+                "type": "boolean"
+            },
+            "path": {
+                "type": "string"
+            },
+            "port": {
+                "maximum": 65535,
+                "minimum": 1,
+                "type": "integer"
+            },
+            "protocol": {
+                "enum": ["http", "https", "tcp", "ping"],
+                "type": "string"
+            },
+            "target": {
+                "type": "string"
+            },
+            "webhookURL": {
+                "type": "string"
+            }
+        },
+        "required": ["target", "protocol"],
+        "additionalProperties": false,
+        "type": "object"
+	}
+    `
+
+	switchParameterJSON = `
+    {
+    	"$schema": "http://json-schema.org/draft-04/schema#",
+        "properties": {
+            "bridgeID": {
+                "type": "integer"
+            },
+            "gateway": {
+                "type": "string"
+            },
+            "subnet": {
+                "type": "string"
+            },
+            "vpcRouterID": {
+                "type": "integer"
+            },
+            "allowRouterRestart": {
+                "type": "boolean"
+            }
+        },
+        "required": ["subnet"],
+        "additionalProperties": false,
+        "type": "object"
+	}
+    `
+
+	vpcRouterParameterJSON = `
+    {
+    	"$schema": "http://json-schema.org/draft-04/schema#",
+        "properties": {
+            "firewall": {
+                "items": {
+                    "properties": {
+                        "action": {
+                            "enum": ["allow", "deny"],
+                            "type": "string"
+                        },
+                        "description": {
+                            "type": "string"
+                        },
+                        "destinationNetwork": {
+                            "type": "string"
+                        },
+                        "destinationPort": {
+                            "type": "string"
+                        },
+                        "direction": {
+                            "enum": ["receive", "send"],
+                            "type": "string"
+                        },
+                        "interface": {
+                            "maximum": 7,
+                            "minimum": 0,
+                            "type": "integer"
+                        },
+                        "logging": {
+                            "type": "boolean"
+                        },
+                        "protocol": {
+                            "enum": ["tcp", "udp", "icmp", "ip"],
+                            "type": "string"
+                        },
+                        "sourceNetwork": {
+                            "type": "string"
+                        },
+                        "sourcePort": {
+                            "type": "string"
+                        }
+                    },
+                    "required": ["interface", "direction", "action", "protocol"],
+                    "additionalProperties": false,
+                    "type": "object"
+                },
+                "type": "array"
+            },
+            "portForwarding": {
+                "items": {
+                    "properties": {
+                        "description": {
+                            "type": "string"
+                        },
+                        "globalPort": {
+                            "maximum": 65535,
+                            "minimum": 1,
+                            "type": "integer"
+                        },
+                        "privateAddress": {
+                            "type": "string"
+                        },
+                        "privatePort": {
+                            "maximum": 65535,
+                            "minimum": 1,
+                            "type": "integer"
+                        },
+                        "protocol": {
+                            "enum": ["tcp", "udp"],
+                            "type": "string"
+                        }
+                    },
+                    "required": ["protocol", "globalPort", "privateAddress", "privatePort"],
+                    "additionalProperties": false,
+                    "type": "object"
+                },
+                "type": "array"
+            },
+            "siteToSiteVPN": {
+                "properties": {
+                    "localPrefix": {
+                        "items": {
+                            "type": "string"
+                        },
+                        "type": "array"
+                    },
+                    "peer": {
+                        "type": "string"
+                    },
+                    "preSharedSecret": {
+                        "maxLength": 40,
+                        "type": "string"
+                    },
+                    "remoteID": {
+                        "type": "string"
+                    },
+                    "routes": {
+                        "items": {
+                            "type": "string"
+                        },
+                        "type": "array"
+                    }
+                },
+                "required": ["peer", "preSharedSecret", "routes", "localPrefix"],
+                "additionalProperties": false,
+                "type": "object"
+            },
+            "staticNAT": {
+                "items": {
+                    "properties": {
+                        "description": {
+                            "type": "string"
+                        },
+                        "globalAddress": {
+                            "type": "string"
+                        },
+                        "privateAddress": {
+                            "type": "string"
+                        }
+                    },
+                    "required": ["globalAddress", "privateAddress"],
+                    "additionalProperties": false,
+                    "type": "object"
+                },
+                "type": "array"
+            },
+            "interfaces": {
+                "items": {
+                    "properties": {
+                        "ipaddress": {
+                            "type": "string"
+                        },
+                        "ipaddresses": {
+                            "items": {
+                                "type": "string"
+                            },
+                            "maxItems": 2,
+                            "minItems": 2,
+                            "type": "array"
+                        },
+                        "maskLen": {
+                            "maximum": 28,
+                            "minimum": 16,
+                            "type": "integer"
+                        },
+                        "switchID": {
+                            "type": "integer"
+                        },
+                        "virtualIPAddress": {
+                            "type": "string"
+                        }
+                    },
+                    "required": ["switchID", "maskLen"],
+                    "additionalProperties": false,
+                    "type": "object"
+                },
+                "maxItems": 7,
+                "minItems": 1,
+                "type": "array"
+            },
+            "ipAliases": {
+                "items": {
+                    "type": "string"
+                },
+                "type": "array"
+            },
+            "publicIPAddresses": {
+                "items": {
+                    "type": "string"
+                },
+                "maxItems": 2,
+                "minItems": 2,
+                "type": "array"
+            },
+            "publicSwitchID": {
+                "type": "integer"
+            },
+            "publicVirtualIPAddress": {
+                "type": "string"
+            },
+            "vrid": {
+                "maximum": 255,
+                "minimum": 1,
+                "type": "integer"
+            }
+        },
+        "required": ["interfaces"],
+        "additionalProperties": false,
+        "type": "object"
+	}
+    `
+
+	vpcRouterUpdateParameterJSON = `
+    {
+    	"$schema": "http://json-schema.org/draft-04/schema#",
+        "properties": {
+            "firewall": {
+                "items": {
+                    "properties": {
+                        "action": {
+                            "enum": ["allow", "deny"],
+                            "type": "string"
+                        },
+                        "description": {
+                            "type": "string"
+                        },
+                        "destinationNetwork": {
+                            "type": "string"
+                        },
+                        "destinationPort": {
+                            "type": "string"
+                        },
+                        "direction": {
+                            "enum": ["receive", "send"],
+                            "type": "string"
+                        },
+                        "interface": {
+                            "maximum": 7,
+                            "minimum": 0,
+                            "type": "integer"
+                        },
+                        "logging": {
+                            "type": "boolean"
+                        },
+                        "protocol": {
+                            "enum": ["tcp", "udp", "icmp", "ip"],
+                            "type": "string"
+                        },
+                        "sourceNetwork": {
+                            "type": "string"
+                        },
+                        "sourcePort": {
+                            "type": "string"
+                        }
+                    },
+                    "required": ["interface", "direction", "action", "protocol"],
+                    "additionalProperties": false,
+                    "type": "object"
+                },
+                "type": "array"
+            },
+            "portForwarding": {
+                "items": {
+                    "properties": {
+                        "description": {
+                            "type": "string"
+                        },
+                        "globalPort": {
+                            "maximum": 65535,
+                            "minimum": 1,
+                            "type": "integer"
+                        },
+                        "privateAddress": {
+                            "type": "string"
+                        },
+                        "privatePort": {
+                            "maximum": 65535,
+                            "minimum": 1,
+                            "type": "integer"
+                        },
+                        "protocol": {
+                            "enum": ["tcp", "udp"],
+                            "type": "string"
+                        }
+                    },
+                    "required": ["protocol", "globalPort", "privateAddress", "privatePort"],
+                    "additionalProperties": false,
+                    "type": "object"
+                },
+                "type": "array"
+            },
+            "siteToSiteVPN": {
+                "properties": {
+                    "localPrefix": {
+                        "items": {
+                            "type": "string"
+                        },
+                        "type": "array"
+                    },
+                    "peer": {
+                        "type": "string"
+                    },
+                    "preSharedSecret": {
+                        "maxLength": 40,
+                        "type": "string"
+                    },
+                    "remoteID": {
+                        "type": "string"
+                    },
+                    "routes": {
+                        "items": {
+                            "type": "string"
+                        },
+                        "type": "array"
+                    }
+                },
+                "required": ["peer", "preSharedSecret", "routes", "localPrefix"],
+                "additionalProperties": false,
+                "type": "object"
+            },
+            "staticNAT": {
+                "items": {
+                    "properties": {
+                        "description": {
+                            "type": "string"
+                        },
+                        "globalAddress": {
+                            "type": "string"
+                        },
+                        "privateAddress": {
+                            "type": "string"
+                        }
+                    },
+                    "required": ["globalAddress", "privateAddress"],
+                    "additionalProperties": false,
+                    "type": "object"
+                },
+                "type": "array"
+            }
+        },
+        "additionalProperties": false,
+        "type": "object"
+	}
+    `
+
+	gslbParameterJSON = `
+    {
+    	"$schema": "http://json-schema.org/draft-04/schema#",
+        "properties": {
+            "healthCheck": {
+                "properties": {
+                    "expectedStatus": {
+                        "maximum": 599,
+                        "minimum": 100,
+                        "type": "integer"
+                    },
+                    "hostHeader": {
+                        "type": "string"
+                    },
+                    "path": {
+                        "type": "string"
+                    },
+                    "port": {
+                        "maximum": 65535,
+                        "minimum": 1,
+                        "type": "integer"
+                    },
+                    "protocol": {
+                        "enum": ["http", "https", "tcp", "ping"],
+                        "type": "string"
+                    }
+                },
+                "required": ["protocol"],
+                "additionalProperties": false,
+                "type": "object"
+            },
+            "interval": {
+                "default": 10,
+                "maximum": 60,
+                "minimum": 10,
+                "type": "integer"
+            },
+            "sorryServer": {
+                "type": "string"
+            },
+            "weighted": {
+                "default": true,
+                "type": "boolean"
+            }
+        },
+        "required": ["healthCheck"],
+        "additionalProperties": false,
+        "type": "object"
+	}
+    `
+
+	gslbBindingParameterJSON = `
+    {
+    	"$schema": "http://json-schema.org/draft-04/schema#",
+        "properties": {
+            "enabled": {
+                "default": true,
+                "type": "boolean"
+            },
+            "ipaddress": {
+                "type": "string"
+            },
+            "weight": {
+                "default": 1,
+                "maximum": 10000,
+                "minimum": 1,
+                "type": "integer"
+            }
+        },
+        "required": ["ipaddress"],
+        "additionalProperties": false,
+        "type": "object"
+	}
+    `
+
+	databaseUpdateParameterJSON = `
+    {
+    	"$schema": "http://json-schema.org/draft-04/schema#",
+        "properties": {
+            "addAllowNetworks": {
+                "items": {
+                    "type": "string"
+                },
+                "type": "array"
+            },
+            "allowNetworks": {
+                "items": {
+                    "type": "string"
+                },
+                "type": "array"
+            },
+            "removeAllowNetworks": {
+                "items": {
+                    "type": "string"
+                },
+                "type": "array"
+            }
+        },
+        "additionalProperties": false,
+        "type": "object"
+	}
+    `
+
+	databaseBindingParameterJSON = `
+    {
+    	"$schema": "http://json-schema.org/draft-04/schema#",
+        "properties": {
+            "allowedHost": {
+                "type": "string"
+            },
+            "characterSet": {
+                "type": "string"
+            },
+            "collation": {
+                "type": "string"
+            },
+            "credentialFormat": {
+                "type": "string"
+            },
+            "encoding": {
+                "type": "string"
+            },
+            "extensions": {
+                "type": "array",
+                "items": {
+                    "type": "string"
+                },
+                "uniqueItems": true
+            },
+            "lcCollate": {
+                "type": "string"
+            },
+            "lcCtype": {
+                "type": "string"
+            },
+            "maxConnections": {
+                "type": "integer",
+                "minimum": 0
             },
-            "port": {
-                "type": "integer"
+            "maxQueryTime": {
+                "type": "integer",
+                "minimum": 0
             },
-            "switchID": {
-                "type": "integer"
+            "maxQueriesPerHour": {
+                "type": "integer",
+                "minimum": 0
             },
-            "username": {
-                "type": "string"
+            "schemas": {
+                "type": "array",
+                "items": {
+                    "type": "string",
+                    "pattern": "^[a-z_][a-z0-9_]{0,62}$"
+                },
+                "uniqueItems": true
+            },
+            "tls": {
+                "type": "boolean"
             }
         },
         "additionalProperties": false,
@@ -125,6 +845,28 @@ var DatabaseIDMap = map[string]PlanIDMap{
 	},
 }
 
+// VPCRouterIDMap defines relations of between VPC router service and plans
+var VPCRouterIDMap = PlanIDMap{
+	ID: VPCRouterServiceID,
+	PlanIDMap: map[int]string{
+		params.VPCRouterPlanStandard: VPCRouterPlanStandardID,
+		params.VPCRouterPlanPremium:  VPCRouterPlanPremiumID,
+		params.VPCRouterPlanHighSpec: VPCRouterPlanHighSpecID,
+	},
+}
+
+// NFSIDMap defines relations of between NFS service and plans
+var NFSIDMap = PlanIDMap{
+	ID: NFSServiceID,
+	PlanIDMap: map[int]string{
+		100:  NFSPlan100GID,
+		500:  NFSPlan500GID,
+		1024: NFSPlan1TID,
+		2048: NFSPlan2TID,
+		4096: NFSPlan4TID,
+	},
+}
+
 var (
 	// MariaDBService is service for manage to SAKURA cloud Database Appliances
 	MariaDBService = &osb.Service{
@@ -144,6 +886,8 @@ var (
 			MariaDBPlan500G,
 			MariaDBPlan1T,
 		},
+
+		InstancesRetrievable: true,
 	}
 
 	// PostgreSQLService is service for manage to SAKURA cloud Database Appliances
@@ -164,6 +908,117 @@ var (
 			PostgreSQLPlan500G,
 			PostgreSQLPlan1T,
 		},
+
+		InstancesRetrievable: true,
+	}
+
+	// NFSService is service for manage to SAKURA cloud NFS Appliances
+	NFSService = &osb.Service{
+		ID:             NFSServiceID,
+		Name:           "sacloud-nfs",
+		Bindable:       true,
+		PlanUpdateable: false,
+		Tags:           []string{"nfs", "volume"},
+		Description:    "SAKURA Cloud NFS appliance",
+		Requires:       []string{"volume_mount"},
+		Metadata:       &osb.Metadata{},
+		Plans: []*osb.Plan{
+			NFSPlan100G,
+			NFSPlan500G,
+			NFSPlan1T,
+			NFSPlan2T,
+			NFSPlan4T,
+		},
+
+		InstancesRetrievable: true,
+	}
+
+	// DNSService is service for manage to SAKURA cloud DNS zones
+	DNSService = &osb.Service{
+		ID:             DNSServiceID,
+		Name:           "sacloud-dns",
+		Bindable:       true,
+		PlanUpdateable: false,
+		Tags:           []string{"dns"},
+		Description:    "SAKURA Cloud DNS zone",
+		Requires:       []string{},
+		Metadata:       &osb.Metadata{},
+		Plans: []*osb.Plan{
+			DNSPlanZone,
+		},
+
+		InstancesRetrievable: true,
+	}
+
+	// SimpleMonitorService is service for manage to SAKURA cloud Simple Monitors
+	SimpleMonitorService = &osb.Service{
+		ID:             SimpleMonitorServiceID,
+		Name:           "sacloud-simple-monitor",
+		Bindable:       false,
+		PlanUpdateable: false,
+		Tags:           []string{"monitoring"},
+		Description:    "SAKURA Cloud Simple Monitor",
+		Requires:       []string{},
+		Metadata:       &osb.Metadata{},
+		Plans: []*osb.Plan{
+			SimpleMonitorPlanDefault,
+		},
+
+		InstancesRetrievable: true,
+	}
+
+	// SwitchService is service for manage to SAKURA cloud Switches
+	SwitchService = &osb.Service{
+		ID:             SwitchServiceID,
+		Name:           "sacloud-switch",
+		Bindable:       true,
+		PlanUpdateable: false,
+		Tags:           []string{"network"},
+		Description:    "SAKURA Cloud Switch",
+		Requires:       []string{},
+		Metadata:       &osb.Metadata{},
+		Plans: []*osb.Plan{
+			SwitchPlanDefault,
+		},
+
+		InstancesRetrievable: true,
+	}
+
+	// VPCRouterService is service for manage to SAKURA cloud VPC Routers
+	VPCRouterService = &osb.Service{
+		ID:             VPCRouterServiceID,
+		Name:           "sacloud-vpc-router",
+		Bindable:       true,
+		PlanUpdateable: false,
+		Tags:           []string{"network", "vpn"},
+		Description:    "SAKURA Cloud VPC Router",
+		Requires:       []string{},
+		Metadata:       &osb.Metadata{},
+		Plans: []*osb.Plan{
+			VPCRouterPlanStandard,
+			VPCRouterPlanPremium,
+			VPCRouterPlanHighSpec,
+		},
+
+		InstancesRetrievable: true,
+	}
+
+	// GSLBService is service for manage to SAKURA cloud GSLBs
+	GSLBService = &osb.Service{
+		ID:             GSLBServiceID,
+		Name:           "sacloud-gslb",
+		Bindable:       true,
+		PlanUpdateable: false,
+		Tags:           []string{"network", "load-balancer"},
+		Description:    "SAKURA Cloud GSLB",
+		Requires:       []string{},
+		Metadata:       &osb.Metadata{},
+		Plans: []*osb.Plan{
+			GSLBPlanDefault,
+		},
+
+		InstancesRetrievable: true,
+		BindingsRetrievable:  true,
 	}
 )
 
@@ -377,6 +1232,211 @@ var (
 	}
 )
 
+var (
+	// NFSPlan100G is represents NFS 100g plan
+	NFSPlan100G = &osb.Plan{
+		ID:          NFSPlan100GID,
+		Name:        "nfs-100g",
+		Description: "NFS 100GB",
+		Bindable:    true,
+		Free:        false,
+		Metadata:    &osb.Metadata{},
+		Schemas: &osb.SchemasObject{
+			ServiceInstance: &osb.ServiceInstanceSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+			ServiceBinding: &osb.ServiceBindingSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+		},
+	}
+	// NFSPlan500G is represents NFS 500g plan
+	NFSPlan500G = &osb.Plan{
+		ID:          NFSPlan500GID,
+		Name:        "nfs-500g",
+		Description: "NFS 500GB",
+		Bindable:    true,
+		Free:        false,
+		Metadata:    &osb.Metadata{},
+		Schemas: &osb.SchemasObject{
+			ServiceInstance: &osb.ServiceInstanceSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+			ServiceBinding: &osb.ServiceBindingSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+		},
+	}
+	// NFSPlan1T is represents NFS 1t plan
+	NFSPlan1T = &osb.Plan{
+		ID:          NFSPlan1TID,
+		Name:        "nfs-1t",
+		Description: "NFS 1TB",
+		Bindable:    true,
+		Free:        false,
+		Metadata:    &osb.Metadata{},
+		Schemas: &osb.SchemasObject{
+			ServiceInstance: &osb.ServiceInstanceSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+			ServiceBinding: &osb.ServiceBindingSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+		},
+	}
+	// NFSPlan2T is represents NFS 2t plan
+	NFSPlan2T = &osb.Plan{
+		ID:          NFSPlan2TID,
+		Name:        "nfs-2t",
+		Description: "NFS 2TB",
+		Bindable:    true,
+		Free:        false,
+		Metadata:    &osb.Metadata{},
+		Schemas: &osb.SchemasObject{
+			ServiceInstance: &osb.ServiceInstanceSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+			ServiceBinding: &osb.ServiceBindingSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+		},
+	}
+	// NFSPlan4T is represents NFS 4t plan
+	NFSPlan4T = &osb.Plan{
+		ID:          NFSPlan4TID,
+		Name:        "nfs-4t",
+		Description: "NFS 4TB",
+		Bindable:    true,
+		Free:        false,
+		Metadata:    &osb.Metadata{},
+		Schemas: &osb.SchemasObject{
+			ServiceInstance: &osb.ServiceInstanceSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+			ServiceBinding: &osb.ServiceBindingSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+		},
+	}
+)
+
+var (
+	// DNSPlanZone is represents DNS zone plan
+	DNSPlanZone = &osb.Plan{
+		ID:          DNSPlanZoneID,
+		Name:        "zone",
+		Description: "DNS zone",
+		Bindable:    true,
+		Free:        false,
+		Metadata:    &osb.Metadata{},
+		Schemas: &osb.SchemasObject{
+			ServiceInstance: &osb.ServiceInstanceSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+			ServiceBinding: &osb.ServiceBindingSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+		},
+	}
+
+	// SimpleMonitorPlanDefault is represents Simple Monitor default plan
+	SimpleMonitorPlanDefault = &osb.Plan{
+		ID:          SimpleMonitorPlanDefaultID,
+		Name:        "default",
+		Description: "Health checks of a target",
+		Bindable:    false,
+		Free:        true,
+		Metadata:    &osb.Metadata{},
+		Schemas: &osb.SchemasObject{
+			ServiceInstance: &osb.ServiceInstanceSchemaObject{
+				Create: &osb.SchemaParameters{},
+				Update: &osb.SchemaParameters{},
+			},
+		},
+	}
+
+	// SwitchPlanDefault is represents Switch default plan
+	SwitchPlanDefault = &osb.Plan{
+		ID:          SwitchPlanDefaultID,
+		Name:        "default",
+		Description: "Switch",
+		Bindable:    true,
+		Free:        false,
+		Metadata:    &osb.Metadata{},
+		Schemas: &osb.SchemasObject{
+			ServiceInstance: &osb.ServiceInstanceSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+		},
+	}
+
+	// VPCRouterPlanStandard is represents VPC Router standard plan
+	VPCRouterPlanStandard = &osb.Plan{
+		ID:          VPCRouterPlanStandardID,
+		Name:        "standard",
+		Description: "VPC Router(standard)",
+		Bindable:    true,
+		Free:        false,
+		Metadata:    &osb.Metadata{},
+		Schemas: &osb.SchemasObject{
+			ServiceInstance: &osb.ServiceInstanceSchemaObject{
+				Create: &osb.SchemaParameters{},
+				Update: &osb.SchemaParameters{},
+			},
+		},
+	}
+
+	// VPCRouterPlanPremium is represents VPC Router premium plan
+	VPCRouterPlanPremium = &osb.Plan{
+		ID:          VPCRouterPlanPremiumID,
+		Name:        "premium",
+		Description: "VPC Router(premium, redundant)",
+		Bindable:    true,
+		Free:        false,
+		Metadata:    &osb.Metadata{},
+		Schemas: &osb.SchemasObject{
+			ServiceInstance: &osb.ServiceInstanceSchemaObject{
+				Create: &osb.SchemaParameters{},
+				Update: &osb.SchemaParameters{},
+			},
+		},
+	}
+
+	// VPCRouterPlanHighSpec is represents VPC Router highspec plan
+	VPCRouterPlanHighSpec = &osb.Plan{
+		ID:          VPCRouterPlanHighSpecID,
+		Name:        "highspec",
+		Description: "VPC Router(highspec, redundant)",
+		Bindable:    true,
+		Free:        false,
+		Metadata:    &osb.Metadata{},
+		Schemas: &osb.SchemasObject{
+			ServiceInstance: &osb.ServiceInstanceSchemaObject{
+				Create: &osb.SchemaParameters{},
+				Update: &osb.SchemaParameters{},
+			},
+		},
+	}
+
+	// GSLBPlanDefault is represents GSLB default plan
+	GSLBPlanDefault = &osb.Plan{
+		ID:          GSLBPlanDefaultID,
+		Name:        "default",
+		Description: "DNS based load balancing with health checks of servers",
+		Bindable:    true,
+		Free:        false,
+		Metadata:    &osb.Metadata{},
+		Schemas: &osb.SchemasObject{
+			ServiceInstance: &osb.ServiceInstanceSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+			ServiceBinding: &osb.ServiceBindingSchemaObject{
+				Create: &osb.SchemaParameters{},
+			},
+		},
+	}
+)
+
 func init() {
 	var dbParamSchema map[string]interface{}
 
@@ -397,6 +1457,121 @@ func init() {
 	PostgreSQLPlan240G.Schemas.ServiceInstance.Create.Parameters = dbParamSchema
 	PostgreSQLPlan500G.Schemas.ServiceInstance.Create.Parameters = dbParamSchema
 	PostgreSQLPlan1T.Schemas.ServiceInstance.Create.Parameters = dbParamSchema
+
+	var dbUpdateParamSchema map[string]interface{}
+	err = json.Unmarshal([]byte(databaseUpdateParameterJSON), &dbUpdateParamSchema)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, service := range []*osb.Service{MariaDBService, PostgreSQLService} {
+		for _, plan := range service.Plans {
+			plan.Schemas.ServiceInstance.Update = &osb.SchemaParameters{Parameters: dbUpdateParamSchema}
+		}
+	}
+	setDatabaseBindingSchemas()
+
+	var nfsParamSchema, nfsBindParamSchema map[string]interface{}
+	if err := json.Unmarshal([]byte(nfsApplianceParameterJSON), &nfsParamSchema); err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal([]byte(nfsBindingParameterJSON), &nfsBindParamSchema); err != nil {
+		panic(err)
+	}
+	for _, plan := range NFSService.Plans {
+		plan.Schemas.ServiceInstance.Create.Parameters = nfsParamSchema
+		plan.Schemas.ServiceBinding.Create.Parameters = nfsBindParamSchema
+	}
+
+	var dnsParamSchema, dnsBindParamSchema map[string]interface{}
+	if err := json.Unmarshal([]byte(dnsParameterJSON), &dnsParamSchema); err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal([]byte(dnsBindingParameterJSON), &dnsBindParamSchema); err != nil {
+		panic(err)
+	}
+	DNSPlanZone.Schemas.ServiceInstance.Create.Parameters = dnsParamSchema
+	DNSPlanZone.Schemas.ServiceBinding.Create.Parameters = dnsBindParamSchema
+
+	var simpleMonitorParamSchema map[string]interface{}
+	if err := json.Unmarshal([]byte(simpleMonitorParameterJSON), &simpleMonitorParamSchema); err != nil {
+		panic(err)
+	}
+	SimpleMonitorPlanDefault.Schemas.ServiceInstance.Create.Parameters = simpleMonitorParamSchema
+	SimpleMonitorPlanDefault.Schemas.ServiceInstance.Update.Parameters = simpleMonitorParamSchema
+
+	var switchParamSchema map[string]interface{}
+	if err := json.Unmarshal([]byte(switchParameterJSON), &switchParamSchema); err != nil {
+		panic(err)
+	}
+	SwitchPlanDefault.Schemas.ServiceInstance.Create.Parameters = switchParamSchema
+
+	var vpcRouterParamSchema, vpcRouterUpdateParamSchema map[string]interface{}
+	if err := json.Unmarshal([]byte(vpcRouterParameterJSON), &vpcRouterParamSchema); err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal([]byte(vpcRouterUpdateParameterJSON), &vpcRouterUpdateParamSchema); err != nil {
+		panic(err)
+	}
+	for _, plan := range VPCRouterService.Plans {
+		plan.Schemas.ServiceInstance.Create.Parameters = vpcRouterParamSchema
+		plan.Schemas.ServiceInstance.Update.Parameters = vpcRouterUpdateParamSchema
+	}
+
+	var gslbParamSchema, gslbBindParamSchema map[string]interface{}
+	if err := json.Unmarshal([]byte(gslbParameterJSON), &gslbParamSchema); err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal([]byte(gslbBindingParameterJSON), &gslbBindParamSchema); err != nil {
+		panic(err)
+	}
+	GSLBPlanDefault.Schemas.ServiceInstance.Create.Parameters = gslbParamSchema
+	GSLBPlanDefault.Schemas.ServiceBinding.Create.Parameters = gslbBindParamSchema
+}
+
+// setDatabaseBindingSchemas sets schemas of binding parameters of database plans.
+// They are set again when defaults of plans are loaded
+func setDatabaseBindingSchemas() {
+	for _, service := range []*osb.Service{MariaDBService, PostgreSQLService} {
+		for _, plan := range service.Plans {
+			plan.Schemas.ServiceBinding.Create.Parameters = bindingParameterSchema(service, plan)
+		}
+	}
+}
+
+// bindingParameterSchema returns the schema of binding parameters with defaults of the plan
+func bindingParameterSchema(service *osb.Service, plan *osb.Plan) map[string]interface{} {
+	var schema map[string]interface{}
+	err := json.Unmarshal([]byte(databaseBindingParameterJSON), &schema)
+	if err != nil {
+		panic(err)
+	}
+
+	properties := schema["properties"].(map[string]interface{})
+	switch service.ID {
+	case MariaDBServiceID:
+		for _, name := range []string{"encoding", "extensions", "lcCollate", "lcCtype", "schemas"} {
+			delete(properties, name)
+		}
+	case PostgreSQLServiceID:
+		for _, name := range []string{"allowedHost", "characterSet", "collation", "maxQueriesPerHour"} {
+			delete(properties, name)
+		}
+		extensions := properties["extensions"].(map[string]interface{})
+		extensions["items"].(map[string]interface{})["enum"] = postgreSQLAllowedExtensions
+	}
+
+	defaults := planBindingDefaults(plan.ID)
+	for name, v := range map[string]*int{
+		"maxConnections":    defaults.MaxConnections,
+		"maxQueryTime":      defaults.MaxQueryTime,
+		"maxQueriesPerHour": defaults.MaxQueriesPerHour,
+	} {
+		if property, ok := properties[name].(map[string]interface{}); ok && v != nil {
+			property["default"] = *v
+		}
+	}
+	return schema
 }
 
 // PlanIDMap defines relations of between actual plan_id and osb plan_id
@@ -21,7 +21,14 @@ var apiError404 = api.NewError(
 	})
 
 type dummyAPI struct {
-	dbAPI iaas.DatabaseAPI
+	dbAPI  iaas.DatabaseAPI
+	nfsAPI iaas.NFSAPI
+	dnsAPI iaas.DNSAPI
+
+	simpleMonitorAPI iaas.SimpleMonitorAPI
+	switchAPI        iaas.SwitchAPI
+	vpcRouterAPI     iaas.VPCRouterAPI
+	gslbAPI          iaas.GSLBAPI
 }
 
 func (c *dummyAPI) AuthStatus() (*sacloud.AuthStatus, error) {
@@ -33,6 +40,24 @@ func (c *dummyAPI) MariaDB() iaas.DatabaseAPI {
 func (c *dummyAPI) PostgreSQL() iaas.DatabaseAPI {
 	return c.dbAPI
 }
+func (c *dummyAPI) NFS() iaas.NFSAPI {
+	return c.nfsAPI
+}
+func (c *dummyAPI) DNS() iaas.DNSAPI {
+	return c.dnsAPI
+}
+func (c *dummyAPI) SimpleMonitor() iaas.SimpleMonitorAPI {
+	return c.simpleMonitorAPI
+}
+func (c *dummyAPI) Switch() iaas.SwitchAPI {
+	return c.switchAPI
+}
+func (c *dummyAPI) VPCRouter() iaas.VPCRouterAPI {
+	return c.vpcRouterAPI
+}
+func (c *dummyAPI) GSLB() iaas.GSLBAPI {
+	return c.gslbAPI
+}
 
 func existsTestEnvVars(envVars ...string) (res bool) {
 	for _, env := range envVars {
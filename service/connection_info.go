@@ -9,5 +9,7 @@ type ConnectionInfo interface {
 	Port() int
 	DBName() string
 	Salt() string
-	FormatDSN() string
+	TLS() *DatabaseTLSConfig // nil if TLS is disabled
+	FormatDSN() string       // DSN for the driver of the broker
+	URI() string             // URI in the standard format of the DBMS, which is passed to applications
 }
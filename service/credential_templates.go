@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+)
+
+// credentialFormat is a set of templates which render additional credential fields
+type credentialFormat map[string]*template.Template
+
+// credentialFormats holds formats per service ID and format name
+var credentialFormats = map[string]map[string]credentialFormat{}
+
+// credentialTemplateData is the data passed to credential templates
+type credentialTemplateData struct {
+	Host          string
+	Port          string
+	Database      string
+	Username      string
+	Password      string
+	URI           string
+	TLS           bool
+	CACertificate string
+}
+
+var credentialTemplateFuncs = template.FuncMap{
+	"pqQuote": pqQuote,
+}
+
+// defaultCredentialTemplates defines built-in formats per service name
+var defaultCredentialTemplates = map[string]map[string]map[string]string{
+	"sacloud-mariadb": {
+		"jdbc": {
+			"jdbcUrl": `jdbc:mariadb://{{.Host}}:{{.Port}}/{{.Database}}?user={{urlquery .Username}}&password={{urlquery .Password}}{{if .TLS}}&useSSL=true{{end}}`,
+		},
+		"spring": {
+			"spring.datasource.url":               `jdbc:mariadb://{{.Host}}:{{.Port}}/{{.Database}}{{if .TLS}}?useSSL=true{{end}}`,
+			"spring.datasource.username":          `{{.Username}}`,
+			"spring.datasource.password":          `{{.Password}}`,
+			"spring.datasource.driver-class-name": `org.mariadb.jdbc.Driver`,
+		},
+		"env": {
+			"DATABASE_URL": `mysql2://{{urlquery .Username}}:{{urlquery .Password}}@{{.Host}}:{{.Port}}/{{.Database}}{{if .TLS}}?ssl_mode=required{{end}}`,
+		},
+	},
+	"sacloud-postgres": {
+		"jdbc": {
+			"jdbcUrl": `jdbc:postgresql://{{.Host}}:{{.Port}}/{{.Database}}?user={{urlquery .Username}}&password={{urlquery .Password}}{{if .TLS}}&sslmode=require{{end}}`,
+		},
+		"spring": {
+			"spring.datasource.url":               `jdbc:postgresql://{{.Host}}:{{.Port}}/{{.Database}}{{if .TLS}}?sslmode=require{{end}}`,
+			"spring.datasource.username":          `{{.Username}}`,
+			"spring.datasource.password":          `{{.Password}}`,
+			"spring.datasource.driver-class-name": `org.postgresql.Driver`,
+		},
+		"libpq": {
+			"connectionString": `host={{pqQuote .Host}} port={{.Port}} dbname={{pqQuote .Database}} user={{pqQuote .Username}} password={{pqQuote .Password}} sslmode={{if .TLS}}require{{else}}disable{{end}}`,
+		},
+		"env": {
+			"DATABASE_URL": `postgres://{{urlquery .Username}}:{{urlquery .Password}}@{{.Host}}:{{.Port}}/{{.Database}}?sslmode={{if .TLS}}require{{else}}disable{{end}}`,
+		},
+	},
+}
+
+func init() {
+	formats, err := parseCredentialTemplates(defaultCredentialTemplates)
+	if err != nil {
+		panic(err)
+	}
+	credentialFormats = formats
+}
+
+// LoadCredentialTemplates reads operator-defined credential formats from the JSON file.
+// The file has an object like {"<service name>": {"<format name>": {"<field>": "<template>"}}}.
+// Formats in the file are added to built-in formats, or replace them if they have the same name
+func LoadCredentialTemplates(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading credential templates is failed: %s", err)
+	}
+
+	var defs map[string]map[string]map[string]string
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return fmt.Errorf("parsing credential templates is failed: %s", err)
+	}
+
+	merged := map[string]map[string]map[string]string{}
+	for _, src := range []map[string]map[string]map[string]string{defaultCredentialTemplates, defs} {
+		for serviceName, formats := range src {
+			if merged[serviceName] == nil {
+				merged[serviceName] = map[string]map[string]string{}
+			}
+			for name, fields := range formats {
+				merged[serviceName][name] = fields
+			}
+		}
+	}
+
+	formats, err := parseCredentialTemplates(merged)
+	if err != nil {
+		return fmt.Errorf("parsing credential templates is failed: %s", err)
+	}
+	credentialFormats = formats
+	return nil
+}
+
+func parseCredentialTemplates(defs map[string]map[string]map[string]string) (map[string]map[string]credentialFormat, error) {
+	formats := map[string]map[string]credentialFormat{}
+	for serviceName, serviceFormats := range defs {
+		serviceID := databaseServiceID(serviceName)
+		if serviceID == "" {
+			return nil, fmt.Errorf("unknown service %q", serviceName)
+		}
+
+		formats[serviceID] = map[string]credentialFormat{}
+		for name, fields := range serviceFormats {
+			format := credentialFormat{}
+			for field, text := range fields {
+				tmpl, err := template.New(field).Funcs(credentialTemplateFuncs).Parse(text)
+				if err != nil {
+					return nil, fmt.Errorf("template %q of format %q is invalid: %s", field, name, err)
+				}
+				// detect references to undefined fields before binding
+				if err := tmpl.Execute(ioutil.Discard, &credentialTemplateData{}); err != nil {
+					return nil, fmt.Errorf("template %q of format %q is invalid: %s", field, name, err)
+				}
+				format[field] = tmpl
+			}
+			formats[serviceID][name] = format
+		}
+	}
+	return formats, nil
+}
+
+// hasCredentialFormat returns true if the format is defined for the service
+func hasCredentialFormat(serviceID, name string) bool {
+	_, ok := credentialFormats[serviceID][name]
+	return ok
+}
+
+// renderCredentialFormat adds fields of the format to credentials
+func renderCredentialFormat(serviceID, name string, credentials map[string]string) error {
+	format, ok := credentialFormats[serviceID][name]
+	if !ok {
+		return fmt.Errorf("credential format %q is not defined", name)
+	}
+
+	data := &credentialTemplateData{
+		Host:          credentials["host"],
+		Port:          credentials["port"],
+		Database:      credentials["database"],
+		Username:      credentials["username"],
+		Password:      credentials["password"],
+		URI:           credentials["uri"],
+		TLS:           credentials["sslRequired"] == "true",
+		CACertificate: credentials["ca_certificate"],
+	}
+
+	rendered := map[string]string{}
+	for field, tmpl := range format {
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, data); err != nil {
+			return fmt.Errorf("rendering %q of credential format %q is failed: %s", field, name, err)
+		}
+		rendered[field] = buf.String()
+	}
+	for field, value := range rendered {
+		credentials[field] = value
+	}
+	return nil
+}
+
+func databaseServiceID(serviceName string) string {
+	for _, service := range CurrentCatalog.Services {
+		if service.Name == serviceName {
+			return service.ID
+		}
+	}
+	return ""
+}
+
+// pqQuote quotes the value of libpq keyword/value connection strings
+func pqQuote(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `'`, `\'`, -1)
+	return "'" + v + "'"
+}
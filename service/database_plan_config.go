@@ -0,0 +1,92 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+)
+
+// DatabasePlanConfig represents settings of a database catalog plan
+type DatabasePlanConfig struct {
+	// TLS enables TLS connections to database appliances of the plan. It is enabled unless false is set
+	TLS *bool `json:"tls,omitempty"`
+	DatabaseTLSConfig
+	// BindingDefaults defines resource limits of binding users which binding parameters don't specify
+	BindingDefaults params.DatabaseBindingLimits `json:"bindingDefaults"`
+}
+
+// databaseBindingDefaults holds default resource limits of binding users per catalog plan ID
+var databaseBindingDefaults = map[string]params.DatabaseBindingLimits{}
+
+// LoadDatabasePlanConfigs reads settings per catalog plan from the JSON file.
+// The file has an object which maps plan IDs to settings
+func LoadDatabasePlanConfigs(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading database plan config is failed: %s", err)
+	}
+
+	configs := map[string]*DatabasePlanConfig{}
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return fmt.Errorf("parsing database plan config is failed: %s", err)
+	}
+
+	tlsConfigs := map[string]*DatabaseTLSConfig{}
+	bindingDefaults := map[string]params.DatabaseBindingLimits{}
+	for planID, config := range configs {
+		if !isDatabasePlanID(planID) {
+			return fmt.Errorf("database plan config has unknown plan ID %q", planID)
+		}
+		if config == nil {
+			config = &DatabasePlanConfig{}
+		}
+
+		if err := config.BindingDefaults.Validate(); err != nil {
+			return fmt.Errorf("binding defaults of plan %q are invalid: %s", planID, err)
+		}
+		if isPostgreSQLPlanID(planID) && params.LimitValue(config.BindingDefaults.MaxQueriesPerHour) > 0 {
+			return fmt.Errorf("binding defaults of plan %q are invalid: maxQueriesPerHour is not supported by PostgreSQL", planID)
+		}
+		bindingDefaults[planID] = config.BindingDefaults
+
+		if config.TLS != nil && !*config.TLS {
+			continue
+		}
+		if err := config.DatabaseTLSConfig.load(planID); err != nil {
+			return fmt.Errorf("loading database TLS config of plan %q is failed: %s", planID, err)
+		}
+		tlsConfigs[planID] = &config.DatabaseTLSConfig
+	}
+
+	databaseTLSConfigs = tlsConfigs
+	databaseBindingDefaults = bindingDefaults
+	setDatabaseBindingSchemas()
+	return nil
+}
+
+// planBindingDefaults returns default resource limits of binding users of the catalog plan
+func planBindingDefaults(planID string) params.DatabaseBindingLimits {
+	return databaseBindingDefaults[planID]
+}
+
+func isDatabasePlanID(planID string) bool {
+	for _, idMap := range DatabaseIDMap {
+		for _, id := range idMap.PlanIDMap {
+			if id == planID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isPostgreSQLPlanID(planID string) bool {
+	for _, id := range DatabaseIDMap["postgres"].PlanIDMap {
+		if id == planID {
+			return true
+		}
+	}
+	return false
+}
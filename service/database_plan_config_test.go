@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDatabasePlanConfigs(t *testing.T) {
+	defer func() {
+		databaseTLSConfigs = map[string]*DatabaseTLSConfig{}
+		databaseBindingDefaults = map[string]params.DatabaseBindingLimits{}
+		setDatabaseBindingSchemas()
+	}()
+
+	dir, err := ioutil.TempDir("", "osbs-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint
+
+	ca := newTestCertificate(t, "ca", nil)
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caFile, ca.pem(), 0600))
+
+	writeConfig := func(content string) string {
+		path := filepath.Join(dir, "plan.json")
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	t.Run("valid config", func(t *testing.T) {
+		path := writeConfig(fmt.Sprintf(`{%q: {"caFile": %q}, %q: {}}`,
+			MariaDBPlan10GID, caFile, PostgreSQLPlan10GID))
+
+		err := LoadDatabasePlanConfigs(path)
+		assert.NoError(t, err)
+
+		withCA := planTLSConfig(MariaDBPlan10GID)
+		assert.NotNil(t, withCA)
+		assert.Equal(t, string(ca.pem()), withCA.CACertificate())
+		assert.Equal(t, "sacloud-"+MariaDBPlan10GID, withCA.mysqlConfigName)
+
+		withoutCA := planTLSConfig(PostgreSQLPlan10GID)
+		assert.NotNil(t, withoutCA)
+		assert.Empty(t, withoutCA.CACertificate())
+
+		assert.Nil(t, planTLSConfig(MariaDBPlan30GID))
+	})
+
+	t.Run("binding defaults", func(t *testing.T) {
+		path := writeConfig(fmt.Sprintf(`{%q: {"tls": false, "bindingDefaults": {"maxConnections": 50, "maxQueryTime": 0}}}`,
+			MariaDBPlan10GID))
+
+		err := LoadDatabasePlanConfigs(path)
+		assert.NoError(t, err)
+
+		assert.Nil(t, planTLSConfig(MariaDBPlan10GID))
+		assert.Equal(t, params.DatabaseBindingLimits{
+			MaxConnections: params.Limit(50),
+			MaxQueryTime:   params.Limit(0),
+		}, planBindingDefaults(MariaDBPlan10GID))
+		assert.Equal(t, params.DatabaseBindingLimits{}, planBindingDefaults(MariaDBPlan30GID))
+
+		// defaults are shown in the catalog
+		schema := MariaDBPlan10G.Schemas.ServiceBinding.Create.Parameters
+		properties := schema["properties"].(map[string]interface{})
+		assert.Equal(t, 50, properties["maxConnections"].(map[string]interface{})["default"])
+		assert.Equal(t, 0, properties["maxQueryTime"].(map[string]interface{})["default"])
+		assert.NotContains(t, properties["maxQueriesPerHour"], "default")
+	})
+
+	t.Run("unknown plan", func(t *testing.T) {
+		path := writeConfig(`{"not-exists": {}}`)
+		assert.Error(t, LoadDatabasePlanConfigs(path))
+	})
+
+	t.Run("invalid CA file", func(t *testing.T) {
+		invalid := filepath.Join(dir, "invalid.pem")
+		assert.NoError(t, ioutil.WriteFile(invalid, []byte("invalid"), 0600))
+		path := writeConfig(fmt.Sprintf(`{%q: {"caFile": %q}}`, MariaDBPlan10GID, invalid))
+		assert.Error(t, LoadDatabasePlanConfigs(path))
+	})
+
+	t.Run("invalid binding defaults", func(t *testing.T) {
+		path := writeConfig(fmt.Sprintf(`{%q: {"bindingDefaults": {"maxConnections": -1}}}`, MariaDBPlan10GID))
+		assert.Error(t, LoadDatabasePlanConfigs(path))
+
+		path = writeConfig(fmt.Sprintf(`{%q: {"bindingDefaults": {"maxQueriesPerHour": 1000}}}`, PostgreSQLPlan10GID))
+		assert.Error(t, LoadDatabasePlanConfigs(path))
+	})
+}
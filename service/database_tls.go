@@ -0,0 +1,86 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DatabaseTLSConfig represents TLS settings to connect database appliances of a catalog plan
+type DatabaseTLSConfig struct {
+	// CAFile is the PEM file of the CA. Server certificates are verified with the CA if set
+	CAFile string `json:"caFile,omitempty"`
+
+	caCert          []byte
+	mysqlConfigName string
+}
+
+// databaseTLSConfigs holds TLS settings per catalog plan ID
+var databaseTLSConfigs = map[string]*DatabaseTLSConfig{}
+
+func (c *DatabaseTLSConfig) load(planID string) error {
+	if c.CAFile == "" {
+		return nil
+	}
+
+	cert, err := ioutil.ReadFile(c.CAFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(cert) {
+		return fmt.Errorf("no certificates in %q", c.CAFile)
+	}
+	c.caCert = cert
+
+	// go-sql-driver/mysql refers TLS settings by registered name
+	c.mysqlConfigName = "sacloud-" + planID
+	return mysql.RegisterTLSConfig(c.mysqlConfigName, &tls.Config{
+		// appliances are accessed by IP address, so only the certificate chain is verified
+		InsecureSkipVerify:    true, // nolint
+		VerifyPeerCertificate: verifyCertificateChain(pool),
+	})
+}
+
+// CACertificate returns the PEM of the CA. It returns empty string if server certificates are not verified
+func (c *DatabaseTLSConfig) CACertificate() string {
+	if c == nil {
+		return ""
+	}
+	return string(c.caCert)
+}
+
+func verifyCertificateChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server certificate is not presented")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		return err
+	}
+}
+
+// planTLSConfig returns TLS settings of the catalog plan. It returns nil if TLS is not configured
+func planTLSConfig(planID string) *DatabaseTLSConfig {
+	return databaseTLSConfigs[planID]
+}
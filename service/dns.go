@@ -0,0 +1,417 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/sacloud/libsacloud/api"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+)
+
+// DNSParentZone is the operator-configured zone in which subdomains of sacloud-dns instances are delegated
+var DNSParentZone string
+
+// dnsDefaultTTL is the TTL of records which don't have the ttl parameter
+const dnsDefaultTTL = 3600
+
+// dnsAttrs implements InstanceState interface. Zones are available as soon as they are created
+type dnsAttrs struct {
+	*sacloud.DNS
+	parameter *params.DNSCreateParameter
+}
+
+func (a *dnsAttrs) IsUp() bool {
+	return true
+}
+
+func (a *dnsAttrs) IsFailed() bool {
+	return false
+}
+
+func (a *dnsAttrs) IsMigrating() bool {
+	return false
+}
+
+func (a *dnsAttrs) HasDiff() bool {
+	if a.parameter == nil {
+		return false
+	}
+	return a.parameter.ZoneName() != a.Status.Zone
+}
+
+// dnsInstanceParameters represents parameters of fetched instances with the zone and name servers
+type dnsInstanceParameters struct {
+	*params.DNSCreateParameter
+	ZoneName    string   `json:"zoneName"`
+	NameServers []string `json:"nameServers"`
+}
+
+// fetchDNS returns the instance with the requested parameters and the actual zone.
+// It returns nil if the instance is not found
+func fetchDNS(instanceID string) (*osb.ServiceInstanceResource, error) {
+	zone, err := sacloudAPI.DNS().Read(instanceID)
+	if err != nil {
+		if e, ok := err.(api.Error); ok && e.ResponseCode() == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	desired, err := iaas.DesiredDNSParameter(zone)
+	if err != nil {
+		return nil, fmt.Errorf("reading requested parameter is failed: %s", err)
+	}
+	if desired == nil {
+		desired = &params.DNSCreateParameter{}
+	}
+
+	return &osb.ServiceInstanceResource{
+		ServiceID: DNSServiceID,
+		PlanID:    DNSPlanZoneID,
+		Parameters: &dnsInstanceParameters{
+			DNSCreateParameter: desired,
+			ZoneName:           zone.Status.Zone,
+			NameServers:        zone.Status.NS,
+		},
+	}, nil
+}
+
+// dnsBinding implements BindingState interface
+type dnsBinding struct {
+	binding *osb.ServiceBinding
+}
+
+func (b *dnsBinding) HasDiff() bool {
+	return false // not supported
+}
+
+func (b *dnsBinding) Binding() *osb.ServiceBinding {
+	return b.binding
+}
+
+type dnsHandler struct {
+	operation    string
+	serviceID    string
+	planID       string
+	rawParameter []byte
+
+	parameter     *params.DNSCreateParameter
+	bindParameter *params.DNSBindParameter
+	paramErr      error
+}
+
+func newDNSServiceHandler(operation, serviceID, planID string, rawParameter []byte) *dnsHandler {
+	handler := &dnsHandler{
+		operation:    operation,
+		serviceID:    serviceID,
+		planID:       planID,
+		rawParameter: rawParameter,
+	}
+
+	switch operation {
+	case operations.Provisioning:
+		if len(rawParameter) == 0 {
+			handler.paramErr = errors.New("dnsService parameter JSON is empty")
+			return handler
+		}
+
+		var p = params.DNSCreateParameter{}
+		err := json.Unmarshal(rawParameter, &p)
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		// the parent zone is decided by the operator
+		p.ParentZone = ""
+		if p.Subdomain != "" {
+			p.ParentZone = DNSParentZone
+		}
+
+		err = p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		handler.parameter = &p
+	case operations.Updating:
+		handler.paramErr = errors.New("updating dnsService is not supported")
+	case operations.Binding:
+		if len(rawParameter) == 0 {
+			handler.paramErr = errors.New("dnsService parameter JSON is empty")
+			return handler
+		}
+
+		var p = params.DNSBindParameter{}
+		err := json.Unmarshal(rawParameter, &p)
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		err = p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		handler.bindParameter = &p
+	}
+
+	return handler
+}
+
+func (s *dnsHandler) InstanceState(instanceID string) (InstanceState, error) {
+	zone, err := sacloudAPI.DNS().Read(instanceID)
+	if err != nil {
+		if e, ok := err.(api.Error); ok {
+			if e.ResponseCode() != http.StatusNotFound {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	if zone == nil {
+		if s.operation == operations.Deprovisioning {
+			knownInstances.remove(instanceID)
+		}
+		return nil, nil
+	}
+	knownInstances.add(instanceID, s.serviceID)
+
+	parameter := s.parameter
+	if parameter == nil {
+		// broken descriptions are ignored not to block other operations
+		parameter, _ = iaas.DesiredDNSParameter(zone)
+	}
+
+	return &dnsAttrs{
+		DNS:       zone,
+		parameter: parameter,
+	}, nil
+}
+
+func (s *dnsHandler) BindingState(instanceID, bindingID string) (BindingState, error) {
+	zone, err := sacloudAPI.DNS().Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := dnsOwnedRecords(zone, bindingID)
+	if owned == nil {
+		return nil, nil
+	}
+
+	return &dnsBinding{
+		binding: &osb.ServiceBinding{
+			Credentials: dnsCredentials(zone, owned),
+		},
+	}, nil
+}
+
+func (s *dnsHandler) CreateInstance(instanceID string) error {
+	_, err := sacloudAPI.DNS().Create(instanceID, s.parameter)
+	if err != nil {
+		return err
+	}
+	knownInstances.add(instanceID, s.serviceID)
+	return nil
+}
+
+func (s *dnsHandler) UpdateInstance(instanceID string) error {
+	return errors.New("updating dnsService is not supported")
+}
+
+func (s *dnsHandler) DeleteInstance(instanceID string) error {
+	err := sacloudAPI.DNS().Delete(instanceID)
+	if err != nil {
+		return err
+	}
+	knownInstances.markDeleting(instanceID)
+	return nil
+}
+
+func (s *dnsHandler) CreateBinding(instanceID, bindingID string) (*osb.ServiceBinding, error) {
+	if s.bindParameter == nil {
+		return nil, errors.New("bind parameter is nil")
+	}
+
+	var records []sacloud.DNSRecordSet
+	var keys []string
+	for _, r := range s.bindParameter.Records {
+		record := dnsRecordSet(r)
+		records = append(records, record)
+		keys = append(keys, dnsRecordKey(record))
+	}
+	// the marker record keeps records created by the binding to remove them on unbinding
+	records = append(records, sacloud.DNSRecordSet{
+		Name:  dnsBindingMarkerName(bindingID),
+		Type:  "TXT",
+		RData: strings.Join(keys, " "),
+		TTL:   dnsDefaultTTL,
+	})
+
+	// records are checked in the lock of the zone not to be added by concurrent bindings
+	var rejected error
+	zone, err := sacloudAPI.DNS().UpdateRecords(instanceID, records, nil, func(latest *sacloud.DNS) error {
+		if dnsOwnedRecords(latest, bindingID) != nil {
+			rejected = &osb.BindingAlreadyExistsError{}
+		} else {
+			rejected = dnsRecordConflicts(latest.Settings.DNS.ResourceRecordSets, s.bindParameter.Records)
+		}
+		return rejected
+	})
+	if rejected != nil {
+		return nil, rejected
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating DNS records is failed: %s", err)
+	}
+
+	return &osb.ServiceBinding{
+		Credentials: dnsCredentials(zone, dnsOwnedRecords(zone, bindingID)),
+	}, nil
+}
+
+func (s *dnsHandler) DeleteBinding(instanceID, bindingID string) error {
+	client := sacloudAPI.DNS()
+	zone, err := client.Read(instanceID)
+	if err != nil {
+		return err
+	}
+
+	owned := dnsOwnedRecords(zone, bindingID)
+	if owned == nil {
+		return nil
+	}
+	for _, r := range zone.Settings.DNS.ResourceRecordSets {
+		if r.Name == dnsBindingMarkerName(bindingID) && r.Type == "TXT" {
+			owned = append(owned, r)
+		}
+	}
+
+	if _, err := client.UpdateRecords(instanceID, nil, owned, nil); err != nil {
+		return fmt.Errorf("deleting DNS records is failed: %s", err)
+	}
+	return nil
+}
+
+func (s *dnsHandler) ConfirmProvision(instanceID string) error {
+	return sacloudAPI.DNS().ConfirmProvisioned(instanceID)
+}
+
+func (s *dnsHandler) IsValid() (bool, error) {
+	return s.paramErr == nil, s.paramErr
+}
+
+func dnsBindingMarkerName(bindingID string) string {
+	return params.DNSBindingMarkerPrefix + bindingID
+}
+
+// dnsOwnedRecords returns records created by the binding. It returns nil if the binding doesn't exist
+func dnsOwnedRecords(zone *sacloud.DNS, bindingID string) []sacloud.DNSRecordSet {
+	var keys []string
+	found := false
+	for _, r := range zone.Settings.DNS.ResourceRecordSets {
+		if r.Name == dnsBindingMarkerName(bindingID) && r.Type == "TXT" {
+			keys = strings.Fields(strings.Trim(r.RData, `"`))
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	owned := []sacloud.DNSRecordSet{}
+	for _, r := range zone.Settings.DNS.ResourceRecordSets {
+		if containsString(keys, dnsRecordKey(r)) {
+			owned = append(owned, r)
+		}
+	}
+	return owned
+}
+
+// dnsRecordKey returns the key of the record in the marker. It is the hash of the name, the type and the value,
+// so records added to the same name and type later are not regarded as records of the binding.
+// Keys have the fixed length to fit keys of all records of the binding in a TXT value(255 characters)
+func dnsRecordKey(r sacloud.DNSRecordSet) string {
+	value := strings.TrimSuffix(strings.Trim(r.RData, `"`), ".")
+	sum := sha256.Sum256([]byte(r.Name + "/" + r.Type + "/" + value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// dnsRecordConflicts returns error if requested records conflict with records in the zone
+func dnsRecordConflicts(existing []sacloud.DNSRecordSet, requested []params.DNSRecord) error {
+	for i, r := range requested {
+		for _, e := range existing {
+			if e.Name != r.Name {
+				continue
+			}
+			if e.Type == r.Type || e.Type == "CNAME" || r.Type == "CNAME" {
+				return fmt.Errorf("records[%d]: %s record of %q already exists", i, e.Type, r.Name)
+			}
+		}
+		for j, o := range requested[:i] {
+			if o.Name == r.Name && (o.Type == "CNAME" || r.Type == "CNAME") {
+				return fmt.Errorf("records[%d]: CNAME record can't be used with records[%d]", i, j)
+			}
+		}
+	}
+	return nil
+}
+
+func dnsRecordSet(r params.DNSRecord) sacloud.DNSRecordSet {
+	value := r.Value
+	if r.Type == "CNAME" && !strings.HasSuffix(value, ".") {
+		value += "."
+	}
+	ttl := r.TTL
+	if ttl == 0 {
+		ttl = dnsDefaultTTL
+	}
+	return sacloud.DNSRecordSet{Name: r.Name, Type: r.Type, RData: value, TTL: ttl}
+}
+
+// dnsCredentials returns credentials which contain the zone, name servers and FQDNs of records
+func dnsCredentials(zone *sacloud.DNS, records []sacloud.DNSRecordSet) map[string]interface{} {
+	var fqdns []string
+	for _, r := range records {
+		name := zone.Status.Zone
+		if r.Name != "@" {
+			name = r.Name + "." + zone.Status.Zone
+		}
+		if !containsString(fqdns, name) {
+			fqdns = append(fqdns, name)
+		}
+	}
+	sort.Strings(fqdns)
+
+	return map[string]interface{}{
+		"zone":        zone.Status.Zone,
+		"nameServers": zone.Status.NS,
+		"fqdns":       fqdns,
+	}
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/stretchr/testify/assert"
+)
+
+type dummyDNSAPI struct {
+	zone      *sacloud.DNS
+	readErr   error
+	createErr error
+	updateErr error
+	deleteErr error
+
+	created *params.DNSCreateParameter
+}
+
+func (c *dummyDNSAPI) List() ([]sacloud.DNS, error) {
+	if c.zone == nil {
+		return nil, c.readErr
+	}
+	return []sacloud.DNS{*c.zone}, c.readErr
+}
+
+func (c *dummyDNSAPI) Read(instanceID string) (*sacloud.DNS, error) {
+	return c.zone, c.readErr
+}
+
+func (c *dummyDNSAPI) Create(instanceID string, param *params.DNSCreateParameter) (*sacloud.DNS, error) {
+	c.created = param
+	return c.zone, c.createErr
+}
+
+func (c *dummyDNSAPI) UpdateRecords(instanceID string, add, remove []sacloud.DNSRecordSet, check func(zone *sacloud.DNS) error) (*sacloud.DNS, error) {
+	if c.updateErr != nil {
+		return nil, c.updateErr
+	}
+	if check != nil {
+		if err := check(c.zone); err != nil {
+			return nil, err
+		}
+	}
+	records := []sacloud.DNSRecordSet{}
+	for _, r := range c.zone.Settings.DNS.ResourceRecordSets {
+		removed := false
+		for _, v := range remove {
+			removed = removed || v == r
+		}
+		if !removed {
+			records = append(records, r)
+		}
+	}
+	c.zone.Settings.DNS.ResourceRecordSets = append(records, add...)
+	return c.zone, nil
+}
+
+func (c *dummyDNSAPI) ConfirmProvisioned(instanceID string) error {
+	return nil
+}
+
+func (c *dummyDNSAPI) Delete(instanceID string) error {
+	return c.deleteErr
+}
+
+func testDNSZone(records ...sacloud.DNSRecordSet) *sacloud.DNS {
+	zone := sacloud.CreateNewDNS("apps.example.com")
+	zone.Status.NS = []string{"ns1.example.net", "ns2.example.net"}
+	zone.Settings.DNS.ResourceRecordSets = records
+	return zone
+}
+
+func TestDNSServiceValidate(t *testing.T) {
+	defer func() { DNSParentZone = "" }()
+
+	t.Run("Provisioning", func(t *testing.T) {
+		s := newDNSServiceHandler(operations.Provisioning, DNSServiceID, DNSPlanZoneID, []byte(`{"zone": "apps.example.com"}`))
+		_, err := s.IsValid()
+		assert.NoError(t, err)
+
+		// subdomains need the parent zone
+		s = newDNSServiceHandler(operations.Provisioning, DNSServiceID, DNSPlanZoneID, []byte(`{"subdomain": "team"}`))
+		_, err = s.IsValid()
+		assert.Error(t, err)
+
+		DNSParentZone = "example.com"
+		s = newDNSServiceHandler(operations.Provisioning, DNSServiceID, DNSPlanZoneID, []byte(`{"subdomain": "team", "parentZone": "evil.com"}`))
+		_, err = s.IsValid()
+		assert.NoError(t, err)
+		assert.Equal(t, "team.example.com", s.parameter.ZoneName())
+
+		s = newDNSServiceHandler(operations.Provisioning, DNSServiceID, DNSPlanZoneID, []byte(`{"zone": "apps.example.com", "parentZone": "evil.com"}`))
+		_, err = s.IsValid()
+		assert.NoError(t, err)
+		assert.Empty(t, s.parameter.ParentZone)
+	})
+
+	t.Run("Binding", func(t *testing.T) {
+		s := newDNSServiceHandler(operations.Binding, DNSServiceID, DNSPlanZoneID, []byte(``))
+		_, err := s.IsValid()
+		assert.Error(t, err)
+
+		s = newDNSServiceHandler(operations.Binding, DNSServiceID, DNSPlanZoneID,
+			[]byte(`{"records": [{"name": "www", "type": "A", "value": "192.0.2.1"}]}`))
+		_, err = s.IsValid()
+		assert.NoError(t, err)
+	})
+}
+
+func TestDNSHandler(t *testing.T) {
+	dnsAPI := &dummyDNSAPI{}
+	sacloudAPI = &dummyAPI{dnsAPI: dnsAPI}
+	defer func() { sacloudAPI = testAPI }()
+
+	manual := sacloud.DNSRecordSet{Name: "mail", Type: "A", RData: "192.0.2.100", TTL: 300}
+
+	t.Run("InstanceState", func(t *testing.T) {
+		dnsAPI.zone = testDNSZone()
+
+		s := newDNSServiceHandler(operations.Provisioning, DNSServiceID, DNSPlanZoneID, []byte(`{"zone": "apps.example.com"}`))
+		state, err := s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.True(t, state.IsUp())
+		assert.False(t, state.HasDiff())
+
+		s = newDNSServiceHandler(operations.Provisioning, DNSServiceID, DNSPlanZoneID, []byte(`{"zone": "other.example.com"}`))
+		state, err = s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.True(t, state.HasDiff())
+	})
+
+	t.Run("Create and delete bindings", func(t *testing.T) {
+		dnsAPI.zone = testDNSZone(manual)
+
+		s := newDNSServiceHandler(operations.Binding, DNSServiceID, DNSPlanZoneID, []byte(`{"records": [
+			{"name": "www", "type": "A", "value": "192.0.2.1"},
+			{"name": "www", "type": "A", "value": "192.0.2.2", "ttl": 60},
+			{"name": "api", "type": "CNAME", "value": "www.apps.example.com"}
+		]}`))
+		binding, err := s.CreateBinding(instanceID, bindingID)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"zone":        "apps.example.com",
+			"nameServers": []string{"ns1.example.net", "ns2.example.net"},
+			"fqdns":       []string{"api.apps.example.com", "www.apps.example.com"},
+		}, binding.Credentials)
+		assert.Contains(t, dnsAPI.zone.Settings.DNS.ResourceRecordSets,
+			sacloud.DNSRecordSet{Name: "api", Type: "CNAME", RData: "www.apps.example.com.", TTL: 3600})
+
+		// the same binding ID
+		_, err = s.CreateBinding(instanceID, bindingID)
+		assert.IsType(t, &osb.BindingAlreadyExistsError{}, err)
+
+		state, err := s.BindingState(instanceID, bindingID)
+		assert.NoError(t, err)
+		assert.Equal(t, binding, state.Binding())
+
+		// conflicts with records of other bindings
+		other := newDNSServiceHandler(operations.Binding, DNSServiceID, DNSPlanZoneID,
+			[]byte(`{"records": [{"name": "api", "type": "TXT", "value": "foo"}]}`))
+		_, err = other.CreateBinding(instanceID, "other-binding")
+		assert.Error(t, err)
+
+		other = newDNSServiceHandler(operations.Binding, DNSServiceID, DNSPlanZoneID,
+			[]byte(`{"records": [{"name": "@", "type": "TXT", "value": "foo"}]}`))
+		_, err = other.CreateBinding(instanceID, "other-binding")
+		assert.NoError(t, err)
+
+		// records added to the same name and type later are not owned by the binding
+		dnsAPI.zone.Settings.DNS.ResourceRecordSets = append(dnsAPI.zone.Settings.DNS.ResourceRecordSets,
+			sacloud.DNSRecordSet{Name: "www", Type: "A", RData: "192.0.2.9", TTL: 3600})
+
+		// only records of the binding are removed
+		err = s.DeleteBinding(instanceID, bindingID)
+		assert.NoError(t, err)
+		state, err = s.BindingState(instanceID, bindingID)
+		assert.NoError(t, err)
+		assert.Nil(t, state)
+
+		var names []string
+		for _, r := range dnsAPI.zone.Settings.DNS.ResourceRecordSets {
+			names = append(names, r.Name)
+		}
+		assert.Equal(t, []string{"mail", "@", "_osbs-other-binding", "www"}, names)
+
+		// unbinding twice
+		assert.NoError(t, s.DeleteBinding(instanceID, bindingID))
+	})
+}
+
+func TestDNSOwnedRecords(t *testing.T) {
+	www := sacloud.DNSRecordSet{Name: "www", Type: "A", RData: "192.0.2.1", TTL: 3600}
+	other := sacloud.DNSRecordSet{Name: "www", Type: "A", RData: "192.0.2.2", TTL: 3600}
+	txt := sacloud.DNSRecordSet{Name: "@", Type: "TXT", RData: `"v=spf1 -all"`, TTL: 3600}
+
+	zone := testDNSZone(www, other, txt, sacloud.DNSRecordSet{
+		Name:  "_osbs-binding",
+		Type:  "TXT",
+		RData: fmt.Sprintf(`"%s %s"`, dnsRecordKey(www), dnsRecordKey(sacloud.DNSRecordSet{Name: "@", Type: "TXT", RData: "v=spf1 -all"})),
+	})
+	assert.Equal(t, []sacloud.DNSRecordSet{www, txt}, dnsOwnedRecords(zone, "binding"))
+	assert.Nil(t, dnsOwnedRecords(zone, "other-binding"))
+
+	// keys which have only names and types don't match records
+	zone = testDNSZone(www, other, sacloud.DNSRecordSet{Name: "_osbs-binding", Type: "TXT", RData: `"www/A"`})
+	assert.Empty(t, dnsOwnedRecords(zone, "binding"))
+}
+
+func TestDNSBindingMarkerLength(t *testing.T) {
+	dnsAPI := &dummyDNSAPI{zone: testDNSZone()}
+	sacloudAPI = &dummyAPI{dnsAPI: dnsAPI}
+	defer func() { sacloudAPI = testAPI }()
+
+	// the maximum number of records with the longest names
+	label := strings.Repeat("a", 62)
+	var records []string
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("%s.%s.%s.%s%d", label, label, label, label[:60], i)
+		records = append(records, fmt.Sprintf(`{"name": %q, "type": "TXT", "value": %q}`, name, strings.Repeat("v", 255)))
+	}
+	s := newDNSServiceHandler(operations.Binding, DNSServiceID, DNSPlanZoneID,
+		[]byte(fmt.Sprintf(`{"records": [%s]}`, strings.Join(records, ","))))
+	_, err := s.IsValid()
+	assert.NoError(t, err)
+
+	_, err = s.CreateBinding(instanceID, bindingID)
+	assert.NoError(t, err)
+
+	var marker *sacloud.DNSRecordSet
+	for i, r := range dnsAPI.zone.Settings.DNS.ResourceRecordSets {
+		if r.Name == dnsBindingMarkerName(bindingID) {
+			marker = &dnsAPI.zone.Settings.DNS.ResourceRecordSets[i]
+		}
+	}
+	if assert.NotNil(t, marker) {
+		assert.True(t, len(marker.RData) <= 255, "marker has %d characters", len(marker.RData))
+		assert.Len(t, strings.Fields(marker.RData), 10)
+	}
+}
+
+func TestDNSRecordConflicts(t *testing.T) {
+	existing := []sacloud.DNSRecordSet{
+		{Name: "www", Type: "A", RData: "192.0.2.1"},
+		{Name: "api", Type: "CNAME", RData: "www.example.com."},
+	}
+
+	assert.Error(t, dnsRecordConflicts(existing, []params.DNSRecord{{Name: "www", Type: "A", Value: "192.0.2.2"}}))
+	assert.Error(t, dnsRecordConflicts(existing, []params.DNSRecord{{Name: "www", Type: "CNAME", Value: "example.com"}}))
+	assert.Error(t, dnsRecordConflicts(existing, []params.DNSRecord{{Name: "api", Type: "TXT", Value: "foo"}}))
+	assert.NoError(t, dnsRecordConflicts(existing, []params.DNSRecord{{Name: "www", Type: "TXT", Value: "foo"}}))
+	assert.Error(t, dnsRecordConflicts(nil, []params.DNSRecord{
+		{Name: "app", Type: "A", Value: "192.0.2.1"},
+		{Name: "app", Type: "CNAME", Value: "example.com"},
+	}))
+}
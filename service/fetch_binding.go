@@ -0,0 +1,9 @@
+package service
+
+import "github.com/sacloud/open-service-broker-sacloud/osb"
+
+// FetchBinding returns the binding with parameters of the actual resource.
+// Only bindings of GSLBs are retrievable. It returns nil if the binding is not found
+func FetchBinding(instanceID, bindingID string) (*osb.ServiceBindingResource, error) {
+	return fetchGSLBBinding(instanceID, bindingID)
+}
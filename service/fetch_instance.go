@@ -0,0 +1,111 @@
+package service
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+)
+
+// FetchInstance returns the instance with actual parameters of the resource.
+// The service is looked up from the instance if serviceID is empty.
+// It returns nil if the instance is not found, or the service doesn't support fetching instances
+func FetchInstance(instanceID, serviceID string) (*osb.ServiceInstanceResource, error) {
+	if serviceID == "" {
+		id, err := lookupServiceID(instanceID)
+		if err != nil || id == "" {
+			return nil, err
+		}
+		serviceID = id
+	}
+
+	inv := inventoryOf(serviceID)
+	if inv == nil || !inv.service.InstancesRetrievable {
+		return nil, nil
+	}
+	return inv.fetch(instanceID)
+}
+
+// lookupServiceID returns the service of the instance. Instances known to the broker are looked up first,
+// then resources on SAKURA Cloud. It returns empty if the instance is not found
+func lookupServiceID(instanceID string) (string, error) {
+	if instance, ok := knownInstances.get(instanceID); ok {
+		return instance.serviceID, nil
+	}
+
+	for _, inv := range inventories() {
+		resources, err := inv.list()
+		if err != nil {
+			return "", fmt.Errorf("listing %q is failed: %s", inv.service.Name, err)
+		}
+		for _, r := range resources {
+			if r.instanceID == instanceID {
+				return r.serviceID, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// fetchDatabase returns the instance with parameters of the actual database.
+// It returns nil if the instance is not found
+func fetchDatabase(target *reconcileTarget, instanceID string) (*osb.ServiceInstanceResource, error) {
+	dbs, err := target.dialect.databaseAPI().List()
+	if err != nil {
+		return nil, fmt.Errorf("listing %q is failed: %s", target.service.Name, err)
+	}
+	var db *sacloud.Database
+	for i := range dbs {
+		if dbs[i].Name == instanceID {
+			db = &dbs[i]
+			break
+		}
+	}
+	if db == nil {
+		return nil, nil
+	}
+
+	desired, err := iaas.DesiredDatabaseParameter(db)
+	if err != nil {
+		return nil, fmt.Errorf("reading requested parameter is failed: %s", err)
+	}
+	if desired != nil {
+		if drift := databaseDrift(db, desired); len(drift) > 0 {
+			log.WithFields(log.Fields{
+				"instanceID": instanceID,
+				"drift":      drift,
+			}).Warn("instance has drift from the requested parameter")
+		}
+	}
+
+	var planID string
+	if db.Remark != nil {
+		planID = target.planIDs[int(db.Remark.GetPlanID())]
+	}
+	return &osb.ServiceInstanceResource{
+		ServiceID:  target.service.ID,
+		PlanID:     planID,
+		Parameters: actualDatabaseParameter(db, desired),
+	}, nil
+}
+
+// actualDatabaseParameter returns the parameter which values compared by databaseDrift are read from the database
+func actualDatabaseParameter(db *sacloud.Database, desired *params.DatabaseCreateParameter) *params.DatabaseCreateParameter {
+	p := &params.DatabaseCreateParameter{}
+	if desired != nil {
+		*p = *desired
+	}
+
+	p.IPAddress = databaseIPAddress(db)
+	p.SwitchID, p.MaskLen, p.DefaultRoute = databaseNetwork(db)
+	if db.Remark != nil {
+		p.PlanID = int(db.Remark.GetPlanID())
+	}
+	if db.Settings != nil && db.Settings.DBConf != nil && db.Settings.DBConf.Common != nil {
+		p.AllowNetworks = normalizeNetworks(db.Settings.DBConf.Common.SourceNetwork)
+	}
+	return p
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchInstance(t *testing.T) {
+	dbAPI := &genericDBDummyAPI{}
+	switchAPI := &dummySwitchAPI{
+		readResult: &sacloud.Switch{
+			Resource:    sacloud.NewResource(123456789012),
+			Description: `{"subnet":"192.2.0.0/24","gateway":"192.2.0.1"}`,
+			UserSubnet:  &sacloud.Subnet{DefaultRoute: "192.2.0.254", NetworkMaskLen: 24},
+		},
+	}
+	sacloudAPI = &dummyAPI{
+		dbAPI:     dbAPI,
+		switchAPI: switchAPI,
+	}
+	defer func() { sacloudAPI = testAPI }()
+
+	orgTargets := reconcileTargets
+	reconcileTargets = []*reconcileTarget{
+		{service: MariaDBService, planIDs: DatabaseIDMap["MariaDB"].PlanIDMap, dialect: &dummyDBFuncs{}},
+	}
+	defer func() { reconcileTargets = orgTargets }()
+
+	// the switch is not listed, so it is found only with the service ID
+	orgInventories := resourceInventories
+	resourceInventories = []*inventory{
+		{
+			service: SwitchService,
+			list:    func() ([]*managedResource, error) { return nil, nil },
+			fetch:   fetchSwitch,
+		},
+	}
+	defer func() { resourceInventories = orgInventories }()
+
+	drifted := reconcileTestInstance("drifted", true)
+	drifted.Settings.DBConf.Common.SourceNetwork = []string{"192.2.1.0/24", "192.2.0.0/24"}
+	dbAPI.listResult = []sacloud.Database{drifted}
+
+	t.Run("found", func(t *testing.T) {
+		instance, err := FetchInstance("drifted", "")
+		assert.NoError(t, err)
+		assert.Equal(t, MariaDBServiceID, instance.ServiceID)
+		assert.Equal(t, MariaDBPlan10GID, instance.PlanID)
+
+		// parameters show actual values
+		p := instance.Parameters.(*params.DatabaseCreateParameter)
+		assert.Equal(t, []string{"192.2.0.0/24", "192.2.1.0/24"}, p.AllowNetworks)
+		assert.Equal(t, "192.2.0.10", p.IPAddress)
+	})
+
+	t.Run("found with the service", func(t *testing.T) {
+		instance, err := FetchInstance("switch", SwitchServiceID)
+		assert.NoError(t, err)
+		assert.Equal(t, SwitchServiceID, instance.ServiceID)
+		assert.Equal(t, SwitchPlanDefaultID, instance.PlanID)
+
+		p := instance.Parameters.(*params.SwitchCreateParameter)
+		assert.Equal(t, "192.2.0.0/24", p.Subnet)
+		assert.Equal(t, "192.2.0.254", p.Gateway)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		instance, err := FetchInstance("not-exists", "")
+		assert.NoError(t, err)
+		assert.Nil(t, instance)
+	})
+
+	t.Run("not found in the service", func(t *testing.T) {
+		instance, err := FetchInstance("drifted", PostgreSQLServiceID)
+		assert.NoError(t, err)
+		assert.Nil(t, instance)
+	})
+
+	t.Run("unknown service", func(t *testing.T) {
+		instance, err := FetchInstance("drifted", "unknown")
+		assert.NoError(t, err)
+		assert.Nil(t, instance)
+	})
+}
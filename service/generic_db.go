@@ -4,17 +4,29 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 
 	"database/sql"
 	"github.com/sacloud/libsacloud/api"
 	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
 	"github.com/sacloud/open-service-broker-sacloud/iaas"
 	"github.com/sacloud/open-service-broker-sacloud/osb"
 	"github.com/sacloud/open-service-broker-sacloud/service/params"
 	"github.com/sacloud/open-service-broker-sacloud/util/cmp"
+	"github.com/sacloud/open-service-broker-sacloud/util/random"
 )
 
+// bindingUsernamePrefix is the prefix of users created for bindings, which tells them from users created by others
+const bindingUsernamePrefix = "osbs"
+
+// newBindingUsername returns a random name of the user created for the binding.
+// The user database has the same name
+func newBindingUsername() string {
+	return bindingUsernamePrefix + random.String(16)
+}
+
 // databaseAttrs implements InstanceState interface
 type databaseAttrs struct {
 	*sacloud.Database
@@ -25,22 +37,93 @@ func (a *databaseAttrs) HasDiff() bool {
 	if a.parameter == nil {
 		return false
 	}
+	return len(databaseDrift(a.Database, a.parameter)) > 0
+}
 
-	switchID, _ := strconv.ParseInt(a.Database.Remark.Switch.ID, 10, 64)
-	ip := a.Database.Remark.Servers[0].(map[string]interface{})["IPAddress"].(string)
-	maskLen := int32(a.Database.Remark.Network.NetworkMaskLen)
-	defaultRoute := a.Database.Remark.Network.DefaultRoute
+func (a *databaseAttrs) IsDeleting() bool {
+	return a.HasTag(iaas.DeletingMarkerTag)
+}
+
+// databaseDrift returns names of the parameters which differ from the actual database
+func databaseDrift(db *sacloud.Database, p *params.DatabaseCreateParameter) []string {
+	switchID, maskLen, defaultRoute := databaseNetwork(db)
+	ip := databaseIPAddress(db)
 
-	p := a.parameter
-	values := []cmp.CompareValue{
-		{X: p.SwitchID, Y: switchID},
-		{X: p.IPAddress, Y: ip},
-		{X: p.MaskLen, Y: maskLen},
-		{X: p.DefaultRoute, Y: defaultRoute},
+	values := map[string]cmp.CompareValue{
+		"switchID":     {X: p.SwitchID, Y: switchID},
+		"ipaddress":    {X: p.IPAddress, Y: ip},
+		"maskLen":      {X: p.MaskLen, Y: maskLen},
+		"defaultRoute": {X: p.DefaultRoute, Y: defaultRoute},
+	}
+	if p.PlanID > 0 {
+		var planID int64
+		if db.Remark != nil {
+			planID = db.Remark.GetPlanID()
+		}
+		values["plan"] = cmp.CompareValue{X: int64(p.PlanID), Y: planID}
+	}
+	if db.Settings != nil && db.Settings.DBConf != nil && db.Settings.DBConf.Common != nil {
+		values["allowNetworks"] = cmp.CompareValue{
+			X: normalizeNetworks(p.AllowNetworks),
+			Y: normalizeNetworks(db.Settings.DBConf.Common.SourceNetwork),
+		}
 	}
 
-	return cmp.Equal(values...)
+	var drift []string
+	for name, v := range values {
+		if !cmp.Equal(v) {
+			drift = append(drift, name)
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
 
+// databaseNetwork returns the switch ID, the mask length and the default route of the database.
+// They are zero values if the remark doesn't have them, like appliances which are being created
+func databaseNetwork(db *sacloud.Database) (switchID int64, maskLen int32, defaultRoute string) {
+	if db.Remark == nil {
+		return
+	}
+	if db.Remark.ApplianceRemarkBase != nil && db.Remark.Switch != nil {
+		switchID, _ = strconv.ParseInt(db.Remark.Switch.ID, 10, 64)
+	}
+	if db.Remark.Network != nil {
+		maskLen = int32(db.Remark.Network.NetworkMaskLen)
+		defaultRoute = db.Remark.Network.DefaultRoute
+	}
+	return
+}
+
+func databaseIPAddress(db *sacloud.Database) string {
+	if db.Remark == nil {
+		return ""
+	}
+	return applianceIPAddress(db.Remark.ApplianceRemarkBase)
+}
+
+// applianceIPAddress returns the IP address of the first server of the appliance
+func applianceIPAddress(remark *sacloud.ApplianceRemarkBase) string {
+	if remark == nil || len(remark.Servers) == 0 {
+		return ""
+	}
+	server, ok := remark.Servers[0].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	ip, _ := server["IPAddress"].(string)
+	return ip
+}
+
+func normalizeNetworks(networks []string) []string {
+	res := []string{}
+	for _, nw := range networks {
+		if nw != "" {
+			res = append(res, nw)
+		}
+	}
+	sort.Strings(res)
+	return res
 }
 
 // databaseBinding implements BindingState interface
@@ -57,19 +140,71 @@ func (b *databaseBinding) Binding() *osb.ServiceBinding {
 }
 
 type databaseBindingRecord struct {
-	bindingID string
-	username  string
-	password  string
+	bindingID        string
+	username         string
+	password         string
+	tls              bool
+	credentialFormat string
+	characterSet     string // MariaDB only
+	collation        string // MariaDB only
+	allowedHost      string // MariaDB only
 }
 
 type databaseFuncs interface {
 	databaseAPI() iaas.DatabaseAPI
-	buildConnInfo(host, dbName, user, password, salt string, port int) ConnectionInfo
-	prepareMetaTable(db *sql.DB, connInfo ConnectionInfo) error
+	idMap() PlanIDMap
+	buildConnInfo(host, dbName, user, password, salt string, port int, tlsConfig *DatabaseTLSConfig) ConnectionInfo
 	existsMetaTable(db *sql.DB, connInfo ConnectionInfo) (bool, error)
+	migrateMetaTable(db *sql.DB, connInfo ConnectionInfo) error
+	metaSchemaVersion(db *sql.DB, connInfo ConnectionInfo) (current int, latest int, err error)
 	readBinding(db *sql.DB, connInfo ConnectionInfo, bindingID string) (*databaseBindingRecord, error)
-	createBinding(db *sql.DB, connInfo ConnectionInfo, bindingID string) (*databaseBindingRecord, error)
+	createBinding(db *sql.DB, connInfo ConnectionInfo, bindingID string, p *params.DatabaseBindParameter) (*databaseBindingRecord, error)
 	deleteBinding(db *sql.DB, record *databaseBindingRecord) error
+	listBindings(db *sql.DB, connInfo ConnectionInfo) ([]*databaseBindingRecord, error)
+	existsUserDatabase(db *sql.DB, dbName string) (bool, error)
+	updateBindingPassword(db *sql.DB, connInfo ConnectionInfo, record *databaseBindingRecord) error
+	updateBindingLimits(db *sql.DB, username string, limits *params.DatabaseBindingLimits) error
+	existsUser(db *sql.DB, username string) (bool, error)
+	listUsers(db *sql.DB) ([]string, error)
+	dropUser(db *sql.DB, username string) error
+	// lockUsers blocks creating bindings until the returned function is called, so that users of bindings
+	// being created aren't regarded as leaked
+	lockUsers(db *sql.DB) (func(), error)
+	// isLegacyBindingUser returns true if the user has privileges which createBinding of older versions granted
+	isLegacyBindingUser(db *sql.DB, connInfo ConnectionInfo, username string) (bool, error)
+}
+
+// metaTableReady returns true if the meta table exists.
+// Existing tables are migrated to the current format before use
+func metaTableReady(dialect databaseFuncs, db *sql.DB, connInfo ConnectionInfo) (bool, error) {
+	exists, err := dialect.existsMetaTable(db, connInfo)
+	if err != nil || !exists {
+		return false, err
+	}
+	if err := dialect.migrateMetaTable(db, connInfo); err != nil {
+		return false, fmt.Errorf("migrating meta table is failed: %s", err)
+	}
+	return true, nil
+}
+
+// verifyBindingRemoved returns error if the user database or the user of the binding still exists
+func verifyBindingRemoved(dialect databaseFuncs, db *sql.DB, username string) error {
+	exists, err := dialect.existsUserDatabase(db, username)
+	if err != nil {
+		return fmt.Errorf("error reading user database %q: %s", username, err)
+	}
+	if exists {
+		return fmt.Errorf("user database %q still exists", username)
+	}
+
+	exists, err = dialect.existsUser(db, username)
+	if err != nil {
+		return fmt.Errorf("error reading user %q: %s", username, err)
+	}
+	if exists {
+		return fmt.Errorf("user %q still exists", username)
+	}
+	return nil
 }
 
 type databaseHandler struct {
@@ -78,8 +213,10 @@ type databaseHandler struct {
 	planID       string
 	rawParameter []byte
 
-	parameter *params.DatabaseCreateParameter
-	paramErr  error
+	parameter       *params.DatabaseCreateParameter
+	updateParameter *params.DatabaseUpdateParameter
+	bindParameter   *params.DatabaseBindParameter
+	paramErr        error
 
 	dialect databaseFuncs
 }
@@ -98,12 +235,23 @@ func (s *databaseHandler) InstanceState(instanceID string) (InstanceState, error
 	}
 
 	if db == nil {
+		if s.operation == operations.Deprovisioning {
+			knownInstances.remove(instanceID)
+		}
 		return nil, nil
 	}
+	knownInstances.add(instanceID, s.serviceID)
+
+	parameter := s.parameter
+	if parameter == nil {
+		// compare with the parameter requested at the creation(or the last update).
+		// broken descriptions are ignored not to block other operations
+		parameter, _ = iaas.DesiredDatabaseParameter(db)
+	}
 
 	return &databaseAttrs{
 		Database:  db,
-		parameter: s.parameter,
+		parameter: parameter,
 	}, nil
 }
 
@@ -122,7 +270,7 @@ func (s *databaseHandler) BindingState(instanceID, bindingID string) (BindingSta
 	}
 	defer db.Close() // nolint
 
-	exists, err := s.dialect.existsMetaTable(db, connInfo)
+	exists, err := metaTableReady(s.dialect, db, connInfo)
 	if err != nil {
 		return nil, fmt.Errorf("error reading meta table: %s", err)
 	}
@@ -141,26 +289,14 @@ func (s *databaseHandler) BindingState(instanceID, bindingID string) (BindingSta
 		return nil, nil
 	}
 
-	newConInfo := s.dialect.buildConnInfo(
-		connInfo.Host(),
-		record.username, // database name
-		record.username,
-		record.password,
-		connInfo.Salt(),
-		connInfo.Port(),
-	)
+	credentials, err := bindingCredentials(s.dialect, connInfo, record)
+	if err != nil {
+		return nil, err
+	}
 
 	// return
 	binding := &osb.ServiceBinding{
-		Credentials: map[string]string{
-			"host":        newConInfo.Host(),
-			"port":        fmt.Sprintf("%d", newConInfo.Port()),
-			"database":    record.username,
-			"username":    record.username,
-			"password":    record.password,
-			"sslRequired": "false",
-			"uri":         newConInfo.FormatDSN(),
-		},
+		Credentials: credentials,
 	}
 
 	return &databaseBinding{binding: binding}, nil
@@ -168,15 +304,47 @@ func (s *databaseHandler) BindingState(instanceID, bindingID string) (BindingSta
 
 func (s *databaseHandler) CreateInstance(instanceID string) error {
 	_, err := s.dialect.databaseAPI().Create(instanceID, s.parameter)
-	return err
+	if err != nil {
+		return err
+	}
+	knownInstances.add(instanceID, s.serviceID)
+	return nil
 }
 
 func (s *databaseHandler) UpdateInstance(instanceID string) error {
-	panic(errors.New("database services does not support update"))
+	if s.updateParameter == nil {
+		return errors.New("update parameter is nil")
+	}
+
+	client := s.dialect.databaseAPI()
+	db, err := client.Read(instanceID)
+	if err != nil {
+		return err
+	}
+	if db.Remark != nil && s.dialect.idMap().PlanIDMap[int(db.Remark.GetPlanID())] != s.planID {
+		return errors.New("changing plan is not supported")
+	}
+
+	var current []string
+	if db.Settings != nil && db.Settings.DBConf != nil && db.Settings.DBConf.Common != nil {
+		current = db.Settings.DBConf.Common.SourceNetwork
+	}
+	networks := s.updateParameter.ApplyAllowNetworks(current)
+	if cmp.Equal(cmp.CompareValue{X: normalizeNetworks(current), Y: networks}) {
+		return nil
+	}
+
+	_, err = client.UpdateAllowNetworks(instanceID, networks)
+	return err
 }
 
 func (s *databaseHandler) DeleteInstance(instanceID string) error {
-	return s.dialect.databaseAPI().Delete(instanceID)
+	err := s.dialect.databaseAPI().Delete(instanceID)
+	if err != nil {
+		return err
+	}
+	knownInstances.markDeleting(instanceID)
+	return nil
 }
 
 func (s *databaseHandler) CreateBinding(instanceID, bindingID string) (*osb.ServiceBinding, error) {
@@ -194,10 +362,10 @@ func (s *databaseHandler) CreateBinding(instanceID, bindingID string) (*osb.Serv
 	}
 	defer db.Close() // nolint
 
-	// check meta table exists
-	err = s.dialect.prepareMetaTable(db, connInfo)
+	// create or migrate meta table
+	err = s.dialect.migrateMetaTable(db, connInfo)
 	if err != nil {
-		return nil, fmt.Errorf("creating metadata table is failed: %s", err)
+		return nil, fmt.Errorf("migrating metadata table is failed: %s", err)
 	}
 
 	// check already exists
@@ -210,7 +378,11 @@ func (s *databaseHandler) CreateBinding(instanceID, bindingID string) (*osb.Serv
 	}
 
 	// create and add metadata
-	record, err := s.dialect.createBinding(db, connInfo, bindingID)
+	bindParameter := s.bindParameter
+	if bindParameter == nil {
+		bindParameter = &params.DatabaseBindParameter{}
+	}
+	record, err := s.dialect.createBinding(db, connInfo, bindingID, bindParameter)
 	if err != nil {
 		return nil, fmt.Errorf("creating user database is failed: %s", err)
 	}
@@ -218,26 +390,64 @@ func (s *databaseHandler) CreateBinding(instanceID, bindingID string) (*osb.Serv
 		return nil, errors.New("creating user database is failed: resulet is nil")
 	}
 
-	newConInfo := s.dialect.buildConnInfo(
+	credentials, err := bindingCredentials(s.dialect, connInfo, record)
+	if err != nil {
+		return nil, err
+	}
+
+	// return
+	return &osb.ServiceBinding{
+		Credentials: credentials,
+	}, nil
+}
+
+// bindingCredentials returns credentials of the binding which are passed to applications
+func bindingCredentials(dialect databaseFuncs, connInfo ConnectionInfo, record *databaseBindingRecord) (map[string]string, error) {
+	var tlsConfig *DatabaseTLSConfig
+	if record.tls {
+		// the CA file of the broker is not available for applications
+		tlsConfig = &DatabaseTLSConfig{}
+	}
+	newConInfo := dialect.buildConnInfo(
 		connInfo.Host(),
-		record.username,
+		record.username, // database name
 		record.username,
 		record.password,
 		connInfo.Salt(),
 		connInfo.Port(),
+		tlsConfig,
 	)
-	// return
-	return &osb.ServiceBinding{
-		Credentials: map[string]string{
-			"host":        newConInfo.Host(),
-			"port":        fmt.Sprintf("%d", newConInfo.Port()),
-			"database":    record.username,
-			"username":    record.username,
-			"password":    record.password,
-			"sslRequired": "false",
-			"uri":         newConInfo.FormatDSN(),
-		},
-	}, nil
+
+	credentials := map[string]string{
+		"host":        newConInfo.Host(),
+		"port":        fmt.Sprintf("%d", newConInfo.Port()),
+		"database":    record.username,
+		"username":    record.username,
+		"password":    record.password,
+		"sslRequired": strconv.FormatBool(record.tls),
+		"uri":         newConInfo.URI(),
+	}
+	if record.tls && connInfo.TLS() != nil {
+		if ca := connInfo.TLS().CACertificate(); ca != "" {
+			credentials["ca_certificate"] = ca
+		}
+	}
+	options := map[string]string{
+		"characterSet": record.characterSet,
+		"collation":    record.collation,
+		"allowedHost":  record.allowedHost,
+	}
+	for k, v := range options {
+		if v != "" {
+			credentials[k] = v
+		}
+	}
+	if record.credentialFormat != "" {
+		if err := renderCredentialFormat(dialect.idMap().ID, record.credentialFormat, credentials); err != nil {
+			return nil, err
+		}
+	}
+	return credentials, nil
 }
 
 func (s *databaseHandler) DeleteBinding(instanceID, bindingID string) error {
@@ -254,7 +464,7 @@ func (s *databaseHandler) DeleteBinding(instanceID, bindingID string) error {
 	}
 	defer db.Close() // nolint
 
-	exists, err := s.dialect.existsMetaTable(db, connInfo)
+	exists, err := metaTableReady(s.dialect, db, connInfo)
 	if err != nil {
 		return fmt.Errorf("error reading meta table: %s", err)
 	}
@@ -280,11 +490,19 @@ func (s *databaseHandler) DeleteBinding(instanceID, bindingID string) error {
 	return nil
 }
 
+func (s *databaseHandler) ConfirmProvision(instanceID string) error {
+	return s.dialect.databaseAPI().ConfirmProvisioned(instanceID)
+}
+
 func (s *databaseHandler) IsValid() (bool, error) {
 	return s.paramErr == nil, s.paramErr
 }
 
 func (s *databaseHandler) open(info ConnectionInfo) (*sql.DB, error) {
+	return openDatabase(info)
+}
+
+func openDatabase(info ConnectionInfo) (*sql.DB, error) {
 	if info == nil {
 		return nil, errors.New("ConnectionInfo is nil")
 	}
@@ -299,18 +517,28 @@ func (s *databaseHandler) connInfo(instanceID string) (ConnectionInfo, error) {
 		return nil, err
 	}
 
+	return adminConnInfo(s.dialect, db), nil
+}
+
+func adminConnInfo(dialect databaseFuncs, db *sacloud.Database) ConnectionInfo {
 	var port int
 	if p, err := strconv.Atoi(db.Settings.DBConf.Common.ServicePort); err != nil {
 		port = p
 	}
-	ip := db.Remark.Servers[0].(map[string]interface{})["IPAddress"].(string)
+	ip := databaseIPAddress(db)
 
-	return s.dialect.buildConnInfo(
+	var tlsConfig *DatabaseTLSConfig
+	if db.Remark != nil {
+		tlsConfig = planTLSConfig(dialect.idMap().PlanIDMap[int(db.Remark.GetPlanID())])
+	}
+
+	return dialect.buildConnInfo(
 		ip,
 		db.Settings.DBConf.Common.DefaultUser,
 		db.Settings.DBConf.Common.DefaultUser,
 		db.Settings.DBConf.Common.UserPassword,
 		db.GetStrID(),
 		port,
-	), nil
+		tlsConfig,
+	)
 }
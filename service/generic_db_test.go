@@ -9,6 +9,7 @@ import (
 	"github.com/sacloud/open-service-broker-sacloud/iaas"
 	"github.com/sacloud/open-service-broker-sacloud/osb"
 	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/sacloud/open-service-broker-sacloud/util/cmp"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -33,11 +34,23 @@ var testConnInfo = &dummyConnInfo{
 }
 
 type genericDBDummyAPI struct {
+	listResult   []sacloud.Database
 	readResult   *sacloud.Database
 	createResult *sacloud.Database
+	listErr      error
 	readErr      error
 	createErr    error
+	updateErr    error
 	deleteErr    error
+
+	updatedNetworks []string
+
+	monitorResult *iaas.DatabaseMonitor
+	monitorErr    error
+}
+
+func (c *genericDBDummyAPI) List() ([]sacloud.Database, error) {
+	return c.listResult, c.listErr
 }
 
 func (c *genericDBDummyAPI) Read(instanceID string) (*sacloud.Database, error) {
@@ -48,6 +61,19 @@ func (c *genericDBDummyAPI) Create(instanceID string, param *params.DatabaseCrea
 	return c.createResult, c.createErr
 }
 
+func (c *genericDBDummyAPI) UpdateAllowNetworks(instanceID string, networks []string) (*sacloud.Database, error) {
+	c.updatedNetworks = networks
+	return c.readResult, c.updateErr
+}
+
+func (c *genericDBDummyAPI) Monitor(instanceID string) (*iaas.DatabaseMonitor, error) {
+	return c.monitorResult, c.monitorErr
+}
+
+func (c *genericDBDummyAPI) ConfirmProvisioned(instanceID string) error {
+	return nil
+}
+
 func (c *genericDBDummyAPI) Delete(instanceID string) error {
 	return c.deleteErr
 }
@@ -59,6 +85,7 @@ type dummyConnInfo struct {
 	port     int
 	salt     string
 	dbName   string
+	tls      *DatabaseTLSConfig
 }
 
 func (c *dummyConnInfo) DriverName() string {
@@ -89,37 +116,89 @@ func (c *dummyConnInfo) Salt() string {
 	return c.salt
 }
 
+func (c *dummyConnInfo) TLS() *DatabaseTLSConfig {
+	return c.tls
+}
+
 func (c *dummyConnInfo) FormatDSN() string {
 	return "dummy@/dummy"
 }
 
+func (c *dummyConnInfo) URI() string {
+	return "dummy://dummy"
+}
+
 type dummyDBFuncs struct {
-	prepareMetaTableErr   error
 	existsMetaTableResult bool
 	existsMetaTableErr    error
+	migrateMetaTableErr   error
+	metaVersionCurrent    int
+	metaVersionLatest     int
+	metaVersionErr        error
 	readBindingResult     *databaseBindingRecord
 	readBindingErr        error
 	createBindingResult   *databaseBindingRecord
 	createBindingErr      error
+	createBindingParam    *params.DatabaseBindParameter
 	deleteBindingErr      error
+	listBindingsResult    []*databaseBindingRecord
+	listBindingsErr       error
+	existsUserDBResult    bool
+	existsUserDBErr       error
+	updatePasswordErr     error
+	updatedRecord         *databaseBindingRecord
+	updateLimitsErr       error
+	updatedLimits         *params.DatabaseBindingLimits
+	existsUserResult      bool
+	existsUserErr         error
+	listUsersResult       []string
+	listUsersErr          error
+	dropUserErr           error
+	droppedUsers          []string
+	legacyUsers           []string
+	locked                bool
 }
 
 func (f *dummyDBFuncs) init() {
-	f.prepareMetaTableErr = nil
 	f.existsMetaTableResult = false
 	f.existsMetaTableErr = nil
+	f.migrateMetaTableErr = nil
+	f.metaVersionCurrent = 0
+	f.metaVersionLatest = 0
+	f.metaVersionErr = nil
 	f.readBindingResult = nil
 	f.readBindingErr = nil
 	f.createBindingResult = nil
 	f.createBindingErr = nil
+	f.createBindingParam = nil
 	f.deleteBindingErr = nil
+	f.listBindingsResult = nil
+	f.listBindingsErr = nil
+	f.existsUserDBResult = false
+	f.existsUserDBErr = nil
+	f.updatePasswordErr = nil
+	f.updatedRecord = nil
+	f.updateLimitsErr = nil
+	f.updatedLimits = nil
+	f.existsUserResult = false
+	f.existsUserErr = nil
+	f.listUsersResult = nil
+	f.listUsersErr = nil
+	f.dropUserErr = nil
+	f.droppedUsers = nil
+	f.legacyUsers = nil
+	f.locked = false
 }
 
 func (f *dummyDBFuncs) databaseAPI() iaas.DatabaseAPI {
 	return sacloudAPI.MariaDB()
 }
 
-func (f *dummyDBFuncs) buildConnInfo(host, dbName, user, password, salt string, port int) ConnectionInfo {
+func (f *dummyDBFuncs) idMap() PlanIDMap {
+	return DatabaseIDMap["MariaDB"]
+}
+
+func (f *dummyDBFuncs) buildConnInfo(host, dbName, user, password, salt string, port int, tlsConfig *DatabaseTLSConfig) ConnectionInfo {
 	return &dummyConnInfo{
 		userName: user,
 		password: password,
@@ -127,22 +206,28 @@ func (f *dummyDBFuncs) buildConnInfo(host, dbName, user, password, salt string,
 		dbName:   dbName,
 		salt:     salt,
 		port:     port,
+		tls:      tlsConfig,
 	}
 }
 
-func (f *dummyDBFuncs) prepareMetaTable(db *sql.DB, connInfo ConnectionInfo) error {
-	return f.prepareMetaTableErr
-}
-
 func (f *dummyDBFuncs) existsMetaTable(db *sql.DB, connInfo ConnectionInfo) (bool, error) {
 	return f.existsMetaTableResult, f.existsMetaTableErr
 }
 
+func (f *dummyDBFuncs) migrateMetaTable(db *sql.DB, connInfo ConnectionInfo) error {
+	return f.migrateMetaTableErr
+}
+
+func (f *dummyDBFuncs) metaSchemaVersion(db *sql.DB, connInfo ConnectionInfo) (int, int, error) {
+	return f.metaVersionCurrent, f.metaVersionLatest, f.metaVersionErr
+}
+
 func (f *dummyDBFuncs) readBinding(db *sql.DB, connInfo ConnectionInfo, bindingID string) (*databaseBindingRecord, error) {
 	return f.readBindingResult, f.readBindingErr
 }
 
-func (f *dummyDBFuncs) createBinding(db *sql.DB, connInfo ConnectionInfo, bindingID string) (*databaseBindingRecord, error) {
+func (f *dummyDBFuncs) createBinding(db *sql.DB, connInfo ConnectionInfo, bindingID string, p *params.DatabaseBindParameter) (*databaseBindingRecord, error) {
+	f.createBindingParam = p
 	return f.createBindingResult, f.createBindingErr
 }
 
@@ -150,6 +235,51 @@ func (f *dummyDBFuncs) deleteBinding(db *sql.DB, record *databaseBindingRecord)
 	return f.deleteBindingErr
 }
 
+func (f *dummyDBFuncs) listBindings(db *sql.DB, connInfo ConnectionInfo) ([]*databaseBindingRecord, error) {
+	return f.listBindingsResult, f.listBindingsErr
+}
+
+func (f *dummyDBFuncs) updateBindingLimits(db *sql.DB, username string, limits *params.DatabaseBindingLimits) error {
+	f.updatedLimits = limits
+	return f.updateLimitsErr
+}
+
+func (f *dummyDBFuncs) existsUserDatabase(db *sql.DB, dbName string) (bool, error) {
+	return f.existsUserDBResult, f.existsUserDBErr
+}
+
+func (f *dummyDBFuncs) updateBindingPassword(db *sql.DB, connInfo ConnectionInfo, record *databaseBindingRecord) error {
+	f.updatedRecord = record
+	return f.updatePasswordErr
+}
+
+func (f *dummyDBFuncs) existsUser(db *sql.DB, username string) (bool, error) {
+	return f.existsUserResult, f.existsUserErr
+}
+
+func (f *dummyDBFuncs) listUsers(db *sql.DB) ([]string, error) {
+	return f.listUsersResult, f.listUsersErr
+}
+
+func (f *dummyDBFuncs) dropUser(db *sql.DB, username string) error {
+	if !f.locked {
+		return errors.New("users are not locked")
+	}
+	if f.dropUserErr == nil {
+		f.droppedUsers = append(f.droppedUsers, username)
+	}
+	return f.dropUserErr
+}
+
+func (f *dummyDBFuncs) lockUsers(db *sql.DB) (func(), error) {
+	f.locked = true
+	return func() { f.locked = false }, nil
+}
+
+func (f *dummyDBFuncs) isLegacyBindingUser(db *sql.DB, connInfo ConnectionInfo, username string) (bool, error) {
+	return containsString(f.legacyUsers, username), nil
+}
+
 func TestGenericDBGetConn(t *testing.T) {
 
 	s := &databaseHandler{
@@ -207,6 +337,80 @@ func TestDatabaseHandler_InstanceState(t *testing.T) {
 		assert.NotNil(t, state)
 		assert.NoError(t, err)
 	})
+
+	t.Run("compare with requested parameter", func(t *testing.T) {
+		s := &databaseHandler{
+			serviceID: MariaDBServiceID,
+			planID:    MariaDBPlan10GID,
+			operation: operations.Updating,
+			dialect:   &dummyDBFuncs{},
+		}
+		db := reconcileTestInstance(instanceID, true)
+		testDBAPI.readResult = &db
+		defer func() {
+			testDBAPI.readResult = nil
+		}()
+
+		state, err := s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.False(t, state.HasDiff())
+
+		db.Settings.DBConf.Common.SourceNetwork = []string{"192.2.0.0/24"}
+		state, err = s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.True(t, state.HasDiff())
+	})
+}
+
+func TestDatabaseHandler_UpdateInstance(t *testing.T) {
+	s := &databaseHandler{
+		serviceID: MariaDBServiceID,
+		planID:    MariaDBPlan10GID,
+		operation: operations.Updating,
+		dialect:   &dummyDBFuncs{},
+		updateParameter: &params.DatabaseUpdateParameter{
+			AddAllowNetworks: []string{"192.2.1.0/24"},
+		},
+	}
+	db := mariaDB10GInstance(instanceID)
+	db.Settings.DBConf.Common.SourceNetwork = []string{"192.2.0.0/24"}
+	testDBAPI.readResult = db
+	defer func() {
+		testDBAPI.readResult = nil
+		testDBAPI.updatedNetworks = nil
+	}()
+
+	t.Run("add network", func(t *testing.T) {
+		err := s.UpdateInstance(instanceID)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"192.2.0.0/24", "192.2.1.0/24"}, testDBAPI.updatedNetworks)
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		testDBAPI.updatedNetworks = nil
+		s := *s
+		s.updateParameter = &params.DatabaseUpdateParameter{AddAllowNetworks: []string{"192.2.0.0/24"}}
+
+		err := s.UpdateInstance(instanceID)
+		assert.NoError(t, err)
+		assert.Nil(t, testDBAPI.updatedNetworks)
+	})
+
+	t.Run("API returns error", func(t *testing.T) {
+		testDBAPI.updateErr = errors.New("dummy")
+		defer func() { testDBAPI.updateErr = nil }()
+
+		err := s.UpdateInstance(instanceID)
+		assert.Error(t, err)
+	})
+
+	t.Run("plan is changed", func(t *testing.T) {
+		s := *s
+		s.planID = MariaDBPlan30GID
+
+		err := s.UpdateInstance(instanceID)
+		assert.Error(t, err)
+	})
 }
 
 func TestDatabaseHandler_BindingState(t *testing.T) {
@@ -392,6 +596,96 @@ func TestDatabaseHandler_CreateBinding(t *testing.T) {
 		assert.Equal(t, credential["port"], fmt.Sprintf("%d", connInfo.Port()))
 		assert.Equal(t, credential["database"], user)
 		assert.Equal(t, credential["password"], pass)
+		assert.Equal(t, "false", credential["sslRequired"])
+		assert.NotContains(t, credential, "ca_certificate")
+	})
+
+	t.Run("create TLS binding", func(t *testing.T) {
+		databaseTLSConfigs[MariaDBPlan10GID] = &DatabaseTLSConfig{caCert: []byte("dummy-ca")}
+		s.bindParameter = &params.DatabaseBindParameter{TLS: true}
+		testDialect.existsMetaTableResult = true
+		testDialect.createBindingResult = &databaseBindingRecord{
+			bindingID: bindingID,
+			username:  "user",
+			password:  "pass",
+			tls:       true,
+		}
+		defer func() {
+			testDialect.init()
+			s.bindParameter = nil
+			databaseTLSConfigs = map[string]*DatabaseTLSConfig{}
+		}()
+
+		binding, err := s.CreateBinding(instanceID, bindingID)
+		assert.NoError(t, err)
+		assert.True(t, testDialect.createBindingParam.TLS)
+
+		credential := binding.Credentials.(map[string]string)
+		assert.Equal(t, "true", credential["sslRequired"])
+		assert.Equal(t, "dummy-ca", credential["ca_certificate"])
+	})
+
+	t.Run("create TLS binding without CA", func(t *testing.T) {
+		databaseTLSConfigs[MariaDBPlan10GID] = &DatabaseTLSConfig{}
+		s.bindParameter = &params.DatabaseBindParameter{TLS: true}
+		testDialect.existsMetaTableResult = true
+		testDialect.createBindingResult = &databaseBindingRecord{
+			bindingID: bindingID,
+			username:  "user",
+			password:  "pass",
+			tls:       true,
+		}
+		defer func() {
+			testDialect.init()
+			s.bindParameter = nil
+			databaseTLSConfigs = map[string]*DatabaseTLSConfig{}
+		}()
+
+		binding, err := s.CreateBinding(instanceID, bindingID)
+		assert.NoError(t, err)
+
+		credential := binding.Credentials.(map[string]string)
+		assert.Equal(t, "true", credential["sslRequired"])
+		assert.NotContains(t, credential, "ca_certificate")
+	})
+
+	t.Run("create binding with credential format", func(t *testing.T) {
+		testDialect.existsMetaTableResult = true
+		testDialect.createBindingResult = &databaseBindingRecord{
+			bindingID:        bindingID,
+			username:         "user",
+			password:         "pass",
+			credentialFormat: "env",
+		}
+		defer testDialect.init()
+
+		binding, err := s.CreateBinding(instanceID, bindingID)
+		assert.NoError(t, err)
+
+		credential := binding.Credentials.(map[string]string)
+		assert.Equal(t, "user", credential["username"])
+		assert.Equal(t, fmt.Sprintf("mysql2://user:pass@%s:%d/user", connInfo.Host(), connInfo.Port()), credential["DATABASE_URL"])
+	})
+
+	t.Run("create binding with database options", func(t *testing.T) {
+		testDialect.existsMetaTableResult = true
+		testDialect.createBindingResult = &databaseBindingRecord{
+			bindingID:    bindingID,
+			username:     "user",
+			password:     "pass",
+			characterSet: "utf8mb4",
+			collation:    "utf8mb4_bin",
+			allowedHost:  "192.2.0.0/24",
+		}
+		defer testDialect.init()
+
+		binding, err := s.CreateBinding(instanceID, bindingID)
+		assert.NoError(t, err)
+
+		credential := binding.Credentials.(map[string]string)
+		assert.Equal(t, "utf8mb4", credential["characterSet"])
+		assert.Equal(t, "utf8mb4_bin", credential["collation"])
+		assert.Equal(t, "192.2.0.0/24", credential["allowedHost"])
 	})
 }
 
@@ -437,3 +731,101 @@ func TestDatabaseHandler_DeleteBinding(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestDatabaseAttrs_HasDiff(t *testing.T) {
+	parameter := &params.DatabaseCreateParameter{
+		SwitchID:     int64(mariaDBTestSwitchID),
+		IPAddress:    "192.2.0.10",
+		MaskLen:      24,
+		DefaultRoute: "192.2.0.1",
+		PlanID:       10,
+	}
+
+	t.Run("same parameter", func(t *testing.T) {
+		attrs := &databaseAttrs{
+			Database:  mariaDB10GInstance(instanceID),
+			parameter: parameter,
+		}
+		assert.False(t, attrs.HasDiff())
+	})
+
+	t.Run("different parameter", func(t *testing.T) {
+		p := *parameter
+		p.IPAddress = "192.2.0.11"
+		attrs := &databaseAttrs{
+			Database:  mariaDB10GInstance(instanceID),
+			parameter: &p,
+		}
+		assert.True(t, attrs.HasDiff())
+	})
+
+	t.Run("different allowNetworks", func(t *testing.T) {
+		p := *parameter
+		p.AllowNetworks = []string{"192.2.0.0/24"}
+		attrs := &databaseAttrs{
+			Database:  mariaDB10GInstance(instanceID),
+			parameter: &p,
+		}
+		assert.True(t, attrs.HasDiff())
+	})
+
+	t.Run("partially created database", func(t *testing.T) {
+		for _, db := range []*sacloud.Database{
+			{},
+			{Remark: &sacloud.DatabaseRemark{}},
+			{Remark: &sacloud.DatabaseRemark{ApplianceRemarkBase: &sacloud.ApplianceRemarkBase{}}},
+		} {
+			assert.NotPanics(t, func() {
+				assert.NotEmpty(t, databaseDrift(db, parameter))
+				actualDatabaseParameter(db, parameter)
+			})
+		}
+	})
+}
+
+// Before the drift detection was introduced, HasDiff returned cmp.Equal(values...) as is.
+// Provisioning the same instance again was rejected with 409 Conflict,
+// and provisioning it with different parameters was accepted with 200 OK
+func TestDatabaseAttrs_HasDiffIsNotInverted(t *testing.T) {
+	db := mariaDB10GInstance(instanceID)
+	testDBAPI.readResult = db
+	defer func() {
+		testDBAPI.readResult = nil
+	}()
+
+	t.Run("same parameter is not conflicted", func(t *testing.T) {
+		s := getMariaDBHandler(operations.Provisioning, `{
+			"switchID" : 999999999999,
+			"ipaddress" : "192.2.0.10",
+			"maskLen" : 24,
+			"defaultRoute" : "192.2.0.1"
+		}`)
+		_, err := s.IsValid()
+		assert.NoError(t, err)
+
+		// the old implementation returned this value as HasDiff
+		assert.True(t, cmp.Equal(
+			cmp.CompareValue{X: s.parameter.SwitchID, Y: int64(mariaDBTestSwitchID)},
+			cmp.CompareValue{X: s.parameter.IPAddress, Y: databaseIPAddress(db)},
+		))
+
+		state, err := s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.False(t, state.HasDiff())
+	})
+
+	t.Run("different parameter is conflicted", func(t *testing.T) {
+		s := getMariaDBHandler(operations.Provisioning, `{
+			"switchID" : 999999999999,
+			"ipaddress" : "192.2.0.11",
+			"maskLen" : 24,
+			"defaultRoute" : "192.2.0.1"
+		}`)
+		_, err := s.IsValid()
+		assert.NoError(t, err)
+
+		state, err := s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.True(t, state.HasDiff())
+	})
+}
@@ -0,0 +1,521 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sacloud/libsacloud/api"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/sacloud/open-service-broker-sacloud/util/mutexkv"
+	"github.com/sacloud/open-service-broker-sacloud/util/validator"
+)
+
+// health statuses of GSLB servers
+const (
+	GSLBServerHealthUp       = "up"
+	GSLBServerHealthDown     = "down"
+	GSLBServerHealthDisabled = "disabled"
+	GSLBServerHealthUnknown  = "unknown"
+)
+
+const (
+	// gslbProbeTimeout is the timeout of probing GSLB servers
+	gslbProbeTimeout = 5 * time.Second
+	// gslbProbeInterval is the period in which the result of probing is reused.
+	// It's the minimum interval of GSLB health checks, so the broker doesn't probe servers more often than GSLBs
+	gslbProbeInterval = 10 * time.Second
+)
+
+type gslbProbeResult struct {
+	health   string
+	probedAt time.Time
+}
+
+var (
+	gslbProbeMutex   = mutexkv.NewMutexKV()
+	gslbProbeMu      sync.Mutex
+	gslbProbeResults = make(map[string]*gslbProbeResult)
+)
+
+// probeGSLBServer returns the health status of the server probed with the health check of the GSLB.
+// SAKURA Cloud API doesn't provide health statuses of GSLB servers, so the broker probes them in the same way.
+// Only public addresses are probed not to make the broker request internal networks,
+// and results are reused for gslbProbeInterval not to flood servers by fetching bindings.
+// It is replaceable for testing
+var probeGSLBServer = func(hc sacloud.GSLBHealthCheck, ipAddress string) string {
+	if !validator.PublicIPv4Addr(ipAddress) {
+		return GSLBServerHealthUnknown
+	}
+	key := fmt.Sprintf("%s/%s/%s/%s/%s/%s", ipAddress, hc.Protocol, hc.Port, hc.Host, hc.Path, hc.Status)
+	return probeGSLBServerWithCache(key, func() string {
+		return checkGSLBServer(hc, ipAddress)
+	})
+}
+
+// probeGSLBServerWithCache returns the result of probe which is cached with the key for gslbProbeInterval.
+// Concurrent requests with the same key wait for the probe
+func probeGSLBServerWithCache(key string, probe func() string) string {
+	gslbProbeMutex.Lock(key)
+	defer gslbProbeMutex.Unlock(key)
+
+	gslbProbeMu.Lock()
+	cached, ok := gslbProbeResults[key]
+	gslbProbeMu.Unlock()
+	if ok && time.Since(cached.probedAt) < gslbProbeInterval {
+		return cached.health
+	}
+
+	health := probe()
+
+	gslbProbeMu.Lock()
+	defer gslbProbeMu.Unlock()
+	for k, r := range gslbProbeResults {
+		if time.Since(r.probedAt) >= gslbProbeInterval {
+			delete(gslbProbeResults, k)
+		}
+	}
+	gslbProbeResults[key] = &gslbProbeResult{health: health, probedAt: time.Now()}
+	return health
+}
+
+// checkGSLBServer checks the server with the health check. ICMP needs the privilege,
+// so servers checked with ping are reported as unknown
+func checkGSLBServer(hc sacloud.GSLBHealthCheck, ipAddress string) string {
+	switch hc.Protocol {
+	case "http", "https":
+		serverName := hc.Host
+		if serverName == "" {
+			serverName = ipAddress
+		}
+		client := &http.Client{
+			Timeout: gslbProbeTimeout,
+			Transport: &http.Transport{
+				// the certificate is verified with the host header, which is the name clients request
+				TLSClientConfig: &tls.Config{ServerName: serverName},
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s://%s%s", hc.Protocol, ipAddress, hc.Path), nil)
+		if err != nil {
+			return GSLBServerHealthUnknown
+		}
+		if hc.Host != "" {
+			req.Host = hc.Host
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			if isCertificateError(err) {
+				// GSLBs don't verify certificates, so the server may be regarded as up
+				return GSLBServerHealthUnknown
+			}
+			return GSLBServerHealthDown
+		}
+		res.Body.Close() // nolint
+		if strconv.Itoa(res.StatusCode) != hc.Status {
+			return GSLBServerHealthDown
+		}
+		return GSLBServerHealthUp
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ipAddress, hc.Port), gslbProbeTimeout)
+		if err != nil {
+			return GSLBServerHealthDown
+		}
+		conn.Close() // nolint
+		return GSLBServerHealthUp
+	default:
+		return GSLBServerHealthUnknown
+	}
+}
+
+// isCertificateError returns true if verifying the server certificate is failed
+func isCertificateError(err error) bool {
+	for err != nil {
+		switch e := err.(type) {
+		case x509.UnknownAuthorityError, x509.HostnameError, x509.CertificateInvalidError:
+			return true
+		case *url.Error:
+			err = e.Err
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// gslbAttrs implements InstanceState interface. GSLBs are available as soon as they are created
+type gslbAttrs struct {
+	*sacloud.GSLB
+	parameter *params.GSLBCreateParameter
+}
+
+func (a *gslbAttrs) IsUp() bool {
+	return true
+}
+
+func (a *gslbAttrs) IsFailed() bool {
+	return false
+}
+
+func (a *gslbAttrs) IsMigrating() bool {
+	return false
+}
+
+func (a *gslbAttrs) HasDiff() bool {
+	if a.parameter == nil {
+		return false
+	}
+	return !gslbParameterEquals(actualGSLBParameter(a.GSLB), a.parameter)
+}
+
+// gslbBinding implements BindingState interface
+type gslbBinding struct {
+	binding   *osb.ServiceBinding
+	actual    *params.GSLBBindParameter
+	parameter *params.GSLBBindParameter
+}
+
+func (b *gslbBinding) HasDiff() bool {
+	if b.parameter == nil {
+		return false
+	}
+	return b.actual.IPAddress != b.parameter.IPAddress ||
+		b.actual.Weight != b.parameter.Weight ||
+		*b.actual.Enabled != *b.parameter.Enabled
+}
+
+func (b *gslbBinding) Binding() *osb.ServiceBinding {
+	return b.binding
+}
+
+// actualGSLBParameter returns the parameter which values are read from settings of the GSLB
+func actualGSLBParameter(g *sacloud.GSLB) *params.GSLBCreateParameter {
+	s := g.Settings.GSLB
+	weighted := s.Weighted == "True"
+	p := &params.GSLBCreateParameter{
+		HealthCheck: params.GSLBHealthCheckParameter{
+			Protocol:   s.HealthCheck.Protocol,
+			HostHeader: s.HealthCheck.Host,
+			Path:       s.HealthCheck.Path,
+		},
+		Interval:    s.DelayLoop,
+		Weighted:    &weighted,
+		SorryServer: s.SorryServer,
+	}
+	p.HealthCheck.ExpectedStatus, _ = strconv.Atoi(s.HealthCheck.Status)
+	p.HealthCheck.Port, _ = strconv.Atoi(s.HealthCheck.Port)
+	return p
+}
+
+func gslbParameterEquals(a, b *params.GSLBCreateParameter) bool {
+	return a.HealthCheck == b.HealthCheck &&
+		a.Interval == b.Interval &&
+		*a.Weighted == *b.Weighted &&
+		a.SorryServer == b.SorryServer
+}
+
+// actualGSLBServerParameter returns the parameter which values are read from the server of the GSLB
+func actualGSLBServerParameter(s *sacloud.GSLBServer) *params.GSLBBindParameter {
+	enabled := s.Enabled == "True"
+	p := &params.GSLBBindParameter{
+		IPAddress: s.IPAddress,
+		Enabled:   &enabled,
+	}
+	p.Weight, _ = strconv.Atoi(s.Weight)
+	return p
+}
+
+// gslbBindingKey returns the key of the binding in the description of the GSLB.
+// Binding IDs are hashed to keep the description short enough for all servers
+func gslbBindingKey(bindingID string) string {
+	sum := sha256.Sum256([]byte(bindingID))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// gslbBindingServer returns the server registered by the binding. It returns nil if the binding doesn't exist
+func gslbBindingServer(g *sacloud.GSLB, bindingID string) (*sacloud.GSLBServer, error) {
+	bindings, err := iaas.GSLBBindingServers(g)
+	if err != nil {
+		return nil, fmt.Errorf("reading servers of bindings is failed: %s", err)
+	}
+	ip, ok := bindings[gslbBindingKey(bindingID)]
+	if !ok {
+		return nil, nil
+	}
+	for i := range g.Settings.GSLB.Servers {
+		if g.Settings.GSLB.Servers[i].IPAddress == ip {
+			return &g.Settings.GSLB.Servers[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// gslbCredentials returns credentials which contain the FQDN of the GSLB and the registered server
+func gslbCredentials(g *sacloud.GSLB, p *params.GSLBBindParameter) map[string]interface{} {
+	return map[string]interface{}{
+		"fqdn":      g.Status.FQDN,
+		"ipaddress": p.IPAddress,
+		"weight":    p.Weight,
+	}
+}
+
+// gslbInstanceParameters represents parameters of fetched instances with the FQDN
+type gslbInstanceParameters struct {
+	*params.GSLBCreateParameter
+	FQDN string `json:"fqdn"`
+}
+
+// gslbBindingParameters represents parameters of fetched bindings with the health status
+type gslbBindingParameters struct {
+	*params.GSLBBindParameter
+	Health string `json:"health"`
+}
+
+// readGSLB returns the GSLB. It returns nil if the GSLB is not found
+func readGSLB(instanceID string) (*sacloud.GSLB, error) {
+	g, err := sacloudAPI.GSLB().Read(instanceID)
+	if err != nil {
+		if e, ok := err.(api.Error); ok && e.ResponseCode() == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return g, nil
+}
+
+// fetchGSLB returns the instance with parameters of the actual GSLB.
+// It returns nil if the instance is not found
+func fetchGSLB(instanceID string) (*osb.ServiceInstanceResource, error) {
+	g, err := readGSLB(instanceID)
+	if err != nil || g == nil {
+		return nil, err
+	}
+
+	return &osb.ServiceInstanceResource{
+		ServiceID: GSLBServiceID,
+		PlanID:    GSLBPlanDefaultID,
+		Parameters: &gslbInstanceParameters{
+			GSLBCreateParameter: actualGSLBParameter(g),
+			FQDN:                g.Status.FQDN,
+		},
+	}, nil
+}
+
+// fetchGSLBBinding returns the binding with parameters and the health status of the registered server.
+// It returns nil if the binding is not found
+func fetchGSLBBinding(instanceID, bindingID string) (*osb.ServiceBindingResource, error) {
+	g, err := readGSLB(instanceID)
+	if err != nil || g == nil {
+		return nil, err
+	}
+
+	server, err := gslbBindingServer(g, bindingID)
+	if err != nil || server == nil {
+		return nil, err
+	}
+
+	p := actualGSLBServerParameter(server)
+	health := GSLBServerHealthDisabled
+	if *p.Enabled {
+		health = probeGSLBServer(g.Settings.GSLB.HealthCheck, server.IPAddress)
+	}
+
+	return &osb.ServiceBindingResource{
+		Credentials: gslbCredentials(g, p),
+		Parameters: &gslbBindingParameters{
+			GSLBBindParameter: p,
+			Health:            health,
+		},
+	}, nil
+}
+
+type gslbHandler struct {
+	operation    string
+	serviceID    string
+	planID       string
+	rawParameter []byte
+
+	parameter     *params.GSLBCreateParameter
+	bindParameter *params.GSLBBindParameter
+	paramErr      error
+}
+
+func newGSLBServiceHandler(operation, serviceID, planID string, rawParameter []byte) *gslbHandler {
+	handler := &gslbHandler{
+		operation:    operation,
+		serviceID:    serviceID,
+		planID:       planID,
+		rawParameter: rawParameter,
+	}
+
+	switch operation {
+	case operations.Provisioning:
+		if len(rawParameter) == 0 {
+			handler.paramErr = errors.New("gslbService parameter JSON is empty")
+			return handler
+		}
+
+		var p = params.GSLBCreateParameter{}
+		err := json.Unmarshal(rawParameter, &p)
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		err = p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+		p.SetDefaults()
+
+		handler.parameter = &p
+	case operations.Updating:
+		handler.paramErr = errors.New("updating gslbService is not supported")
+	case operations.Binding:
+		if len(rawParameter) == 0 {
+			handler.paramErr = errors.New("gslbService parameter JSON is empty")
+			return handler
+		}
+
+		var p = params.GSLBBindParameter{}
+		err := json.Unmarshal(rawParameter, &p)
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		err = p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+		p.SetDefaults()
+
+		handler.bindParameter = &p
+	}
+
+	return handler
+}
+
+func (s *gslbHandler) InstanceState(instanceID string) (InstanceState, error) {
+	g, err := readGSLB(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if g == nil {
+		if s.operation == operations.Deprovisioning {
+			knownInstances.remove(instanceID)
+		}
+		return nil, nil
+	}
+	knownInstances.add(instanceID, s.serviceID)
+
+	// drift is detected only against the requested parameter on provisioning
+	return &gslbAttrs{
+		GSLB:      g,
+		parameter: s.parameter,
+	}, nil
+}
+
+func (s *gslbHandler) BindingState(instanceID, bindingID string) (BindingState, error) {
+	g, err := sacloudAPI.GSLB().Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := gslbBindingServer(g, bindingID)
+	if err != nil || server == nil {
+		return nil, err
+	}
+
+	actual := actualGSLBServerParameter(server)
+	return &gslbBinding{
+		binding: &osb.ServiceBinding{
+			Credentials: gslbCredentials(g, actual),
+		},
+		actual:    actual,
+		parameter: s.bindParameter,
+	}, nil
+}
+
+func (s *gslbHandler) CreateInstance(instanceID string) error {
+	_, err := sacloudAPI.GSLB().Create(instanceID, s.parameter)
+	if err != nil {
+		return err
+	}
+	knownInstances.add(instanceID, s.serviceID)
+	return nil
+}
+
+func (s *gslbHandler) UpdateInstance(instanceID string) error {
+	return errors.New("updating gslbService is not supported")
+}
+
+func (s *gslbHandler) DeleteInstance(instanceID string) error {
+	err := sacloudAPI.GSLB().Delete(instanceID)
+	if err != nil {
+		return err
+	}
+	knownInstances.markDeleting(instanceID)
+	return nil
+}
+
+func (s *gslbHandler) CreateBinding(instanceID, bindingID string) (*osb.ServiceBinding, error) {
+	if s.bindParameter == nil {
+		return nil, errors.New("bind parameter is nil")
+	}
+	p := s.bindParameter
+
+	server := sacloud.GSLBServer{
+		IPAddress: p.IPAddress,
+		Weight:    strconv.Itoa(p.Weight),
+		Enabled:   "False",
+	}
+	if *p.Enabled {
+		server.Enabled = "True"
+	}
+
+	g, err := sacloudAPI.GSLB().AddServer(instanceID, gslbBindingKey(bindingID), server)
+	if err != nil {
+		return nil, fmt.Errorf("adding GSLB server is failed: %s", err)
+	}
+
+	return &osb.ServiceBinding{
+		Credentials: gslbCredentials(g, p),
+	}, nil
+}
+
+func (s *gslbHandler) DeleteBinding(instanceID, bindingID string) error {
+	if _, err := sacloudAPI.GSLB().RemoveServer(instanceID, gslbBindingKey(bindingID)); err != nil {
+		return fmt.Errorf("removing GSLB server is failed: %s", err)
+	}
+	return nil
+}
+
+func (s *gslbHandler) ConfirmProvision(instanceID string) error {
+	return sacloudAPI.GSLB().ConfirmProvisioned(instanceID)
+}
+
+func (s *gslbHandler) IsValid() (bool, error) {
+	return s.paramErr == nil, s.paramErr
+}
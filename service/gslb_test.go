@@ -0,0 +1,320 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/stretchr/testify/assert"
+)
+
+type dummyGSLBAPI struct {
+	gslb      *sacloud.GSLB
+	readErr   error
+	createErr error
+	updateErr error
+	deleteErr error
+
+	created *params.GSLBCreateParameter
+}
+
+func (c *dummyGSLBAPI) List() ([]sacloud.GSLB, error) {
+	if c.gslb == nil {
+		return nil, c.readErr
+	}
+	return []sacloud.GSLB{*c.gslb}, c.readErr
+}
+
+func (c *dummyGSLBAPI) Read(instanceID string) (*sacloud.GSLB, error) {
+	return c.gslb, c.readErr
+}
+
+func (c *dummyGSLBAPI) Create(instanceID string, param *params.GSLBCreateParameter) (*sacloud.GSLB, error) {
+	c.created = param
+	return c.gslb, c.createErr
+}
+
+func (c *dummyGSLBAPI) AddServer(instanceID, bindingKey string, server sacloud.GSLBServer) (*sacloud.GSLB, error) {
+	if c.updateErr != nil {
+		return nil, c.updateErr
+	}
+	bindings, _ := iaas.GSLBBindingServers(c.gslb)
+	bindings[bindingKey] = server.IPAddress
+	c.gslb.Settings.GSLB.Servers = append(c.gslb.Settings.GSLB.Servers, server)
+	c.setBindings(bindings)
+	return c.gslb, nil
+}
+
+func (c *dummyGSLBAPI) RemoveServer(instanceID, bindingKey string) (*sacloud.GSLB, error) {
+	if c.updateErr != nil {
+		return nil, c.updateErr
+	}
+	bindings, _ := iaas.GSLBBindingServers(c.gslb)
+	if ip, ok := bindings[bindingKey]; ok {
+		c.gslb.Settings.GSLB.DeleteServer(ip)
+		delete(bindings, bindingKey)
+	}
+	c.setBindings(bindings)
+	return c.gslb, nil
+}
+
+func (c *dummyGSLBAPI) ConfirmProvisioned(instanceID string) error {
+	return nil
+}
+
+func (c *dummyGSLBAPI) Delete(instanceID string) error {
+	return c.deleteErr
+}
+
+func (c *dummyGSLBAPI) setBindings(bindings map[string]string) {
+	desc, _ := json.Marshal(bindings)
+	c.gslb.Description = string(desc)
+}
+
+func testGSLB() *sacloud.GSLB {
+	g := sacloud.CreateNewGSLB("gslb")
+	g.Status.FQDN = "site-000000000000.gslb1.sakura.ne.jp"
+	g.SetHTTPHealthCheck("www.example.com", "/healthz", 200)
+	g.SetDelayLoop(10)
+	g.SetWeightedEnable(true)
+	return g
+}
+
+func TestGSLBServiceValidate(t *testing.T) {
+	expects := []struct {
+		name      string
+		operation string
+		param     []byte
+		result    bool
+	}{
+		{
+			name:      "Provisioning without parameter",
+			operation: operations.Provisioning,
+			result:    false,
+		},
+		{
+			name:      "Provisioning",
+			operation: operations.Provisioning,
+			param:     []byte(`{"healthCheck":{"protocol":"http","path":"/healthz"}}`),
+			result:    true,
+		},
+		{
+			name:      "Updating",
+			operation: operations.Updating,
+			param:     []byte(`{"healthCheck":{"protocol":"ping"}}`),
+			result:    false,
+		},
+		{
+			name:      "Binding with invalid weight",
+			operation: operations.Binding,
+			param:     []byte(`{"ipaddress":"192.2.0.1","weight":0.5}`),
+			result:    false,
+		},
+		{
+			name:      "Binding",
+			operation: operations.Binding,
+			param:     []byte(`{"ipaddress":"192.2.0.1","weight":10}`),
+			result:    true,
+		},
+	}
+
+	for _, expect := range expects {
+		t.Run(expect.name, func(t *testing.T) {
+			handler := newGSLBServiceHandler(expect.operation, GSLBServiceID, GSLBPlanDefaultID, expect.param)
+			valid, err := handler.IsValid()
+			assert.Equal(t, expect.result, valid, "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestGSLBHandler(t *testing.T) {
+	gslbAPI := &dummyGSLBAPI{}
+	sacloudAPI = &dummyAPI{gslbAPI: gslbAPI}
+	defer func() { sacloudAPI = testAPI }()
+
+	defer func(f func(sacloud.GSLBHealthCheck, string) string) { probeGSLBServer = f }(probeGSLBServer)
+	probeGSLBServer = func(hc sacloud.GSLBHealthCheck, ipAddress string) string {
+		return GSLBServerHealthUp
+	}
+
+	t.Run("InstanceState", func(t *testing.T) {
+		gslbAPI.gslb = testGSLB()
+
+		handler := newGSLBServiceHandler(operations.Provisioning, GSLBServiceID, GSLBPlanDefaultID,
+			[]byte(`{"healthCheck":{"protocol":"http","hostHeader":"www.example.com","path":"/healthz"}}`))
+		state, err := handler.InstanceState("gslb")
+		assert.NoError(t, err)
+		assert.True(t, state.IsUp())
+		assert.False(t, state.HasDiff())
+
+		handler = newGSLBServiceHandler(operations.Provisioning, GSLBServiceID, GSLBPlanDefaultID,
+			[]byte(`{"healthCheck":{"protocol":"tcp","port":443}}`))
+		state, err = handler.InstanceState("gslb")
+		assert.NoError(t, err)
+		assert.True(t, state.HasDiff())
+	})
+
+	t.Run("Instance not found", func(t *testing.T) {
+		gslbAPI.gslb = nil
+		gslbAPI.readErr = apiError404
+		defer func() { gslbAPI.readErr = nil }()
+
+		handler := newGSLBServiceHandler(operations.Deprovisioning, GSLBServiceID, GSLBPlanDefaultID, nil)
+		state, err := handler.InstanceState("gslb")
+		assert.NoError(t, err)
+		assert.Nil(t, state)
+
+		instance, err := fetchGSLB("gslb")
+		assert.NoError(t, err)
+		assert.Nil(t, instance)
+	})
+
+	t.Run("Create and delete bindings", func(t *testing.T) {
+		gslbAPI.gslb = testGSLB()
+
+		handler := newGSLBServiceHandler(operations.Binding, GSLBServiceID, GSLBPlanDefaultID,
+			[]byte(`{"ipaddress":"192.2.0.1","weight":10}`))
+		binding, err := handler.CreateBinding("gslb", "binding")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"fqdn":      "site-000000000000.gslb1.sakura.ne.jp",
+			"ipaddress": "192.2.0.1",
+			"weight":    10,
+		}, binding.Credentials)
+		assert.Equal(t, []sacloud.GSLBServer{{IPAddress: "192.2.0.1", Enabled: "True", Weight: "10"}},
+			gslbAPI.gslb.Settings.GSLB.Servers)
+
+		// same parameter returns the existing binding
+		state, err := handler.BindingState("gslb", "binding")
+		assert.NoError(t, err)
+		assert.False(t, state.HasDiff())
+		assert.Equal(t, binding.Credentials, state.Binding().Credentials)
+
+		// different parameter conflicts
+		conflicted := newGSLBServiceHandler(operations.Binding, GSLBServiceID, GSLBPlanDefaultID,
+			[]byte(`{"ipaddress":"192.2.0.1","weight":10,"enabled":false}`))
+		state, err = conflicted.BindingState("gslb", "binding")
+		assert.NoError(t, err)
+		assert.True(t, state.HasDiff())
+
+		state, err = handler.BindingState("gslb", "other")
+		assert.NoError(t, err)
+		assert.Nil(t, state)
+
+		fetched, err := FetchBinding("gslb", "binding")
+		assert.NoError(t, err)
+		assert.Equal(t, binding.Credentials, fetched.Credentials)
+		p := fetched.Parameters.(*gslbBindingParameters)
+		assert.Equal(t, "192.2.0.1", p.IPAddress)
+		assert.Equal(t, GSLBServerHealthUp, p.Health)
+
+		fetched, err = FetchBinding("gslb", "other")
+		assert.NoError(t, err)
+		assert.Nil(t, fetched)
+
+		assert.NoError(t, handler.DeleteBinding("gslb", "binding"))
+		assert.Empty(t, gslbAPI.gslb.Settings.GSLB.Servers)
+
+		// deleting again is no-op
+		assert.NoError(t, handler.DeleteBinding("gslb", "binding"))
+	})
+
+	t.Run("Disabled server", func(t *testing.T) {
+		gslbAPI.gslb = testGSLB()
+
+		handler := newGSLBServiceHandler(operations.Binding, GSLBServiceID, GSLBPlanDefaultID,
+			[]byte(`{"ipaddress":"192.2.0.2","enabled":false}`))
+		_, err := handler.CreateBinding("gslb", "binding")
+		assert.NoError(t, err)
+
+		fetched, err := FetchBinding("gslb", "binding")
+		assert.NoError(t, err)
+		assert.Equal(t, GSLBServerHealthDisabled, fetched.Parameters.(*gslbBindingParameters).Health)
+	})
+
+	t.Run("Adding server is failed", func(t *testing.T) {
+		gslbAPI.gslb = testGSLB()
+		gslbAPI.updateErr = errors.New("dummy")
+		defer func() { gslbAPI.updateErr = nil }()
+
+		handler := newGSLBServiceHandler(operations.Binding, GSLBServiceID, GSLBPlanDefaultID,
+			[]byte(`{"ipaddress":"192.2.0.1"}`))
+		_, err := handler.CreateBinding("gslb", "binding")
+		assert.Error(t, err)
+	})
+
+	t.Run("FetchInstance", func(t *testing.T) {
+		gslbAPI.gslb = testGSLB()
+
+		instance, err := fetchGSLB("gslb")
+		assert.NoError(t, err)
+		assert.Equal(t, GSLBServiceID, instance.ServiceID)
+		assert.Equal(t, GSLBPlanDefaultID, instance.PlanID)
+
+		p := instance.Parameters.(*gslbInstanceParameters)
+		assert.Equal(t, "site-000000000000.gslb1.sakura.ne.jp", p.FQDN)
+		assert.Equal(t, "http", p.HealthCheck.Protocol)
+		assert.Equal(t, 200, p.HealthCheck.ExpectedStatus)
+		assert.Equal(t, 10, p.Interval)
+	})
+}
+
+func TestProbeGSLBServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+
+	address := server.Listener.Addr().String()
+	host, port, _ := net.SplitHostPort(address)
+
+	t.Run("internal addresses are not probed", func(t *testing.T) {
+		hc := sacloud.GSLBHealthCheck{Protocol: "tcp", Port: port}
+		assert.Equal(t, GSLBServerHealthUnknown, probeGSLBServer(hc, host))
+		assert.Equal(t, GSLBServerHealthUnknown, probeGSLBServer(hc, "169.254.169.254"))
+	})
+
+	t.Run("check servers", func(t *testing.T) {
+		assert.Equal(t, GSLBServerHealthUp,
+			checkGSLBServer(sacloud.GSLBHealthCheck{Protocol: "http", Path: "/healthz", Status: "200"}, address))
+		assert.Equal(t, GSLBServerHealthDown,
+			checkGSLBServer(sacloud.GSLBHealthCheck{Protocol: "http", Path: "/", Status: "200"}, address))
+		assert.Equal(t, GSLBServerHealthUp,
+			checkGSLBServer(sacloud.GSLBHealthCheck{Protocol: "tcp", Port: port}, host))
+		assert.Equal(t, GSLBServerHealthUnknown,
+			checkGSLBServer(sacloud.GSLBHealthCheck{Protocol: "ping"}, host))
+
+		// the certificate of the test server is not trusted
+		assert.Equal(t, GSLBServerHealthUnknown,
+			checkGSLBServer(sacloud.GSLBHealthCheck{Protocol: "https", Path: "/", Status: "200"}, tlsServer.Listener.Addr().String()))
+	})
+
+	t.Run("results are cached", func(t *testing.T) {
+		defer func() { gslbProbeResults = make(map[string]*gslbProbeResult) }()
+
+		probed := 0
+		probe := func() string {
+			probed++
+			return GSLBServerHealthUp
+		}
+		assert.Equal(t, GSLBServerHealthUp, probeGSLBServerWithCache("key", probe))
+		assert.Equal(t, GSLBServerHealthUp, probeGSLBServerWithCache("key", probe))
+		assert.Equal(t, 1, probed)
+
+		gslbProbeResults["key"].probedAt = time.Now().Add(-gslbProbeInterval)
+		probeGSLBServerWithCache("key", probe)
+		assert.Equal(t, 2, probed)
+	})
+}
@@ -16,3 +16,9 @@ type Handler interface {
 
 	IsValid() (bool, error)
 }
+
+// ProvisionConfirmer is implemented by Handler which marks instances until their provisioning is reported to the platform.
+// ConfirmProvision is called when the platform is notified of the completion of provisioning
+type ProvisionConfirmer interface {
+	ConfirmProvision(instanceID string) error
+}
@@ -7,3 +7,20 @@ type InstanceState interface {
 	IsMigrating() bool
 	HasDiff() bool
 }
+
+// StatusDescriber is implemented by InstanceState which reports the status of the instance in last_operation
+type StatusDescriber interface {
+	StatusDescription() string
+}
+
+// DeletionReporter is implemented by InstanceState of resources which are deleted in background.
+// IsDeleting reports whether the deletion of the resource is requested
+type DeletionReporter interface {
+	IsDeleting() bool
+}
+
+// IsDeleting reports whether the deletion of the instance is requested and not completed yet
+func IsDeleting(state InstanceState) bool {
+	r, ok := state.(DeletionReporter)
+	return ok && r.IsDeleting()
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+)
+
+// managedResource represents a resource on SAKURA Cloud which is tagged by the broker
+type managedResource struct {
+	instanceID string
+	resourceID string
+	serviceID  string
+	planID     string
+	tags       []string
+	up         bool
+	failed     bool
+	// resource is the resource read from SAKURA Cloud API
+	resource interface{}
+}
+
+func (r *managedResource) hasTag(tag string) bool {
+	return containsString(r.tags, tag)
+}
+
+// taggedResource is implemented by resources of SAKURA Cloud
+type taggedResource interface {
+	GetName() string
+	GetStrID() string
+	GetTags() []string
+}
+
+func newManagedResource(r taggedResource, service *osb.Service, planID string, up, failed bool) *managedResource {
+	return &managedResource{
+		instanceID: r.GetName(),
+		resourceID: r.GetStrID(),
+		serviceID:  service.ID,
+		planID:     planID,
+		tags:       r.GetTags(),
+		up:         up,
+		failed:     failed,
+		resource:   r,
+	}
+}
+
+// inventory lists resources of the service which are tagged by the broker
+type inventory struct {
+	service *osb.Service
+	list    func() ([]*managedResource, error)
+	delete  func(instanceID string) error
+	// fetch returns the instance with actual parameters. It returns nil if the instance is not found
+	fetch func(instanceID string) (*osb.ServiceInstanceResource, error)
+	// requested returns the parameter requested at the creation and names of parameters which differ from the resource.
+	// It is nil if the service doesn't keep requested parameters
+	requested func(r *managedResource) (interface{}, []string, error)
+	// target is set for database services, which are reconciled with parameters and bindings
+	target *reconcileTarget
+}
+
+// inventories returns inventories of all services which create resources on SAKURA Cloud
+func inventories() []*inventory {
+	var res []*inventory
+	for _, target := range reconcileTargets {
+		res = append(res, target.inventory())
+	}
+	return append(res, resourceInventories...)
+}
+
+// resourceInventories cover services other than databases, which are covered by reconcileTargets
+var resourceInventories = []*inventory{
+	{
+		service: NFSService,
+		list: func() ([]*managedResource, error) {
+			nfss, err := sacloudAPI.NFS().List()
+			if err != nil {
+				return nil, err
+			}
+			var res []*managedResource
+			for i := range nfss {
+				nfs := &nfss[i]
+				var planID string
+				if nfs.Remark != nil {
+					planID = NFSIDMap.PlanIDMap[int(nfs.Remark.GetPlanID())]
+				}
+				res = append(res, newManagedResource(nfs, NFSService, planID, nfs.IsUp(), nfs.IsFailed()))
+			}
+			return res, nil
+		},
+		delete: func(instanceID string) error { return sacloudAPI.NFS().Delete(instanceID) },
+		fetch:  fetchNFS,
+		requested: func(r *managedResource) (interface{}, []string, error) {
+			nfs := r.resource.(*sacloud.NFS)
+			p, err := iaas.DesiredNFSParameter(nfs)
+			if err != nil || p == nil {
+				return nil, nil, err
+			}
+			return p, nfsDrift(nfs, p), nil
+		},
+	},
+	{
+		service: DNSService,
+		list: func() ([]*managedResource, error) {
+			zones, err := sacloudAPI.DNS().List()
+			if err != nil {
+				return nil, err
+			}
+			var res []*managedResource
+			for i := range zones {
+				zone := &zones[i]
+				instanceID := iaas.DNSInstanceID(zone)
+				if instanceID == "" {
+					continue
+				}
+				r := newManagedResource(zone, DNSService, DNSPlanZoneID, true, false)
+				r.instanceID = instanceID
+				res = append(res, r)
+			}
+			return res, nil
+		},
+		delete: func(instanceID string) error { return sacloudAPI.DNS().Delete(instanceID) },
+		fetch:  fetchDNS,
+		requested: func(r *managedResource) (interface{}, []string, error) {
+			zone := r.resource.(*sacloud.DNS)
+			p, err := iaas.DesiredDNSParameter(zone)
+			if err != nil || p == nil {
+				return nil, nil, err
+			}
+			var drift []string
+			if p.ZoneName() != zone.Status.Zone {
+				drift = append(drift, "zone")
+			}
+			return p, drift, nil
+		},
+	},
+	{
+		service: SimpleMonitorService,
+		list: func() ([]*managedResource, error) {
+			monitors, err := sacloudAPI.SimpleMonitor().List()
+			if err != nil {
+				return nil, err
+			}
+			var res []*managedResource
+			for i := range monitors {
+				monitor := &monitors[i]
+				instanceID := iaas.SimpleMonitorInstanceID(monitor)
+				if instanceID == "" {
+					continue
+				}
+				r := newManagedResource(monitor, SimpleMonitorService, SimpleMonitorPlanDefaultID, true, false)
+				r.instanceID = instanceID
+				res = append(res, r)
+			}
+			return res, nil
+		},
+		delete: func(instanceID string) error { return sacloudAPI.SimpleMonitor().Delete(instanceID) },
+		fetch:  fetchSimpleMonitor,
+		requested: func(r *managedResource) (interface{}, []string, error) {
+			m := r.resource.(*sacloud.SimpleMonitor)
+			p, err := iaas.DesiredSimpleMonitorParameter(m)
+			if err != nil || p == nil {
+				return nil, nil, err
+			}
+			drift := simpleMonitorDrift(m, p)
+			// the webhook URL is a secret, drift of it is reported without values
+			p.WebhookURL = ""
+			return p, drift, nil
+		},
+	},
+	{
+		service: SwitchService,
+		list: func() ([]*managedResource, error) {
+			switches, err := sacloudAPI.Switch().List()
+			if err != nil {
+				return nil, err
+			}
+			var res []*managedResource
+			for i := range switches {
+				sw := &switches[i]
+				attrs := &switchAttrs{Switch: sw}
+				res = append(res, newManagedResource(sw, SwitchService, SwitchPlanDefaultID, attrs.IsUp(), attrs.IsFailed()))
+			}
+			return res, nil
+		},
+		delete: func(instanceID string) error { return sacloudAPI.Switch().Delete(instanceID) },
+		fetch:  fetchSwitch,
+		requested: func(r *managedResource) (interface{}, []string, error) {
+			sw := r.resource.(*sacloud.Switch)
+			p, err := iaas.DesiredSwitchParameter(sw)
+			if err != nil || p == nil {
+				return nil, nil, err
+			}
+			return p, switchDrift(sw, p), nil
+		},
+	},
+	{
+		service: VPCRouterService,
+		list: func() ([]*managedResource, error) {
+			routers, err := sacloudAPI.VPCRouter().List()
+			if err != nil {
+				return nil, err
+			}
+			var res []*managedResource
+			for i := range routers {
+				router := &routers[i]
+				attrs := &vpcRouterAttrs{VPCRouter: router}
+				planID := VPCRouterIDMap.PlanIDMap[int(router.GetPlanID())]
+				res = append(res, newManagedResource(router, VPCRouterService, planID, attrs.IsUp(), attrs.IsFailed()))
+			}
+			return res, nil
+		},
+		delete: func(instanceID string) error { return sacloudAPI.VPCRouter().Delete(instanceID) },
+		fetch:  fetchVPCRouter,
+	},
+	{
+		service: GSLBService,
+		list: func() ([]*managedResource, error) {
+			gslbs, err := sacloudAPI.GSLB().List()
+			if err != nil {
+				return nil, err
+			}
+			var res []*managedResource
+			for i := range gslbs {
+				res = append(res, newManagedResource(&gslbs[i], GSLBService, GSLBPlanDefaultID, true, false))
+			}
+			return res, nil
+		},
+		delete: func(instanceID string) error { return sacloudAPI.GSLB().Delete(instanceID) },
+		fetch:  fetchGSLB,
+	},
+}
+
+// inventory returns the inventory of databases of the target
+func (t *reconcileTarget) inventory() *inventory {
+	return &inventory{
+		service: t.service,
+		list: func() ([]*managedResource, error) {
+			dbs, err := t.dialect.databaseAPI().List()
+			if err != nil {
+				return nil, err
+			}
+			var res []*managedResource
+			for i := range dbs {
+				db := &dbs[i]
+				var planID string
+				if db.Remark != nil {
+					planID = t.planIDs[int(db.Remark.GetPlanID())]
+				}
+				res = append(res, newManagedResource(db, t.service, planID, db.IsUp(), db.IsFailed()))
+			}
+			return res, nil
+		},
+		delete: func(instanceID string) error { return t.dialect.databaseAPI().Delete(instanceID) },
+		fetch:  func(instanceID string) (*osb.ServiceInstanceResource, error) { return fetchDatabase(t, instanceID) },
+		requested: func(r *managedResource) (interface{}, []string, error) {
+			db := r.resource.(*sacloud.Database)
+			p, err := iaas.DesiredDatabaseParameter(db)
+			if err != nil || p == nil {
+				return nil, nil, err
+			}
+			return p, databaseDrift(db, p), nil
+		},
+		target: t,
+	}
+}
+
+// inventoryOf returns the inventory of the service. It returns nil if the service doesn't create resources
+func inventoryOf(serviceID string) *inventory {
+	for _, inv := range inventories() {
+		if inv.service.ID == serviceID {
+			return inv
+		}
+	}
+	return nil
+}
+
+// PendingProvision represents the instance which provisioning is not reported as succeeded to the platform yet
+type PendingProvision struct {
+	InstanceID string
+	ServiceID  string
+	PlanID     string
+}
+
+// PendingProvisions returns instances which are tagged as provisioning on SAKURA Cloud.
+// Instances which deletion is requested are excluded
+func PendingProvisions() ([]*PendingProvision, error) {
+	var res []*PendingProvision
+	for _, inv := range inventories() {
+		resources, err := inv.list()
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range resources {
+			if !r.hasTag(iaas.ProvisioningMarkerTag) || r.hasTag(iaas.DeletingMarkerTag) {
+				continue
+			}
+			res = append(res, &PendingProvision{
+				InstanceID: r.instanceID,
+				ServiceID:  r.serviceID,
+				PlanID:     r.planID,
+			})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+)
+
+// knownInstances holds instances which the broker has handled.
+// They are kept only in memory unless LoadKnownInstances is called
+var knownInstances = newInstanceRegistry()
+
+type knownInstance struct {
+	serviceID string
+	deleting  bool
+}
+
+// knownInstanceRecord is the format of instances in the file of the registry
+type knownInstanceRecord struct {
+	ServiceID string `json:"service_id"`
+	Deleting  bool   `json:"deleting,omitempty"`
+}
+
+type instanceRegistry struct {
+	mu        sync.Mutex
+	instances map[string]*knownInstance
+	// loaded is true if instances are loaded from the file, so instances which aren't in the registry are unknown
+	loaded bool
+	// path is the file which changes are saved to
+	path string
+}
+
+func newInstanceRegistry() *instanceRegistry {
+	return &instanceRegistry{
+		instances: make(map[string]*knownInstance),
+	}
+}
+
+// LoadKnownInstances reads instances known to the broker from the file.
+// If save is true, changes are written to the file, and the file is created with
+// resources found on SAKURA Cloud if it doesn't exist.
+// Instances are kept only in memory if the file doesn't exist and save is false
+func LoadKnownInstances(path string, save bool) error {
+	registry := newInstanceRegistry()
+
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case err == nil:
+		records := map[string]*knownInstanceRecord{}
+		if err := json.Unmarshal(data, &records); err != nil {
+			return fmt.Errorf("parsing known instances is failed: %s", err)
+		}
+		for id, record := range records {
+			registry.instances[id] = &knownInstance{serviceID: record.ServiceID, deleting: record.Deleting}
+		}
+		registry.loaded = true
+	case os.IsNotExist(err) && save:
+		if err := registry.seed(); err != nil {
+			return fmt.Errorf("listing resources for known instances is failed: %s", err)
+		}
+		registry.loaded = true
+	case os.IsNotExist(err):
+		log.WithField("path", path).Warn("known instances file is not found, instances are kept only in memory")
+	default:
+		return fmt.Errorf("reading known instances is failed: %s", err)
+	}
+
+	if save {
+		registry.path = path
+		registry.mu.Lock()
+		err := registry.save()
+		registry.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	knownInstances = registry
+	return nil
+}
+
+// seed adds all resources tagged by the broker. It is used when the broker starts with a new file
+func (r *instanceRegistry) seed() error {
+	for _, inv := range inventories() {
+		resources, err := inv.list()
+		if err != nil {
+			return err
+		}
+		for _, res := range resources {
+			r.instances[res.instanceID] = &knownInstance{
+				serviceID: res.serviceID,
+				deleting:  res.hasTag(iaas.DeletingMarkerTag),
+			}
+		}
+	}
+	return nil
+}
+
+// save writes instances to the file. The caller must hold the lock
+func (r *instanceRegistry) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	records := make(map[string]*knownInstanceRecord, len(r.instances))
+	for id, instance := range r.instances {
+		records[id] = &knownInstanceRecord{ServiceID: instance.serviceID, Deleting: instance.deleting}
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// replace the file at once not to leave broken contents
+	tmp, err := ioutil.TempFile(filepath.Dir(r.path), filepath.Base(r.path))
+	if err != nil {
+		return fmt.Errorf("saving known instances is failed: %s", err)
+	}
+	defer os.Remove(tmp.Name()) // nolint
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() // nolint
+		return fmt.Errorf("saving known instances is failed: %s", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("saving known instances is failed: %s", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("saving known instances is failed: %s", err)
+	}
+	return nil
+}
+
+// saveOrLog saves instances, and logs the error because callers can't recover from it.
+// The caller must hold the lock
+func (r *instanceRegistry) saveOrLog() {
+	if err := r.save(); err != nil {
+		log.WithField("err", err).Error("known instances are not saved")
+	}
+}
+
+// isLoaded returns true if instances which aren't in the registry are unknown to the broker
+func (r *instanceRegistry) isLoaded() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.loaded
+}
+
+func (r *instanceRegistry) add(instanceID, serviceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.instances[instanceID]; !ok {
+		r.instances[instanceID] = &knownInstance{serviceID: serviceID}
+		r.saveOrLog()
+	}
+}
+
+func (r *instanceRegistry) markDeleting(instanceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if instance, ok := r.instances[instanceID]; ok && !instance.deleting {
+		instance.deleting = true
+		r.saveOrLog()
+	}
+}
+
+func (r *instanceRegistry) remove(instanceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.instances[instanceID]; ok {
+		delete(r.instances, instanceID)
+		r.saveOrLog()
+	}
+}
+
+func (r *instanceRegistry) get(instanceID string) (knownInstance, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	instance, ok := r.instances[instanceID]
+	if !ok {
+		return knownInstance{}, false
+	}
+	return *instance, true
+}
+
+// list returns instance IDs of the service
+func (r *instanceRegistry) list(serviceID string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []string
+	for id, instance := range r.instances {
+		if instance.serviceID == serviceID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
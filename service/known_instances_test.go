@@ -0,0 +1,84 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadKnownInstances(t *testing.T) {
+	orgKnownInstances := knownInstances
+	defer func() { knownInstances = orgKnownInstances }()
+
+	dbAPI := &genericDBDummyAPI{}
+	sacloudAPI = &dummyAPI{dbAPI: dbAPI}
+	defer func() { sacloudAPI = testAPI }()
+
+	orgTargets := reconcileTargets
+	reconcileTargets = []*reconcileTarget{
+		{service: MariaDBService, planIDs: DatabaseIDMap["MariaDB"].PlanIDMap, dialect: &dummyDBFuncs{}},
+	}
+	defer func() { reconcileTargets = orgTargets }()
+
+	orgInventories := resourceInventories
+	resourceInventories = nil
+	defer func() { resourceInventories = orgInventories }()
+
+	dir, err := ioutil.TempDir("", "known-instances")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint
+	path := filepath.Join(dir, "instances.json")
+
+	t.Run("Not saved", func(t *testing.T) {
+		assert.NoError(t, LoadKnownInstances(path, false))
+		assert.False(t, knownInstances.isLoaded())
+
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Created with existing resources", func(t *testing.T) {
+		deleting := reconcileTestInstance("deleting", true)
+		deleting.AppendTag(iaas.DeletingMarkerTag)
+		dbAPI.listResult = []sacloud.Database{reconcileTestInstance("existing", true), deleting}
+		defer func() { dbAPI.listResult = nil }()
+
+		assert.NoError(t, LoadKnownInstances(path, true))
+		assert.True(t, knownInstances.isLoaded())
+
+		instance, ok := knownInstances.get("existing")
+		assert.True(t, ok)
+		assert.Equal(t, MariaDBServiceID, instance.serviceID)
+		instance, ok = knownInstances.get("deleting")
+		assert.True(t, ok)
+		assert.True(t, instance.deleting)
+	})
+
+	t.Run("Changes are saved", func(t *testing.T) {
+		knownInstances.add("added", SwitchServiceID)
+		knownInstances.remove("existing")
+		knownInstances.markDeleting("added")
+
+		assert.NoError(t, LoadKnownInstances(path, false))
+		assert.True(t, knownInstances.isLoaded())
+
+		instance, ok := knownInstances.get("added")
+		assert.True(t, ok)
+		assert.Equal(t, SwitchServiceID, instance.serviceID)
+		assert.True(t, instance.deleting)
+		_, ok = knownInstances.get("existing")
+		assert.False(t, ok)
+	})
+
+	t.Run("Broken file", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("{"), 0600))
+		assert.Error(t, LoadKnownInstances(path, true))
+	})
+}
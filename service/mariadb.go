@@ -1,10 +1,15 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
 	"github.com/go-sql-driver/mysql"
 	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
 	"github.com/sacloud/open-service-broker-sacloud/iaas"
@@ -13,15 +18,110 @@ import (
 )
 
 const (
-	mariaDBProtocol      = "tcp"
-	mariaDBMetaTableName = "open_service_broker_meta"
-	mariaDBMetaTableDDL  = `CREATE TABLE %s.` + mariaDBMetaTableName + ` (
-		binding_id VARCHAR(36),
-		name VARCHAR(20),
-		password VARCHAR(128)
-	)`
+	mariaDBProtocol        = "tcp"
+	mariaDBErrNoSuchThread = 1094
+	mariaDBMetaTableName   = "open_service_broker_meta"
+	mariaDBMetaLockName    = "open_service_broker_meta_migration"
+	mariaDBMetaLockTimeout = 30 // seconds
 )
 
+// mariaDBMetaSchema returns migrations of meta tables in the admin database
+func mariaDBMetaSchema(connInfo ConnectionInfo) *metaSchema {
+	schemaName := connInfo.UserName()
+	return &metaSchema{
+		versionTableDDL: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s.%s (
+			version INT PRIMARY KEY,
+			description VARCHAR(255),
+			applied_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+		)`, schemaName, metaSchemaVersionTableName),
+		existsVersionTable: func(ctx context.Context, conn *sql.Conn) (bool, error) {
+			return mariaDBExistsTable(ctx, conn, schemaName, metaSchemaVersionTableName)
+		},
+		selectVersions: fmt.Sprintf(`SELECT version FROM %s.%s`, schemaName, metaSchemaVersionTableName),
+		insertVersion:  fmt.Sprintf(`INSERT INTO %s.%s (version, description) VALUES (?, ?)`, schemaName, metaSchemaVersionTableName),
+		lock: func(ctx context.Context, conn *sql.Conn) error {
+			var locked sql.NullInt64
+			err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, mariaDBMetaLockName, mariaDBMetaLockTimeout).Scan(&locked)
+			if err != nil {
+				return err
+			}
+			if !locked.Valid || locked.Int64 != 1 {
+				return fmt.Errorf("timeout waiting for lock %q", mariaDBMetaLockName)
+			}
+			return nil
+		},
+		unlock: fmt.Sprintf(`SELECT RELEASE_LOCK('%s')`, mariaDBMetaLockName),
+		migrations: []*metaMigration{
+			{
+				version:     1,
+				description: "create meta table",
+				statements: []string{
+					fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s.%s (
+						binding_id VARCHAR(36),
+						name VARCHAR(20),
+						password VARCHAR(128)
+					)`, schemaName, mariaDBMetaTableName),
+				},
+			},
+			{
+				version:     2,
+				description: "add created_at to meta table",
+				statements: []string{
+					fmt.Sprintf(`ALTER TABLE %s.%s ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NULL DEFAULT NULL`,
+						schemaName, mariaDBMetaTableName),
+				},
+			},
+			{
+				version:     3,
+				description: "add tls to meta table",
+				statements: []string{
+					fmt.Sprintf(`ALTER TABLE %s.%s ADD COLUMN IF NOT EXISTS tls BOOLEAN NOT NULL DEFAULT FALSE`,
+						schemaName, mariaDBMetaTableName),
+				},
+			},
+			{
+				version:     4,
+				description: "add credential_format to meta table",
+				statements: []string{
+					fmt.Sprintf(`ALTER TABLE %s.%s ADD COLUMN IF NOT EXISTS credential_format VARCHAR(64) NOT NULL DEFAULT ''`,
+						schemaName, mariaDBMetaTableName),
+				},
+			},
+			{
+				version:     5,
+				description: "add database options to meta table",
+				statements: []string{
+					fmt.Sprintf(`ALTER TABLE %s.%s ADD COLUMN IF NOT EXISTS character_set VARCHAR(64) NOT NULL DEFAULT ''`,
+						schemaName, mariaDBMetaTableName),
+					fmt.Sprintf(`ALTER TABLE %s.%s ADD COLUMN IF NOT EXISTS collation_name VARCHAR(64) NOT NULL DEFAULT ''`,
+						schemaName, mariaDBMetaTableName),
+					fmt.Sprintf(`ALTER TABLE %s.%s ADD COLUMN IF NOT EXISTS allowed_host VARCHAR(64) NOT NULL DEFAULT ''`,
+						schemaName, mariaDBMetaTableName),
+				},
+			},
+		},
+	}
+}
+
+func mariaDBExistsTable(ctx context.Context, conn *sql.Conn, schemaName, tableName string) (bool, error) {
+	var res string
+	query := `
+			SELECT table_name
+			FROM information_schema.tables
+			WHERE table_schema = ? AND table_name = ? LIMIT 1;
+		`
+	err := conn.QueryRowContext(ctx, query, schemaName, tableName).Scan(&res)
+
+	switch {
+	case err == nil:
+		return true, nil
+	case err == sql.ErrNoRows:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
 func newMariaDBServiceHandler(operation, serviceID, planID string, rawParameter []byte) *databaseHandler {
 	handler := &databaseHandler{
 		operation:    operation,
@@ -62,8 +162,56 @@ func newMariaDBServiceHandler(operation, serviceID, planID string, rawParameter
 		}
 
 		handler.parameter = &p
+	case operations.Updating:
+		if len(rawParameter) == 0 {
+			handler.paramErr = errors.New("mariaDBService parameter JSON is empty")
+			return handler
+		}
+
+		var p = params.DatabaseUpdateParameter{}
+		err := json.Unmarshal(rawParameter, &p)
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		err = p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		handler.updateParameter = &p
 	case operations.Binding:
-		// noop
+		var p = params.DatabaseBindParameter{}
+		if len(rawParameter) > 0 {
+			err := json.Unmarshal(rawParameter, &p)
+			if err != nil {
+				handler.paramErr = err
+				return handler
+			}
+		}
+
+		err := p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+		if p.CredentialFormat != "" && !hasCredentialFormat(serviceID, p.CredentialFormat) {
+			handler.paramErr = fmt.Errorf("credentialFormat %q is not defined", p.CredentialFormat)
+			return handler
+		}
+		if p.TLS && planTLSConfig(planID) == nil {
+			handler.paramErr = errors.New("tls is not available: TLS is not configured on the plan")
+			return handler
+		}
+		if !p.PostgreSQLDatabaseOptions.IsEmpty() {
+			handler.paramErr = errors.New("mariaDBService not support extensions, schemas, encoding, lcCollate and lcCtype")
+			return handler
+		}
+		p.DatabaseBindingLimits = p.DatabaseBindingLimits.WithDefaults(planBindingDefaults(planID))
+
+		handler.bindParameter = &p
 	default:
 		handler.paramErr = fmt.Errorf("mariaDBService not support %q", operation)
 	}
@@ -77,7 +225,11 @@ func (f *mariaDBHandler) databaseAPI() iaas.DatabaseAPI {
 	return sacloudAPI.MariaDB()
 }
 
-func (f *mariaDBHandler) buildConnInfo(host, dbName, user, password, salt string, port int) ConnectionInfo {
+func (f *mariaDBHandler) idMap() PlanIDMap {
+	return DatabaseIDMap["MariaDB"]
+}
+
+func (f *mariaDBHandler) buildConnInfo(host, dbName, user, password, salt string, port int, tlsConfig *DatabaseTLSConfig) ConnectionInfo {
 	return &mariaDBConnInfo{
 		host:     host,
 		dbName:   dbName,
@@ -85,22 +237,10 @@ func (f *mariaDBHandler) buildConnInfo(host, dbName, user, password, salt string
 		password: password,
 		salt:     salt,
 		port:     port,
+		tls:      tlsConfig,
 	}
 }
 
-func (f *mariaDBHandler) prepareMetaTable(db *sql.DB, connInfo ConnectionInfo) error {
-	exists, err := f.existsMetaTable(db, connInfo)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return nil
-	}
-
-	_, err = db.Exec(fmt.Sprintf(mariaDBMetaTableDDL, connInfo.UserName()))
-	return err
-}
-
 func (f *mariaDBHandler) existsMetaTable(db *sql.DB, connInfo ConnectionInfo) (bool, error) {
 	var res string
 	query := `
@@ -120,10 +260,22 @@ func (f *mariaDBHandler) existsMetaTable(db *sql.DB, connInfo ConnectionInfo) (b
 	}
 }
 
+func (f *mariaDBHandler) migrateMetaTable(db *sql.DB, connInfo ConnectionInfo) error {
+	return applyMetaMigrations(db, connInfo, mariaDBMetaSchema(connInfo))
+}
+
+func (f *mariaDBHandler) metaSchemaVersion(db *sql.DB, connInfo ConnectionInfo) (int, int, error) {
+	schema := mariaDBMetaSchema(connInfo)
+	current, err := currentMetaVersion(db, schema)
+	return current, schema.latestVersion(), err
+}
+
 func (f *mariaDBHandler) readBinding(db *sql.DB, connInfo ConnectionInfo, bindingID string) (*databaseBindingRecord, error) {
-	var username, password string
+	var username, password, credentialFormat, characterSet, collation, allowedHost string
+	var useTLS bool
 	query := fmt.Sprintf(
-		`SELECT name, AES_DECRYPT(password, SHA2(?,512)) FROM %s.%s WHERE binding_id = ? LIMIT 1`,
+		`SELECT name, AES_DECRYPT(password, SHA2(?,512)), tls, credential_format, character_set, collation_name, allowed_host `+
+			`FROM %s.%s WHERE binding_id = ? LIMIT 1`,
 		connInfo.UserName(),
 		mariaDBMetaTableName)
 	rows, err := db.Query(query, connInfo.Salt(), bindingID)
@@ -131,34 +283,92 @@ func (f *mariaDBHandler) readBinding(db *sql.DB, connInfo ConnectionInfo, bindin
 		return nil, err
 	}
 	if rows.Next() {
-		err = rows.Scan(&username, &password)
+		err = rows.Scan(&username, &password, &useTLS, &credentialFormat, &characterSet, &collation, &allowedHost)
 		if err != nil {
 			return nil, err
 		}
 
 		return &databaseBindingRecord{
-			bindingID: bindingID,
-			username:  username,
-			password:  password,
+			bindingID:        bindingID,
+			username:         username,
+			password:         password,
+			tls:              useTLS,
+			credentialFormat: credentialFormat,
+			characterSet:     characterSet,
+			collation:        collation,
+			allowedHost:      allowedHost,
 		}, nil
 	}
 	return nil, nil
 }
 
-func (f *mariaDBHandler) createBinding(db *sql.DB, connInfo ConnectionInfo, bindingID string) (*databaseBindingRecord, error) {
+func (f *mariaDBHandler) listBindings(db *sql.DB, connInfo ConnectionInfo) ([]*databaseBindingRecord, error) {
+	query := fmt.Sprintf(
+		`SELECT binding_id, name, AES_DECRYPT(password, SHA2(?,512)), tls, credential_format, character_set, collation_name, allowed_host `+
+			`FROM %s.%s`,
+		connInfo.UserName(),
+		mariaDBMetaTableName)
+	rows, err := db.Query(query, connInfo.Salt())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // nolint
+
+	var records []*databaseBindingRecord
+	for rows.Next() {
+		record := &databaseBindingRecord{}
+		err := rows.Scan(
+			&record.bindingID,
+			&record.username,
+			&record.password,
+			&record.tls,
+			&record.credentialFormat,
+			&record.characterSet,
+			&record.collation,
+			&record.allowedHost,
+		)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (f *mariaDBHandler) createBinding(db *sql.DB, connInfo ConnectionInfo, bindingID string, p *params.DatabaseBindParameter) (*databaseBindingRecord, error) {
+	// reject options which the DBMS doesn't support before creating anything
+	if err := f.checkDatabaseOptions(db, &p.MariaDBDatabaseOptions); err != nil {
+		return nil, err
+	}
+
 	// create and add metadata
-	username := random.String(20)
+	username := newBindingUsername()
 	password := random.String(30)
+	host := mariaDBUserHost(p.AllowedHost)
 
-	_, err := db.Exec(fmt.Sprintf(`CREATE DATABASE %s`, username))
+	_, err := db.Exec(createMariaDBDatabaseQuery(username, &p.MariaDBDatabaseOptions))
 	if err != nil {
 		return nil, fmt.Errorf(`error creating user database %q: %s`, username, err)
 
 	}
 
+	// record actual values, defaults of the server are applied to unspecified ones
+	var characterSet, collation string
+	err = db.QueryRow(
+		`SELECT default_character_set_name, default_collation_name FROM information_schema.schemata WHERE schema_name = ?`,
+		username,
+	).Scan(&characterSet, &collation)
+	if err != nil {
+		return nil, fmt.Errorf(`error reading user database %q: %s`, username, err)
+	}
+
 	createUserSQL := fmt.Sprintf(
-		`CREATE USER '%s'@'%%' IDENTIFIED BY '%s'`,
-		username, password)
+		`CREATE USER '%s'@'%s' IDENTIFIED BY '%s'`,
+		username, host, password)
+	if p.TLS {
+		// reject connections without TLS
+		createUserSQL += " REQUIRE SSL"
+	}
 	_, err = db.Exec(createUserSQL)
 	if err != nil {
 		return nil, fmt.Errorf("error creating user %q: %s", username, err)
@@ -169,34 +379,121 @@ func (f *mariaDBHandler) createBinding(db *sql.DB, connInfo ConnectionInfo, bind
 			"INDEX, ALTER, CREATE TEMPORARY TABLES, LOCK TABLES, "+
 			"CREATE VIEW, SHOW VIEW, CREATE ROUTINE, ALTER ROUTINE, "+
 			"EXECUTE, REFERENCES, EVENT, "+
-			"TRIGGER ON %s.* TO '%s'@'%%'",
-		username, username)
+			"TRIGGER ON %s.* TO '%s'@'%s'",
+		username, username, host)
 	if _, err = db.Exec(grantSQL); err != nil {
 		return nil, fmt.Errorf("error granting permission to %q: %s", username, err)
 	}
 
+	if err := f.updateBindingLimits(db, username, &p.DatabaseBindingLimits); err != nil {
+		return nil, err
+	}
+
 	// insert metadata
 	_, err = db.Exec(
-		fmt.Sprintf("insert into %s values (?,?,AES_ENCRYPT(?, SHA2(?,512)))", mariaDBMetaTableName),
+		fmt.Sprintf(
+			"insert into %s (binding_id, name, password, tls, credential_format, character_set, collation_name, allowed_host, created_at) "+
+				"values (?,?,AES_ENCRYPT(?, SHA2(?,512)),?,?,?,?,?,CURRENT_TIMESTAMP)",
+			mariaDBMetaTableName),
 		bindingID,
 		username,
 		password,
 		connInfo.Salt(),
+		p.TLS,
+		p.CredentialFormat,
+		characterSet,
+		collation,
+		p.AllowedHost,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error creating metadata record : %s", err)
 	}
 
 	return &databaseBindingRecord{
-		bindingID: bindingID,
-		username:  username,
-		password:  password,
+		bindingID:        bindingID,
+		username:         username,
+		password:         password,
+		tls:              p.TLS,
+		credentialFormat: p.CredentialFormat,
+		characterSet:     characterSet,
+		collation:        collation,
+		allowedHost:      p.AllowedHost,
 	}, nil
 }
 
+// checkDatabaseOptions verifies the requested character set and collation are available on the DBMS
+func (f *mariaDBHandler) checkDatabaseOptions(db *sql.DB, opts *params.MariaDBDatabaseOptions) error {
+	if opts.CharacterSet == "" && opts.Collation == "" {
+		return nil
+	}
+
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM information_schema.collations `+
+			`WHERE (? = '' OR character_set_name = ?) AND (? = '' OR collation_name = ?)`,
+		opts.CharacterSet, opts.CharacterSet, opts.Collation, opts.Collation,
+	).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("error reading collations: %s", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("character set %q and collation %q are not available", opts.CharacterSet, opts.Collation)
+	}
+	return nil
+}
+
+func createMariaDBDatabaseQuery(name string, opts *params.MariaDBDatabaseOptions) string {
+	query := fmt.Sprintf(`CREATE DATABASE %s`, name)
+	if opts.CharacterSet != "" {
+		query += fmt.Sprintf(` CHARACTER SET %s`, opts.CharacterSet)
+	}
+	if opts.Collation != "" {
+		query += fmt.Sprintf(` COLLATE %s`, opts.Collation)
+	}
+	return query
+}
+
+// mariaDBUserHost converts the CIDR to the host part of the account name.
+// It returns '%'(any host) if cidr is empty
+func mariaDBUserHost(cidr string) string {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "%"
+	}
+	// MariaDB accepts only the netmask notation
+	return fmt.Sprintf("%s/%s", ipNet.IP, net.IP(ipNet.Mask))
+}
+
+// userHosts returns host parts of accounts which have the user name
+func (f *mariaDBHandler) userHosts(db *sql.DB, username string) ([]string, error) {
+	rows, err := db.Query(`SELECT host FROM mysql.user WHERE user = ?`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // nolint
+
+	var hosts []string
+	for rows.Next() {
+		var host string
+		if err := rows.Scan(&host); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, rows.Err()
+}
+
 func (f *mariaDBHandler) deleteBinding(db *sql.DB, record *databaseBindingRecord) error {
 
-	exists, _ := f.existsUserDatabase(db, record.username)
+	// drop the user first so that the application can't reconnect
+	if err := f.dropUser(db, record.username); err != nil {
+		return err
+	}
+
+	exists, err := f.existsUserDatabase(db, record.username)
+	if err != nil {
+		return fmt.Errorf(`error reading user database %q: %s`, record.username, err)
+	}
 	if exists {
 		_, err := db.Exec(fmt.Sprintf(`DROP DATABASE %s`, record.username))
 		if err != nil {
@@ -204,7 +501,11 @@ func (f *mariaDBHandler) deleteBinding(db *sql.DB, record *databaseBindingRecord
 		}
 	}
 
-	_, err := db.Exec(
+	if err := verifyBindingRemoved(f, db, record.username); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
 		fmt.Sprintf(`DELETE FROM %s WHERE binding_id = ?`, mariaDBMetaTableName),
 		record.bindingID,
 	)
@@ -215,6 +516,187 @@ func (f *mariaDBHandler) deleteBinding(db *sql.DB, record *databaseBindingRecord
 	return nil
 }
 
+func (f *mariaDBHandler) dropUser(db *sql.DB, username string) error {
+	hosts, err := f.userHosts(db, username)
+	if err != nil {
+		return fmt.Errorf(`error reading user %q: %s`, username, err)
+	}
+	for _, host := range hosts {
+		_, err := db.Exec(fmt.Sprintf(`DROP USER '%s'@'%s'`, username, host))
+		if err != nil {
+			return fmt.Errorf(`error deleting user %q: %s`, username, err)
+		}
+	}
+
+	// sessions are kept after DROP USER
+	rows, err := db.Query(`SELECT id FROM information_schema.processlist WHERE user = ?`, username)
+	if err != nil {
+		return fmt.Errorf(`error reading sessions of user %q: %s`, username, err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close() // nolint
+			return fmt.Errorf(`error reading sessions of user %q: %s`, username, err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close() // nolint
+
+	for _, id := range ids {
+		if _, err := db.Exec(fmt.Sprintf(`KILL CONNECTION %d`, id)); err != nil {
+			// the session may be already closed
+			if e, ok := err.(*mysql.MySQLError); !ok || e.Number != mariaDBErrNoSuchThread {
+				return fmt.Errorf(`error terminating session of user %q: %s`, username, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (f *mariaDBHandler) existsUser(db *sql.DB, username string) (bool, error) {
+	var res string
+	query := `SELECT user FROM mysql.user WHERE user = ? LIMIT 1`
+	err := db.QueryRow(query, username).Scan(&res)
+
+	switch {
+	case err == nil:
+		return true, nil
+	case err == sql.ErrNoRows:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (f *mariaDBHandler) listUsers(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT DISTINCT user FROM mysql.user`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // nolint
+
+	var users []string
+	for rows.Next() {
+		var user string
+		if err := rows.Scan(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// lockUsers does nothing because createBinding creates the user database before the user,
+// so users of bindings being created are never regarded as leaked
+func (f *mariaDBHandler) lockUsers(db *sql.DB) (func(), error) {
+	return func() {}, nil
+}
+
+// isLegacyBindingUser returns true if the user is only for any host and has privileges only on the database
+// which has the same name, like users created by older versions
+func (f *mariaDBHandler) isLegacyBindingUser(db *sql.DB, connInfo ConnectionInfo, username string) (bool, error) {
+	hosts, err := f.userHosts(db, username)
+	if err != nil {
+		return false, err
+	}
+	if len(hosts) != 1 || hosts[0] != "%" {
+		return false, nil
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`SHOW GRANTS FOR '%s'@'%%'`, username))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close() // nolint
+
+	var grants []string
+	for rows.Next() {
+		var grant string
+		if err := rows.Scan(&grant); err != nil {
+			return false, err
+		}
+		grants = append(grants, grant)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return mariaDBGrantsOnlyOwnDatabase(grants, username), nil
+}
+
+// mariaDBGrantsOnlyOwnDatabase returns true if grants have privileges on the database which has the same name as the user,
+// and no privileges on others
+func mariaDBGrantsOnlyOwnDatabase(grants []string, username string) bool {
+	own := false
+	for _, grant := range grants {
+		on := strings.Index(grant, " ON ")
+		to := strings.Index(grant, " TO ")
+		if on < 0 || to < on {
+			return false
+		}
+		target := strings.Replace(grant[on+len(" ON "):to], "`", "", -1)
+		switch {
+		case target == username+".*":
+			own = true
+		case target == "*.*" && strings.HasPrefix(grant, "GRANT USAGE ON "):
+			// USAGE means no privileges
+		default:
+			return false
+		}
+	}
+	return own
+}
+
+func (f *mariaDBHandler) updateBindingPassword(db *sql.DB, connInfo ConnectionInfo, record *databaseBindingRecord) error {
+	_, err := db.Exec(fmt.Sprintf(
+		`SET PASSWORD FOR '%s'@'%s' = PASSWORD('%s')`,
+		record.username,
+		mariaDBUserHost(record.allowedHost),
+		record.password,
+	))
+	if err != nil {
+		return fmt.Errorf("error updating password of user %q: %s", record.username, err)
+	}
+
+	_, err = db.Exec(
+		fmt.Sprintf(
+			`UPDATE %s.%s SET password = AES_ENCRYPT(?, SHA2(?,512)) WHERE binding_id = ?`,
+			connInfo.UserName(),
+			mariaDBMetaTableName),
+		record.password,
+		connInfo.Salt(),
+		record.bindingID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating metadata record : %s", err)
+	}
+
+	return nil
+}
+
+// updateBindingLimits sets resource limits of the user. Zero means unlimited
+func (f *mariaDBHandler) updateBindingLimits(db *sql.DB, username string, limits *params.DatabaseBindingLimits) error {
+	hosts, err := f.userHosts(db, username)
+	if err != nil {
+		return fmt.Errorf("error reading user %q: %s", username, err)
+	}
+	for _, host := range hosts {
+		_, err := db.Exec(fmt.Sprintf(
+			"ALTER USER '%s'@'%s' WITH MAX_USER_CONNECTIONS %d MAX_QUERIES_PER_HOUR %d MAX_STATEMENT_TIME %d",
+			username,
+			host,
+			params.LimitValue(limits.MaxConnections),
+			params.LimitValue(limits.MaxQueriesPerHour),
+			params.LimitValue(limits.MaxQueryTime),
+		))
+		if err != nil {
+			return fmt.Errorf("error updating limits of user %q: %s", username, err)
+		}
+	}
+	return nil
+}
+
 func (f *mariaDBHandler) existsUserDatabase(db *sql.DB, dbName string) (bool, error) {
 	var res string
 	query := `
@@ -240,6 +722,7 @@ type mariaDBConnInfo struct {
 	port     int
 	dbName   string
 	salt     string
+	tls      *DatabaseTLSConfig
 }
 
 func (c *mariaDBConnInfo) DriverName() string {
@@ -274,6 +757,10 @@ func (c *mariaDBConnInfo) Salt() string {
 	return c.salt
 }
 
+func (c *mariaDBConnInfo) TLS() *DatabaseTLSConfig {
+	return c.tls
+}
+
 func (c *mariaDBConnInfo) FormatDSN() string {
 	cfg := mysql.NewConfig()
 	cfg.User = c.username
@@ -281,5 +768,24 @@ func (c *mariaDBConnInfo) FormatDSN() string {
 	cfg.Net = mariaDBProtocol
 	cfg.Addr = fmt.Sprintf("%s:%d", c.host, c.Port())
 	cfg.DBName = c.dbName
+	if c.tls != nil {
+		cfg.TLSConfig = "skip-verify"
+		if c.tls.mysqlConfigName != "" {
+			cfg.TLSConfig = c.tls.mysqlConfigName
+		}
+	}
 	return cfg.FormatDSN()
 }
+
+func (c *mariaDBConnInfo) URI() string {
+	u := &url.URL{
+		Scheme: "mysql",
+		User:   url.UserPassword(c.username, c.password),
+		Host:   net.JoinHostPort(c.host, fmt.Sprintf("%d", c.Port())),
+		Path:   "/" + c.dbName,
+	}
+	if c.tls != nil {
+		u.RawQuery = "ssl-mode=REQUIRED"
+	}
+	return u.String()
+}
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"github.com/sacloud/libsacloud/sacloud"
 	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
 	"github.com/stretchr/testify/assert"
 	"time"
 )
@@ -91,6 +92,105 @@ func TestMariaDBServiceValidate(t *testing.T) {
 			assert.NoError(t, err)
 			assert.Nil(t, s.parameter)
 		})
+
+		t.Run("TLS rawParameter", func(t *testing.T) {
+			databaseTLSConfigs[MariaDBPlan10GID] = &DatabaseTLSConfig{}
+			defer func() { databaseTLSConfigs = map[string]*DatabaseTLSConfig{} }()
+
+			s := getMariaDBHandler(operations.Binding, `{"tls": true}`)
+			result, err := s.IsValid()
+			assert.True(t, result)
+			assert.NoError(t, err)
+			assert.True(t, s.bindParameter.TLS)
+		})
+
+		t.Run("TLS rawParameter without TLS config", func(t *testing.T) {
+			s := getMariaDBHandler(operations.Binding, `{"tls": true}`)
+			result, err := s.IsValid()
+			assert.False(t, result)
+			assert.Error(t, err)
+		})
+
+		t.Run("Invalid rawParameter", func(t *testing.T) {
+			s := getMariaDBHandler(operations.Binding, `{"tls": "yes"}`)
+			result, err := s.IsValid()
+			assert.False(t, result)
+			assert.Error(t, err)
+		})
+
+		t.Run("limits rawParameter", func(t *testing.T) {
+			databaseBindingDefaults[MariaDBPlan10GID] = params.DatabaseBindingLimits{
+				MaxConnections: params.Limit(50),
+				MaxQueryTime:   params.Limit(60),
+			}
+			defer func() { databaseBindingDefaults = map[string]params.DatabaseBindingLimits{} }()
+
+			s := getMariaDBHandler(operations.Binding, `{"maxQueryTime": 0, "maxQueriesPerHour": 1000}`)
+			result, err := s.IsValid()
+			assert.True(t, result)
+			assert.NoError(t, err)
+			assert.Equal(t, params.DatabaseBindingLimits{
+				MaxConnections:    params.Limit(50), // default of the plan
+				MaxQueryTime:      params.Limit(0),  // unlimited
+				MaxQueriesPerHour: params.Limit(1000),
+			}, s.bindParameter.DatabaseBindingLimits)
+
+			s = getMariaDBHandler(operations.Binding, `{"maxConnections": -1}`)
+			result, err = s.IsValid()
+			assert.False(t, result)
+			assert.Error(t, err)
+		})
+
+		t.Run("database options rawParameter", func(t *testing.T) {
+			s := getMariaDBHandler(operations.Binding, `{"characterSet": "utf8mb4", "collation": "utf8mb4_bin", "allowedHost": "192.2.0.0/24"}`)
+			result, err := s.IsValid()
+			assert.True(t, result)
+			assert.NoError(t, err)
+			assert.Equal(t, "192.2.0.0/24", s.bindParameter.AllowedHost)
+
+			s = getMariaDBHandler(operations.Binding, `{"allowedHost": "any"}`)
+			result, err = s.IsValid()
+			assert.False(t, result)
+			assert.Error(t, err)
+		})
+
+		t.Run("PostgreSQL options rawParameter", func(t *testing.T) {
+			s := getMariaDBHandler(operations.Binding, `{"extensions": ["pgcrypto"]}`)
+			result, err := s.IsValid()
+			assert.False(t, result)
+			assert.Error(t, err)
+		})
+
+		t.Run("credentialFormat rawParameter", func(t *testing.T) {
+			s := getMariaDBHandler(operations.Binding, `{"credentialFormat": "jdbc"}`)
+			result, err := s.IsValid()
+			assert.True(t, result)
+			assert.NoError(t, err)
+			assert.Equal(t, "jdbc", s.bindParameter.CredentialFormat)
+
+			s = getMariaDBHandler(operations.Binding, `{"credentialFormat": "not-exists"}`)
+			result, err = s.IsValid()
+			assert.False(t, result)
+			assert.Error(t, err)
+		})
+	})
+
+	t.Run("Updating", func(t *testing.T) {
+		s := getMariaDBHandler(operations.Updating, `{"addAllowNetworks": ["192.2.0.0/24"]}`)
+		result, err := s.IsValid()
+		assert.True(t, result)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"192.2.0.0/24"}, s.updateParameter.AddAllowNetworks)
+
+		s = getMariaDBHandler(operations.Updating, `{"allowNetworks": ["invalid"]}`)
+		result, err = s.IsValid()
+		assert.False(t, result)
+		assert.Error(t, err)
+
+		s = getMariaDBHandler(operations.Updating, ``)
+		result, err = s.IsValid()
+		assert.False(t, result)
+		assert.Error(t, err)
 	})
 
 	t.Run("Other operations", func(t *testing.T) {
@@ -102,7 +202,7 @@ func TestMariaDBServiceValidate(t *testing.T) {
 	})
 }
 
-func TestMariaDBHandler_prepareMetaTable(t *testing.T) {
+func TestMariaDBHandler_migrateMetaTable(t *testing.T) {
 	if !existsTestEnvVars("TEST_DB") {
 		t.Skipf("environment variable %q is empty. skip.", "TEST_DB")
 		return
@@ -137,13 +237,26 @@ func TestMariaDBHandler_prepareMetaTable(t *testing.T) {
 		assert.False(t, exists)
 		assert.NoError(t, err)
 
-		err = s.dialect.prepareMetaTable(db, connInfo)
+		current, latest, err := s.dialect.metaSchemaVersion(db, connInfo)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, current)
+		assert.NotZero(t, latest)
+
+		err = s.dialect.migrateMetaTable(db, connInfo)
 		assert.NoError(t, err)
 
 		// post
 		exists, err = s.dialect.existsMetaTable(db, connInfo)
 		assert.True(t, exists)
 		assert.NoError(t, err)
+
+		current, latest, err = s.dialect.metaSchemaVersion(db, connInfo)
+		assert.NoError(t, err)
+		assert.Equal(t, latest, current)
+
+		// migrations are applied only once
+		err = s.dialect.migrateMetaTable(db, connInfo)
+		assert.NoError(t, err)
 	})
 }
 
@@ -178,10 +291,10 @@ func TestMariaDBHandler_create_read_delete(t *testing.T) {
 		}
 		defer db.Close()
 
-		err = s.dialect.prepareMetaTable(db, connInfo)
+		err = s.dialect.migrateMetaTable(db, connInfo)
 		assert.NoError(t, err)
 
-		record, err := s.dialect.createBinding(db, connInfo, bindingID)
+		record, err := s.dialect.createBinding(db, connInfo, bindingID, &params.DatabaseBindParameter{})
 		assert.NotNil(t, record)
 		assert.NoError(t, err)
 
@@ -224,6 +337,10 @@ func TestMariaDBHandler_create_read_delete(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Nil(t, record)
 
+		exists, err := s.dialect.existsUser(db, createdRecord.username)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+
 	})
 }
 
@@ -241,6 +358,39 @@ func TestMariaDBConnInfo(t *testing.T) {
 	assert.NotNil(t, info)
 }
 
+func TestMariaDBUserHost(t *testing.T) {
+	assert.Equal(t, "%", mariaDBUserHost(""))
+	assert.Equal(t, "192.2.0.0/255.255.255.0", mariaDBUserHost("192.2.0.0/24"))
+	assert.Equal(t, "192.2.0.0/255.255.254.0", mariaDBUserHost("192.2.1.10/23"))
+	assert.Equal(t, "192.2.0.10/255.255.255.255", mariaDBUserHost("192.2.0.10/32"))
+}
+
+func TestCreateMariaDBDatabaseQuery(t *testing.T) {
+	query := createMariaDBDatabaseQuery("user", &params.MariaDBDatabaseOptions{})
+	assert.Equal(t, "CREATE DATABASE user", query)
+
+	query = createMariaDBDatabaseQuery("user", &params.MariaDBDatabaseOptions{
+		CharacterSet: "utf8mb4",
+		Collation:    "utf8mb4_bin",
+	})
+	assert.Equal(t, "CREATE DATABASE user CHARACTER SET utf8mb4 COLLATE utf8mb4_bin", query)
+}
+
+func TestMariaDBGrantsOnlyOwnDatabase(t *testing.T) {
+	user := "abcdefghijklmnopqrst"
+	usage := "GRANT USAGE ON *.* TO `abcdefghijklmnopqrst`@`%` IDENTIFIED BY PASSWORD '*0000'"
+	own := "GRANT SELECT, INSERT ON `abcdefghijklmnopqrst`.* TO `abcdefghijklmnopqrst`@`%`"
+
+	assert.True(t, mariaDBGrantsOnlyOwnDatabase([]string{usage, own}, user))
+	assert.False(t, mariaDBGrantsOnlyOwnDatabase([]string{usage}, user))
+	assert.False(t, mariaDBGrantsOnlyOwnDatabase([]string{
+		usage, own, "GRANT SELECT ON `other`.* TO `abcdefghijklmnopqrst`@`%`",
+	}, user))
+	assert.False(t, mariaDBGrantsOnlyOwnDatabase([]string{
+		"GRANT ALL PRIVILEGES ON *.* TO `abcdefghijklmnopqrst`@`%`", own,
+	}, user))
+}
+
 func initMariaDB(queries ...string) func() {
 
 	cleanup, err := startDocker("mariadb:10.2",
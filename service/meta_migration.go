@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// metaSchemaVersionTableName is the table which records applied migrations of meta tables
+const metaSchemaVersionTableName = "open_service_broker_schema_version"
+
+// metaMigration is a versioned change of meta tables.
+// Statements are executed in order, then migrate is called if not nil
+type metaMigration struct {
+	version     int
+	description string
+	statements  []string
+	migrate     func(tx *sql.Tx, connInfo ConnectionInfo) error
+}
+
+// metaSchema defines migrations of meta tables and how to record them for a dialect
+type metaSchema struct {
+	versionTableDDL    string
+	existsVersionTable func(ctx context.Context, conn *sql.Conn) (bool, error)
+	selectVersions     string
+	insertVersion      string // args: version, description
+	lock               func(ctx context.Context, conn *sql.Conn) error
+	unlock             string
+	migrations         []*metaMigration
+}
+
+// latestVersion returns the version of the last migration
+func (s *metaSchema) latestVersion() int {
+	latest := 0
+	for _, m := range s.migrations {
+		if m.version > latest {
+			latest = m.version
+		}
+	}
+	return latest
+}
+
+// applyMetaMigrations applies migrations which are not applied yet.
+// It is safe to call concurrently from multiple brokers
+func applyMetaMigrations(db *sql.DB, connInfo ConnectionInfo, schema *metaSchema) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close() // nolint
+
+	if err := schema.lock(ctx, conn); err != nil {
+		return fmt.Errorf("error locking meta schema: %s", err)
+	}
+	defer conn.ExecContext(ctx, schema.unlock) // nolint
+
+	if _, err := conn.ExecContext(ctx, schema.versionTableDDL); err != nil {
+		return fmt.Errorf("error creating schema version table: %s", err)
+	}
+
+	applied, err := appliedMetaVersions(ctx, conn, schema)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range schema.migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := applyMetaMigration(ctx, conn, connInfo, schema, m); err != nil {
+			return fmt.Errorf("error applying migration %d(%s): %s", m.version, m.description, err)
+		}
+		log.WithFields(log.Fields{
+			"host":        connInfo.Host(),
+			"version":     m.version,
+			"description": m.description,
+		}).Info("meta schema migrated")
+	}
+	return nil
+}
+
+func applyMetaMigration(ctx context.Context, conn *sql.Conn, connInfo ConnectionInfo, schema *metaSchema, m *metaMigration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range m.statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback() // nolint
+			return err
+		}
+	}
+	if m.migrate != nil {
+		if err := m.migrate(tx, connInfo); err != nil {
+			tx.Rollback() // nolint
+			return err
+		}
+	}
+	if _, err := tx.Exec(schema.insertVersion, m.version, m.description); err != nil {
+		tx.Rollback() // nolint
+		return err
+	}
+	return tx.Commit()
+}
+
+// currentMetaVersion returns the latest applied version. It returns 0 if no migrations are applied
+func currentMetaVersion(db *sql.DB, schema *metaSchema) (int, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close() // nolint
+
+	exists, err := schema.existsVersionTable(ctx, conn)
+	if err != nil {
+		return 0, fmt.Errorf("error reading schema version table: %s", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	applied, err := appliedMetaVersions(ctx, conn, schema)
+	if err != nil {
+		return 0, err
+	}
+	current := 0
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	return current, nil
+}
+
+func appliedMetaVersions(ctx context.Context, conn *sql.Conn, schema *metaSchema) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, schema.selectVersions)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema version table: %s", err)
+	}
+	defer rows.Close() // nolint
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("error reading schema version table: %s", err)
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetaSchemaMigrations(t *testing.T) {
+	connInfo := (&mariaDBHandler{}).buildConnInfo("localhost", "admin", "admin", "password", "salt", 0, nil)
+
+	schemas := map[string]*metaSchema{
+		"MariaDB":    mariaDBMetaSchema(connInfo),
+		"PostgreSQL": postgreSQLMetaSchema,
+	}
+	for name, schema := range schemas {
+		t.Run(name, func(t *testing.T) {
+			// versions must be unique and in ascending order
+			prev := 0
+			for _, m := range schema.migrations {
+				assert.True(t, m.version > prev, "version %d must be greater than %d", m.version, prev)
+				assert.NotEmpty(t, m.description)
+				assert.True(t, len(m.statements) > 0 || m.migrate != nil)
+				prev = m.version
+			}
+			assert.Equal(t, prev, schema.latestVersion())
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/sacloud/libsacloud/api"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/sacloud/open-service-broker-sacloud/util/cmp"
+)
+
+const (
+	// nfsVolumeDriver is the volume driver of Cloud Foundry nfs-volume-release
+	nfsVolumeDriver = "nfsv3driver"
+	// nfsDefaultContainerDir is the mount path used when containerDir isn't requested
+	nfsDefaultContainerDir = "/var/vcap/data/nfs"
+)
+
+// nfsAttrs implements InstanceState interface
+type nfsAttrs struct {
+	*sacloud.NFS
+	parameter *params.NFSCreateParameter
+}
+
+func (a *nfsAttrs) HasDiff() bool {
+	if a.parameter == nil {
+		return false
+	}
+	return len(nfsDrift(a.NFS, a.parameter)) > 0
+}
+
+func (a *nfsAttrs) IsDeleting() bool {
+	return a.HasTag(iaas.DeletingMarkerTag)
+}
+
+// nfsDrift returns names of the parameters which differ from the actual NFS
+func nfsDrift(nfs *sacloud.NFS, p *params.NFSCreateParameter) []string {
+	if nfs.Remark == nil || nfs.Remark.ApplianceRemarkBase == nil {
+		return nil
+	}
+	remark := nfs.Remark.ApplianceRemarkBase
+
+	var switchID int64
+	if remark.Switch != nil {
+		switchID, _ = strconv.ParseInt(remark.Switch.ID, 10, 64)
+	}
+	var maskLen int32
+	var defaultRoute string
+	if remark.Network != nil {
+		maskLen = int32(remark.Network.NetworkMaskLen)
+		defaultRoute = remark.Network.DefaultRoute
+	}
+
+	values := map[string]cmp.CompareValue{
+		"switchID":     {X: p.SwitchID, Y: switchID},
+		"ipaddress":    {X: p.IPAddress, Y: applianceIPAddress(remark)},
+		"maskLen":      {X: p.MaskLen, Y: maskLen},
+		"defaultRoute": {X: p.DefaultRoute, Y: defaultRoute},
+	}
+	if p.PlanID > 0 {
+		values["plan"] = cmp.CompareValue{X: int64(p.PlanID), Y: nfs.Remark.GetPlanID()}
+	}
+
+	var drift []string
+	for name, v := range values {
+		if !cmp.Equal(v) {
+			drift = append(drift, name)
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+func nfsIPAddress(nfs *sacloud.NFS) string {
+	if nfs.Remark == nil {
+		return ""
+	}
+	return applianceIPAddress(nfs.Remark.ApplianceRemarkBase)
+}
+
+// fetchNFS returns the instance with parameters of the actual NFS.
+// It returns nil if the instance is not found
+func fetchNFS(instanceID string) (*osb.ServiceInstanceResource, error) {
+	nfs, err := sacloudAPI.NFS().Read(instanceID)
+	if err != nil {
+		if e, ok := err.(api.Error); ok && e.ResponseCode() == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	desired, err := iaas.DesiredNFSParameter(nfs)
+	if err != nil {
+		return nil, fmt.Errorf("reading requested parameter is failed: %s", err)
+	}
+	p := &params.NFSCreateParameter{}
+	if desired != nil {
+		*p = *desired
+	}
+	var planID string
+	if nfs.Remark != nil {
+		p.PlanID = int(nfs.Remark.GetPlanID())
+		planID = NFSIDMap.PlanIDMap[p.PlanID]
+		if remark := nfs.Remark.ApplianceRemarkBase; remark != nil {
+			p.IPAddress = applianceIPAddress(remark)
+			if remark.Switch != nil {
+				p.SwitchID, _ = strconv.ParseInt(remark.Switch.ID, 10, 64)
+			}
+			if remark.Network != nil {
+				p.MaskLen = int32(remark.Network.NetworkMaskLen)
+				p.DefaultRoute = remark.Network.DefaultRoute
+			}
+		}
+	}
+
+	return &osb.ServiceInstanceResource{
+		ServiceID:  NFSServiceID,
+		PlanID:     planID,
+		Parameters: p,
+	}, nil
+}
+
+type nfsHandler struct {
+	operation    string
+	serviceID    string
+	planID       string
+	rawParameter []byte
+
+	parameter     *params.NFSCreateParameter
+	bindParameter *params.NFSBindParameter
+	paramErr      error
+}
+
+func newNFSServiceHandler(operation, serviceID, planID string, rawParameter []byte) *nfsHandler {
+	handler := &nfsHandler{
+		operation:    operation,
+		serviceID:    serviceID,
+		planID:       planID,
+		rawParameter: rawParameter,
+	}
+
+	switch operation {
+	case operations.Provisioning:
+		if len(rawParameter) == 0 {
+			handler.paramErr = errors.New("nfsService parameter JSON is empty")
+			return handler
+		}
+
+		var p = params.NFSCreateParameter{}
+		err := json.Unmarshal(rawParameter, &p)
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		err = p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		for k, v := range NFSIDMap.PlanIDMap {
+			if v == planID {
+				p.PlanID = k
+				break
+			}
+		}
+
+		handler.parameter = &p
+	case operations.Updating:
+		handler.paramErr = errors.New("updating nfsService is not supported")
+	case operations.Binding:
+		var p = params.NFSBindParameter{}
+		if len(rawParameter) > 0 {
+			err := json.Unmarshal(rawParameter, &p)
+			if err != nil {
+				handler.paramErr = err
+				return handler
+			}
+		}
+
+		err := p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		handler.bindParameter = &p
+	}
+
+	return handler
+}
+
+func (s *nfsHandler) InstanceState(instanceID string) (InstanceState, error) {
+	nfs, err := sacloudAPI.NFS().Read(instanceID)
+	if err != nil {
+		if e, ok := err.(api.Error); ok {
+			if e.ResponseCode() != http.StatusNotFound {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	if nfs == nil {
+		if s.operation == operations.Deprovisioning {
+			knownInstances.remove(instanceID)
+		}
+		return nil, nil
+	}
+	knownInstances.add(instanceID, s.serviceID)
+
+	parameter := s.parameter
+	if parameter == nil {
+		// broken descriptions are ignored not to block other operations
+		parameter, _ = iaas.DesiredNFSParameter(nfs)
+	}
+
+	return &nfsAttrs{
+		NFS:       nfs,
+		parameter: parameter,
+	}, nil
+}
+
+// BindingState always returns nil because bindings of NFS have no state on the appliance
+func (s *nfsHandler) BindingState(instanceID, bindingID string) (BindingState, error) {
+	return nil, nil
+}
+
+func (s *nfsHandler) CreateInstance(instanceID string) error {
+	_, err := sacloudAPI.NFS().Create(instanceID, s.parameter)
+	if err != nil {
+		return err
+	}
+	knownInstances.add(instanceID, s.serviceID)
+	return nil
+}
+
+func (s *nfsHandler) UpdateInstance(instanceID string) error {
+	return errors.New("updating nfsService is not supported")
+}
+
+func (s *nfsHandler) DeleteInstance(instanceID string) error {
+	err := sacloudAPI.NFS().Delete(instanceID)
+	if err != nil {
+		return err
+	}
+	knownInstances.markDeleting(instanceID)
+	return nil
+}
+
+func (s *nfsHandler) CreateBinding(instanceID, bindingID string) (*osb.ServiceBinding, error) {
+	nfs, err := sacloudAPI.NFS().Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	ip := nfsIPAddress(nfs)
+	if ip == "" {
+		return nil, errors.New("IP address of the NFS is not available")
+	}
+
+	p := s.bindParameter
+	if p == nil {
+		p = &params.NFSBindParameter{}
+	}
+	return nfsBinding(instanceID, ip, p), nil
+}
+
+// nfsBinding returns the binding which mounts the export of the NFS as a shared volume
+func nfsBinding(instanceID, ip string, p *params.NFSBindParameter) *osb.ServiceBinding {
+	source := fmt.Sprintf("nfs://%s%s", ip, iaas.NFSExportPath)
+
+	containerDir := p.ContainerDir
+	if containerDir == "" {
+		containerDir = nfsDefaultContainerDir
+	}
+	mode := p.Mode
+	if mode == "" {
+		mode = params.NFSMountModeReadWrite
+	}
+
+	mountConfig := map[string]interface{}{
+		"source": source,
+	}
+	if p.UID > 0 {
+		mountConfig["uid"] = strconv.Itoa(p.UID)
+	}
+	if p.GID > 0 {
+		mountConfig["gid"] = strconv.Itoa(p.GID)
+	}
+	if mode == params.NFSMountModeReadOnly {
+		mountConfig["readonly"] = true
+	}
+
+	return &osb.ServiceBinding{
+		Credentials: map[string]string{
+			"host":   ip,
+			"export": iaas.NFSExportPath,
+			"uri":    source,
+		},
+		VolumeMounts: &[]osb.ServiceBindingVolumeMount{
+			{
+				Driver:       nfsVolumeDriver,
+				ContainerDir: containerDir,
+				Mode:         mode,
+				DeviceType:   "shared",
+				Device: &osb.ServiceBindingVolumeMountDevice{
+					// all bindings of the instance share the same volume
+					VolumeID:    instanceID,
+					MountConfig: mountConfig,
+				},
+			},
+		},
+	}
+}
+
+// DeleteBinding does nothing because bindings of NFS have no state on the appliance
+func (s *nfsHandler) DeleteBinding(instanceID, bindingID string) error {
+	return nil
+}
+
+func (s *nfsHandler) ConfirmProvision(instanceID string) error {
+	return sacloudAPI.NFS().ConfirmProvisioned(instanceID)
+}
+
+func (s *nfsHandler) IsValid() (bool, error) {
+	return s.paramErr == nil, s.paramErr
+}
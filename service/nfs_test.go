@@ -0,0 +1,173 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/stretchr/testify/assert"
+)
+
+const validNFSProvisioningParam = `{"switchID": 999999999999, "ipaddress": "192.2.0.20", "maskLen": 24}`
+
+type dummyNFSAPI struct {
+	readResult   *sacloud.NFS
+	createResult *sacloud.NFS
+	readErr      error
+	createErr    error
+	deleteErr    error
+
+	created *params.NFSCreateParameter
+}
+
+func (c *dummyNFSAPI) List() ([]sacloud.NFS, error) {
+	if c.readResult == nil {
+		return nil, c.readErr
+	}
+	return []sacloud.NFS{*c.readResult}, c.readErr
+}
+
+func (c *dummyNFSAPI) Read(instanceID string) (*sacloud.NFS, error) {
+	return c.readResult, c.readErr
+}
+
+func (c *dummyNFSAPI) Create(instanceID string, param *params.NFSCreateParameter) (*sacloud.NFS, error) {
+	c.created = param
+	return c.createResult, c.createErr
+}
+
+func (c *dummyNFSAPI) ConfirmProvisioned(instanceID string) error {
+	return nil
+}
+
+func (c *dummyNFSAPI) Delete(instanceID string) error {
+	return c.deleteErr
+}
+
+// nfs100GInstance returns the NFS in the same form as responses of SAKURA Cloud API
+func nfs100GInstance(t *testing.T, instanceID string) *sacloud.NFS {
+	p := sacloud.NewCreateNFSValue()
+	p.SwitchID = "999999999999"
+	p.IPAddress = "192.2.0.20"
+	p.MaskLen = 24
+	p.Name = instanceID
+
+	data, err := json.Marshal(sacloud.NewNFS(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nfs sacloud.NFS
+	if err := json.Unmarshal(data, &nfs); err != nil {
+		t.Fatal(err)
+	}
+	return &nfs
+}
+
+func TestNFSServiceValidate(t *testing.T) {
+	t.Run("Provisioning", func(t *testing.T) {
+		s := newNFSServiceHandler(operations.Provisioning, NFSServiceID, NFSPlan1TID, []byte(``))
+		_, err := s.IsValid()
+		assert.Error(t, err)
+
+		s = newNFSServiceHandler(operations.Provisioning, NFSServiceID, NFSPlan1TID, []byte(validNFSProvisioningParam))
+		_, err = s.IsValid()
+		assert.NoError(t, err)
+		assert.Equal(t, 1024, s.parameter.PlanID)
+	})
+
+	t.Run("Updating", func(t *testing.T) {
+		s := newNFSServiceHandler(operations.Updating, NFSServiceID, NFSPlan1TID, []byte(`{}`))
+		_, err := s.IsValid()
+		assert.Error(t, err)
+	})
+
+	t.Run("Binding", func(t *testing.T) {
+		s := newNFSServiceHandler(operations.Binding, NFSServiceID, NFSPlan1TID, []byte(``))
+		_, err := s.IsValid()
+		assert.NoError(t, err)
+
+		s = newNFSServiceHandler(operations.Binding, NFSServiceID, NFSPlan1TID, []byte(`{"mode": "w"}`))
+		_, err = s.IsValid()
+		assert.Error(t, err)
+	})
+}
+
+func TestNFSHandler(t *testing.T) {
+	nfsAPI := &dummyNFSAPI{}
+	sacloudAPI = &dummyAPI{nfsAPI: nfsAPI}
+	defer func() { sacloudAPI = testAPI }()
+
+	t.Run("InstanceState", func(t *testing.T) {
+		nfsAPI.readResult = nfs100GInstance(t, instanceID)
+
+		s := newNFSServiceHandler(operations.Provisioning, NFSServiceID, NFSPlan100GID, []byte(validNFSProvisioningParam))
+		state, err := s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.False(t, state.HasDiff())
+
+		s = newNFSServiceHandler(operations.Provisioning, NFSServiceID, NFSPlan1TID, []byte(validNFSProvisioningParam))
+		state, err = s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.True(t, state.HasDiff())
+		assert.Equal(t, []string{"plan"}, nfsDrift(nfsAPI.readResult, s.parameter))
+
+		nfsAPI.readResult = nil
+		state, err = s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.Nil(t, state)
+	})
+
+	t.Run("CreateInstance", func(t *testing.T) {
+		s := newNFSServiceHandler(operations.Provisioning, NFSServiceID, NFSPlan500GID, []byte(validNFSProvisioningParam))
+		assert.NoError(t, s.CreateInstance(instanceID))
+		assert.Equal(t, 500, nfsAPI.created.PlanID)
+		assert.Equal(t, "192.2.0.20", nfsAPI.created.IPAddress)
+	})
+
+	t.Run("CreateBinding", func(t *testing.T) {
+		nfsAPI.readResult = nfs100GInstance(t, instanceID)
+
+		s := newNFSServiceHandler(operations.Binding, NFSServiceID, NFSPlan100GID,
+			[]byte(`{"containerDir": "/data", "mode": "r", "uid": 1000, "gid": 1000}`))
+		binding, err := s.CreateBinding(instanceID, bindingID)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"host":   "192.2.0.20",
+			"export": "/export",
+			"uri":    "nfs://192.2.0.20/export",
+		}, binding.Credentials)
+		assert.Equal(t, &[]osb.ServiceBindingVolumeMount{
+			{
+				Driver:       "nfsv3driver",
+				ContainerDir: "/data",
+				Mode:         "r",
+				DeviceType:   "shared",
+				Device: &osb.ServiceBindingVolumeMountDevice{
+					VolumeID: instanceID,
+					MountConfig: map[string]interface{}{
+						"source":   "nfs://192.2.0.20/export",
+						"uid":      "1000",
+						"gid":      "1000",
+						"readonly": true,
+					},
+				},
+			},
+		}, binding.VolumeMounts)
+
+		s = newNFSServiceHandler(operations.Binding, NFSServiceID, NFSPlan100GID, []byte(``))
+		binding, err = s.CreateBinding(instanceID, bindingID)
+		assert.NoError(t, err)
+		mount := (*binding.VolumeMounts)[0]
+		assert.Equal(t, nfsDefaultContainerDir, mount.ContainerDir)
+		assert.Equal(t, "rw", mount.Mode)
+
+		nfsAPI.readErr = fmt.Errorf("dummy")
+		_, err = s.CreateBinding(instanceID, bindingID)
+		assert.Error(t, err)
+		nfsAPI.readErr = nil
+	})
+}
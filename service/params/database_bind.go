@@ -0,0 +1,170 @@
+package params
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// maxCredentialFormatLen is the length of credential_format column in meta tables
+const maxCredentialFormatLen = 64
+
+var (
+	postgreSQLIdentifierPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+	postgreSQLExtensionPattern  = regexp.MustCompile(`^[a-z0-9_-]{1,63}$`)
+	postgreSQLLocalePattern     = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`)
+	mariaDBCharsetPattern       = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+)
+
+// DatabaseBindParameter represents binding parameter
+// for SAKURA Cloud Database Appliances
+type DatabaseBindParameter struct {
+	TLS              bool   `json:"tls,omitempty"`
+	CredentialFormat string `json:"credentialFormat,omitempty"`
+	DatabaseBindingLimits
+	PostgreSQLDatabaseOptions
+	MariaDBDatabaseOptions
+}
+
+// MariaDBDatabaseOptions represents options of the user database and the user. MariaDB only
+type MariaDBDatabaseOptions struct {
+	CharacterSet string `json:"characterSet,omitempty"`
+	Collation    string `json:"collation,omitempty"`
+	AllowedHost  string `json:"allowedHost,omitempty"` // IPv4 CIDR
+}
+
+// PostgreSQLDatabaseOptions represents options of the user database. PostgreSQL only
+type PostgreSQLDatabaseOptions struct {
+	Extensions []string `json:"extensions,omitempty"`
+	Schemas    []string `json:"schemas,omitempty"`
+	Encoding   string   `json:"encoding,omitempty"`
+	LCCollate  string   `json:"lcCollate,omitempty"`
+	LCCtype    string   `json:"lcCtype,omitempty"`
+}
+
+// DatabaseBindingLimits represents resource limits of the binding user.
+// Nil means the default of the plan, and zero means unlimited
+type DatabaseBindingLimits struct {
+	MaxConnections    *int `json:"maxConnections,omitempty"`
+	MaxQueryTime      *int `json:"maxQueryTime,omitempty"`      // seconds
+	MaxQueriesPerHour *int `json:"maxQueriesPerHour,omitempty"` // MariaDB only
+}
+
+// Validate performs parameter validation
+func (p *DatabaseBindParameter) Validate() error {
+	if len(p.CredentialFormat) > maxCredentialFormatLen {
+		return fmt.Errorf("%q must be at most %d characters", "credentialFormat", maxCredentialFormatLen)
+	}
+	if err := p.DatabaseBindingLimits.Validate(); err != nil {
+		return err
+	}
+	if err := p.PostgreSQLDatabaseOptions.Validate(); err != nil {
+		return err
+	}
+	return p.MariaDBDatabaseOptions.Validate()
+}
+
+// IsEmpty returns true if no options are specified
+func (o *MariaDBDatabaseOptions) IsEmpty() bool {
+	return o.CharacterSet == "" && o.Collation == "" && o.AllowedHost == ""
+}
+
+// Validate performs parameter validation
+func (o *MariaDBDatabaseOptions) Validate() error {
+	names := map[string]string{
+		"characterSet": o.CharacterSet,
+		"collation":    o.Collation,
+	}
+	for k, v := range names {
+		if v != "" && !mariaDBCharsetPattern.MatchString(v) {
+			return fmt.Errorf("%q is invalid: %q", k, v)
+		}
+	}
+
+	if o.AllowedHost != "" {
+		ip, _, err := net.ParseCIDR(o.AllowedHost)
+		if err != nil || ip.To4() == nil {
+			return fmt.Errorf("%q must be IPv4 CIDR: %q", "allowedHost", o.AllowedHost)
+		}
+	}
+	return nil
+}
+
+// IsEmpty returns true if no options are specified
+func (o *PostgreSQLDatabaseOptions) IsEmpty() bool {
+	return len(o.Extensions) == 0 && len(o.Schemas) == 0 &&
+		o.Encoding == "" && o.LCCollate == "" && o.LCCtype == ""
+}
+
+// Validate performs parameter validation
+func (o *PostgreSQLDatabaseOptions) Validate() error {
+	for _, extension := range o.Extensions {
+		if !postgreSQLExtensionPattern.MatchString(extension) {
+			return fmt.Errorf("%q has invalid extension name %q", "extensions", extension)
+		}
+	}
+
+	for _, schema := range o.Schemas {
+		if !postgreSQLIdentifierPattern.MatchString(schema) {
+			return fmt.Errorf("%q has invalid schema name %q", "schemas", schema)
+		}
+		if schema == "public" || strings.HasPrefix(schema, "pg_") {
+			return fmt.Errorf("%q has reserved schema name %q", "schemas", schema)
+		}
+	}
+
+	locales := map[string]string{
+		"encoding":  o.Encoding,
+		"lcCollate": o.LCCollate,
+		"lcCtype":   o.LCCtype,
+	}
+	for k, v := range locales {
+		if v != "" && !postgreSQLLocalePattern.MatchString(v) {
+			return fmt.Errorf("%q is invalid: %q", k, v)
+		}
+	}
+	return nil
+}
+
+// Validate performs parameter validation
+func (l *DatabaseBindingLimits) Validate() error {
+	notNegative := map[string]*int{
+		"maxConnections":    l.MaxConnections,
+		"maxQueryTime":      l.MaxQueryTime,
+		"maxQueriesPerHour": l.MaxQueriesPerHour,
+	}
+	for k, v := range notNegative {
+		if v != nil && *v < 0 {
+			return fmt.Errorf("%q must not be negative", k)
+		}
+	}
+	return nil
+}
+
+// WithDefaults returns limits which unset values are replaced with defaults
+func (l DatabaseBindingLimits) WithDefaults(defaults DatabaseBindingLimits) DatabaseBindingLimits {
+	if l.MaxConnections == nil {
+		l.MaxConnections = defaults.MaxConnections
+	}
+	if l.MaxQueryTime == nil {
+		l.MaxQueryTime = defaults.MaxQueryTime
+	}
+	if l.MaxQueriesPerHour == nil {
+		l.MaxQueriesPerHour = defaults.MaxQueriesPerHour
+	}
+	return l
+}
+
+// LimitValue returns the value of the limit. Unset limits are unlimited(zero)
+func LimitValue(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// Limit returns the pointer of the limit value
+func Limit(v int) *int {
+	return &v
+}
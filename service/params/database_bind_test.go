@@ -0,0 +1,141 @@
+package params
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabaseBindParameterValidate(t *testing.T) {
+
+	expects := []struct {
+		name   string
+		param  *DatabaseBindParameter
+		result bool
+	}{
+		{
+			name:   "Empty",
+			param:  &DatabaseBindParameter{},
+			result: true,
+		},
+		{
+			name: "Valid limits",
+			param: &DatabaseBindParameter{
+				DatabaseBindingLimits: DatabaseBindingLimits{MaxConnections: Limit(10), MaxQueryTime: Limit(30), MaxQueriesPerHour: Limit(1000)},
+			},
+			result: true,
+		},
+		{
+			name: "Negative maxConnections",
+			param: &DatabaseBindParameter{
+				DatabaseBindingLimits: DatabaseBindingLimits{MaxConnections: Limit(-1)},
+			},
+			result: false,
+		},
+		{
+			name: "Negative maxQueryTime",
+			param: &DatabaseBindParameter{
+				DatabaseBindingLimits: DatabaseBindingLimits{MaxQueryTime: Limit(-1)},
+			},
+			result: false,
+		},
+		{
+			name: "Valid PostgreSQL options",
+			param: &DatabaseBindParameter{
+				PostgreSQLDatabaseOptions: PostgreSQLDatabaseOptions{
+					Extensions: []string{"pgcrypto", "uuid-ossp"},
+					Schemas:    []string{"app", "audit_log"},
+					Encoding:   "UTF8",
+					LCCollate:  "ja_JP.UTF-8",
+					LCCtype:    "C",
+				},
+			},
+			result: true,
+		},
+		{
+			name: "Invalid extension",
+			param: &DatabaseBindParameter{
+				PostgreSQLDatabaseOptions: PostgreSQLDatabaseOptions{Extensions: []string{`pgcrypto"; drop`}},
+			},
+			result: false,
+		},
+		{
+			name: "Invalid schema",
+			param: &DatabaseBindParameter{
+				PostgreSQLDatabaseOptions: PostgreSQLDatabaseOptions{Schemas: []string{"App"}},
+			},
+			result: false,
+		},
+		{
+			name: "Reserved schema",
+			param: &DatabaseBindParameter{
+				PostgreSQLDatabaseOptions: PostgreSQLDatabaseOptions{Schemas: []string{"pg_app"}},
+			},
+			result: false,
+		},
+		{
+			name: "Invalid locale",
+			param: &DatabaseBindParameter{
+				PostgreSQLDatabaseOptions: PostgreSQLDatabaseOptions{LCCollate: "ja_JP'UTF-8"},
+			},
+			result: false,
+		},
+		{
+			name: "Valid MariaDB options",
+			param: &DatabaseBindParameter{
+				MariaDBDatabaseOptions: MariaDBDatabaseOptions{
+					CharacterSet: "utf8mb4",
+					Collation:    "utf8mb4_bin",
+					AllowedHost:  "192.2.0.0/24",
+				},
+			},
+			result: true,
+		},
+		{
+			name: "Invalid collation",
+			param: &DatabaseBindParameter{
+				MariaDBDatabaseOptions: MariaDBDatabaseOptions{Collation: "utf8mb4_bin'"},
+			},
+			result: false,
+		},
+		{
+			name: "Invalid allowedHost",
+			param: &DatabaseBindParameter{
+				MariaDBDatabaseOptions: MariaDBDatabaseOptions{AllowedHost: "192.2.0.1"},
+			},
+			result: false,
+		},
+		{
+			name: "IPv6 allowedHost",
+			param: &DatabaseBindParameter{
+				MariaDBDatabaseOptions: MariaDBDatabaseOptions{AllowedHost: "2001:db8::/32"},
+			},
+			result: false,
+		},
+		{
+			name:   "Too long credentialFormat",
+			param:  &DatabaseBindParameter{CredentialFormat: strings.Repeat("a", 65)},
+			result: false,
+		},
+	}
+
+	for _, expect := range expects {
+		t.Run(expect.name, func(t *testing.T) {
+			err := expect.param.Validate()
+			assert.Equal(t, expect.result, err == nil)
+		})
+	}
+}
+
+func TestDatabaseBindingLimitsWithDefaults(t *testing.T) {
+	defaults := DatabaseBindingLimits{MaxConnections: Limit(50), MaxQueryTime: Limit(60), MaxQueriesPerHour: Limit(1000)}
+	limits := DatabaseBindingLimits{MaxConnections: Limit(10), MaxQueryTime: Limit(0)}.WithDefaults(defaults)
+	assert.Equal(t, DatabaseBindingLimits{
+		MaxConnections:    Limit(10),
+		MaxQueryTime:      Limit(0), // unlimited is not replaced
+		MaxQueriesPerHour: Limit(1000),
+	}, limits)
+
+	assert.Equal(t, 0, LimitValue(DatabaseBindingLimits{}.WithDefaults(DatabaseBindingLimits{}).MaxConnections))
+}
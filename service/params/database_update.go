@@ -0,0 +1,74 @@
+package params
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// DatabaseUpdateParameter represents update parameter
+// for SAKURA Cloud Database Appliances
+type DatabaseUpdateParameter struct {
+	// AllowNetworks replaces all allowed networks. It is ignored if nil
+	AllowNetworks       []string `json:"allowNetworks,omitempty"`
+	AddAllowNetworks    []string `json:"addAllowNetworks,omitempty"`
+	RemoveAllowNetworks []string `json:"removeAllowNetworks,omitempty"`
+}
+
+// Validate performs parameter validation
+func (p *DatabaseUpdateParameter) Validate() error {
+	if p.AllowNetworks == nil && len(p.AddAllowNetworks) == 0 && len(p.RemoveAllowNetworks) == 0 {
+		return errors.New("one of \"allowNetworks\", \"addAllowNetworks\" and \"removeAllowNetworks\" is required")
+	}
+	if p.AllowNetworks != nil && (len(p.AddAllowNetworks) > 0 || len(p.RemoveAllowNetworks) > 0) {
+		return errors.New("\"allowNetworks\" can't be used with \"addAllowNetworks\" or \"removeAllowNetworks\"")
+	}
+
+	networks := map[string][]string{
+		"allowNetworks":       p.AllowNetworks,
+		"addAllowNetworks":    p.AddAllowNetworks,
+		"removeAllowNetworks": p.RemoveAllowNetworks,
+	}
+	for k, values := range networks {
+		for _, v := range values {
+			if !validNetwork(v) {
+				return fmt.Errorf("%q expects IPv4 address or CIDR: %q", k, v)
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyAllowNetworks returns allowed networks which the parameter is applied to current networks
+func (p *DatabaseUpdateParameter) ApplyAllowNetworks(current []string) []string {
+	base := current
+	if p.AllowNetworks != nil {
+		base = p.AllowNetworks
+	}
+
+	removed := map[string]bool{}
+	for _, nw := range p.RemoveAllowNetworks {
+		removed[nw] = true
+	}
+
+	seen := map[string]bool{}
+	res := []string{}
+	for _, nw := range append(append([]string{}, base...), p.AddAllowNetworks...) {
+		if nw == "" || removed[nw] || seen[nw] {
+			continue
+		}
+		seen[nw] = true
+		res = append(res, nw)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func validNetwork(v string) bool {
+	if ip, _, err := net.ParseCIDR(v); err == nil {
+		return ip.To4() != nil
+	}
+	ip := net.ParseIP(v)
+	return ip != nil && ip.To4() != nil
+}
@@ -0,0 +1,82 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabaseUpdateParameterValidate(t *testing.T) {
+
+	expects := []struct {
+		name   string
+		param  *DatabaseUpdateParameter
+		result bool
+	}{
+		{
+			name:   "Empty",
+			param:  &DatabaseUpdateParameter{},
+			result: false,
+		},
+		{
+			name:   "Replace",
+			param:  &DatabaseUpdateParameter{AllowNetworks: []string{"192.2.0.0/24", "192.2.1.10"}},
+			result: true,
+		},
+		{
+			name:   "Replace with empty",
+			param:  &DatabaseUpdateParameter{AllowNetworks: []string{}},
+			result: true,
+		},
+		{
+			name: "Add and remove",
+			param: &DatabaseUpdateParameter{
+				AddAllowNetworks:    []string{"192.2.0.0/24"},
+				RemoveAllowNetworks: []string{"192.2.1.0/24"},
+			},
+			result: true,
+		},
+		{
+			name: "Replace with add",
+			param: &DatabaseUpdateParameter{
+				AllowNetworks:    []string{"192.2.0.0/24"},
+				AddAllowNetworks: []string{"192.2.1.0/24"},
+			},
+			result: false,
+		},
+		{
+			name:   "Invalid network",
+			param:  &DatabaseUpdateParameter{AddAllowNetworks: []string{"192.2.0.0/33"}},
+			result: false,
+		},
+		{
+			name:   "IPv6 network",
+			param:  &DatabaseUpdateParameter{AddAllowNetworks: []string{"2001:db8::/32"}},
+			result: false,
+		},
+	}
+
+	for _, expect := range expects {
+		t.Run(expect.name, func(t *testing.T) {
+			err := expect.param.Validate()
+			assert.Equal(t, expect.result, err == nil)
+		})
+	}
+}
+
+func TestDatabaseUpdateParameterApplyAllowNetworks(t *testing.T) {
+	current := []string{"192.2.1.0/24", "192.2.0.0/24"}
+
+	p := &DatabaseUpdateParameter{AllowNetworks: []string{"192.2.2.0/24"}}
+	assert.Equal(t, []string{"192.2.2.0/24"}, p.ApplyAllowNetworks(current))
+
+	p = &DatabaseUpdateParameter{AllowNetworks: []string{}}
+	assert.Equal(t, []string{}, p.ApplyAllowNetworks(current))
+
+	p = &DatabaseUpdateParameter{
+		AddAllowNetworks:    []string{"192.2.2.0/24", "192.2.0.0/24"},
+		RemoveAllowNetworks: []string{"192.2.1.0/24"},
+	}
+	assert.Equal(t, []string{"192.2.0.0/24", "192.2.2.0/24"}, p.ApplyAllowNetworks(current))
+	assert.Equal(t, []string{"192.2.1.0/24", "192.2.0.0/24"}, current)
+}
@@ -0,0 +1,90 @@
+package params
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxDNSRecordsPerBinding is the maximum number of records which a binding can create
+	maxDNSRecordsPerBinding = 10
+	// DNSBindingMarkerPrefix is the prefix of the names of records which hold records owned by bindings
+	DNSBindingMarkerPrefix = "_osbs-"
+)
+
+var dnsRecordNamePattern = regexp.MustCompile(`^(\*\.)?[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9])?(\.[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9])?)*$`)
+
+// DNSBindParameter represents binding parameter
+// for SAKURA Cloud DNS zones
+type DNSBindParameter struct {
+	Records []DNSRecord `json:"records"`
+}
+
+// DNSRecord represents a record which is created by the binding
+type DNSRecord struct {
+	Name  string `json:"name"` // relative to the zone. "@" means the apex
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	TTL   int    `json:"ttl,omitempty"`
+}
+
+// Validate performs parameter validation
+func (p *DNSBindParameter) Validate() error {
+	if len(p.Records) == 0 {
+		return fmt.Errorf("%q is required", "records")
+	}
+	if len(p.Records) > maxDNSRecordsPerBinding {
+		return fmt.Errorf("%q must have at most %d records", "records", maxDNSRecordsPerBinding)
+	}
+
+	seen := map[DNSRecord]bool{}
+	for i, r := range p.Records {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("records[%d]: %s", i, err)
+		}
+		key := DNSRecord{Name: r.Name, Type: r.Type, Value: r.Value}
+		if seen[key] {
+			return fmt.Errorf("records[%d]: duplicated record", i)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Validate performs parameter validation
+func (r *DNSRecord) Validate() error {
+	if r.Name != "@" {
+		if len(r.Name) > 63*4 || !dnsRecordNamePattern.MatchString(r.Name) {
+			return fmt.Errorf("%q is not a valid record name", r.Name)
+		}
+		if strings.HasPrefix(r.Name, DNSBindingMarkerPrefix) {
+			return fmt.Errorf("names with %q prefix are reserved", DNSBindingMarkerPrefix)
+		}
+	}
+	if r.TTL != 0 && (r.TTL < 10 || r.TTL > 3600000) {
+		return fmt.Errorf("%q must be between 10 and 3600000", "ttl")
+	}
+
+	switch r.Type {
+	case "A":
+		if ip := net.ParseIP(r.Value); ip == nil || ip.To4() == nil || strings.Contains(r.Value, ":") {
+			return fmt.Errorf("value of A record must be an IPv4 address")
+		}
+	case "CNAME":
+		if r.Name == "@" {
+			return fmt.Errorf("CNAME record can't be created at the apex")
+		}
+		if !ValidDNSZone(strings.TrimSuffix(r.Value, ".")) {
+			return fmt.Errorf("value of CNAME record must be a domain name")
+		}
+	case "TXT":
+		if r.Value == "" || len(r.Value) > 255 {
+			return fmt.Errorf("value of TXT record must be 1 to 255 characters")
+		}
+	default:
+		return fmt.Errorf("%q must be one of A, CNAME and TXT", "type")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSBindParameterValidate(t *testing.T) {
+
+	expects := []struct {
+		name   string
+		param  *DNSBindParameter
+		result bool
+	}{
+		{
+			name:   "records required",
+			param:  &DNSBindParameter{},
+			result: false,
+		},
+		{
+			name: "valid records",
+			param: &DNSBindParameter{Records: []DNSRecord{
+				{Name: "www", Type: "A", Value: "192.0.2.1"},
+				{Name: "www", Type: "A", Value: "192.0.2.2", TTL: 60},
+				{Name: "api.v1", Type: "CNAME", Value: "www.example.com."},
+				{Name: "@", Type: "TXT", Value: "v=spf1 -all"},
+			}},
+			result: true,
+		},
+		{
+			name:   "invalid name",
+			param:  &DNSBindParameter{Records: []DNSRecord{{Name: "WWW!", Type: "A", Value: "192.0.2.1"}}},
+			result: false,
+		},
+		{
+			name:   "reserved name",
+			param:  &DNSBindParameter{Records: []DNSRecord{{Name: "_osbs-foo", Type: "TXT", Value: "foo"}}},
+			result: false,
+		},
+		{
+			name:   "unsupported type",
+			param:  &DNSBindParameter{Records: []DNSRecord{{Name: "mail", Type: "MX", Value: "10 mail.example.com."}}},
+			result: false,
+		},
+		{
+			name:   "invalid A value",
+			param:  &DNSBindParameter{Records: []DNSRecord{{Name: "www", Type: "A", Value: "2001:db8::1"}}},
+			result: false,
+		},
+		{
+			name:   "CNAME at apex",
+			param:  &DNSBindParameter{Records: []DNSRecord{{Name: "@", Type: "CNAME", Value: "www.example.com"}}},
+			result: false,
+		},
+		{
+			name:   "invalid TTL",
+			param:  &DNSBindParameter{Records: []DNSRecord{{Name: "www", Type: "A", Value: "192.0.2.1", TTL: 1}}},
+			result: false,
+		},
+		{
+			name: "duplicated records",
+			param: &DNSBindParameter{Records: []DNSRecord{
+				{Name: "www", Type: "A", Value: "192.0.2.1"},
+				{Name: "www", Type: "A", Value: "192.0.2.1", TTL: 60},
+			}},
+			result: false,
+		},
+	}
+
+	for _, expect := range expects {
+		p := expect.param
+		err := p.Validate()
+		t.Run(expect.name, func(t *testing.T) {
+			assert.Equal(t, expect.result, err == nil)
+		})
+	}
+}
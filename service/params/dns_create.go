@@ -0,0 +1,58 @@
+package params
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	dnsLabelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	dnsZonePattern  = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+)
+
+// DNSCreateParameter represents parameter
+// for SAKURA Cloud DNS zones
+type DNSCreateParameter struct {
+	Zone      string `json:"zone,omitempty"`
+	Subdomain string `json:"subdomain,omitempty"`
+
+	// ParentZone is the operator-configured zone in which subdomains are delegated. It isn't requested by users
+	ParentZone string `json:"parentZone,omitempty"`
+}
+
+// Validate performs parameter validation
+func (p *DNSCreateParameter) Validate() error {
+	switch {
+	case p.Zone == "" && p.Subdomain == "":
+		return fmt.Errorf("%q or %q is required", "zone", "subdomain")
+	case p.Zone != "" && p.Subdomain != "":
+		return fmt.Errorf("%q can't be used with %q", "zone", "subdomain")
+	case p.Zone != "":
+		if !ValidDNSZone(p.Zone) {
+			return fmt.Errorf("%q is not a valid domain name", "zone")
+		}
+	default:
+		if p.ParentZone == "" {
+			return errors.New("subdomains are not available: the parent zone is not configured")
+		}
+		if !dnsLabelPattern.MatchString(p.Subdomain) {
+			return fmt.Errorf("%q must be a single label of lowercase letters, digits and hyphens", "subdomain")
+		}
+	}
+	return nil
+}
+
+// ZoneName returns the name of the zone managed by the instance
+func (p *DNSCreateParameter) ZoneName() string {
+	if p.Subdomain != "" {
+		return p.Subdomain + "." + p.ParentZone
+	}
+	return p.Zone
+}
+
+// ValidDNSZone returns true if the name is a valid zone name without the trailing dot
+func ValidDNSZone(name string) bool {
+	return len(name) <= 253 && !strings.HasSuffix(name, ".") && dnsZonePattern.MatchString(name)
+}
@@ -0,0 +1,70 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSCreateParameterValidate(t *testing.T) {
+
+	expects := []struct {
+		name   string
+		param  *DNSCreateParameter
+		result bool
+	}{
+		{
+			name:   "zone or subdomain required",
+			param:  &DNSCreateParameter{},
+			result: false,
+		},
+		{
+			name:   "zone and subdomain",
+			param:  &DNSCreateParameter{Zone: "example.com", Subdomain: "team", ParentZone: "example.com"},
+			result: false,
+		},
+		{
+			name:   "invalid zone",
+			param:  &DNSCreateParameter{Zone: "example"},
+			result: false,
+		},
+		{
+			name:   "zone with trailing dot",
+			param:  &DNSCreateParameter{Zone: "example.com."},
+			result: false,
+		},
+		{
+			name:   "valid zone",
+			param:  &DNSCreateParameter{Zone: "apps.example.com"},
+			result: true,
+		},
+		{
+			name:   "subdomain without parent zone",
+			param:  &DNSCreateParameter{Subdomain: "team"},
+			result: false,
+		},
+		{
+			name:   "subdomain with multiple labels",
+			param:  &DNSCreateParameter{Subdomain: "a.team", ParentZone: "example.com"},
+			result: false,
+		},
+		{
+			name:   "valid subdomain",
+			param:  &DNSCreateParameter{Subdomain: "team-a", ParentZone: "example.com"},
+			result: true,
+		},
+	}
+
+	for _, expect := range expects {
+		p := expect.param
+		err := p.Validate()
+		t.Run(expect.name, func(t *testing.T) {
+			assert.Equal(t, expect.result, err == nil)
+		})
+	}
+}
+
+func TestDNSCreateParameterZoneName(t *testing.T) {
+	assert.Equal(t, "example.com", (&DNSCreateParameter{Zone: "example.com"}).ZoneName())
+	assert.Equal(t, "team.example.com", (&DNSCreateParameter{Subdomain: "team", ParentZone: "example.com"}).ZoneName())
+}
@@ -0,0 +1,43 @@
+package params
+
+import (
+	"fmt"
+
+	"github.com/sacloud/open-service-broker-sacloud/util/validator"
+)
+
+// GSLBBindParameter represents parameter
+// for the endpoint which is registered by the binding
+type GSLBBindParameter struct {
+	IPAddress string `json:"ipaddress"`
+	Weight    int    `json:"weight,omitempty"`
+	Enabled   *bool  `json:"enabled,omitempty"`
+}
+
+// Validate performs parameter validation
+func (p *GSLBBindParameter) Validate() error {
+	if !validator.Required(p.IPAddress) {
+		return fmt.Errorf("%q is required", "ipaddress")
+	}
+	if !validIPv4(p.IPAddress) {
+		return fmt.Errorf("%q expects IPv4 format(xxx.xxx.xxx.xxx)", "ipaddress")
+	}
+	if !validator.PublicIPv4Addr(p.IPAddress) {
+		return fmt.Errorf("%q must be a public address: servers are checked by the GSLB on the internet", "ipaddress")
+	}
+	if p.Weight != 0 && (p.Weight < 1 || p.Weight > 10000) {
+		return fmt.Errorf("%q must be between 1 and 10000", "weight")
+	}
+	return nil
+}
+
+// SetDefaults fills values which are not requested
+func (p *GSLBBindParameter) SetDefaults() {
+	if p.Weight == 0 {
+		p.Weight = 1
+	}
+	if p.Enabled == nil {
+		enabled := true
+		p.Enabled = &enabled
+	}
+}
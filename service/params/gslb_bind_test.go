@@ -0,0 +1,21 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGSLBBindParameter(t *testing.T) {
+	assert.Error(t, (&GSLBBindParameter{}).Validate())
+	assert.Error(t, (&GSLBBindParameter{IPAddress: "192.2.0.1", Weight: 10001}).Validate())
+	assert.NoError(t, (&GSLBBindParameter{IPAddress: "192.2.0.1"}).Validate())
+	for _, address := range []string{"10.0.0.1", "127.0.0.1", "169.254.169.254", "172.16.0.1", "192.168.0.1", "100.64.0.1", "224.0.0.1"} {
+		assert.Error(t, (&GSLBBindParameter{IPAddress: address}).Validate(), address)
+	}
+
+	p := &GSLBBindParameter{IPAddress: "192.2.0.1"}
+	p.SetDefaults()
+	assert.Equal(t, 1, p.Weight)
+	assert.True(t, *p.Enabled)
+}
@@ -0,0 +1,107 @@
+package params
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sacloud/open-service-broker-sacloud/util/validator"
+)
+
+const (
+	// GSLBDefaultInterval is the interval of health checks in seconds if not requested
+	GSLBDefaultInterval = 10
+	// GSLBMaxServers is the max number of servers of a GSLB
+	GSLBMaxServers = 12
+)
+
+// GSLBHealthCheckProtocols are protocols of health checks which the service supports
+var GSLBHealthCheckProtocols = []string{"http", "https", "tcp", "ping"}
+
+// GSLBCreateParameter represents parameter
+// for SAKURA Cloud GSLBs. The health check is applied to all endpoints of the GSLB
+type GSLBCreateParameter struct {
+	HealthCheck GSLBHealthCheckParameter `json:"healthCheck"`
+	Interval    int                      `json:"interval,omitempty"`
+	Weighted    *bool                    `json:"weighted,omitempty"`
+	SorryServer string                   `json:"sorryServer,omitempty"`
+}
+
+// GSLBHealthCheckParameter represents the health check of endpoints
+type GSLBHealthCheckParameter struct {
+	Protocol       string `json:"protocol"`
+	HostHeader     string `json:"hostHeader,omitempty"`
+	Path           string `json:"path,omitempty"`
+	ExpectedStatus int    `json:"expectedStatus,omitempty"`
+	Port           int    `json:"port,omitempty"`
+}
+
+// Validate performs parameter validation
+func (p *GSLBCreateParameter) Validate() error {
+	hc := &p.HealthCheck
+	if !validator.Required(hc.Protocol) {
+		return fmt.Errorf("%q of %q is required", "protocol", "healthCheck")
+	}
+
+	switch hc.Protocol {
+	case "http", "https":
+		if hc.Path != "" && !strings.HasPrefix(hc.Path, "/") {
+			return fmt.Errorf("%q must start with \"/\"", "path")
+		}
+		if hc.ExpectedStatus != 0 && (hc.ExpectedStatus < 100 || hc.ExpectedStatus > 599) {
+			return fmt.Errorf("%q must be between 100 and 599", "expectedStatus")
+		}
+		if hc.Port != 0 {
+			return fmt.Errorf("%q can't be used with %q protocol", "port", hc.Protocol)
+		}
+	case "tcp":
+		if hc.Port < 1 || hc.Port > 65535 {
+			return fmt.Errorf("%q must be between 1 and 65535 with %q protocol", "port", hc.Protocol)
+		}
+	case "ping":
+		if hc.Port != 0 {
+			return fmt.Errorf("%q can't be used with %q protocol", "port", hc.Protocol)
+		}
+	default:
+		return fmt.Errorf("%q must be one of %s", "protocol", strings.Join(GSLBHealthCheckProtocols, ", "))
+	}
+
+	if hc.Protocol != "http" && hc.Protocol != "https" {
+		for k, v := range map[string]interface{}{
+			"path":           hc.Path,
+			"hostHeader":     hc.HostHeader,
+			"expectedStatus": hc.ExpectedStatus,
+		} {
+			if validator.Required(v) {
+				return fmt.Errorf("%q can be used only with http or https protocol", k)
+			}
+		}
+	}
+
+	if p.Interval != 0 && (p.Interval < 10 || p.Interval > 60) {
+		return fmt.Errorf("%q must be between 10 and 60 seconds", "interval")
+	}
+	if p.SorryServer != "" && !validIPv4(p.SorryServer) {
+		return fmt.Errorf("%q expects IPv4 format(xxx.xxx.xxx.xxx)", "sorryServer")
+	}
+	return nil
+}
+
+// SetDefaults fills values which are not requested
+func (p *GSLBCreateParameter) SetDefaults() {
+	if p.Interval == 0 {
+		p.Interval = GSLBDefaultInterval
+	}
+	if p.Weighted == nil {
+		weighted := true
+		p.Weighted = &weighted
+	}
+	hc := &p.HealthCheck
+	if hc.Protocol == "http" || hc.Protocol == "https" {
+		if hc.Path == "" {
+			hc.Path = "/"
+		}
+		if hc.ExpectedStatus == 0 {
+			hc.ExpectedStatus = 200
+		}
+	}
+}
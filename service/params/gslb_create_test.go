@@ -0,0 +1,82 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGSLBCreateParameterValidate(t *testing.T) {
+
+	expects := []struct {
+		name   string
+		param  *GSLBCreateParameter
+		result bool
+	}{
+		{
+			name:   "Protocol required",
+			param:  &GSLBCreateParameter{},
+			result: false,
+		},
+		{
+			name:   "Unknown protocol",
+			param:  &GSLBCreateParameter{HealthCheck: GSLBHealthCheckParameter{Protocol: "smtp"}},
+			result: false,
+		},
+		{
+			name:   "Port required with tcp",
+			param:  &GSLBCreateParameter{HealthCheck: GSLBHealthCheckParameter{Protocol: "tcp"}},
+			result: false,
+		},
+		{
+			name:   "Path with ping",
+			param:  &GSLBCreateParameter{HealthCheck: GSLBHealthCheckParameter{Protocol: "ping", Path: "/"}},
+			result: false,
+		},
+		{
+			name:   "Invalid path",
+			param:  &GSLBCreateParameter{HealthCheck: GSLBHealthCheckParameter{Protocol: "http", Path: "healthz"}},
+			result: false,
+		},
+		{
+			name:   "Interval out of range",
+			param:  &GSLBCreateParameter{HealthCheck: GSLBHealthCheckParameter{Protocol: "ping"}, Interval: 5},
+			result: false,
+		},
+		{
+			name:   "Invalid sorry server",
+			param:  &GSLBCreateParameter{HealthCheck: GSLBHealthCheckParameter{Protocol: "ping"}, SorryServer: "example.com"},
+			result: false,
+		},
+		{
+			name: "Valid http",
+			param: &GSLBCreateParameter{
+				HealthCheck: GSLBHealthCheckParameter{Protocol: "http", HostHeader: "example.com", Path: "/healthz", ExpectedStatus: 204},
+				Interval:    30,
+				SorryServer: "192.2.0.100",
+			},
+			result: true,
+		},
+		{
+			name:   "Valid tcp",
+			param:  &GSLBCreateParameter{HealthCheck: GSLBHealthCheckParameter{Protocol: "tcp", Port: 443}},
+			result: true,
+		},
+	}
+
+	for _, expect := range expects {
+		t.Run(expect.name, func(t *testing.T) {
+			err := expect.param.Validate()
+			assert.Equal(t, expect.result, err == nil, "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestGSLBCreateParameterSetDefaults(t *testing.T) {
+	p := &GSLBCreateParameter{HealthCheck: GSLBHealthCheckParameter{Protocol: "https"}}
+	p.SetDefaults()
+	assert.Equal(t, GSLBDefaultInterval, p.Interval)
+	assert.True(t, *p.Weighted)
+	assert.Equal(t, "/", p.HealthCheck.Path)
+	assert.Equal(t, 200, p.HealthCheck.ExpectedStatus)
+}
@@ -0,0 +1,38 @@
+package params
+
+import (
+	"fmt"
+	"path"
+)
+
+const (
+	// NFSMountModeReadWrite is the mode of read-write volume mounts
+	NFSMountModeReadWrite = "rw"
+	// NFSMountModeReadOnly is the mode of read-only volume mounts
+	NFSMountModeReadOnly = "r"
+)
+
+// NFSBindParameter represents binding parameter
+// for SAKURA Cloud NFS Appliances
+type NFSBindParameter struct {
+	ContainerDir string `json:"containerDir,omitempty"`
+	Mode         string `json:"mode,omitempty"`
+	UID          int    `json:"uid,omitempty"`
+	GID          int    `json:"gid,omitempty"`
+}
+
+// Validate performs parameter validation
+func (p *NFSBindParameter) Validate() error {
+	if p.ContainerDir != "" && (!path.IsAbs(p.ContainerDir) || path.Clean(p.ContainerDir) != p.ContainerDir) {
+		return fmt.Errorf("%q must be a clean absolute path", "containerDir")
+	}
+	switch p.Mode {
+	case "", NFSMountModeReadWrite, NFSMountModeReadOnly:
+	default:
+		return fmt.Errorf("%q must be %q or %q", "mode", NFSMountModeReadWrite, NFSMountModeReadOnly)
+	}
+	if p.UID < 0 || p.GID < 0 {
+		return fmt.Errorf("%q and %q must not be negative", "uid", "gid")
+	}
+	return nil
+}
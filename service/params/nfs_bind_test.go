@@ -0,0 +1,55 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNFSBindParameterValidate(t *testing.T) {
+
+	expects := []struct {
+		name   string
+		param  *NFSBindParameter
+		result bool
+	}{
+		{
+			name:   "empty",
+			param:  &NFSBindParameter{},
+			result: true,
+		},
+		{
+			name:   "valid",
+			param:  &NFSBindParameter{ContainerDir: "/var/data", Mode: "r", UID: 1000, GID: 1000},
+			result: true,
+		},
+		{
+			name:   "relative containerDir",
+			param:  &NFSBindParameter{ContainerDir: "var/data"},
+			result: false,
+		},
+		{
+			name:   "unclean containerDir",
+			param:  &NFSBindParameter{ContainerDir: "/var/../etc"},
+			result: false,
+		},
+		{
+			name:   "invalid mode",
+			param:  &NFSBindParameter{Mode: "w"},
+			result: false,
+		},
+		{
+			name:   "negative uid",
+			param:  &NFSBindParameter{UID: -1},
+			result: false,
+		},
+	}
+
+	for _, expect := range expects {
+		p := expect.param
+		err := p.Validate()
+		t.Run(expect.name, func(t *testing.T) {
+			assert.Equal(t, expect.result, err == nil)
+		})
+	}
+}
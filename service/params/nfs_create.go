@@ -0,0 +1,45 @@
+package params
+
+import (
+	"fmt"
+
+	"github.com/sacloud/open-service-broker-sacloud/util/validator"
+)
+
+// NFSCreateParameter represents parameter
+// for SAKURA Cloud NFS Appliances
+type NFSCreateParameter struct {
+	SwitchID     int64  `json:"switchID"`
+	IPAddress    string `json:"ipaddress"`
+	MaskLen      int32  `json:"maskLen"`
+	DefaultRoute string `json:"defaultRoute,omitempty"`
+	PlanID       int
+}
+
+// Validate performs parameter validation
+func (p *NFSCreateParameter) Validate() error {
+
+	required := map[string]interface{}{
+		"switchID":  p.SwitchID,
+		"ipaddress": p.IPAddress,
+		"maskLen":   p.MaskLen,
+	}
+
+	for k, v := range required {
+		if !validator.Required(v) {
+			return fmt.Errorf("%q is required", k)
+		}
+	}
+
+	if !validator.ValidIPv4Addr(p.IPAddress) {
+		return fmt.Errorf("%q expects IPv4 format(xxx.xxx.xxx.xxx)", "ipaddress")
+	}
+	if p.DefaultRoute != "" && !validator.ValidIPv4Addr(p.DefaultRoute) {
+		return fmt.Errorf("%q expects IPv4 format(xxx.xxx.xxx.xxx)", "defaultRoute")
+	}
+	if p.MaskLen < 8 || p.MaskLen > 29 {
+		return fmt.Errorf("%q must be between 8 and 29", "maskLen")
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNFSCreateParameterValidate(t *testing.T) {
+
+	expects := []struct {
+		name   string
+		param  *NFSCreateParameter
+		result bool
+	}{
+		{
+			name: "SwitchID required",
+			param: &NFSCreateParameter{
+				IPAddress: "192.168.0.10",
+				MaskLen:   24,
+			},
+			result: false,
+		},
+		{
+			name: "IPAddress invalid format",
+			param: &NFSCreateParameter{
+				SwitchID:  999999999999,
+				IPAddress: "xxx.xxx.xxx.xxx",
+				MaskLen:   24,
+			},
+			result: false,
+		},
+		{
+			name: "MaskLen out of range",
+			param: &NFSCreateParameter{
+				SwitchID:  999999999999,
+				IPAddress: "192.168.0.10",
+				MaskLen:   32,
+			},
+			result: false,
+		},
+		{
+			name: "DefaultRoute invalid format",
+			param: &NFSCreateParameter{
+				SwitchID:     999999999999,
+				IPAddress:    "192.168.0.10",
+				MaskLen:      24,
+				DefaultRoute: "xxx",
+			},
+			result: false,
+		},
+		{
+			name: "Minimum valid params",
+			param: &NFSCreateParameter{
+				SwitchID:  999999999999,
+				IPAddress: "192.168.0.10",
+				MaskLen:   24,
+			},
+			result: true,
+		},
+	}
+
+	for _, expect := range expects {
+		p := expect.param
+		err := p.Validate()
+		t.Run(expect.name, func(t *testing.T) {
+			assert.Equal(t, expect.result, err == nil)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package params
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/sacloud/open-service-broker-sacloud/util/validator"
+)
+
+// SimpleMonitorDefaultInterval is the interval of health checks in seconds if not requested
+const SimpleMonitorDefaultInterval = 60
+
+// SimpleMonitorProtocols are protocols of health checks which the service supports
+var SimpleMonitorProtocols = []string{"http", "https", "tcp", "ping"}
+
+// SimpleMonitorParameter represents parameter
+// for SAKURA Cloud Simple Monitors. Updating takes the same parameter and replaces all settings
+type SimpleMonitorParameter struct {
+	Target         string `json:"target"`
+	Protocol       string `json:"protocol"`
+	Port           int    `json:"port,omitempty"`
+	Path           string `json:"path,omitempty"`
+	HostHeader     string `json:"hostHeader,omitempty"`
+	ExpectedStatus int    `json:"expectedStatus,omitempty"`
+	Interval       int    `json:"interval,omitempty"`
+	NotifyEmail    bool   `json:"notifyEmail,omitempty"`
+	WebhookURL     string `json:"webhookURL,omitempty"`
+}
+
+// Validate performs parameter validation
+func (p *SimpleMonitorParameter) Validate() error {
+	required := map[string]interface{}{
+		"target":   p.Target,
+		"protocol": p.Protocol,
+	}
+	for k, v := range required {
+		if !validator.Required(v) {
+			return fmt.Errorf("%q is required", k)
+		}
+	}
+
+	if !validSimpleMonitorTarget(p.Target) {
+		return fmt.Errorf("%q expects IPv4 address or FQDN", "target")
+	}
+
+	switch p.Protocol {
+	case "http", "https":
+		if p.Path != "" && !strings.HasPrefix(p.Path, "/") {
+			return fmt.Errorf("%q must start with \"/\"", "path")
+		}
+		if p.ExpectedStatus != 0 && (p.ExpectedStatus < 100 || p.ExpectedStatus > 599) {
+			return fmt.Errorf("%q must be between 100 and 599", "expectedStatus")
+		}
+	case "tcp":
+		if p.Port == 0 {
+			return fmt.Errorf("%q is required with %q protocol", "port", p.Protocol)
+		}
+	case "ping":
+		if p.Port != 0 {
+			return fmt.Errorf("%q can't be used with %q protocol", "port", p.Protocol)
+		}
+	default:
+		return fmt.Errorf("%q must be one of %s", "protocol", strings.Join(SimpleMonitorProtocols, ", "))
+	}
+
+	if p.Protocol != "http" && p.Protocol != "https" {
+		for k, v := range map[string]interface{}{
+			"path":           p.Path,
+			"hostHeader":     p.HostHeader,
+			"expectedStatus": p.ExpectedStatus,
+		} {
+			if validator.Required(v) {
+				return fmt.Errorf("%q can be used only with http or https protocol", k)
+			}
+		}
+	}
+
+	if p.Port < 0 || p.Port > 65535 {
+		return fmt.Errorf("%q must be between 1 and 65535", "port")
+	}
+	if p.Interval != 0 && (p.Interval < 60 || p.Interval > 3600) {
+		return fmt.Errorf("%q must be between 60 and 3600 seconds", "interval")
+	}
+
+	if p.WebhookURL != "" {
+		u, err := url.Parse(p.WebhookURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("%q expects https URL", "webhookURL")
+		}
+	}
+	return nil
+}
+
+// SetDefaults fills values which are not requested
+func (p *SimpleMonitorParameter) SetDefaults() {
+	if p.Interval == 0 {
+		p.Interval = SimpleMonitorDefaultInterval
+	}
+}
+
+func validSimpleMonitorTarget(target string) bool {
+	if strings.Contains(target, ":") {
+		return false
+	}
+	if validator.ValidIPv4Addr(target) && strings.Trim(target, "0123456789.") == "" {
+		return true
+	}
+	return ValidDNSZone(strings.ToLower(target))
+}
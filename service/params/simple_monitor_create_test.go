@@ -0,0 +1,114 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimpleMonitorParameterValidate(t *testing.T) {
+
+	expects := []struct {
+		name   string
+		param  *SimpleMonitorParameter
+		result bool
+	}{
+		{
+			name:   "Target required",
+			param:  &SimpleMonitorParameter{Protocol: "ping"},
+			result: false,
+		},
+		{
+			name:   "Protocol required",
+			param:  &SimpleMonitorParameter{Target: "192.0.2.1"},
+			result: false,
+		},
+		{
+			name:   "Target invalid format",
+			param:  &SimpleMonitorParameter{Target: "http://example.com/", Protocol: "http"},
+			result: false,
+		},
+		{
+			name:   "Unsupported protocol",
+			param:  &SimpleMonitorParameter{Target: "example.com", Protocol: "smtp"},
+			result: false,
+		},
+		{
+			name:   "Port required with tcp",
+			param:  &SimpleMonitorParameter{Target: "example.com", Protocol: "tcp"},
+			result: false,
+		},
+		{
+			name:   "Port out of range",
+			param:  &SimpleMonitorParameter{Target: "example.com", Protocol: "tcp", Port: 65536},
+			result: false,
+		},
+		{
+			name:   "Port with ping",
+			param:  &SimpleMonitorParameter{Target: "192.0.2.1", Protocol: "ping", Port: 80},
+			result: false,
+		},
+		{
+			name:   "Path with tcp",
+			param:  &SimpleMonitorParameter{Target: "example.com", Protocol: "tcp", Port: 22, Path: "/"},
+			result: false,
+		},
+		{
+			name:   "Path without slash",
+			param:  &SimpleMonitorParameter{Target: "example.com", Protocol: "http", Path: "healthz"},
+			result: false,
+		},
+		{
+			name:   "ExpectedStatus out of range",
+			param:  &SimpleMonitorParameter{Target: "example.com", Protocol: "https", ExpectedStatus: 600},
+			result: false,
+		},
+		{
+			name:   "Interval out of range",
+			param:  &SimpleMonitorParameter{Target: "example.com", Protocol: "https", Interval: 30},
+			result: false,
+		},
+		{
+			name:   "WebhookURL without https",
+			param:  &SimpleMonitorParameter{Target: "example.com", Protocol: "https", WebhookURL: "http://hooks.example.com/xxx"},
+			result: false,
+		},
+		{
+			name: "Valid HTTPS",
+			param: &SimpleMonitorParameter{
+				Target:         "www.example.com",
+				Protocol:       "https",
+				Port:           8443,
+				Path:           "/healthz",
+				HostHeader:     "app.example.com",
+				ExpectedStatus: 200,
+				Interval:       300,
+				NotifyEmail:    true,
+				WebhookURL:     "https://hooks.example.com/services/xxx",
+			},
+			result: true,
+		},
+		{
+			name:   "Valid ping",
+			param:  &SimpleMonitorParameter{Target: "192.0.2.1", Protocol: "ping"},
+			result: true,
+		},
+	}
+
+	for _, expect := range expects {
+		t.Run(expect.name, func(t *testing.T) {
+			err := expect.param.Validate()
+			assert.Equal(t, expect.result, err == nil, "%s", err)
+		})
+	}
+}
+
+func TestSimpleMonitorParameterSetDefaults(t *testing.T) {
+	p := &SimpleMonitorParameter{}
+	p.SetDefaults()
+	assert.Equal(t, SimpleMonitorDefaultInterval, p.Interval)
+
+	p = &SimpleMonitorParameter{Interval: 300}
+	p.SetDefaults()
+	assert.Equal(t, 300, p.Interval)
+}
@@ -0,0 +1,70 @@
+package params
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/sacloud/open-service-broker-sacloud/util/validator"
+)
+
+// SwitchCreateParameter represents parameter
+// for SAKURA Cloud Switches
+type SwitchCreateParameter struct {
+	Subnet      string `json:"subnet"`
+	Gateway     string `json:"gateway,omitempty"`
+	BridgeID    int64  `json:"bridgeID,omitempty"`
+	VPCRouterID int64  `json:"vpcRouterID,omitempty"`
+	// AllowRouterRestart allows the broker to restart the running VPC router to change interfaces
+	AllowRouterRestart bool `json:"allowRouterRestart,omitempty"`
+}
+
+// Validate performs parameter validation
+func (p *SwitchCreateParameter) Validate() error {
+	if !validator.Required(p.Subnet) {
+		return fmt.Errorf("%q is required", "subnet")
+	}
+
+	ip, network, err := net.ParseCIDR(p.Subnet)
+	if err != nil || ip.To4() == nil {
+		return fmt.Errorf("%q expects IPv4 CIDR format(xxx.xxx.xxx.xxx/nn)", "subnet")
+	}
+	if !ip.Equal(network.IP) {
+		return fmt.Errorf("%q must be the network address: %s", "subnet", network.String())
+	}
+	if maskLen := p.MaskLen(); maskLen < 8 || maskLen > 29 {
+		return fmt.Errorf("mask length of %q must be between 8 and 29", "subnet")
+	}
+
+	if p.Gateway != "" {
+		gw := net.ParseIP(p.Gateway)
+		if gw == nil || gw.To4() == nil {
+			return fmt.Errorf("%q expects IPv4 format(xxx.xxx.xxx.xxx)", "gateway")
+		}
+		if !network.Contains(gw) || gw.Equal(network.IP) || gw.Equal(broadcastAddress(network)) {
+			return fmt.Errorf("%q must be a host address in %q", "gateway", "subnet")
+		}
+	}
+	if p.VPCRouterID != 0 && p.Gateway == "" {
+		return fmt.Errorf("%q is required with %q: it's assigned to the VPC router", "gateway", "vpcRouterID")
+	}
+	return nil
+}
+
+// MaskLen returns the mask length of the subnet
+func (p *SwitchCreateParameter) MaskLen() int {
+	_, network, err := net.ParseCIDR(p.Subnet)
+	if err != nil {
+		return 0
+	}
+	ones, _ := network.Mask.Size()
+	return ones
+}
+
+func broadcastAddress(network *net.IPNet) net.IP {
+	ip := network.IP.To4()
+	broadcast := make(net.IP, len(ip))
+	for i := range ip {
+		broadcast[i] = ip[i] | ^network.Mask[i]
+	}
+	return broadcast
+}
@@ -0,0 +1,74 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwitchCreateParameterValidate(t *testing.T) {
+
+	expects := []struct {
+		name   string
+		param  *SwitchCreateParameter
+		result bool
+	}{
+		{
+			name:   "Subnet required",
+			param:  &SwitchCreateParameter{},
+			result: false,
+		},
+		{
+			name:   "Subnet invalid format",
+			param:  &SwitchCreateParameter{Subnet: "192.168.0.0"},
+			result: false,
+		},
+		{
+			name:   "Subnet not network address",
+			param:  &SwitchCreateParameter{Subnet: "192.168.0.1/24"},
+			result: false,
+		},
+		{
+			name:   "Subnet mask out of range",
+			param:  &SwitchCreateParameter{Subnet: "192.168.0.0/30"},
+			result: false,
+		},
+		{
+			name:   "Gateway out of subnet",
+			param:  &SwitchCreateParameter{Subnet: "192.168.0.0/24", Gateway: "192.168.1.1"},
+			result: false,
+		},
+		{
+			name:   "Gateway is broadcast address",
+			param:  &SwitchCreateParameter{Subnet: "192.168.0.0/24", Gateway: "192.168.0.255"},
+			result: false,
+		},
+		{
+			name:   "Gateway required with VPC router",
+			param:  &SwitchCreateParameter{Subnet: "192.168.0.0/24", VPCRouterID: 999999999999},
+			result: false,
+		},
+		{
+			name:   "Valid",
+			param:  &SwitchCreateParameter{Subnet: "192.168.0.0/24"},
+			result: true,
+		},
+		{
+			name: "Valid with VPC router and bridge",
+			param: &SwitchCreateParameter{
+				Subnet:      "192.168.0.0/24",
+				Gateway:     "192.168.0.1",
+				BridgeID:    999999999999,
+				VPCRouterID: 999999999999,
+			},
+			result: true,
+		},
+	}
+
+	for _, expect := range expects {
+		t.Run(expect.name, func(t *testing.T) {
+			err := expect.param.Validate()
+			assert.Equal(t, expect.result, err == nil, "%s", err)
+		})
+	}
+}
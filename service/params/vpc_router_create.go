@@ -0,0 +1,157 @@
+package params
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sacloud/open-service-broker-sacloud/util/validator"
+)
+
+const (
+	// VPCRouterPlanStandard is the plan ID of standard VPC routers
+	VPCRouterPlanStandard = 1
+	// VPCRouterPlanPremium is the plan ID of premium VPC routers
+	VPCRouterPlanPremium = 2
+	// VPCRouterPlanHighSpec is the plan ID of highspec VPC routers
+	VPCRouterPlanHighSpec = 3
+
+	// VPCRouterMaxInterfaces is the max number of private interfaces
+	VPCRouterMaxInterfaces = 7
+)
+
+// VPCRouterCreateParameter represents parameter
+// for SAKURA Cloud VPC Routers.
+// The public network is the shared segment on the standard plan, and given by public* parameters on the other plans
+type VPCRouterCreateParameter struct {
+	PublicSwitchID         int64                         `json:"publicSwitchID,omitempty"`
+	PublicIPAddresses      []string                      `json:"publicIPAddresses,omitempty"`
+	PublicVirtualIPAddress string                        `json:"publicVirtualIPAddress,omitempty"`
+	IPAliases              []string                      `json:"ipAliases,omitempty"`
+	VRID                   int                           `json:"vrid,omitempty"`
+	Interfaces             []VPCRouterInterfaceParameter `json:"interfaces"`
+	VPCRouterConfigParameter
+	PlanID int
+}
+
+// VPCRouterInterfaceParameter represents a private interface of the VPC router.
+// IPAddress is for the standard plan, IPAddresses and VirtualIPAddress are for the other plans
+type VPCRouterInterfaceParameter struct {
+	SwitchID         int64    `json:"switchID"`
+	IPAddress        string   `json:"ipaddress,omitempty"`
+	IPAddresses      []string `json:"ipaddresses,omitempty"`
+	VirtualIPAddress string   `json:"virtualIPAddress,omitempty"`
+	MaskLen          int      `json:"maskLen"`
+}
+
+// IsStandardPlan returns true if the parameter is for the standard plan
+func (p *VPCRouterCreateParameter) IsStandardPlan() bool {
+	return p.PlanID == VPCRouterPlanStandard
+}
+
+// Validate performs parameter validation
+func (p *VPCRouterCreateParameter) Validate() error {
+	switch p.PlanID {
+	case VPCRouterPlanStandard:
+		for k, v := range map[string]interface{}{
+			"publicSwitchID":         p.PublicSwitchID,
+			"publicIPAddresses":      p.PublicIPAddresses,
+			"publicVirtualIPAddress": p.PublicVirtualIPAddress,
+			"ipAliases":              p.IPAliases,
+			"vrid":                   p.VRID,
+		} {
+			if validator.Required(v) {
+				return fmt.Errorf("%q can't be used with the standard plan", k)
+			}
+		}
+	case VPCRouterPlanPremium, VPCRouterPlanHighSpec:
+		if !validator.Required(p.PublicSwitchID) {
+			return fmt.Errorf("%q is required", "publicSwitchID")
+		}
+		if err := validRedundantAddresses("public", p.PublicIPAddresses, p.PublicVirtualIPAddress); err != nil {
+			return err
+		}
+		for _, alias := range p.IPAliases {
+			if !validIPv4(alias) {
+				return fmt.Errorf("%q expects IPv4 format(xxx.xxx.xxx.xxx)", "ipAliases")
+			}
+		}
+		if p.VRID < 1 || p.VRID > 255 {
+			return fmt.Errorf("%q must be between 1 and 255", "vrid")
+		}
+	default:
+		return fmt.Errorf("unknown VPC router plan: %d", p.PlanID)
+	}
+
+	if len(p.Interfaces) == 0 {
+		return fmt.Errorf("%q is required", "interfaces")
+	}
+	if len(p.Interfaces) > VPCRouterMaxInterfaces {
+		return fmt.Errorf("%q must be %d or less", "interfaces", VPCRouterMaxInterfaces)
+	}
+	switches := map[int64]bool{}
+	for i := range p.Interfaces {
+		nic := &p.Interfaces[i]
+		if !validator.Required(nic.SwitchID) {
+			return fmt.Errorf("%q of interface %d is required", "switchID", i+1)
+		}
+		if switches[nic.SwitchID] || nic.SwitchID == p.PublicSwitchID {
+			return fmt.Errorf("switch %d is connected to multiple interfaces", nic.SwitchID)
+		}
+		switches[nic.SwitchID] = true
+
+		if nic.MaskLen < 16 || nic.MaskLen > 28 {
+			return fmt.Errorf("%q of interface %d must be between 16 and 28", "maskLen", i+1)
+		}
+		if p.IsStandardPlan() {
+			if !validIPv4(nic.IPAddress) {
+				return fmt.Errorf("%q of interface %d expects IPv4 format(xxx.xxx.xxx.xxx)", "ipaddress", i+1)
+			}
+			if len(nic.IPAddresses) > 0 || nic.VirtualIPAddress != "" {
+				return fmt.Errorf("%q and %q can't be used with the standard plan", "ipaddresses", "virtualIPAddress")
+			}
+			continue
+		}
+		if nic.IPAddress != "" {
+			return fmt.Errorf("%q can be used only with the standard plan, use %q", "ipaddress", "ipaddresses")
+		}
+		if err := validRedundantAddresses(fmt.Sprintf("interface %d", i+1), nic.IPAddresses, nic.VirtualIPAddress); err != nil {
+			return err
+		}
+	}
+
+	if err := p.VPCRouterConfigParameter.Validate(p.PlanID, len(p.Interfaces)); err != nil {
+		return err
+	}
+	for _, nat := range p.StaticNAT {
+		if !containsString(p.IPAliases, nat.GlobalAddress) {
+			return fmt.Errorf("%q of static NAT must be one of %q", "globalAddress", "ipAliases")
+		}
+	}
+	return nil
+}
+
+// validRedundantAddresses validates a pair of addresses and the virtual IP address for redundant VPC routers
+func validRedundantAddresses(name string, addresses []string, vip string) error {
+	if len(addresses) != 2 {
+		return fmt.Errorf("2 IP addresses are required for %s", name)
+	}
+	for _, addr := range append(addresses, vip) {
+		if !validIPv4(addr) {
+			return fmt.Errorf("addresses of %s expect IPv4 format(xxx.xxx.xxx.xxx)", name)
+		}
+	}
+	return nil
+}
+
+func validIPv4(addr string) bool {
+	return validator.Required(addr) && validator.ValidIPv4Addr(addr) && !strings.Contains(addr, ":")
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVPCRouterCreateParameterValidate(t *testing.T) {
+
+	standard := func() *VPCRouterCreateParameter {
+		return &VPCRouterCreateParameter{
+			PlanID: VPCRouterPlanStandard,
+			Interfaces: []VPCRouterInterfaceParameter{
+				{SwitchID: 111111111111, IPAddress: "192.168.0.1", MaskLen: 24},
+			},
+		}
+	}
+	premium := func() *VPCRouterCreateParameter {
+		return &VPCRouterCreateParameter{
+			PlanID:                 VPCRouterPlanPremium,
+			PublicSwitchID:         999999999999,
+			PublicIPAddresses:      []string{"203.0.113.11", "203.0.113.12"},
+			PublicVirtualIPAddress: "203.0.113.10",
+			IPAliases:              []string{"203.0.113.13"},
+			VRID:                   1,
+			Interfaces: []VPCRouterInterfaceParameter{
+				{
+					SwitchID:         111111111111,
+					IPAddresses:      []string{"192.168.0.2", "192.168.0.3"},
+					VirtualIPAddress: "192.168.0.1",
+					MaskLen:          24,
+				},
+			},
+		}
+	}
+
+	expects := []struct {
+		name   string
+		param  func() *VPCRouterCreateParameter
+		result bool
+	}{
+		{
+			name:   "Valid standard",
+			param:  standard,
+			result: true,
+		},
+		{
+			name:   "Valid premium",
+			param:  premium,
+			result: true,
+		},
+		{
+			name: "Unknown plan",
+			param: func() *VPCRouterCreateParameter {
+				p := standard()
+				p.PlanID = 0
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Interfaces required",
+			param: func() *VPCRouterCreateParameter {
+				p := standard()
+				p.Interfaces = nil
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Public network with standard plan",
+			param: func() *VPCRouterCreateParameter {
+				p := standard()
+				p.PublicSwitchID = 999999999999
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Public switch required with premium plan",
+			param: func() *VPCRouterCreateParameter {
+				p := premium()
+				p.PublicSwitchID = 0
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "VRID out of range",
+			param: func() *VPCRouterCreateParameter {
+				p := premium()
+				p.VRID = 256
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Redundant addresses required with premium plan",
+			param: func() *VPCRouterCreateParameter {
+				p := premium()
+				p.Interfaces[0].IPAddresses = p.Interfaces[0].IPAddresses[:1]
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Duplicated switches",
+			param: func() *VPCRouterCreateParameter {
+				p := standard()
+				p.Interfaces = append(p.Interfaces, VPCRouterInterfaceParameter{
+					SwitchID: 111111111111, IPAddress: "192.168.1.1", MaskLen: 24,
+				})
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Mask length out of range",
+			param: func() *VPCRouterCreateParameter {
+				p := standard()
+				p.Interfaces[0].MaskLen = 29
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Static NAT with standard plan",
+			param: func() *VPCRouterCreateParameter {
+				p := standard()
+				p.StaticNAT = []VPCRouterStaticNATParameter{
+					{GlobalAddress: "203.0.113.13", PrivateAddress: "192.168.0.11"},
+				}
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Static NAT global address not in IP aliases",
+			param: func() *VPCRouterCreateParameter {
+				p := premium()
+				p.StaticNAT = []VPCRouterStaticNATParameter{
+					{GlobalAddress: "203.0.113.14", PrivateAddress: "192.168.0.11"},
+				}
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Valid static NAT",
+			param: func() *VPCRouterCreateParameter {
+				p := premium()
+				p.StaticNAT = []VPCRouterStaticNATParameter{
+					{GlobalAddress: "203.0.113.13", PrivateAddress: "192.168.0.11"},
+				}
+				return p
+			},
+			result: true,
+		},
+		{
+			name: "Port forwarding invalid protocol",
+			param: func() *VPCRouterCreateParameter {
+				p := standard()
+				p.PortForwarding = []VPCRouterPortForwardingParameter{
+					{Protocol: "icmp", GlobalPort: 80, PrivateAddress: "192.168.0.11", PrivatePort: 8080},
+				}
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Firewall interface out of range",
+			param: func() *VPCRouterCreateParameter {
+				p := standard()
+				p.Firewall = []VPCRouterFirewallRuleParameter{
+					{Interface: 2, Direction: "receive", Action: "allow", Protocol: "ip"},
+				}
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Firewall ports with icmp",
+			param: func() *VPCRouterCreateParameter {
+				p := standard()
+				p.Firewall = []VPCRouterFirewallRuleParameter{
+					{Interface: 0, Direction: "receive", Action: "allow", Protocol: "icmp", DestinationPort: "80"},
+				}
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Site-to-site VPN without routes",
+			param: func() *VPCRouterCreateParameter {
+				p := standard()
+				p.SiteToSiteVPN = &VPCRouterSiteToSiteVPNParameter{
+					Peer:            "198.51.100.1",
+					PreSharedSecret: "secret",
+					LocalPrefix:     []string{"192.168.0.0/24"},
+				}
+				return p
+			},
+			result: false,
+		},
+		{
+			name: "Valid full configuration",
+			param: func() *VPCRouterCreateParameter {
+				p := standard()
+				p.PortForwarding = []VPCRouterPortForwardingParameter{
+					{Protocol: "tcp", GlobalPort: 80, PrivateAddress: "192.168.0.11", PrivatePort: 8080},
+				}
+				p.Firewall = []VPCRouterFirewallRuleParameter{
+					{Interface: 0, Direction: "receive", Action: "allow", Protocol: "tcp", DestinationPort: "80"},
+					{Interface: 0, Direction: "receive", Action: "allow", Protocol: "udp", SourcePort: "1024-65535"},
+					{Interface: 1, Direction: "send", Action: "deny", Protocol: "ip", SourceNetwork: "192.168.0.0/24", Logging: true},
+				}
+				p.SiteToSiteVPN = &VPCRouterSiteToSiteVPNParameter{
+					Peer:            "198.51.100.1",
+					PreSharedSecret: "secret",
+					Routes:          []string{"10.0.0.0/8"},
+					LocalPrefix:     []string{"192.168.0.0/24"},
+				}
+				return p
+			},
+			result: true,
+		},
+	}
+
+	for _, expect := range expects {
+		t.Run(expect.name, func(t *testing.T) {
+			err := expect.param().Validate()
+			assert.Equal(t, expect.result, err == nil, "unexpected error: %v", err)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package params
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/sacloud/open-service-broker-sacloud/util/validator"
+)
+
+// VPCRouterConfigParameter represents the configuration of the VPC router.
+// Updating takes this parameter and replaces all of the configuration
+type VPCRouterConfigParameter struct {
+	StaticNAT      []VPCRouterStaticNATParameter      `json:"staticNAT,omitempty"`
+	PortForwarding []VPCRouterPortForwardingParameter `json:"portForwarding,omitempty"`
+	Firewall       []VPCRouterFirewallRuleParameter   `json:"firewall,omitempty"`
+	SiteToSiteVPN  *VPCRouterSiteToSiteVPNParameter   `json:"siteToSiteVPN,omitempty"`
+}
+
+// VPCRouterStaticNATParameter represents a static NAT rule
+type VPCRouterStaticNATParameter struct {
+	GlobalAddress  string `json:"globalAddress"`
+	PrivateAddress string `json:"privateAddress"`
+	Description    string `json:"description,omitempty"`
+}
+
+// VPCRouterPortForwardingParameter represents a port forwarding rule
+type VPCRouterPortForwardingParameter struct {
+	Protocol       string `json:"protocol"`
+	GlobalPort     int    `json:"globalPort"`
+	PrivateAddress string `json:"privateAddress"`
+	PrivatePort    int    `json:"privatePort"`
+	Description    string `json:"description,omitempty"`
+}
+
+// VPCRouterFirewallRuleParameter represents a firewall rule on the interface.
+// Interface 0 is the public interface, and 1 or later are the private interfaces in order
+type VPCRouterFirewallRuleParameter struct {
+	Interface          int    `json:"interface"`
+	Direction          string `json:"direction"`
+	Action             string `json:"action"`
+	Protocol           string `json:"protocol"`
+	SourceNetwork      string `json:"sourceNetwork,omitempty"`
+	SourcePort         string `json:"sourcePort,omitempty"`
+	DestinationNetwork string `json:"destinationNetwork,omitempty"`
+	DestinationPort    string `json:"destinationPort,omitempty"`
+	Logging            bool   `json:"logging,omitempty"`
+	Description        string `json:"description,omitempty"`
+}
+
+// VPCRouterSiteToSiteVPNParameter represents the site-to-site IPsec VPN peer
+type VPCRouterSiteToSiteVPNParameter struct {
+	Peer            string   `json:"peer"`
+	RemoteID        string   `json:"remoteID,omitempty"`
+	PreSharedSecret string   `json:"preSharedSecret,omitempty"`
+	Routes          []string `json:"routes"`
+	LocalPrefix     []string `json:"localPrefix"`
+}
+
+// Validate performs parameter validation with the plan and the number of private interfaces of the VPC router
+func (p *VPCRouterConfigParameter) Validate(planID, interfaces int) error {
+	if len(p.StaticNAT) > 0 && planID == VPCRouterPlanStandard {
+		return fmt.Errorf("%q can't be used with the standard plan", "staticNAT")
+	}
+	for _, nat := range p.StaticNAT {
+		if !validIPv4(nat.GlobalAddress) || !validIPv4(nat.PrivateAddress) {
+			return fmt.Errorf("addresses of static NAT expect IPv4 format(xxx.xxx.xxx.xxx)")
+		}
+	}
+
+	for _, pf := range p.PortForwarding {
+		if pf.Protocol != "tcp" && pf.Protocol != "udp" {
+			return fmt.Errorf("%q of port forwarding must be tcp or udp", "protocol")
+		}
+		if pf.GlobalPort < 1 || pf.GlobalPort > 65535 || pf.PrivatePort < 1 || pf.PrivatePort > 65535 {
+			return fmt.Errorf("ports of port forwarding must be between 1 and 65535")
+		}
+		if !validIPv4(pf.PrivateAddress) {
+			return fmt.Errorf("%q of port forwarding expects IPv4 format(xxx.xxx.xxx.xxx)", "privateAddress")
+		}
+	}
+
+	for i, rule := range p.Firewall {
+		if err := rule.validate(interfaces); err != nil {
+			return fmt.Errorf("firewall rule %d: %s", i, err)
+		}
+	}
+
+	if vpn := p.SiteToSiteVPN; vpn != nil {
+		if !validIPv4(vpn.Peer) {
+			return fmt.Errorf("%q of site-to-site VPN expects IPv4 format(xxx.xxx.xxx.xxx)", "peer")
+		}
+		if !validator.Required(vpn.PreSharedSecret) {
+			return fmt.Errorf("%q of site-to-site VPN is required", "preSharedSecret")
+		}
+		if len(vpn.PreSharedSecret) > 40 {
+			return fmt.Errorf("%q of site-to-site VPN must be 40 characters or less", "preSharedSecret")
+		}
+		for k, networks := range map[string][]string{
+			"routes":      vpn.Routes,
+			"localPrefix": vpn.LocalPrefix,
+		} {
+			if len(networks) == 0 {
+				return fmt.Errorf("%q of site-to-site VPN is required", k)
+			}
+			for _, network := range networks {
+				if _, _, err := net.ParseCIDR(network); err != nil {
+					return fmt.Errorf("%q of site-to-site VPN expects CIDR format(xxx.xxx.xxx.xxx/nn)", k)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// SetDefaults fills values which are not requested
+func (p *VPCRouterConfigParameter) SetDefaults() {
+	if p.SiteToSiteVPN != nil && p.SiteToSiteVPN.RemoteID == "" {
+		p.SiteToSiteVPN.RemoteID = p.SiteToSiteVPN.Peer
+	}
+}
+
+func (r *VPCRouterFirewallRuleParameter) validate(interfaces int) error {
+	if r.Interface < 0 || r.Interface > interfaces {
+		return fmt.Errorf("%q must be between 0 and %d", "interface", interfaces)
+	}
+	if r.Direction != "receive" && r.Direction != "send" {
+		return fmt.Errorf("%q must be receive or send", "direction")
+	}
+	if r.Action != "allow" && r.Action != "deny" {
+		return fmt.Errorf("%q must be allow or deny", "action")
+	}
+
+	switch r.Protocol {
+	case "tcp", "udp":
+		for k, v := range map[string]string{"sourcePort": r.SourcePort, "destinationPort": r.DestinationPort} {
+			if v != "" && !validPortRange(v) {
+				return fmt.Errorf("%q expects a port or a range of ports(nnnn-nnnn)", k)
+			}
+		}
+	case "icmp", "ip":
+		if r.SourcePort != "" || r.DestinationPort != "" {
+			return fmt.Errorf("ports can be used only with tcp or udp protocol")
+		}
+	default:
+		return fmt.Errorf("%q must be one of tcp, udp, icmp, ip", "protocol")
+	}
+
+	for k, v := range map[string]string{"sourceNetwork": r.SourceNetwork, "destinationNetwork": r.DestinationNetwork} {
+		if v == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(v); err != nil && !validIPv4(v) {
+			return fmt.Errorf("%q expects IPv4 or CIDR format", k)
+		}
+	}
+	return nil
+}
+
+func validPortRange(v string) bool {
+	ports := strings.SplitN(v, "-", 2)
+	for _, port := range ports {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return false
+		}
+	}
+	return true
+}
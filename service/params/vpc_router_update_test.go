@@ -0,0 +1,15 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVPCRouterConfigParameterSetDefaults(t *testing.T) {
+	p := &VPCRouterConfigParameter{
+		SiteToSiteVPN: &VPCRouterSiteToSiteVPNParameter{Peer: "198.51.100.1"},
+	}
+	p.SetDefaults()
+	assert.Equal(t, "198.51.100.1", p.SiteToSiteVPN.RemoteID)
+}
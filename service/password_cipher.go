@@ -0,0 +1,181 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// encryptedPasswordPrefix is the prefix of all encrypted passwords
+	encryptedPasswordPrefix = "enc:"
+	// legacyPasswordPrefix is the prefix of passwords encrypted with the key derived only from the salt by older versions.
+	// They are only decrypted, and encrypted again with the password secret
+	legacyPasswordPrefix = encryptedPasswordPrefix + "v1:"
+	// passwordPrefixV2 is the prefix of passwords encrypted with the key derived from the password secret by HKDF.
+	// It is followed by the ID of the secret and ":"
+	passwordPrefixV2 = encryptedPasswordPrefix + "v2:"
+
+	// passwordKeyInfo separates keys of passwords from other keys derived from the same secret
+	passwordKeyInfo = "open-service-broker-sacloud binding password"
+	// passwordSecretMinLength is the minimum length of password secrets
+	passwordSecretMinLength = 32
+)
+
+// passwordSecret is the operator secret which keys of passwords are derived from
+type passwordSecret struct {
+	id     string
+	secret []byte
+}
+
+// errPasswordSecretNotConfigured is returned if passwords are used without the password secret
+var errPasswordSecretNotConfigured = errors.New("password secret is not configured(set --password-secret to use PostgreSQL)")
+
+var (
+	// currentPasswordSecret encrypts passwords. It is nil until SetPasswordSecrets is called
+	currentPasswordSecret *passwordSecret
+	// passwordSecrets decrypt passwords. It has previous secrets to decrypt passwords encrypted before the rotation
+	passwordSecrets map[string]*passwordSecret
+)
+
+// SetPasswordSecrets sets the operator secret which encrypts binding passwords, and previous secrets which
+// only decrypt passwords encrypted before the rotation. Passwords are encrypted again with the current secret
+// when the broker reads meta tables
+func SetPasswordSecrets(current string, previous ...string) error {
+	secrets := make(map[string]*passwordSecret)
+	var cur *passwordSecret
+	for i, s := range append([]string{current}, previous...) {
+		if len(s) < passwordSecretMinLength {
+			return fmt.Errorf("password secret must be at least %d characters", passwordSecretMinLength)
+		}
+		secret := newPasswordSecret(s)
+		secrets[secret.id] = secret
+		if i == 0 {
+			cur = secret
+		}
+	}
+
+	currentPasswordSecret = cur
+	passwordSecrets = secrets
+	return nil
+}
+
+func newPasswordSecret(s string) *passwordSecret {
+	// the ID doesn't reveal the secret, it only tells which secret encrypted the password
+	sum := sha256.Sum256([]byte("password secret id:" + s))
+	return &passwordSecret{
+		id:     hex.EncodeToString(sum[:4]),
+		secret: []byte(s),
+	}
+}
+
+// encryptPassword encrypts the password with AES-GCM keyed by the current password secret and salt(per instance)
+func encryptPassword(salt, password string) (string, error) {
+	if currentPasswordSecret == nil {
+		return "", errPasswordSecretNotConfigured
+	}
+	aead, err := passwordCipher(currentPasswordSecret, salt)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(password), nil)
+	return currentPasswordPrefix() + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptPassword decrypts the password encrypted by encryptPassword or older versions
+func decryptPassword(salt, encrypted string) (string, error) {
+	var aead cipher.AEAD
+	var sealed string
+	var err error
+	switch {
+	case strings.HasPrefix(encrypted, passwordPrefixV2):
+		tokens := strings.SplitN(strings.TrimPrefix(encrypted, passwordPrefixV2), ":", 2)
+		if len(tokens) != 2 {
+			return "", errors.New("encrypted password has no secret ID")
+		}
+		secret, ok := passwordSecrets[tokens[0]]
+		if !ok {
+			return "", fmt.Errorf("password is encrypted with unknown secret %q", tokens[0])
+		}
+		sealed = tokens[1]
+		aead, err = passwordCipher(secret, salt)
+	case strings.HasPrefix(encrypted, legacyPasswordPrefix):
+		sealed = strings.TrimPrefix(encrypted, legacyPasswordPrefix)
+		aead, err = legacyPasswordCipher(salt)
+	default:
+		return "", errors.New("password is not encrypted")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("encrypted password is too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func isEncryptedPassword(password string) bool {
+	return strings.HasPrefix(password, encryptedPasswordPrefix)
+}
+
+// currentPasswordPrefix returns the prefix of passwords encrypted with the current secret.
+// Passwords without it should be encrypted again
+func currentPasswordPrefix() string {
+	if currentPasswordSecret == nil {
+		return passwordPrefixV2
+	}
+	return passwordPrefixV2 + currentPasswordSecret.id + ":"
+}
+
+// passwordCipher returns the cipher keyed by HKDF(secret, salt)
+func passwordCipher(secret *passwordSecret, salt string) (cipher.AEAD, error) {
+	if salt == "" {
+		return nil, errors.New("salt is empty")
+	}
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret.secret, []byte(salt), []byte(passwordKeyInfo)), key); err != nil {
+		return nil, err
+	}
+	return newGCM(key)
+}
+
+// legacyPasswordCipher returns the cipher of older versions, which key is derived only from the salt
+func legacyPasswordCipher(salt string) (cipher.AEAD, error) {
+	if salt == "" {
+		return nil, errors.New("salt is empty")
+	}
+	key := sha256.Sum256([]byte(salt))
+	return newGCM(key[:])
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sacloud/open-service-broker-sacloud/util/random"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	testPasswordSecret        = "0123456789abcdef0123456789abcdef"
+	testRotatedPasswordSecret = "fedcba9876543210fedcba9876543210"
+)
+
+func TestPasswordCipher(t *testing.T) {
+	defer func() {
+		currentPasswordSecret = nil
+		passwordSecrets = nil
+	}()
+
+	_, err := encryptPassword("123456789012", "password")
+	assert.Equal(t, errPasswordSecretNotConfigured, err)
+	// PostgreSQL instances are rejected before anything is created
+	assert.Equal(t, errPasswordSecretNotConfigured, (&postgreSQLHandler{}).migrateMetaTable(nil, nil))
+
+	assert.Error(t, SetPasswordSecrets("short"))
+	assert.NoError(t, SetPasswordSecrets(testPasswordSecret))
+
+	encrypted, err := encryptPassword("123456789012", "password")
+	assert.NoError(t, err)
+	assert.True(t, isEncryptedPassword(encrypted))
+	assert.True(t, strings.HasPrefix(encrypted, currentPasswordPrefix()))
+	assert.NotContains(t, encrypted, "password")
+
+	// passwords generated by createBinding fit in the password column
+	long, err := encryptPassword("123456789012", random.String(30))
+	assert.NoError(t, err)
+	assert.True(t, len(long) <= 128)
+
+	plain, err := decryptPassword("123456789012", encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "password", plain)
+
+	// keyed per instance
+	_, err = decryptPassword("999999999999", encrypted)
+	assert.Error(t, err)
+
+	_, err = decryptPassword("123456789012", "password")
+	assert.Error(t, err)
+
+	_, err = encryptPassword("", "password")
+	assert.Error(t, err)
+
+	t.Run("rotation", func(t *testing.T) {
+		assert.NoError(t, SetPasswordSecrets(testRotatedPasswordSecret, testPasswordSecret))
+		assert.False(t, strings.HasPrefix(encrypted, currentPasswordPrefix()))
+
+		// passwords encrypted with previous secrets are still decrypted
+		plain, err := decryptPassword("123456789012", encrypted)
+		assert.NoError(t, err)
+		assert.Equal(t, "password", plain)
+
+		// and can't be decrypted after the previous secret is removed
+		assert.NoError(t, SetPasswordSecrets(testRotatedPasswordSecret))
+		_, err = decryptPassword("123456789012", encrypted)
+		assert.Error(t, err)
+	})
+
+	t.Run("legacy passwords", func(t *testing.T) {
+		aead, err := legacyPasswordCipher("123456789012")
+		assert.NoError(t, err)
+		nonce := make([]byte, aead.NonceSize())
+		legacy := legacyPasswordPrefix + base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte("password"), nil))
+
+		assert.True(t, isEncryptedPassword(legacy))
+		plain, err := decryptPassword("123456789012", legacy)
+		assert.NoError(t, err)
+		assert.Equal(t, "password", plain)
+	})
+}
+
+// TestHKDF checks the vendored package with the test case 1 of RFC 5869
+func TestHKDF(t *testing.T) {
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+
+	okm := make([]byte, 42)
+	_, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, info), okm)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865",
+		hex.EncodeToString(okm),
+	)
+}
@@ -1,13 +1,17 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/sacloud/open-service-broker-sacloud/iaas"
 	"github.com/sacloud/open-service-broker-sacloud/util/random"
+	"net/url"
+	"strings"
 
+	log "github.com/Sirupsen/logrus"
 	_ "github.com/lib/pq" // nolint
 	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
 	"github.com/sacloud/open-service-broker-sacloud/service/params"
@@ -15,13 +19,165 @@ import (
 
 const (
 	postgreSQLMetaTableName = "open_service_broker_meta"
-	postgreSQLMetaTableDDL  = `create table ` + postgreSQLMetaTableName + ` (
-		binding_id varchar(36),
-		name varchar(20),
-		password varchar(128)
-	)`
+	postgreSQLMetaLockKey   = 0x7361636c6f7564 // "sacloud", the key for pg_advisory_lock
+	// postgreSQLUserLockKey is the key for pg_advisory_lock which is shared by creating bindings and held by sweeping users
+	postgreSQLUserLockKey = postgreSQLMetaLockKey + 1
 )
 
+// postgreSQLAllowedExtensions is extensions which can be requested on binding
+var postgreSQLAllowedExtensions = []string{
+	"btree_gin",
+	"btree_gist",
+	"citext",
+	"cube",
+	"earthdistance",
+	"fuzzystrmatch",
+	"hstore",
+	"intarray",
+	"ltree",
+	"pg_trgm",
+	"pgcrypto",
+	"tablefunc",
+	"unaccent",
+	"uuid-ossp",
+}
+
+func isAllowedPostgreSQLExtension(name string) bool {
+	for _, extension := range postgreSQLAllowedExtensions {
+		if extension == name {
+			return true
+		}
+	}
+	return false
+}
+
+// postgreSQLMetaSchema is migrations of meta tables in the admin database
+var postgreSQLMetaSchema = &metaSchema{
+	versionTableDDL: `create table if not exists ` + metaSchemaVersionTableName + ` (
+		version int primary key,
+		description varchar(255),
+		applied_at timestamptz default now()
+	)`,
+	existsVersionTable: func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		var exists bool
+		err := conn.QueryRowContext(ctx, `select to_regclass($1) is not null`, metaSchemaVersionTableName).Scan(&exists)
+		return exists, err
+	},
+	selectVersions: `select version from ` + metaSchemaVersionTableName,
+	insertVersion:  `insert into ` + metaSchemaVersionTableName + ` (version, description) values ($1, $2)`,
+	lock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, `select pg_advisory_lock($1)`, postgreSQLMetaLockKey)
+		return err
+	},
+	unlock: fmt.Sprintf(`select pg_advisory_unlock(%d)`, postgreSQLMetaLockKey),
+	migrations: []*metaMigration{
+		{
+			version:     1,
+			description: "create meta table",
+			statements: []string{
+				`create table if not exists ` + postgreSQLMetaTableName + ` (
+					binding_id varchar(36),
+					name varchar(20),
+					password varchar(128)
+				)`,
+			},
+		},
+		{
+			version:     2,
+			description: "encrypt passwords in meta table",
+			migrate:     postgreSQLEncryptPasswords,
+		},
+		{
+			version:     3,
+			description: "add created_at to meta table",
+			statements: []string{
+				`alter table ` + postgreSQLMetaTableName + ` add column if not exists created_at timestamptz`,
+			},
+		},
+		{
+			version:     4,
+			description: "add tls to meta table",
+			statements: []string{
+				`alter table ` + postgreSQLMetaTableName + ` add column if not exists tls boolean not null default false`,
+			},
+		},
+		{
+			version:     5,
+			description: "add credential_format to meta table",
+			statements: []string{
+				`alter table ` + postgreSQLMetaTableName + ` add column if not exists credential_format varchar(64) not null default ''`,
+			},
+		},
+	},
+}
+
+// postgreSQLEncryptPasswords encrypts passwords which were stored as plain text by older versions.
+// Passwords encrypted with previous secrets or by older versions are encrypted again with the current secret
+func postgreSQLEncryptPasswords(tx *sql.Tx, connInfo ConnectionInfo) error {
+	query := fmt.Sprintf(`select binding_id, password from %s where password not like $1`, postgreSQLMetaTableName)
+	rows, err := tx.Query(query, currentPasswordPrefix()+"%")
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]string)
+	for rows.Next() {
+		var bindingID, password string
+		if err := rows.Scan(&bindingID, &password); err != nil {
+			rows.Close() // nolint
+			return err
+		}
+		stored[bindingID] = password
+	}
+	rows.Close() // nolint
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for bindingID, password := range stored {
+		plain := password
+		if isEncryptedPassword(password) {
+			plain, err = decryptPassword(connInfo.Salt(), password)
+			if err != nil {
+				// the binding can't be read anyway, so it doesn't block other bindings
+				log.WithFields(log.Fields{
+					"host":      connInfo.Host(),
+					"bindingID": bindingID,
+					"err":       err,
+				}).Warn("password is not encrypted again: decrypting is failed")
+				continue
+			}
+		}
+		encrypted, err := encryptPassword(connInfo.Salt(), plain)
+		if err != nil {
+			return err
+		}
+		// the password may be updated by others after reading
+		_, err = tx.Exec(
+			fmt.Sprintf("update %s set password = $1 where binding_id = $2 and password = $3", postgreSQLMetaTableName),
+			encrypted,
+			bindingID,
+			password,
+		)
+		if err != nil {
+			return fmt.Errorf("error encrypting password of binding %q: %s", bindingID, err)
+		}
+	}
+	return nil
+}
+
+// postgreSQLReencryptPasswords encrypts passwords again with the current secret after the secret is rotated
+func postgreSQLReencryptPasswords(db *sql.DB, connInfo ConnectionInfo) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := postgreSQLEncryptPasswords(tx, connInfo); err != nil {
+		tx.Rollback() // nolint
+		return err
+	}
+	return tx.Commit()
+}
+
 func newPostgreSQLServiceHandler(operation, serviceID, planID string, rawParameter []byte) *databaseHandler {
 	handler := &databaseHandler{
 		operation:    operation,
@@ -62,8 +218,66 @@ func newPostgreSQLServiceHandler(operation, serviceID, planID string, rawParamet
 		}
 
 		handler.parameter = &p
+	case operations.Updating:
+		if len(rawParameter) == 0 {
+			handler.paramErr = errors.New("postgreSQLService parameter JSON is empty")
+			return handler
+		}
+
+		var p = params.DatabaseUpdateParameter{}
+		err := json.Unmarshal(rawParameter, &p)
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		err = p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		handler.updateParameter = &p
 	case operations.Binding:
-		// noop
+		var p = params.DatabaseBindParameter{}
+		if len(rawParameter) > 0 {
+			err := json.Unmarshal(rawParameter, &p)
+			if err != nil {
+				handler.paramErr = err
+				return handler
+			}
+		}
+
+		err := p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+		if p.CredentialFormat != "" && !hasCredentialFormat(serviceID, p.CredentialFormat) {
+			handler.paramErr = fmt.Errorf("credentialFormat %q is not defined", p.CredentialFormat)
+			return handler
+		}
+		if p.TLS && planTLSConfig(planID) == nil {
+			handler.paramErr = errors.New("tls is not available: TLS is not configured on the plan")
+			return handler
+		}
+		if params.LimitValue(p.MaxQueriesPerHour) > 0 {
+			handler.paramErr = errors.New("postgreSQLService not support maxQueriesPerHour")
+			return handler
+		}
+		if !p.MariaDBDatabaseOptions.IsEmpty() {
+			handler.paramErr = errors.New("postgreSQLService not support characterSet, collation and allowedHost")
+			return handler
+		}
+		for _, extension := range p.Extensions {
+			if !isAllowedPostgreSQLExtension(extension) {
+				handler.paramErr = fmt.Errorf("extension %q is not allowed", extension)
+				return handler
+			}
+		}
+		p.DatabaseBindingLimits = p.DatabaseBindingLimits.WithDefaults(planBindingDefaults(planID))
+
+		handler.bindParameter = &p
 	default:
 		handler.paramErr = fmt.Errorf("postgreSQLService not support %q", operation)
 	}
@@ -77,7 +291,11 @@ func (f *postgreSQLHandler) databaseAPI() iaas.DatabaseAPI {
 	return sacloudAPI.PostgreSQL()
 }
 
-func (f *postgreSQLHandler) buildConnInfo(host, dbName, user, password, salt string, port int) ConnectionInfo {
+func (f *postgreSQLHandler) idMap() PlanIDMap {
+	return DatabaseIDMap["postgres"]
+}
+
+func (f *postgreSQLHandler) buildConnInfo(host, dbName, user, password, salt string, port int, tlsConfig *DatabaseTLSConfig) ConnectionInfo {
 	return &postgreSQLConnInfo{
 		host:     host,
 		dbName:   dbName,
@@ -85,22 +303,10 @@ func (f *postgreSQLHandler) buildConnInfo(host, dbName, user, password, salt str
 		password: password,
 		salt:     salt,
 		port:     port,
+		tls:      tlsConfig,
 	}
 }
 
-func (f *postgreSQLHandler) prepareMetaTable(db *sql.DB, connInfo ConnectionInfo) error {
-	exists, err := f.existsMetaTable(db, connInfo)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return nil
-	}
-
-	_, err = db.Exec(postgreSQLMetaTableDDL)
-	return err
-}
-
 func (f *postgreSQLHandler) existsMetaTable(db *sql.DB, connInfo ConnectionInfo) (bool, error) {
 	var res string
 	query := `
@@ -121,33 +327,92 @@ func (f *postgreSQLHandler) existsMetaTable(db *sql.DB, connInfo ConnectionInfo)
 }
 
 func (f *postgreSQLHandler) readBinding(db *sql.DB, connInfo ConnectionInfo, bindingID string) (*databaseBindingRecord, error) {
-	var username, password string
-	query := fmt.Sprintf(`select name, password from %s where binding_id = $1 limit 1`, postgreSQLMetaTableName)
+	var username, password, credentialFormat string
+	var useTLS bool
+	query := fmt.Sprintf(`select name, password, tls, credential_format from %s where binding_id = $1 limit 1`, postgreSQLMetaTableName)
 	rows, err := db.Query(query, bindingID)
 	if err != nil {
 		return nil, err
 	}
 	if rows.Next() {
-		err = rows.Scan(&username, &password)
+		err = rows.Scan(&username, &password, &useTLS, &credentialFormat)
 		if err != nil {
 			return nil, err
 		}
+		password, err = decryptPassword(connInfo.Salt(), password)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting password of binding %q: %s", bindingID, err)
+		}
 
 		return &databaseBindingRecord{
-			bindingID: bindingID,
-			username:  username,
-			password:  password,
+			bindingID:        bindingID,
+			username:         username,
+			password:         password,
+			tls:              useTLS,
+			credentialFormat: credentialFormat,
 		}, nil
 	}
 	return nil, nil
 }
 
-func (f *postgreSQLHandler) createBinding(db *sql.DB, connInfo ConnectionInfo, bindingID string) (*databaseBindingRecord, error) {
+func (f *postgreSQLHandler) migrateMetaTable(db *sql.DB, connInfo ConnectionInfo) error {
+	// fail before creating anything, passwords of bindings can't be stored or read without the secret
+	if currentPasswordSecret == nil {
+		return errPasswordSecretNotConfigured
+	}
+	if err := applyMetaMigrations(db, connInfo, postgreSQLMetaSchema); err != nil {
+		return err
+	}
+	return postgreSQLReencryptPasswords(db, connInfo)
+}
+
+func (f *postgreSQLHandler) metaSchemaVersion(db *sql.DB, connInfo ConnectionInfo) (int, int, error) {
+	current, err := currentMetaVersion(db, postgreSQLMetaSchema)
+	return current, postgreSQLMetaSchema.latestVersion(), err
+}
+
+func (f *postgreSQLHandler) listBindings(db *sql.DB, connInfo ConnectionInfo) ([]*databaseBindingRecord, error) {
+	query := fmt.Sprintf(`select binding_id, name, password, tls, credential_format from %s`, postgreSQLMetaTableName)
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // nolint
+
+	var records []*databaseBindingRecord
+	for rows.Next() {
+		record := &databaseBindingRecord{}
+		var password string
+		if err := rows.Scan(&record.bindingID, &record.username, &password, &record.tls, &record.credentialFormat); err != nil {
+			return nil, err
+		}
+		record.password, err = decryptPassword(connInfo.Salt(), password)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting password of binding %q: %s", record.bindingID, err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (f *postgreSQLHandler) createBinding(db *sql.DB, connInfo ConnectionInfo, bindingID string, p *params.DatabaseBindParameter) (*databaseBindingRecord, error) {
+	// reject options which the appliance doesn't support before creating anything
+	if err := f.checkDatabaseOptions(db, &p.PostgreSQLDatabaseOptions); err != nil {
+		return nil, err
+	}
+
+	// the role has neither its database nor the metadata until the end, so block sweeping users meanwhile
+	unlock, err := postgreSQLAdvisoryLock(db, postgreSQLUserLockKey, true)
+	if err != nil {
+		return nil, fmt.Errorf("error locking users: %s", err)
+	}
+	defer unlock()
+
 	// create and add metadata
-	username := random.String(20)
+	username := newBindingUsername()
 	password := random.String(30)
 
-	_, err := db.Exec(fmt.Sprintf("create role %q with password '%s' login", username, password))
+	_, err = db.Exec(fmt.Sprintf("create role %q with password '%s' login", username, password))
 	if err != nil {
 		return nil, fmt.Errorf(`error creating user role %q: %s`, username, err)
 	}
@@ -158,33 +423,234 @@ func (f *postgreSQLHandler) createBinding(db *sql.DB, connInfo ConnectionInfo, b
 		return nil, fmt.Errorf(`error grant to role %q: %s`, username, err)
 	}
 
-	_, err = db.Exec(fmt.Sprintf(`create database %q owner %q`, username, username))
+	_, err = db.Exec(createPostgreSQLDatabaseQuery(username, &p.PostgreSQLDatabaseOptions))
 	if err != nil {
 		return nil, fmt.Errorf(`error creating user database %q: %s`, username, err)
 
 	}
 
+	if err := f.prepareUserDatabase(connInfo, username, &p.PostgreSQLDatabaseOptions); err != nil {
+		return nil, err
+	}
+
+	if err := f.updateBindingLimits(db, username, &p.DatabaseBindingLimits); err != nil {
+		return nil, err
+	}
+
 	// insert metadata
+	encrypted, err := encryptPassword(connInfo.Salt(), password)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting password: %s", err)
+	}
 	_, err = db.Exec(
-		fmt.Sprintf("insert into %s values ($1,$2,$3)", postgreSQLMetaTableName),
+		fmt.Sprintf(
+			"insert into %s (binding_id, name, password, tls, credential_format, created_at) values ($1,$2,$3,$4,$5,now())",
+			postgreSQLMetaTableName),
 		bindingID,
 		username,
-		password,
+		encrypted,
+		p.TLS,
+		p.CredentialFormat,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error creating metadata record : %s", err)
 	}
 
 	return &databaseBindingRecord{
-		bindingID: bindingID,
-		username:  username,
-		password:  password,
+		bindingID:        bindingID,
+		username:         username,
+		password:         password,
+		tls:              p.TLS,
+		credentialFormat: p.CredentialFormat,
 	}, nil
 }
 
+// checkDatabaseOptions verifies requested extensions, encoding and locales are available on the appliance
+func (f *postgreSQLHandler) checkDatabaseOptions(db *sql.DB, opts *params.PostgreSQLDatabaseOptions) error {
+	if opts.IsEmpty() {
+		return nil
+	}
+
+	var version string
+	if err := db.QueryRow(`select current_setting('server_version')`).Scan(&version); err != nil {
+		return fmt.Errorf("error reading server version: %s", err)
+	}
+
+	for _, extension := range opts.Extensions {
+		var available bool
+		err := db.QueryRow(`select exists(select 1 from pg_available_extensions where name = $1)`, extension).Scan(&available)
+		if err != nil {
+			return fmt.Errorf("error reading available extensions: %s", err)
+		}
+		if !available {
+			return fmt.Errorf("extension %q is not available on PostgreSQL %s", extension, version)
+		}
+	}
+
+	if opts.Encoding != "" {
+		var valid bool
+		if err := db.QueryRow(`select pg_char_to_encoding($1) >= 0`, opts.Encoding).Scan(&valid); err != nil {
+			return fmt.Errorf("error reading encodings: %s", err)
+		}
+		if !valid {
+			return fmt.Errorf("encoding %q is not supported on PostgreSQL %s", opts.Encoding, version)
+		}
+	}
+
+	var locales map[string]bool
+	for _, locale := range []string{opts.LCCollate, opts.LCCtype} {
+		if locale == "" || locale == "C" || locale == "POSIX" {
+			continue
+		}
+		if locales == nil {
+			var err error
+			if locales, err = f.availableLocales(db); err != nil {
+				return fmt.Errorf("error reading locales: %s", err)
+			}
+		}
+		if !locales[normalizeLocale(locale)] {
+			return fmt.Errorf("locale %q is not available on PostgreSQL %s", locale, version)
+		}
+	}
+	return nil
+}
+
+// availableLocales returns normalized names of libc locales which databases can be created with
+func (f *postgreSQLHandler) availableLocales(db *sql.DB) (map[string]bool, error) {
+	var versionNum int
+	if err := db.QueryRow(`select current_setting('server_version_num')::int`).Scan(&versionNum); err != nil {
+		return nil, err
+	}
+	query := `select collcollate from pg_collation where collcollate is not null`
+	if versionNum >= 100000 {
+		// ICU collations can't be locales of databases
+		query += ` and collprovider <> 'i'`
+	}
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // nolint
+
+	locales := make(map[string]bool)
+	for rows.Next() {
+		var locale string
+		if err := rows.Scan(&locale); err != nil {
+			return nil, err
+		}
+		locales[normalizeLocale(locale)] = true
+	}
+	return locales, rows.Err()
+}
+
+// normalizeLocale normalizes the codeset of the locale name in the same way as glibc,
+// so that "en_US.UTF-8" matches "en_US.utf8" in pg_collation
+func normalizeLocale(locale string) string {
+	name, modifier := locale, ""
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, modifier = name[:i], name[i:]
+	}
+	i := strings.Index(name, ".")
+	if i < 0 {
+		return locale
+	}
+
+	var codeset []rune
+	digitsOnly := true
+	for _, r := range strings.ToLower(name[i+1:]) {
+		switch {
+		case r >= 'a' && r <= 'z':
+			digitsOnly = false
+			codeset = append(codeset, r)
+		case r >= '0' && r <= '9':
+			codeset = append(codeset, r)
+		}
+	}
+	if digitsOnly {
+		// e.g. "8859-1" is "iso88591"
+		return name[:i] + ".iso" + string(codeset) + modifier
+	}
+	return name[:i] + "." + string(codeset) + modifier
+}
+
+func createPostgreSQLDatabaseQuery(name string, opts *params.PostgreSQLDatabaseOptions) string {
+	query := fmt.Sprintf(`create database %q owner %q`, name, name)
+	if opts.Encoding == "" && opts.LCCollate == "" && opts.LCCtype == "" {
+		return query
+	}
+
+	// template1 may have incompatible encoding and locales
+	query += " template template0"
+	if opts.Encoding != "" {
+		query += fmt.Sprintf(" encoding '%s'", opts.Encoding)
+	}
+	if opts.LCCollate != "" {
+		query += fmt.Sprintf(" lc_collate '%s'", opts.LCCollate)
+	}
+	if opts.LCCtype != "" {
+		query += fmt.Sprintf(" lc_ctype '%s'", opts.LCCtype)
+	}
+	return query
+}
+
+// prepareUserDatabase creates schemas and extensions in the user database as the admin user
+func (f *postgreSQLHandler) prepareUserDatabase(connInfo ConnectionInfo, dbName string, opts *params.PostgreSQLDatabaseOptions) error {
+	if len(opts.Schemas) == 0 && len(opts.Extensions) == 0 {
+		return nil
+	}
+
+	userDBConnInfo := f.buildConnInfo(
+		connInfo.Host(),
+		dbName,
+		connInfo.UserName(),
+		connInfo.Password(),
+		connInfo.Salt(),
+		connInfo.Port(),
+		connInfo.TLS(),
+	)
+	db, err := openDatabase(userDBConnInfo)
+	if err != nil {
+		return fmt.Errorf("error connecting user database %q: %s", dbName, err)
+	}
+	defer db.Close() // nolint
+
+	for _, schema := range opts.Schemas {
+		_, err := db.Exec(fmt.Sprintf(`create schema if not exists %q authorization %q`, schema, dbName))
+		if err != nil {
+			return fmt.Errorf("error creating schema %q: %s", schema, err)
+		}
+	}
+	for _, extension := range opts.Extensions {
+		_, err := db.Exec(fmt.Sprintf(`create extension if not exists %q`, extension))
+		if err != nil {
+			return fmt.Errorf("error creating extension %q: %s", extension, err)
+		}
+	}
+	return nil
+}
+
 func (f *postgreSQLHandler) deleteBinding(db *sql.DB, record *databaseBindingRecord) error {
 
-	exists, _ := f.existsUserDatabase(db, record.username)
+	exists, err := f.existsUser(db, record.username)
+	if err != nil {
+		return fmt.Errorf(`error reading user role %q: %s`, record.username, err)
+	}
+	if exists {
+		// prevent reconnecting before terminating sessions
+		_, err := db.Exec(fmt.Sprintf(`alter role %q nologin`, record.username))
+		if err != nil {
+			return fmt.Errorf(`error disabling login of user role %q: %s`, record.username, err)
+		}
+		if err := f.terminateSessions(db, record.username); err != nil {
+			return err
+		}
+	}
+
+	exists, err = f.existsUserDatabase(db, record.username)
+	if err != nil {
+		return fmt.Errorf(`error reading user database %q: %s`, record.username, err)
+	}
 	if exists {
 		_, err := db.Exec(fmt.Sprintf(`drop database %q`, record.username))
 		if err != nil {
@@ -192,7 +658,15 @@ func (f *postgreSQLHandler) deleteBinding(db *sql.DB, record *databaseBindingRec
 		}
 	}
 
-	_, err := db.Exec(
+	if err := f.dropUser(db, record.username); err != nil {
+		return err
+	}
+
+	if err := verifyBindingRemoved(f, db, record.username); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
 		fmt.Sprintf(`delete from %s where binding_id = $1`, postgreSQLMetaTableName),
 		record.bindingID,
 	)
@@ -203,6 +677,161 @@ func (f *postgreSQLHandler) deleteBinding(db *sql.DB, record *databaseBindingRec
 	return nil
 }
 
+func (f *postgreSQLHandler) terminateSessions(db *sql.DB, username string) error {
+	_, err := db.Exec(
+		`select pg_terminate_backend(pid) from pg_stat_activity where usename = $1 and pid <> pg_backend_pid()`,
+		username,
+	)
+	if err != nil {
+		return fmt.Errorf(`error terminating sessions of user role %q: %s`, username, err)
+	}
+	return nil
+}
+
+func (f *postgreSQLHandler) dropUser(db *sql.DB, username string) error {
+	exists, err := f.existsUser(db, username)
+	if err != nil {
+		return fmt.Errorf(`error reading user role %q: %s`, username, err)
+	}
+	if !exists {
+		return nil
+	}
+
+	if err := f.terminateSessions(db, username); err != nil {
+		return err
+	}
+
+	// admin user was granted the role at binding
+	_, err = db.Exec(fmt.Sprintf(`revoke %q from current_user`, username))
+	if err != nil {
+		return fmt.Errorf(`error revoking user role %q: %s`, username, err)
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`drop role %q`, username))
+	if err != nil {
+		return fmt.Errorf(`error deleting user role %q: %s`, username, err)
+	}
+	return nil
+}
+
+func (f *postgreSQLHandler) existsUser(db *sql.DB, username string) (bool, error) {
+	var res string
+	query := `select rolname from pg_roles where rolname = $1 limit 1`
+	err := db.QueryRow(query, username).Scan(&res)
+
+	switch {
+	case err == nil:
+		return true, nil
+	case err == sql.ErrNoRows:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (f *postgreSQLHandler) listUsers(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`select rolname from pg_roles where rolname !~ '^pg_'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // nolint
+
+	var users []string
+	for rows.Next() {
+		var user string
+		if err := rows.Scan(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// isLegacyBindingUser returns true if the role is granted to the admin role, which only createBinding does
+func (f *postgreSQLHandler) lockUsers(db *sql.DB) (func(), error) {
+	return postgreSQLAdvisoryLock(db, postgreSQLUserLockKey, false)
+}
+
+// postgreSQLAdvisoryLock takes the advisory lock on a dedicated connection, and returns the function to release it.
+// Shared locks are taken together, and block the exclusive lock
+func postgreSQLAdvisoryLock(db *sql.DB, key int64, shared bool) (func(), error) {
+	lock, unlock := "pg_advisory_lock", "pg_advisory_unlock"
+	if shared {
+		lock, unlock = "pg_advisory_lock_shared", "pg_advisory_unlock_shared"
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`select %s($1)`, lock), key); err != nil {
+		conn.Close() // nolint
+		return nil, err
+	}
+	return func() {
+		conn.ExecContext(ctx, fmt.Sprintf(`select %s($1)`, unlock), key) // nolint
+		conn.Close()                                                     // nolint
+	}, nil
+}
+
+func (f *postgreSQLHandler) isLegacyBindingUser(db *sql.DB, connInfo ConnectionInfo, username string) (bool, error) {
+	var granted bool
+	err := db.QueryRow(`
+		select exists(
+			select 1 from pg_auth_members m
+			join pg_roles r on r.oid = m.roleid
+			join pg_roles a on a.oid = m.member
+			where r.rolname = $1 and a.rolname = $2
+		)`, username, connInfo.UserName()).Scan(&granted)
+	return granted, err
+}
+
+func (f *postgreSQLHandler) updateBindingPassword(db *sql.DB, connInfo ConnectionInfo, record *databaseBindingRecord) error {
+	_, err := db.Exec(fmt.Sprintf("alter role %q with password '%s'", record.username, record.password))
+	if err != nil {
+		return fmt.Errorf("error updating password of role %q: %s", record.username, err)
+	}
+
+	encrypted, err := encryptPassword(connInfo.Salt(), record.password)
+	if err != nil {
+		return fmt.Errorf("error encrypting password: %s", err)
+	}
+	_, err = db.Exec(
+		fmt.Sprintf("update %s set password = $1 where binding_id = $2", postgreSQLMetaTableName),
+		encrypted,
+		record.bindingID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating metadata record : %s", err)
+	}
+
+	return nil
+}
+
+// updateBindingLimits sets resource limits of the user role. Zero means unlimited
+func (f *postgreSQLHandler) updateBindingLimits(db *sql.DB, username string, limits *params.DatabaseBindingLimits) error {
+	if params.LimitValue(limits.MaxQueriesPerHour) > 0 {
+		return errors.New("maxQueriesPerHour is not supported by PostgreSQL")
+	}
+
+	connLimit := -1 // unlimited
+	if maxConnections := params.LimitValue(limits.MaxConnections); maxConnections > 0 {
+		connLimit = maxConnections
+	}
+	_, err := db.Exec(fmt.Sprintf(`alter role %q connection limit %d`, username, connLimit))
+	if err != nil {
+		return fmt.Errorf(`error updating connection limit of user role %q: %s`, username, err)
+	}
+
+	// statement_timeout is milliseconds and applied to new sessions
+	_, err = db.Exec(fmt.Sprintf(`alter role %q set statement_timeout = %d`, username, params.LimitValue(limits.MaxQueryTime)*1000))
+	if err != nil {
+		return fmt.Errorf(`error updating statement_timeout of user role %q: %s`, username, err)
+	}
+	return nil
+}
+
 func (f *postgreSQLHandler) existsUserDatabase(db *sql.DB, dbName string) (bool, error) {
 	var res string
 	query := `
@@ -228,6 +857,7 @@ type postgreSQLConnInfo struct {
 	port     int
 	dbName   string
 	salt     string
+	tls      *DatabaseTLSConfig
 }
 
 func (c *postgreSQLConnInfo) DriverName() string {
@@ -262,13 +892,34 @@ func (c *postgreSQLConnInfo) Salt() string {
 	return c.salt
 }
 
+func (c *postgreSQLConnInfo) TLS() *DatabaseTLSConfig {
+	return c.tls
+}
+
 func (c *postgreSQLConnInfo) FormatDSN() string {
-	format := "postgres://%s:%s@%s:%d/%s?sslmode=disable"
+	format := "postgres://%s:%s@%s:%d/%s?%s"
 	return fmt.Sprintf(format,
 		c.username,
 		c.password,
 		c.host,
 		c.Port(),
 		c.dbName,
+		c.sslParams(),
 	)
 }
+
+func (c *postgreSQLConnInfo) URI() string {
+	return c.FormatDSN()
+}
+
+func (c *postgreSQLConnInfo) sslParams() string {
+	switch {
+	case c.tls == nil:
+		return "sslmode=disable"
+	case c.tls.CAFile != "":
+		// appliances are accessed by IP address, so the host name is not verified
+		return "sslmode=verify-ca&sslrootcert=" + url.QueryEscape(c.tls.CAFile)
+	default:
+		return "sslmode=require"
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"context"
@@ -9,6 +10,7 @@ import (
 	"errors"
 	"github.com/sacloud/libsacloud/sacloud"
 	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
 	"github.com/stretchr/testify/assert"
 	"time"
 )
@@ -68,6 +70,81 @@ func TestPostgreSQLServiceValidate(t *testing.T) {
 			assert.NoError(t, err)
 			assert.Nil(t, s.parameter)
 		})
+
+		t.Run("TLS rawParameter", func(t *testing.T) {
+			databaseTLSConfigs[MariaDBPlan10GID] = &DatabaseTLSConfig{}
+			defer func() { databaseTLSConfigs = map[string]*DatabaseTLSConfig{} }()
+
+			s := getPostgreSQLHandler(operations.Binding, `{"tls": true}`)
+			result, err := s.IsValid()
+			assert.True(t, result)
+			assert.NoError(t, err)
+			assert.True(t, s.bindParameter.TLS)
+		})
+
+		t.Run("TLS rawParameter without TLS config", func(t *testing.T) {
+			s := getPostgreSQLHandler(operations.Binding, `{"tls": true}`)
+			result, err := s.IsValid()
+			assert.False(t, result)
+			assert.Error(t, err)
+		})
+
+		t.Run("Invalid rawParameter", func(t *testing.T) {
+			s := getPostgreSQLHandler(operations.Binding, `{"tls": "yes"}`)
+			result, err := s.IsValid()
+			assert.False(t, result)
+			assert.Error(t, err)
+		})
+
+		t.Run("limits rawParameter", func(t *testing.T) {
+			s := getPostgreSQLHandler(operations.Binding, `{"maxConnections": 10, "maxQueryTime": 30}`)
+			result, err := s.IsValid()
+			assert.True(t, result)
+			assert.NoError(t, err)
+			assert.Equal(t, params.DatabaseBindingLimits{
+				MaxConnections: params.Limit(10),
+				MaxQueryTime:   params.Limit(30),
+			}, s.bindParameter.DatabaseBindingLimits)
+
+			// MAX_QUERIES_PER_HOUR is MariaDB only
+			s = getPostgreSQLHandler(operations.Binding, `{"maxQueriesPerHour": 1000}`)
+			result, err = s.IsValid()
+			assert.False(t, result)
+			assert.Error(t, err)
+		})
+
+		t.Run("database options rawParameter", func(t *testing.T) {
+			s := getPostgreSQLHandler(operations.Binding, `{"extensions": ["pgcrypto", "uuid-ossp"], "schemas": ["app"], "lcCollate": "C"}`)
+			result, err := s.IsValid()
+			assert.True(t, result)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"pgcrypto", "uuid-ossp"}, s.bindParameter.Extensions)
+
+			s = getPostgreSQLHandler(operations.Binding, `{"extensions": ["plpythonu"]}`)
+			result, err = s.IsValid()
+			assert.False(t, result)
+			assert.Error(t, err)
+		})
+
+		t.Run("MariaDB options rawParameter", func(t *testing.T) {
+			s := getPostgreSQLHandler(operations.Binding, `{"allowedHost": "192.2.0.0/24"}`)
+			result, err := s.IsValid()
+			assert.False(t, result)
+			assert.Error(t, err)
+		})
+
+		t.Run("credentialFormat rawParameter", func(t *testing.T) {
+			s := getPostgreSQLHandler(operations.Binding, `{"credentialFormat": "libpq"}`)
+			result, err := s.IsValid()
+			assert.True(t, result)
+			assert.NoError(t, err)
+			assert.Equal(t, "libpq", s.bindParameter.CredentialFormat)
+
+			s = getPostgreSQLHandler(operations.Binding, `{"credentialFormat": "not-exists"}`)
+			result, err = s.IsValid()
+			assert.False(t, result)
+			assert.Error(t, err)
+		})
 	})
 
 	t.Run("Other operations", func(t *testing.T) {
@@ -79,7 +156,7 @@ func TestPostgreSQLServiceValidate(t *testing.T) {
 	})
 }
 
-func TestPostgreSQLHandler_prepareMetaTable(t *testing.T) {
+func TestPostgreSQLHandler_migrateMetaTable(t *testing.T) {
 	if !existsTestEnvVars("TEST_DB") {
 		t.Skipf("environment variable %q is empty. skip.", "TEST_DB")
 		return
@@ -114,13 +191,26 @@ func TestPostgreSQLHandler_prepareMetaTable(t *testing.T) {
 		assert.False(t, exists)
 		assert.NoError(t, err)
 
-		err = s.dialect.prepareMetaTable(db, connInfo)
+		current, latest, err := s.dialect.metaSchemaVersion(db, connInfo)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, current)
+		assert.NotZero(t, latest)
+
+		err = s.dialect.migrateMetaTable(db, connInfo)
 		assert.NoError(t, err)
 
 		// post
 		exists, err = s.dialect.existsMetaTable(db, connInfo)
 		assert.True(t, exists)
 		assert.NoError(t, err)
+
+		current, latest, err = s.dialect.metaSchemaVersion(db, connInfo)
+		assert.NoError(t, err)
+		assert.Equal(t, latest, current)
+
+		// migrations are applied only once
+		err = s.dialect.migrateMetaTable(db, connInfo)
+		assert.NoError(t, err)
 	})
 }
 
@@ -155,10 +245,10 @@ func TestPostgreSQLHandler_create_read_delete(t *testing.T) {
 		}
 		defer db.Close()
 
-		err = s.dialect.prepareMetaTable(db, connInfo)
+		err = s.dialect.migrateMetaTable(db, connInfo)
 		assert.NoError(t, err)
 
-		record, err := s.dialect.createBinding(db, connInfo, bindingID)
+		record, err := s.dialect.createBinding(db, connInfo, bindingID, &params.DatabaseBindParameter{})
 		assert.NotNil(t, record)
 		assert.NoError(t, err)
 
@@ -170,6 +260,73 @@ func TestPostgreSQLHandler_create_read_delete(t *testing.T) {
 		createdRecord = record
 	})
 
+	t.Run("create binding with database options", func(t *testing.T) {
+		// connect db
+		db, err := s.open(connInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		record, err := s.dialect.createBinding(db, connInfo, "with-options", &params.DatabaseBindParameter{
+			PostgreSQLDatabaseOptions: params.PostgreSQLDatabaseOptions{
+				Extensions: []string{"pgcrypto"},
+				Schemas:    []string{"app"},
+				Encoding:   "UTF8",
+				LCCollate:  "C",
+			},
+		})
+		assert.NoError(t, err)
+		if record == nil {
+			t.Fatal("record is nil")
+		}
+		defer s.dialect.deleteBinding(db, record) // nolint
+
+		userDB, err := s.open(s.dialect.buildConnInfo(
+			connInfo.Host(), record.username, record.username, record.password, "", connInfo.Port(), nil,
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer userDB.Close()
+
+		var digest string
+		err = userDB.QueryRow(`select encode(digest('a', 'sha1'), 'hex')`).Scan(&digest)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, digest)
+
+		_, err = userDB.Exec(`create table app.items (id int)`)
+		assert.NoError(t, err)
+
+		var collate string
+		err = userDB.QueryRow(`select datcollate from pg_database where datname = current_database()`).Scan(&collate)
+		assert.NoError(t, err)
+		assert.Equal(t, "C", collate)
+
+		t.Run("locale with the codeset in another spelling", func(t *testing.T) {
+			// pg_collation has "en_US.utf8"
+			record, err := s.dialect.createBinding(db, connInfo, "with-locale", &params.DatabaseBindParameter{
+				PostgreSQLDatabaseOptions: params.PostgreSQLDatabaseOptions{LCCollate: "en_US.UTF-8"},
+			})
+			assert.NoError(t, err)
+			if record != nil {
+				s.dialect.deleteBinding(db, record) // nolint
+			}
+
+			_, err = s.dialect.createBinding(db, connInfo, "unavailable-locale", &params.DatabaseBindParameter{
+				PostgreSQLDatabaseOptions: params.PostgreSQLDatabaseOptions{LCCollate: "xx_XX.UTF-8"},
+			})
+			assert.Error(t, err)
+		})
+
+		t.Run("unavailable extension", func(t *testing.T) {
+			_, err := s.dialect.createBinding(db, connInfo, "unavailable", &params.DatabaseBindParameter{
+				PostgreSQLDatabaseOptions: params.PostgreSQLDatabaseOptions{Extensions: []string{"not_exists"}},
+			})
+			assert.Error(t, err)
+		})
+	})
+
 	t.Run("read binding", func(t *testing.T) {
 		// connect db
 		db, err := s.open(connInfo)
@@ -185,6 +342,62 @@ func TestPostgreSQLHandler_create_read_delete(t *testing.T) {
 		assert.EqualValues(t, createdRecord, record)
 	})
 
+	t.Run("migrate plaintext password", func(t *testing.T) {
+		// connect db
+		db, err := s.open(connInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		_, err = db.Exec(
+			fmt.Sprintf("insert into %s values ($1,$2,$3)", postgreSQLMetaTableName),
+			"plain", "plain", "password",
+		)
+		assert.NoError(t, err)
+		defer db.Exec(fmt.Sprintf("delete from %s where binding_id = $1", postgreSQLMetaTableName), "plain") // nolint
+
+		// simulate the meta table created by older versions
+		_, err = db.Exec(fmt.Sprintf("delete from %s where version >= 2", metaSchemaVersionTableName))
+		assert.NoError(t, err)
+
+		err = s.dialect.migrateMetaTable(db, connInfo)
+		assert.NoError(t, err)
+
+		var stored string
+		err = db.QueryRow(
+			fmt.Sprintf("select password from %s where binding_id = $1", postgreSQLMetaTableName),
+			"plain",
+		).Scan(&stored)
+		assert.NoError(t, err)
+		assert.True(t, isEncryptedPassword(stored))
+
+		record, err := s.dialect.readBinding(db, connInfo, "plain")
+		assert.NoError(t, err)
+		assert.Equal(t, "password", record.password)
+
+		t.Run("rotate the secret", func(t *testing.T) {
+			assert.NoError(t, SetPasswordSecrets(testRotatedPasswordSecret, testPasswordSecret))
+			// other bindings are also encrypted with the rotated secret
+			defer SetPasswordSecrets(testPasswordSecret, testRotatedPasswordSecret) // nolint
+
+			err := s.dialect.migrateMetaTable(db, connInfo)
+			assert.NoError(t, err)
+
+			var stored string
+			err = db.QueryRow(
+				fmt.Sprintf("select password from %s where binding_id = $1", postgreSQLMetaTableName),
+				"plain",
+			).Scan(&stored)
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(stored, currentPasswordPrefix()))
+
+			record, err := s.dialect.readBinding(db, connInfo, "plain")
+			assert.NoError(t, err)
+			assert.Equal(t, "password", record.password)
+		})
+	})
+
 	t.Run("delete binding", func(t *testing.T) {
 		// connect db
 		db, err := s.open(connInfo)
@@ -201,6 +414,10 @@ func TestPostgreSQLHandler_create_read_delete(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Nil(t, record)
 
+		exists, err := s.dialect.existsUser(db, createdRecord.username)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+
 	})
 }
 
@@ -219,6 +436,9 @@ func TestPostgreSQLConnInfo(t *testing.T) {
 }
 
 func initPostgreSQL(queries ...string) func() {
+	if err := SetPasswordSecrets(testPasswordSecret); err != nil {
+		panic(err)
+	}
 
 	cleanup, err := startDocker("postgres:9.6",
 		map[string]string{
@@ -263,3 +483,33 @@ func initPostgreSQL(queries ...string) func() {
 
 	return cleanup
 }
+
+func TestCreatePostgreSQLDatabaseQuery(t *testing.T) {
+	query := createPostgreSQLDatabaseQuery("user", &params.PostgreSQLDatabaseOptions{})
+	assert.Equal(t, `create database "user" owner "user"`, query)
+
+	query = createPostgreSQLDatabaseQuery("user", &params.PostgreSQLDatabaseOptions{
+		Encoding:  "UTF8",
+		LCCollate: "ja_JP.UTF-8",
+		LCCtype:   "ja_JP.UTF-8",
+	})
+	assert.Equal(t,
+		`create database "user" owner "user" template template0 encoding 'UTF8' lc_collate 'ja_JP.UTF-8' lc_ctype 'ja_JP.UTF-8'`,
+		query)
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	expects := map[string]string{
+		"en_US.UTF-8":      "en_US.utf8",
+		"en_US.utf8":       "en_US.utf8",
+		"ja_JP.eucJP":      "ja_JP.eucjp",
+		"de_DE.ISO-8859-1": "de_DE.iso88591",
+		"de_DE.8859-1":     "de_DE.iso88591",
+		"de_DE.UTF-8@euro": "de_DE.utf8@euro",
+		"en_US":            "en_US",
+		"sr_RS@latin":      "sr_RS@latin",
+	}
+	for locale, expect := range expects {
+		assert.Equal(t, expect, normalizeLocale(locale), locale)
+	}
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/util/metrics"
+)
+
+const (
+	reconcileStateOrphaned       = "orphaned"
+	reconcileStateMissing        = "missing"
+	reconcileStateDrifted        = "drifted"
+	reconcileStateMissingBinding = "missing_binding"
+)
+
+var (
+	reconcileResources = metrics.NewGauge(
+		"osbs_reconcile_resources",
+		"Number of resources per state detected by the reconciler",
+		"service", "state",
+	)
+	reconcileDrift = metrics.NewGauge(
+		"osbs_reconcile_drift",
+		"Parameters of instances which differ from the requested values",
+		"service", "instance_id", "attribute",
+	)
+	reconcileLastRun = metrics.NewGauge(
+		"osbs_reconcile_last_run_timestamp_seconds",
+		"Unix time of the last reconciliation",
+	)
+)
+
+// ReconcileReport represents the result of Reconcile
+type ReconcileReport struct {
+	Orphans         []*ReconcileItem
+	Missing         []*ReconcileItem
+	Drifts          []*ReconcileItem
+	MissingBindings []*ReconcileItem
+}
+
+// ReconcileItem represents a resource detected by Reconcile
+type ReconcileItem struct {
+	ServiceName string
+	InstanceID  string
+	BindingID   string
+	ResourceID  string
+	Reason      string
+	Attributes  []string
+	Remediated  bool
+}
+
+func (i *ReconcileItem) logFields() log.Fields {
+	fields := log.Fields{
+		"service":    i.ServiceName,
+		"instanceID": i.InstanceID,
+	}
+	if i.BindingID != "" {
+		fields["bindingID"] = i.BindingID
+	}
+	if i.ResourceID != "" {
+		fields["resourceID"] = i.ResourceID
+	}
+	if i.Reason != "" {
+		fields["reason"] = i.Reason
+	}
+	if len(i.Attributes) > 0 {
+		fields["attributes"] = strings.Join(i.Attributes, ",")
+	}
+	if i.Remediated {
+		fields["remediated"] = true
+	}
+	return fields
+}
+
+type reconcileTarget struct {
+	service *osb.Service
+	planIDs map[int]string
+	dialect databaseFuncs
+}
+
+var reconcileTargets = []*reconcileTarget{
+	{service: MariaDBService, planIDs: DatabaseIDMap["MariaDB"].PlanIDMap, dialect: &mariaDBHandler{}},
+	{service: PostgreSQLService, planIDs: DatabaseIDMap["postgres"].PlanIDMap, dialect: &postgreSQLHandler{}},
+}
+
+// planName returns the name of the catalog plan of the database
+func (t *reconcileTarget) planName(db *sacloud.Database) string {
+	if db.Remark == nil {
+		return ""
+	}
+	planID, ok := t.planIDs[int(db.Remark.GetPlanID())]
+	if !ok {
+		return ""
+	}
+	for _, plan := range t.service.Plans {
+		if plan.ID == planID {
+			return plan.Name
+		}
+	}
+	return ""
+}
+
+type reconcileOptions struct {
+	remediateOrphans  bool
+	remediateBindings bool
+}
+
+// Reconcile compares resources tagged by the broker on SAKURA Cloud with
+// instances and bindings known to the broker, and reports the differences.
+// Resources which aren't known to the broker are reported only if known instances are loaded from the file.
+// If remediate is true, orphaned resources and broken binding records are deleted.
+// Parameter drift and bindings are reconciled only for databases.
+func Reconcile(remediate bool) (*ReconcileReport, error) {
+	return reconcile(&reconcileOptions{
+		remediateOrphans:  remediate,
+		remediateBindings: remediate,
+	})
+}
+
+func reconcile(opts *reconcileOptions) (*ReconcileReport, error) {
+	report := &ReconcileReport{}
+	reconcileDrift.Reset()
+
+	invs := inventories()
+	for _, inv := range invs {
+		if err := reconcileInventory(inv, report, opts); err != nil {
+			return nil, fmt.Errorf("reconciling %q is failed: %s", inv.service.Name, err)
+		}
+	}
+
+	reconcileResources.Reset()
+	for _, inv := range invs {
+		for state, items := range map[string][]*ReconcileItem{
+			reconcileStateOrphaned:       report.Orphans,
+			reconcileStateMissing:        report.Missing,
+			reconcileStateDrifted:        report.Drifts,
+			reconcileStateMissingBinding: report.MissingBindings,
+		} {
+			count := 0
+			for _, item := range items {
+				if item.ServiceName == inv.service.Name {
+					count++
+				}
+			}
+			reconcileResources.Set(float64(count), inv.service.Name, state)
+		}
+	}
+	reconcileLastRun.Set(float64(time.Now().Unix()))
+
+	return report, nil
+}
+
+func reconcileInventory(inv *inventory, report *ReconcileReport, opts *reconcileOptions) error {
+	resources, err := inv.list()
+	if err != nil {
+		return err
+	}
+
+	exists := make(map[string]bool)
+	for _, r := range resources {
+		exists[r.instanceID] = true
+		reconcileResource(inv, r, report, opts)
+	}
+
+	// instances which the broker knows but are not found on SAKURA Cloud
+	ids := knownInstances.list(inv.service.ID)
+	sort.Strings(ids)
+	for _, instanceID := range ids {
+		instance, _ := knownInstances.get(instanceID)
+		if exists[instanceID] || instance.deleting {
+			continue
+		}
+		item := &ReconcileItem{
+			ServiceName: inv.service.Name,
+			InstanceID:  instanceID,
+			Reason:      "resource is not found on SAKURA Cloud",
+		}
+		log.WithFields(item.logFields()).Warn("reconcile: missing resource detected")
+		report.Missing = append(report.Missing, item)
+	}
+	return nil
+}
+
+func reconcileResource(inv *inventory, r *managedResource, report *ReconcileReport, opts *reconcileOptions) {
+	instanceID := r.instanceID
+	instance, known := knownInstances.get(instanceID)
+
+	var reason string
+	remediable := true
+	switch {
+	case r.hasTag(iaas.DeletingMarkerTag) && !(known && instance.deleting) && (r.up || r.failed):
+		reason = "deletion was not completed"
+	case r.failed:
+		reason = "resource is failed"
+	case !known && knownInstances.isLoaded() &&
+		!r.hasTag(iaas.DeletingMarkerTag) && !r.hasTag(iaas.ProvisioningMarkerTag):
+		// provisionings in progress are cleaned up by orphan mitigation.
+		// Healthy resources may be in use, so they are only reported
+		reason = "instance is not known to the broker"
+		remediable = false
+	}
+	if reason != "" {
+		item := &ReconcileItem{
+			ServiceName: inv.service.Name,
+			InstanceID:  instanceID,
+			ResourceID:  r.resourceID,
+			Reason:      reason,
+		}
+		if opts.remediateOrphans && remediable {
+			if err := inv.delete(instanceID); err != nil {
+				fields := item.logFields()
+				fields["err"] = err
+				log.WithFields(fields).Error("reconcile: deleting orphaned resource is failed")
+			} else {
+				knownInstances.markDeleting(instanceID)
+				item.Remediated = true
+			}
+		}
+		log.WithFields(item.logFields()).Warn("reconcile: orphaned resource detected")
+		report.Orphans = append(report.Orphans, item)
+		return
+	}
+
+	if inv.target != nil {
+		reconcileDatabase(inv.target, r.resource.(*sacloud.Database), report, opts)
+	}
+}
+
+// reconcileDatabase reports drift of parameters and broken bindings of the database
+func reconcileDatabase(target *reconcileTarget, db *sacloud.Database, report *ReconcileReport, opts *reconcileOptions) {
+	instanceID := db.Name
+
+	if !db.IsUp() || db.HasTag(iaas.DeletingMarkerTag) {
+		return
+	}
+
+	desired, err := iaas.DesiredDatabaseParameter(db)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"service":    target.service.Name,
+			"instanceID": instanceID,
+			"err":        err,
+		}).Warn("reconcile: reading requested parameter is failed")
+	}
+	if desired != nil {
+		if drift := databaseDrift(db, desired); len(drift) > 0 {
+			item := &ReconcileItem{
+				ServiceName: target.service.Name,
+				InstanceID:  instanceID,
+				ResourceID:  db.GetStrID(),
+				Attributes:  drift,
+			}
+			for _, attr := range drift {
+				reconcileDrift.Set(1, target.service.Name, instanceID, attr)
+			}
+			log.WithFields(item.logFields()).Warn("reconcile: parameter drift detected")
+			report.Drifts = append(report.Drifts, item)
+		}
+	}
+
+	reconcileBindings(target, db, report, opts.remediateBindings)
+}
+
+func reconcileBindings(target *reconcileTarget, database *sacloud.Database, report *ReconcileReport, remediate bool) {
+	instanceID := database.Name
+	logFields := log.Fields{
+		"service":    target.service.Name,
+		"instanceID": instanceID,
+	}
+
+	connInfo := adminConnInfo(target.dialect, database)
+	db, err := openDatabase(connInfo)
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Warn("reconcile: connecting to database is failed")
+		return
+	}
+	defer db.Close() // nolint
+
+	exists, err := metaTableReady(target.dialect, db, connInfo)
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Warn("reconcile: reading meta table is failed")
+		return
+	}
+	if !exists {
+		return
+	}
+
+	records, err := target.dialect.listBindings(db, connInfo)
+	if err != nil {
+		logFields["err"] = err
+		log.WithFields(logFields).Warn("reconcile: reading meta table is failed")
+		return
+	}
+
+	for _, record := range records {
+		found, err := target.dialect.existsUserDatabase(db, record.username)
+		if err != nil {
+			logFields["err"] = err
+			log.WithFields(logFields).Warn("reconcile: reading user database is failed")
+			return
+		}
+		if found {
+			continue
+		}
+
+		item := &ReconcileItem{
+			ServiceName: target.service.Name,
+			InstanceID:  instanceID,
+			BindingID:   record.bindingID,
+			Reason:      fmt.Sprintf("user database %q is not found", record.username),
+		}
+		if remediate {
+			if err := target.dialect.deleteBinding(db, record); err != nil {
+				fields := item.logFields()
+				fields["err"] = err
+				log.WithFields(fields).Error("reconcile: deleting binding record is failed")
+			} else {
+				item.Remediated = true
+			}
+		}
+		log.WithFields(item.logFields()).Warn("reconcile: missing binding resource detected")
+		report.MissingBindings = append(report.MissingBindings, item)
+	}
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/stretchr/testify/assert"
+)
+
+func reconcileTestInstance(instanceID string, up bool) sacloud.Database {
+	db := mariaDB10GInstance(instanceID)
+	db.Resource = sacloud.NewResource(123456789012)
+	db.Availability = sacloud.EAAvailable
+	if up {
+		db.Instance = &sacloud.Instance{
+			EServerInstanceStatus: &sacloud.EServerInstanceStatus{Status: "up"},
+		}
+	}
+	desired, _ := json.Marshal(&params.DatabaseCreateParameter{
+		SwitchID:     int64(mariaDBTestSwitchID),
+		IPAddress:    "192.2.0.10",
+		MaskLen:      24,
+		DefaultRoute: "192.2.0.1",
+		PlanID:       10,
+	})
+	db.Description = string(desired)
+	return *db
+}
+
+func TestReconcile(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	orgKnownInstances := knownInstances
+	knownInstances = newInstanceRegistry()
+	defer func() { knownInstances = orgKnownInstances }()
+
+	testDialect := &dummyDBFuncs{}
+	dbAPI := &genericDBDummyAPI{}
+	sacloudAPI = &dummyAPI{dbAPI: dbAPI}
+	defer func() { sacloudAPI = testAPI }()
+
+	orgTargets := reconcileTargets
+	reconcileTargets = []*reconcileTarget{
+		{service: MariaDBService, dialect: testDialect},
+	}
+	defer func() { reconcileTargets = orgTargets }()
+
+	orgInventories := resourceInventories
+	resourceInventories = nil
+	defer func() { resourceInventories = orgInventories }()
+
+	t.Run("In sync", func(t *testing.T) {
+		defer testDialect.init()
+		dbAPI.listResult = []sacloud.Database{reconcileTestInstance("in-sync", true)}
+		knownInstances.add("in-sync", MariaDBServiceID)
+		defer knownInstances.remove("in-sync")
+
+		report, err := Reconcile(false)
+		assert.NoError(t, err)
+		assert.Empty(t, report.Orphans)
+		assert.Empty(t, report.Missing)
+		assert.Empty(t, report.Drifts)
+		assert.Empty(t, report.MissingBindings)
+
+		v, ok := reconcileResources.Get(MariaDBService.Name, reconcileStateOrphaned)
+		assert.True(t, ok)
+		assert.Equal(t, float64(0), v)
+	})
+
+	t.Run("Orphaned resources", func(t *testing.T) {
+		defer testDialect.init()
+		failed := reconcileTestInstance("failed", false)
+		failed.Availability = sacloud.EAFailed
+		deleting := reconcileTestInstance("deleting", true)
+		deleting.AppendTag(iaas.DeletingMarkerTag)
+		dbAPI.listResult = []sacloud.Database{failed, deleting}
+
+		report, err := Reconcile(false)
+		assert.NoError(t, err)
+		assert.Len(t, report.Orphans, 2)
+		for _, item := range report.Orphans {
+			assert.False(t, item.Remediated)
+		}
+
+		v, _ := reconcileResources.Get(MariaDBService.Name, reconcileStateOrphaned)
+		assert.Equal(t, float64(2), v)
+
+		t.Run("with remediation", func(t *testing.T) {
+			report, err := Reconcile(true)
+			assert.NoError(t, err)
+			assert.Len(t, report.Orphans, 2)
+			for _, item := range report.Orphans {
+				assert.True(t, item.Remediated)
+			}
+		})
+	})
+
+	t.Run("Deletion in progress", func(t *testing.T) {
+		defer testDialect.init()
+		deleting := reconcileTestInstance("deleting", true)
+		deleting.AppendTag(iaas.DeletingMarkerTag)
+		dbAPI.listResult = []sacloud.Database{deleting}
+		knownInstances.add("deleting", MariaDBServiceID)
+		knownInstances.markDeleting("deleting")
+		defer knownInstances.remove("deleting")
+
+		report, err := Reconcile(false)
+		assert.NoError(t, err)
+		assert.Empty(t, report.Orphans)
+	})
+
+	t.Run("Missing resources", func(t *testing.T) {
+		defer testDialect.init()
+		dbAPI.listResult = []sacloud.Database{}
+		knownInstances.add("missing", MariaDBServiceID)
+		defer knownInstances.remove("missing")
+
+		report, err := Reconcile(false)
+		assert.NoError(t, err)
+		assert.Len(t, report.Missing, 1)
+		assert.Equal(t, "missing", report.Missing[0].InstanceID)
+	})
+
+	t.Run("Parameter drift", func(t *testing.T) {
+		defer testDialect.init()
+		drifted := reconcileTestInstance("drifted", true)
+		drifted.Remark.Switch.ID = "999"
+		drifted.Settings.DBConf.Common.SourceNetwork = []string{"192.2.0.0/24"}
+		dbAPI.listResult = []sacloud.Database{drifted}
+
+		report, err := Reconcile(false)
+		assert.NoError(t, err)
+		assert.Len(t, report.Drifts, 1)
+		assert.Equal(t, []string{"allowNetworks", "switchID"}, report.Drifts[0].Attributes)
+
+		v, ok := reconcileDrift.Get(MariaDBService.Name, "drifted", "switchID")
+		assert.True(t, ok)
+		assert.Equal(t, float64(1), v)
+	})
+
+	t.Run("Missing binding resources", func(t *testing.T) {
+		defer testDialect.init()
+		dbAPI.listResult = []sacloud.Database{reconcileTestInstance("bound", true)}
+		testDialect.existsMetaTableResult = true
+		testDialect.listBindingsResult = []*databaseBindingRecord{
+			{bindingID: bindingID, username: "user", password: "pass"},
+		}
+		testDialect.existsUserDBResult = false
+
+		report, err := Reconcile(true)
+		assert.NoError(t, err)
+		assert.Len(t, report.MissingBindings, 1)
+		assert.Equal(t, bindingID, report.MissingBindings[0].BindingID)
+		assert.True(t, report.MissingBindings[0].Remediated)
+	})
+
+	t.Run("Unknown resources", func(t *testing.T) {
+		defer testDialect.init()
+		provisioning := reconcileTestInstance("provisioning", true)
+		provisioning.AppendTag(iaas.ProvisioningMarkerTag)
+		dbAPI.listResult = []sacloud.Database{reconcileTestInstance("unknown", true), provisioning}
+
+		// all instances may be unknown after broker restart without the file
+		report, err := Reconcile(true)
+		assert.NoError(t, err)
+		assert.Empty(t, report.Orphans)
+
+		knownInstances.loaded = true
+		defer func() { knownInstances.loaded = false }()
+
+		report, err = Reconcile(true)
+		assert.NoError(t, err)
+		assert.Len(t, report.Orphans, 1)
+		assert.Equal(t, "unknown", report.Orphans[0].InstanceID)
+		// healthy resources may be in use, so they aren't deleted
+		assert.False(t, report.Orphans[0].Remediated)
+	})
+
+	t.Run("Resources of other services", func(t *testing.T) {
+		defer testDialect.init()
+		dbAPI.listResult = nil
+
+		sw := testSwitch(t, "failed-switch", &params.SwitchCreateParameter{Subnet: "192.2.0.0/24"})
+		sw.AppendTag(iaas.SwitchFailedMarkerTag)
+		switchAPI := &dummySwitchAPI{readResult: sw}
+		sacloudAPI = &dummyAPI{dbAPI: dbAPI, switchAPI: switchAPI}
+		defer func() { sacloudAPI = &dummyAPI{dbAPI: dbAPI} }()
+
+		for _, inv := range orgInventories {
+			if inv.service == SwitchService {
+				resourceInventories = []*inventory{inv}
+			}
+		}
+		defer func() { resourceInventories = nil }()
+
+		knownInstances.add("missing-switch", SwitchServiceID)
+		defer knownInstances.remove("missing-switch")
+
+		report, err := Reconcile(true)
+		assert.NoError(t, err)
+		assert.Len(t, report.Orphans, 1)
+		assert.Equal(t, SwitchService.Name, report.Orphans[0].ServiceName)
+		assert.True(t, report.Orphans[0].Remediated)
+		assert.True(t, switchAPI.deleted)
+
+		assert.Len(t, report.Missing, 1)
+		assert.Equal(t, "missing-switch", report.Missing[0].InstanceID)
+
+		v, _ := reconcileResources.Get(SwitchService.Name, reconcileStateOrphaned)
+		assert.Equal(t, float64(1), v)
+	})
+}
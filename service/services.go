@@ -26,6 +26,18 @@ func factory(operation, serviceID, planID string, rawParameter []byte) Handler {
 		return newMariaDBServiceHandler(operation, serviceID, planID, rawParameter)
 	case PostgreSQLServiceID:
 		return newPostgreSQLServiceHandler(operation, serviceID, planID, rawParameter)
+	case NFSServiceID:
+		return newNFSServiceHandler(operation, serviceID, planID, rawParameter)
+	case DNSServiceID:
+		return newDNSServiceHandler(operation, serviceID, planID, rawParameter)
+	case SimpleMonitorServiceID:
+		return newSimpleMonitorServiceHandler(operation, serviceID, planID, rawParameter)
+	case SwitchServiceID:
+		return newSwitchServiceHandler(operation, serviceID, planID, rawParameter)
+	case VPCRouterServiceID:
+		return newVPCRouterServiceHandler(operation, serviceID, planID, rawParameter)
+	case GSLBServiceID:
+		return newGSLBServiceHandler(operation, serviceID, planID, rawParameter)
 	default:
 		return nil
 	}
@@ -52,3 +64,14 @@ func Initialize(client iaas.Client) error {
 
 	return nil
 }
+
+// ResumeOperations resumes background operations which were stopped by the previous broker process.
+// It's called on startup of the broker, not admin commands
+func ResumeOperations() {
+	if err := sacloudAPI.Switch().ResumeConnecting(); err != nil {
+		log.WithField("err", err).Error("resuming connections of switches to VPC routers is failed")
+	}
+	if err := sacloudAPI.VPCRouter().ResumeConfiguring(); err != nil {
+		log.WithField("err", err).Error("resuming configurations of VPC routers is failed")
+	}
+}
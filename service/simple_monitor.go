@@ -0,0 +1,289 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sacloud/libsacloud/api"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/sacloud/open-service-broker-sacloud/util/cmp"
+)
+
+// simpleMonitorAttrs implements InstanceState interface. Simple monitors are available as soon as they are created
+type simpleMonitorAttrs struct {
+	*sacloud.SimpleMonitor
+	instanceID string
+	parameter  *params.SimpleMonitorParameter
+}
+
+func (a *simpleMonitorAttrs) IsUp() bool {
+	return true
+}
+
+func (a *simpleMonitorAttrs) IsFailed() bool {
+	return false
+}
+
+func (a *simpleMonitorAttrs) IsMigrating() bool {
+	return false
+}
+
+func (a *simpleMonitorAttrs) HasDiff() bool {
+	if a.parameter == nil {
+		return false
+	}
+	return *actualSimpleMonitorParameter(a.SimpleMonitor) != *a.parameter
+}
+
+// StatusDescription returns the latest health status of the target
+func (a *simpleMonitorAttrs) StatusDescription() string {
+	health, err := sacloudAPI.SimpleMonitor().Health(a.instanceID)
+	if err != nil {
+		return fmt.Sprintf("health: %s", iaas.SimpleMonitorHealthUnknown)
+	}
+	return simpleMonitorHealthDescription(health)
+}
+
+func simpleMonitorHealthDescription(health *iaas.SimpleMonitorHealth) string {
+	desc := fmt.Sprintf("health: %s", health.Status)
+	if health.ResponseTimeSec != nil {
+		desc += fmt.Sprintf(" (response time: %.3fs)", *health.ResponseTimeSec)
+	}
+	if health.LastCheckedAt != nil {
+		desc += fmt.Sprintf(", last checked at %s", health.LastCheckedAt.Format(time.RFC3339))
+	}
+	return desc
+}
+
+// actualSimpleMonitorParameter returns the parameter which values are read from settings of the simple monitor
+func actualSimpleMonitorParameter(m *sacloud.SimpleMonitor) *params.SimpleMonitorParameter {
+	p := &params.SimpleMonitorParameter{}
+	if m.Status != nil {
+		p.Target = m.Status.Target
+	}
+	if m.Settings == nil || m.Settings.SimpleMonitor == nil {
+		return p
+	}
+	s := m.Settings.SimpleMonitor
+
+	p.Interval = s.DelayLoop
+	if hc := s.HealthCheck; hc != nil {
+		p.Protocol = hc.Protocol
+		p.Port, _ = strconv.Atoi(hc.Port)
+		p.Path = hc.Path
+		p.HostHeader = hc.Host
+		p.ExpectedStatus, _ = strconv.Atoi(hc.Status)
+	}
+	if s.NotifyEmail != nil {
+		p.NotifyEmail = s.NotifyEmail.Enabled == "True"
+	}
+	if s.NotifySlack != nil && s.NotifySlack.Enabled == "True" {
+		p.WebhookURL = s.NotifySlack.IncomingWebhooksURL
+	}
+	return p
+}
+
+// simpleMonitorDrift returns names of the parameters which differ from the actual simple monitor
+func simpleMonitorDrift(m *sacloud.SimpleMonitor, p *params.SimpleMonitorParameter) []string {
+	actual := actualSimpleMonitorParameter(m)
+
+	values := map[string]cmp.CompareValue{
+		"target":         {X: p.Target, Y: actual.Target},
+		"protocol":       {X: p.Protocol, Y: actual.Protocol},
+		"port":           {X: p.Port, Y: actual.Port},
+		"path":           {X: p.Path, Y: actual.Path},
+		"hostHeader":     {X: p.HostHeader, Y: actual.HostHeader},
+		"expectedStatus": {X: p.ExpectedStatus, Y: actual.ExpectedStatus},
+		"interval":       {X: p.Interval, Y: actual.Interval},
+		"notifyEmail":    {X: p.NotifyEmail, Y: actual.NotifyEmail},
+		"webhookURL":     {X: p.WebhookURL, Y: actual.WebhookURL},
+	}
+
+	var drift []string
+	for name, v := range values {
+		if !cmp.Equal(v) {
+			drift = append(drift, name)
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+// simpleMonitorInstanceParameters represents parameters of fetched instances with the health status.
+// The webhook URL is a secret, so only whether it is set is reported
+type simpleMonitorInstanceParameters struct {
+	*params.SimpleMonitorParameter
+	NotifySlack bool                      `json:"notifySlack"`
+	Health      *iaas.SimpleMonitorHealth `json:"health,omitempty"`
+}
+
+// fetchSimpleMonitor returns the instance with parameters of the actual simple monitor except the webhook URL.
+// It returns nil if the instance is not found
+func fetchSimpleMonitor(instanceID string) (*osb.ServiceInstanceResource, error) {
+	client := sacloudAPI.SimpleMonitor()
+	m, err := client.Read(instanceID)
+	if err != nil {
+		if e, ok := err.(api.Error); ok && e.ResponseCode() == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	health, err := client.Health(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("reading health status is failed: %s", err)
+	}
+
+	p := actualSimpleMonitorParameter(m)
+	notifySlack := p.WebhookURL != ""
+	p.WebhookURL = ""
+	return &osb.ServiceInstanceResource{
+		ServiceID: SimpleMonitorServiceID,
+		PlanID:    SimpleMonitorPlanDefaultID,
+		Parameters: &simpleMonitorInstanceParameters{
+			SimpleMonitorParameter: p,
+			NotifySlack:            notifySlack,
+			Health:                 health,
+		},
+	}, nil
+}
+
+type simpleMonitorHandler struct {
+	operation    string
+	serviceID    string
+	planID       string
+	rawParameter []byte
+
+	parameter *params.SimpleMonitorParameter
+	paramErr  error
+}
+
+func newSimpleMonitorServiceHandler(operation, serviceID, planID string, rawParameter []byte) *simpleMonitorHandler {
+	handler := &simpleMonitorHandler{
+		operation:    operation,
+		serviceID:    serviceID,
+		planID:       planID,
+		rawParameter: rawParameter,
+	}
+
+	switch operation {
+	case operations.Provisioning, operations.Updating:
+		// updating takes all parameters because they replace all settings
+		if len(rawParameter) == 0 {
+			handler.paramErr = errors.New("simpleMonitorService parameter JSON is empty")
+			return handler
+		}
+
+		var p = params.SimpleMonitorParameter{}
+		err := json.Unmarshal(rawParameter, &p)
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		err = p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+		p.SetDefaults()
+
+		handler.parameter = &p
+	case operations.Binding:
+		handler.paramErr = errors.New("simpleMonitorService is not bindable")
+	}
+
+	return handler
+}
+
+func (s *simpleMonitorHandler) InstanceState(instanceID string) (InstanceState, error) {
+	m, err := sacloudAPI.SimpleMonitor().Read(instanceID)
+	if err != nil {
+		if e, ok := err.(api.Error); ok {
+			if e.ResponseCode() != http.StatusNotFound {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	if m == nil {
+		if s.operation == operations.Deprovisioning {
+			knownInstances.remove(instanceID)
+		}
+		return nil, nil
+	}
+	knownInstances.add(instanceID, s.serviceID)
+
+	// drift is detected only against the requested parameter on provisioning
+	var parameter *params.SimpleMonitorParameter
+	if s.operation == operations.Provisioning {
+		parameter = s.parameter
+	}
+
+	return &simpleMonitorAttrs{
+		SimpleMonitor: m,
+		instanceID:    instanceID,
+		parameter:     parameter,
+	}, nil
+}
+
+// BindingState always returns nil because simple monitors are not bindable
+func (s *simpleMonitorHandler) BindingState(instanceID, bindingID string) (BindingState, error) {
+	return nil, nil
+}
+
+func (s *simpleMonitorHandler) CreateInstance(instanceID string) error {
+	_, err := sacloudAPI.SimpleMonitor().Create(instanceID, s.parameter)
+	if err != nil {
+		return err
+	}
+	knownInstances.add(instanceID, s.serviceID)
+	return nil
+}
+
+func (s *simpleMonitorHandler) UpdateInstance(instanceID string) error {
+	if s.parameter == nil {
+		return errors.New("update parameter is nil")
+	}
+	_, err := sacloudAPI.SimpleMonitor().Update(instanceID, s.parameter)
+	if err != nil {
+		return fmt.Errorf("updating simple monitor is failed: %s", err)
+	}
+	return nil
+}
+
+func (s *simpleMonitorHandler) DeleteInstance(instanceID string) error {
+	err := sacloudAPI.SimpleMonitor().Delete(instanceID)
+	if err != nil {
+		return err
+	}
+	knownInstances.markDeleting(instanceID)
+	return nil
+}
+
+func (s *simpleMonitorHandler) CreateBinding(instanceID, bindingID string) (*osb.ServiceBinding, error) {
+	return nil, errors.New("simpleMonitorService is not bindable")
+}
+
+func (s *simpleMonitorHandler) DeleteBinding(instanceID, bindingID string) error {
+	return errors.New("simpleMonitorService is not bindable")
+}
+
+func (s *simpleMonitorHandler) ConfirmProvision(instanceID string) error {
+	return sacloudAPI.SimpleMonitor().ConfirmProvisioned(instanceID)
+}
+
+func (s *simpleMonitorHandler) IsValid() (bool, error) {
+	return s.paramErr == nil, s.paramErr
+}
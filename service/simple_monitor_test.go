@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/stretchr/testify/assert"
+)
+
+type dummySimpleMonitorAPI struct {
+	readResult *sacloud.SimpleMonitor
+	health     *iaas.SimpleMonitorHealth
+	readErr    error
+	createErr  error
+	updateErr  error
+	healthErr  error
+	deleteErr  error
+
+	created *params.SimpleMonitorParameter
+	updated *params.SimpleMonitorParameter
+}
+
+func (c *dummySimpleMonitorAPI) List() ([]sacloud.SimpleMonitor, error) {
+	if c.readResult == nil {
+		return nil, c.readErr
+	}
+	return []sacloud.SimpleMonitor{*c.readResult}, c.readErr
+}
+
+func (c *dummySimpleMonitorAPI) Read(instanceID string) (*sacloud.SimpleMonitor, error) {
+	return c.readResult, c.readErr
+}
+
+func (c *dummySimpleMonitorAPI) Create(instanceID string, param *params.SimpleMonitorParameter) (*sacloud.SimpleMonitor, error) {
+	c.created = param
+	return c.readResult, c.createErr
+}
+
+func (c *dummySimpleMonitorAPI) Update(instanceID string, param *params.SimpleMonitorParameter) (*sacloud.SimpleMonitor, error) {
+	c.updated = param
+	return c.readResult, c.updateErr
+}
+
+func (c *dummySimpleMonitorAPI) Health(instanceID string) (*iaas.SimpleMonitorHealth, error) {
+	return c.health, c.healthErr
+}
+
+func (c *dummySimpleMonitorAPI) ConfirmProvisioned(instanceID string) error {
+	return nil
+}
+
+func (c *dummySimpleMonitorAPI) Delete(instanceID string) error {
+	return c.deleteErr
+}
+
+func testSimpleMonitor() *sacloud.SimpleMonitor {
+	m := sacloud.CreateNewSimpleMonitor("www.example.com")
+	m.SetHealthCheckHTTPS("", "/healthz", "200", "")
+	m.SetDelayLoop(60)
+	m.EnableNotifyEmail(false)
+	return m
+}
+
+func TestSimpleMonitorServiceValidate(t *testing.T) {
+	valid := []byte(`{"target": "www.example.com", "protocol": "https", "path": "/healthz"}`)
+
+	for _, operation := range []string{operations.Provisioning, operations.Updating} {
+		t.Run(operation, func(t *testing.T) {
+			s := newSimpleMonitorServiceHandler(operation, SimpleMonitorServiceID, SimpleMonitorPlanDefaultID, valid)
+			_, err := s.IsValid()
+			assert.NoError(t, err)
+			assert.Equal(t, params.SimpleMonitorDefaultInterval, s.parameter.Interval)
+
+			s = newSimpleMonitorServiceHandler(operation, SimpleMonitorServiceID, SimpleMonitorPlanDefaultID, []byte(``))
+			_, err = s.IsValid()
+			assert.Error(t, err)
+
+			s = newSimpleMonitorServiceHandler(operation, SimpleMonitorServiceID, SimpleMonitorPlanDefaultID, []byte(`{"target": "www.example.com"}`))
+			_, err = s.IsValid()
+			assert.Error(t, err)
+		})
+	}
+
+	s := newSimpleMonitorServiceHandler(operations.Binding, SimpleMonitorServiceID, SimpleMonitorPlanDefaultID, []byte(`{}`))
+	_, err := s.IsValid()
+	assert.Error(t, err)
+}
+
+func TestSimpleMonitorHandler(t *testing.T) {
+	monitorAPI := &dummySimpleMonitorAPI{readResult: testSimpleMonitor()}
+	sacloudAPI = &dummyAPI{simpleMonitorAPI: monitorAPI}
+	defer func() { sacloudAPI = testAPI }()
+
+	t.Run("InstanceState", func(t *testing.T) {
+		s := newSimpleMonitorServiceHandler(operations.Provisioning, SimpleMonitorServiceID, SimpleMonitorPlanDefaultID,
+			[]byte(`{"target": "www.example.com", "protocol": "https", "path": "/healthz", "expectedStatus": 200, "notifyEmail": true}`))
+		state, err := s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.True(t, state.IsUp())
+		assert.False(t, state.HasDiff())
+
+		s = newSimpleMonitorServiceHandler(operations.Provisioning, SimpleMonitorServiceID, SimpleMonitorPlanDefaultID,
+			[]byte(`{"target": "www.example.com", "protocol": "https", "path": "/", "expectedStatus": 200, "notifyEmail": true}`))
+		state, err = s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.True(t, state.HasDiff())
+
+		// updating replaces settings, so it's not a conflict
+		s = newSimpleMonitorServiceHandler(operations.Updating, SimpleMonitorServiceID, SimpleMonitorPlanDefaultID,
+			[]byte(`{"target": "www.example.com", "protocol": "ping"}`))
+		state, err = s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.False(t, state.HasDiff())
+	})
+
+	t.Run("StatusDescription", func(t *testing.T) {
+		s := newSimpleMonitorServiceHandler(operations.Provisioning, SimpleMonitorServiceID, SimpleMonitorPlanDefaultID, []byte(``))
+		state, err := s.InstanceState(instanceID)
+		assert.NoError(t, err)
+
+		checked := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+		responseTime := 0.25
+		monitorAPI.health = &iaas.SimpleMonitorHealth{
+			Status:          iaas.SimpleMonitorHealthUp,
+			LastCheckedAt:   &checked,
+			ResponseTimeSec: &responseTime,
+		}
+		assert.Equal(t, "health: up (response time: 0.250s), last checked at 2018-04-01T12:00:00Z",
+			state.(StatusDescriber).StatusDescription())
+
+		monitorAPI.healthErr = errors.New("dummy")
+		defer func() { monitorAPI.healthErr = nil }()
+		assert.Equal(t, "health: unknown", state.(StatusDescriber).StatusDescription())
+	})
+
+	t.Run("UpdateInstance", func(t *testing.T) {
+		s := newSimpleMonitorServiceHandler(operations.Updating, SimpleMonitorServiceID, SimpleMonitorPlanDefaultID,
+			[]byte(`{"target": "192.0.2.1", "protocol": "tcp", "port": 22, "interval": 300}`))
+		assert.NoError(t, s.UpdateInstance(instanceID))
+		assert.Equal(t, &params.SimpleMonitorParameter{Target: "192.0.2.1", Protocol: "tcp", Port: 22, Interval: 300}, monitorAPI.updated)
+	})
+
+	t.Run("FetchInstance", func(t *testing.T) {
+		monitorAPI.health = &iaas.SimpleMonitorHealth{Status: iaas.SimpleMonitorHealthDown}
+		instance, err := fetchSimpleMonitor(instanceID)
+		assert.NoError(t, err)
+		assert.Equal(t, SimpleMonitorServiceID, instance.ServiceID)
+		assert.Equal(t, SimpleMonitorPlanDefaultID, instance.PlanID)
+
+		p := instance.Parameters.(*simpleMonitorInstanceParameters)
+		assert.Equal(t, "/healthz", p.Path)
+		assert.Equal(t, 200, p.ExpectedStatus)
+		assert.Equal(t, iaas.SimpleMonitorHealthDown, p.Health.Status)
+		assert.False(t, p.NotifySlack)
+
+		monitorAPI.readResult.EnableNofitySlack("https://hooks.slack.com/services/xxx")
+		defer monitorAPI.readResult.DisableNotifySlack()
+		instance, err = fetchSimpleMonitor(instanceID)
+		assert.NoError(t, err)
+		p = instance.Parameters.(*simpleMonitorInstanceParameters)
+		assert.True(t, p.NotifySlack)
+		assert.Empty(t, p.WebhookURL)
+
+		monitorAPI.readErr = apiError404
+		defer func() { monitorAPI.readErr = nil }()
+		instance, err = fetchSimpleMonitor(instanceID)
+		assert.NoError(t, err)
+		assert.Nil(t, instance)
+	})
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sacloud/libsacloud/api"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/sacloud/open-service-broker-sacloud/util/cmp"
+)
+
+// switchAttrs implements InstanceState interface.
+// Switches are migrating while they are connected to VPC routers
+type switchAttrs struct {
+	*sacloud.Switch
+	parameter *params.SwitchCreateParameter
+}
+
+func (a *switchAttrs) IsUp() bool {
+	return !a.HasTag(iaas.SwitchConnectingMarkerTag) &&
+		!a.HasTag(iaas.SwitchFailedMarkerTag) &&
+		!a.HasTag(iaas.DeletingMarkerTag)
+}
+
+func (a *switchAttrs) IsFailed() bool {
+	return a.HasTag(iaas.SwitchFailedMarkerTag) && !a.HasTag(iaas.DeletingMarkerTag)
+}
+
+func (a *switchAttrs) IsMigrating() bool {
+	return a.HasTag(iaas.SwitchConnectingMarkerTag)
+}
+
+func (a *switchAttrs) IsDeleting() bool {
+	return a.HasTag(iaas.DeletingMarkerTag)
+}
+
+func (a *switchAttrs) HasDiff() bool {
+	if a.parameter == nil {
+		return false
+	}
+	return len(switchDrift(a.Switch, a.parameter)) > 0
+}
+
+// switchDrift returns names of the parameters which differ from the actual switch
+func switchDrift(sw *sacloud.Switch, p *params.SwitchCreateParameter) []string {
+	desired, _ := iaas.DesiredSwitchParameter(sw)
+	if desired == nil {
+		desired = &params.SwitchCreateParameter{}
+	}
+
+	var bridgeID int64
+	if sw.Bridge != nil && sw.Bridge.Bridge != nil && sw.Bridge.Resource != nil {
+		bridgeID = sw.Bridge.ID
+	}
+	var maskLen int
+	var gateway string
+	if sw.UserSubnet != nil {
+		maskLen = sw.UserSubnet.NetworkMaskLen
+		gateway = sw.UserSubnet.DefaultRoute
+	}
+
+	values := map[string]cmp.CompareValue{
+		"subnet":      {X: p.Subnet, Y: desired.Subnet},
+		"maskLen":     {X: p.MaskLen(), Y: maskLen},
+		"gateway":     {X: p.Gateway, Y: gateway},
+		"bridgeID":    {X: p.BridgeID, Y: bridgeID},
+		"vpcRouterID": {X: p.VPCRouterID, Y: desired.VPCRouterID},
+	}
+
+	var drift []string
+	for name, v := range values {
+		if !cmp.Equal(v) {
+			drift = append(drift, name)
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+// fetchSwitch returns the instance with parameters of the actual switch.
+// It returns nil if the instance is not found
+func fetchSwitch(instanceID string) (*osb.ServiceInstanceResource, error) {
+	sw, err := sacloudAPI.Switch().Read(instanceID)
+	if err != nil {
+		if e, ok := err.(api.Error); ok && e.ResponseCode() == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	desired, err := iaas.DesiredSwitchParameter(sw)
+	if err != nil {
+		return nil, fmt.Errorf("reading requested parameter is failed: %s", err)
+	}
+	p := &params.SwitchCreateParameter{}
+	if desired != nil {
+		*p = *desired
+	}
+	p.BridgeID = 0
+	if sw.Bridge != nil && sw.Bridge.Bridge != nil && sw.Bridge.Resource != nil {
+		p.BridgeID = sw.Bridge.ID
+	}
+	p.Gateway = ""
+	if sw.UserSubnet != nil {
+		p.Gateway = sw.UserSubnet.DefaultRoute
+	}
+
+	return &osb.ServiceInstanceResource{
+		ServiceID:  SwitchServiceID,
+		PlanID:     SwitchPlanDefaultID,
+		Parameters: p,
+	}, nil
+}
+
+type switchHandler struct {
+	operation    string
+	serviceID    string
+	planID       string
+	rawParameter []byte
+
+	parameter *params.SwitchCreateParameter
+	paramErr  error
+}
+
+func newSwitchServiceHandler(operation, serviceID, planID string, rawParameter []byte) *switchHandler {
+	handler := &switchHandler{
+		operation:    operation,
+		serviceID:    serviceID,
+		planID:       planID,
+		rawParameter: rawParameter,
+	}
+
+	switch operation {
+	case operations.Provisioning:
+		if len(rawParameter) == 0 {
+			handler.paramErr = errors.New("switchService parameter JSON is empty")
+			return handler
+		}
+
+		var p = params.SwitchCreateParameter{}
+		err := json.Unmarshal(rawParameter, &p)
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		err = p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		handler.parameter = &p
+	case operations.Updating:
+		handler.paramErr = errors.New("updating switchService is not supported")
+	}
+
+	return handler
+}
+
+func (s *switchHandler) InstanceState(instanceID string) (InstanceState, error) {
+	sw, err := sacloudAPI.Switch().Read(instanceID)
+	if err != nil {
+		if e, ok := err.(api.Error); ok {
+			if e.ResponseCode() != http.StatusNotFound {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	if sw == nil {
+		if s.operation == operations.Deprovisioning {
+			knownInstances.remove(instanceID)
+		}
+		return nil, nil
+	}
+	knownInstances.add(instanceID, s.serviceID)
+
+	return &switchAttrs{
+		Switch:    sw,
+		parameter: s.parameter,
+	}, nil
+}
+
+// BindingState always returns nil because bindings of switches have no state on the switch
+func (s *switchHandler) BindingState(instanceID, bindingID string) (BindingState, error) {
+	return nil, nil
+}
+
+func (s *switchHandler) CreateInstance(instanceID string) error {
+	_, err := sacloudAPI.Switch().Create(instanceID, s.parameter)
+	if err != nil {
+		return err
+	}
+	knownInstances.add(instanceID, s.serviceID)
+	return nil
+}
+
+func (s *switchHandler) UpdateInstance(instanceID string) error {
+	return errors.New("updating switchService is not supported")
+}
+
+func (s *switchHandler) DeleteInstance(instanceID string) error {
+	client := sacloudAPI.Switch()
+	sw, err := client.Read(instanceID)
+	if err != nil {
+		return err
+	}
+
+	connected, err := switchConnectedInstances(sw)
+	if err != nil {
+		return err
+	}
+	if len(connected) > 0 {
+		return &osb.InstanceInUseError{
+			Description: fmt.Sprintf("instances are connected to the switch: %s", strings.Join(connected, ", ")),
+		}
+	}
+	if sw.ServerCount > 0 {
+		return &osb.InstanceInUseError{
+			Description: fmt.Sprintf("%d servers are connected to the switch", sw.ServerCount),
+		}
+	}
+
+	err = client.Delete(instanceID)
+	if err != nil {
+		return err
+	}
+	knownInstances.markDeleting(instanceID)
+	return nil
+}
+
+// switchConnectedInstances returns IDs of broker-managed instances which are connected to the switch.
+// The VPC router requested on the creation is excluded because it's disconnected on the deletion
+func switchConnectedInstances(sw *sacloud.Switch) ([]string, error) {
+	switchID := sw.GetStrID()
+	var connected []string
+	for _, target := range reconcileTargets {
+		dbs, err := target.dialect.databaseAPI().List()
+		if err != nil {
+			return nil, fmt.Errorf("listing %q is failed: %s", target.service.Name, err)
+		}
+		for _, db := range dbs {
+			if db.Remark != nil && db.Remark.Switch != nil && db.Remark.Switch.ID == switchID {
+				connected = append(connected, db.Name)
+			}
+		}
+	}
+
+	nfsList, err := sacloudAPI.NFS().List()
+	if err != nil {
+		return nil, fmt.Errorf("listing %q is failed: %s", NFSService.Name, err)
+	}
+	for _, nfs := range nfsList {
+		if nfs.Remark != nil && nfs.Remark.ApplianceRemarkBase != nil &&
+			nfs.Remark.Switch != nil && nfs.Remark.Switch.ID == switchID {
+			connected = append(connected, nfs.Name)
+		}
+	}
+
+	desired, err := iaas.DesiredSwitchParameter(sw)
+	if err != nil {
+		return nil, fmt.Errorf("reading requested parameter is failed: %s", err)
+	}
+	routers, err := sacloudAPI.VPCRouter().List()
+	if err != nil {
+		return nil, fmt.Errorf("listing %q is failed: %s", VPCRouterService.Name, err)
+	}
+	for _, router := range routers {
+		if desired != nil && router.ID == desired.VPCRouterID {
+			continue
+		}
+		for _, nic := range router.Interfaces {
+			if nic.Switch != nil && nic.Switch.Resource != nil && nic.Switch.ID == sw.ID {
+				connected = append(connected, router.Name)
+				break
+			}
+		}
+	}
+
+	sort.Strings(connected)
+	return connected, nil
+}
+
+func (s *switchHandler) CreateBinding(instanceID, bindingID string) (*osb.ServiceBinding, error) {
+	sw, err := sacloudAPI.Switch().Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := iaas.DesiredSwitchParameter(sw)
+	if err != nil {
+		return nil, fmt.Errorf("reading requested parameter is failed: %s", err)
+	}
+	if p == nil {
+		return nil, errors.New("subnet of the switch is not available")
+	}
+	return switchBinding(sw, p), nil
+}
+
+// switchBinding returns the binding which contains the switch ID and the subnet
+func switchBinding(sw *sacloud.Switch, p *params.SwitchCreateParameter) *osb.ServiceBinding {
+	_, network, _ := net.ParseCIDR(p.Subnet)
+
+	credentials := map[string]string{
+		"switchID": sw.GetStrID(),
+		"subnet":   p.Subnet,
+		"maskLen":  strconv.Itoa(p.MaskLen()),
+		"gateway":  p.Gateway,
+	}
+	if network != nil {
+		credentials["networkAddress"] = network.IP.String()
+	}
+	return &osb.ServiceBinding{
+		Credentials: credentials,
+	}
+}
+
+// DeleteBinding does nothing because bindings of switches have no state on the switch
+func (s *switchHandler) DeleteBinding(instanceID, bindingID string) error {
+	return nil
+}
+
+func (s *switchHandler) ConfirmProvision(instanceID string) error {
+	return sacloudAPI.Switch().ConfirmProvisioned(instanceID)
+}
+
+func (s *switchHandler) IsValid() (bool, error) {
+	return s.paramErr == nil, s.paramErr
+}
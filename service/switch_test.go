@@ -0,0 +1,159 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/stretchr/testify/assert"
+)
+
+type dummySwitchAPI struct {
+	readResult *sacloud.Switch
+	readErr    error
+	createErr  error
+	deleteErr  error
+
+	created *params.SwitchCreateParameter
+	deleted bool
+}
+
+func (c *dummySwitchAPI) List() ([]sacloud.Switch, error) {
+	if c.readResult == nil {
+		return nil, c.readErr
+	}
+	return []sacloud.Switch{*c.readResult}, c.readErr
+}
+
+func (c *dummySwitchAPI) Read(instanceID string) (*sacloud.Switch, error) {
+	return c.readResult, c.readErr
+}
+
+func (c *dummySwitchAPI) Create(instanceID string, param *params.SwitchCreateParameter) (*sacloud.Switch, error) {
+	c.created = param
+	return c.readResult, c.createErr
+}
+
+func (c *dummySwitchAPI) ResumeConnecting() error {
+	return nil
+}
+
+func (c *dummySwitchAPI) ConfirmProvisioned(instanceID string) error {
+	return nil
+}
+
+func (c *dummySwitchAPI) Delete(instanceID string) error {
+	c.deleted = true
+	return c.deleteErr
+}
+
+const validSwitchProvisioningParam = `{"subnet": "192.2.0.0/24", "gateway": "192.2.0.1"}`
+
+// testSwitch returns the switch which is created with the parameter
+func testSwitch(t *testing.T, instanceID string, p *params.SwitchCreateParameter) *sacloud.Switch {
+	desired, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw := &sacloud.Switch{Resource: sacloud.NewResource(int64(mariaDBTestSwitchID))}
+	sw.Name = instanceID
+	sw.Description = string(desired)
+	sw.Tags = []string{"@open-service-broker-sacloud"}
+	sw.UserSubnet = &sacloud.Subnet{DefaultRoute: p.Gateway, NetworkMaskLen: p.MaskLen()}
+	return sw
+}
+
+func TestSwitchServiceValidate(t *testing.T) {
+	s := newSwitchServiceHandler(operations.Provisioning, SwitchServiceID, SwitchPlanDefaultID, []byte(``))
+	_, err := s.IsValid()
+	assert.Error(t, err)
+
+	s = newSwitchServiceHandler(operations.Provisioning, SwitchServiceID, SwitchPlanDefaultID, []byte(`{"subnet": "192.2.0.0/24", "vpcRouterID": 999999999999}`))
+	_, err = s.IsValid()
+	assert.Error(t, err)
+
+	s = newSwitchServiceHandler(operations.Provisioning, SwitchServiceID, SwitchPlanDefaultID, []byte(validSwitchProvisioningParam))
+	_, err = s.IsValid()
+	assert.NoError(t, err)
+
+	s = newSwitchServiceHandler(operations.Updating, SwitchServiceID, SwitchPlanDefaultID, []byte(validSwitchProvisioningParam))
+	_, err = s.IsValid()
+	assert.Error(t, err)
+}
+
+func TestSwitchHandler(t *testing.T) {
+	p := &params.SwitchCreateParameter{Subnet: "192.2.0.0/24", Gateway: "192.2.0.1"}
+	switchAPI := &dummySwitchAPI{readResult: testSwitch(t, instanceID, p)}
+	dbAPI := &genericDBDummyAPI{}
+	nfsAPI := &dummyNFSAPI{}
+	vpcRouterAPI := &dummyVPCRouterAPI{}
+	sacloudAPI = &dummyAPI{dbAPI: dbAPI, nfsAPI: nfsAPI, switchAPI: switchAPI, vpcRouterAPI: vpcRouterAPI}
+	defer func() { sacloudAPI = testAPI }()
+
+	orgTargets := reconcileTargets
+	reconcileTargets = []*reconcileTarget{
+		{service: MariaDBService, planIDs: DatabaseIDMap["MariaDB"].PlanIDMap, dialect: &dummyDBFuncs{}},
+	}
+	defer func() { reconcileTargets = orgTargets }()
+
+	t.Run("InstanceState", func(t *testing.T) {
+		s := newSwitchServiceHandler(operations.Provisioning, SwitchServiceID, SwitchPlanDefaultID, []byte(validSwitchProvisioningParam))
+		state, err := s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.True(t, state.IsUp())
+		assert.False(t, state.HasDiff())
+
+		s = newSwitchServiceHandler(operations.Provisioning, SwitchServiceID, SwitchPlanDefaultID, []byte(`{"subnet": "192.2.1.0/24"}`))
+		state, err = s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.True(t, state.HasDiff())
+	})
+
+	t.Run("InstanceState while connecting to VPC router", func(t *testing.T) {
+		switchAPI.readResult.AppendTag(iaas.SwitchConnectingMarkerTag)
+		defer switchAPI.readResult.RemoveTag(iaas.SwitchConnectingMarkerTag)
+
+		s := newSwitchServiceHandler(operations.Provisioning, SwitchServiceID, SwitchPlanDefaultID, []byte(validSwitchProvisioningParam))
+		state, err := s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.False(t, state.IsUp())
+		assert.False(t, state.IsFailed())
+		assert.True(t, state.IsMigrating())
+	})
+
+	t.Run("CreateBinding", func(t *testing.T) {
+		s := newSwitchServiceHandler(operations.Binding, SwitchServiceID, SwitchPlanDefaultID, []byte(``))
+		binding, err := s.CreateBinding(instanceID, bindingID)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"switchID":       "999999999999",
+			"subnet":         "192.2.0.0/24",
+			"networkAddress": "192.2.0.0",
+			"maskLen":        "24",
+			"gateway":        "192.2.0.1",
+		}, binding.Credentials)
+	})
+
+	t.Run("DeleteInstance", func(t *testing.T) {
+		s := newSwitchServiceHandler(operations.Deprovisioning, SwitchServiceID, SwitchPlanDefaultID, []byte(``))
+
+		// the database is connected to the switch
+		dbAPI.listResult = []sacloud.Database{reconcileTestInstance("connected-db", true)}
+		nfsAPI.readResult = nfs100GInstance(t, "connected-nfs")
+		vpcRouterAPI.listResult = []sacloud.VPCRouter{*testVPCRouter("connected-vpc-router")}
+		err := s.DeleteInstance(instanceID)
+		assert.IsType(t, &osb.InstanceInUseError{}, err)
+		assert.Contains(t, err.Error(), "connected-db, connected-nfs, connected-vpc-router")
+		assert.False(t, switchAPI.deleted)
+
+		dbAPI.listResult = nil
+		nfsAPI.readResult = nil
+		vpcRouterAPI.listResult = nil
+		assert.NoError(t, s.DeleteInstance(instanceID))
+		assert.True(t, switchAPI.deleted)
+	})
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/util/metrics"
+)
+
+// DiskUsageWarningRatio is the ratio of disk usage to the plan size which is reported as a warning
+var DiskUsageWarningRatio = 0.8
+
+var (
+	usageDiskUsed = metrics.NewGauge(
+		"osbs_instance_disk_used_bytes",
+		"Used disk size of instances",
+		"service", "instance_id",
+	)
+	usageDiskSize = metrics.NewGauge(
+		"osbs_instance_disk_size_bytes",
+		"Disk size of the plan of instances",
+		"service", "instance_id",
+	)
+	usageDiskRatio = metrics.NewGauge(
+		"osbs_instance_disk_usage_ratio",
+		"Ratio of used disk size to the plan size of instances",
+		"service", "instance_id",
+	)
+	usageMemoryUsed = metrics.NewGauge(
+		"osbs_instance_memory_used_bytes",
+		"Used memory size of instances",
+		"service", "instance_id",
+	)
+	usageCPUTime = metrics.NewGauge(
+		"osbs_instance_cpu_time",
+		"CPU time of instances reported by SAKURA Cloud",
+		"service", "instance_id",
+	)
+	usageReceive = metrics.NewGauge(
+		"osbs_instance_network_receive_bps",
+		"Received traffic of instances in bits per second",
+		"service", "instance_id",
+	)
+	usageSend = metrics.NewGauge(
+		"osbs_instance_network_send_bps",
+		"Sent traffic of instances in bits per second",
+		"service", "instance_id",
+	)
+	usageGauges = []*metrics.Gauge{
+		usageDiskUsed, usageDiskSize, usageDiskRatio, usageMemoryUsed, usageCPUTime, usageReceive, usageSend,
+	}
+)
+
+// InstanceUsage represents resource usage of the instance read from SAKURA Cloud activity monitors
+type InstanceUsage struct {
+	InstanceID     string                `json:"instance_id"`
+	ServiceName    string                `json:"service"`
+	PlanName       string                `json:"plan"`
+	PlanSizeBytes  float64               `json:"plan_size_bytes"`
+	Monitor        *iaas.DatabaseMonitor `json:"monitor,omitempty"`
+	DiskUsageRatio *float64              `json:"disk_usage_ratio,omitempty"`
+	Warnings       []string              `json:"warnings,omitempty"`
+	Error          string                `json:"error,omitempty"`
+}
+
+// ListInstanceUsage returns usage of all running instances and updates usage metrics.
+// Failures of reading monitors are reported per instance
+func ListInstanceUsage() ([]*InstanceUsage, error) {
+	var usages []*InstanceUsage
+	for _, target := range reconcileTargets {
+		client := target.dialect.databaseAPI()
+		dbs, err := client.List()
+		if err != nil {
+			return nil, fmt.Errorf("listing %q is failed: %s", target.service.Name, err)
+		}
+		for i := range dbs {
+			db := &dbs[i]
+			if !db.IsUp() || db.HasTag(iaas.DeletingMarkerTag) {
+				continue
+			}
+			usage, err := instanceUsage(target, db)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"service":    target.service.Name,
+					"instanceID": db.Name,
+				}).Warnf("reading activity monitors is failed: %s", err)
+				usage.Error = err.Error()
+			}
+			usages = append(usages, usage)
+		}
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].InstanceID < usages[j].InstanceID
+	})
+
+	for _, g := range usageGauges {
+		g.Reset()
+	}
+	for _, usage := range usages {
+		usage.setMetrics()
+	}
+	return usages, nil
+}
+
+// ShowInstanceUsage returns usage of the instance and updates usage metrics of it
+func ShowInstanceUsage(instanceID string) (*InstanceUsage, error) {
+	target, db, err := findInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := instanceUsage(target, db)
+	if err != nil {
+		return nil, fmt.Errorf("reading activity monitors is failed: %s", err)
+	}
+	usage.setMetrics()
+	return usage, nil
+}
+
+func instanceUsage(target *reconcileTarget, db *sacloud.Database) (*InstanceUsage, error) {
+	usage := &InstanceUsage{
+		InstanceID:  db.Name,
+		ServiceName: target.service.Name,
+		PlanName:    target.planName(db),
+	}
+	// plan IDs of database appliances are disk sizes in GiB
+	if db.Remark != nil && db.Remark.GetPlanID() > 0 {
+		usage.PlanSizeBytes = float64(db.Remark.GetPlanID()) * 1024 * 1024 * 1024
+	}
+
+	monitor, err := target.dialect.databaseAPI().Monitor(db.Name)
+	if err != nil {
+		return usage, err
+	}
+	usage.Monitor = monitor
+
+	size := usage.PlanSizeBytes
+	if size == 0 && monitor.DiskTotalBytes != nil {
+		size = *monitor.DiskTotalBytes
+	}
+	if monitor.DiskUsedBytes != nil && size > 0 {
+		ratio := *monitor.DiskUsedBytes / size
+		usage.DiskUsageRatio = &ratio
+		if ratio >= DiskUsageWarningRatio {
+			usage.Warnings = append(usage.Warnings,
+				fmt.Sprintf("disk usage is %.0f%% of the plan size, consider upgrading the plan", ratio*100))
+			log.WithFields(log.Fields{
+				"service":    usage.ServiceName,
+				"instanceID": usage.InstanceID,
+				"plan":       usage.PlanName,
+				"ratio":      ratio,
+			}).Warn("disk usage approaches the plan size")
+		}
+	}
+	return usage, nil
+}
+
+func (u *InstanceUsage) setMetrics() {
+	if u.PlanSizeBytes > 0 {
+		usageDiskSize.Set(u.PlanSizeBytes, u.ServiceName, u.InstanceID)
+	}
+	if u.DiskUsageRatio != nil {
+		usageDiskRatio.Set(*u.DiskUsageRatio, u.ServiceName, u.InstanceID)
+	}
+	if u.Monitor == nil {
+		return
+	}
+	for _, v := range []struct {
+		gauge *metrics.Gauge
+		value *float64
+	}{
+		{usageDiskUsed, u.Monitor.DiskUsedBytes},
+		{usageMemoryUsed, u.Monitor.MemoryUsedBytes},
+		{usageCPUTime, u.Monitor.CPUTime},
+		{usageReceive, u.Monitor.ReceiveBPS},
+		{usageSend, u.Monitor.SendBPS},
+	} {
+		if v.value != nil {
+			v.gauge.Set(*v.value, u.ServiceName, u.InstanceID)
+		}
+	}
+}
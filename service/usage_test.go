@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceUsage(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dbAPI := &genericDBDummyAPI{}
+	sacloudAPI = &dummyAPI{dbAPI: dbAPI}
+	defer func() { sacloudAPI = testAPI }()
+
+	orgTargets := reconcileTargets
+	reconcileTargets = []*reconcileTarget{
+		{service: MariaDBService, planIDs: DatabaseIDMap["MariaDB"].PlanIDMap, dialect: &dummyDBFuncs{}},
+	}
+	defer func() { reconcileTargets = orgTargets }()
+
+	orgInventories := resourceInventories
+	resourceInventories = nil
+	defer func() { resourceInventories = orgInventories }()
+
+	gib := float64(1024 * 1024 * 1024)
+	used := 9 * gib
+	cpu := 12.5
+
+	t.Run("ListInstanceUsage", func(t *testing.T) {
+		dbAPI.listResult = []sacloud.Database{
+			reconcileTestInstance("instance-b", true),
+			reconcileTestInstance("instance-a", true),
+			reconcileTestInstance("stopped", false),
+		}
+		dbAPI.monitorResult = &iaas.DatabaseMonitor{DiskUsedBytes: &used, CPUTime: &cpu}
+		dbAPI.monitorErr = nil
+
+		usages, err := ListInstanceUsage()
+		assert.NoError(t, err)
+		assert.Len(t, usages, 2)
+		assert.Equal(t, "instance-a", usages[0].InstanceID)
+		assert.Equal(t, MariaDBPlan10G.Name, usages[0].PlanName)
+		assert.Equal(t, 10*gib, usages[0].PlanSizeBytes)
+		assert.InDelta(t, 0.9, *usages[0].DiskUsageRatio, 0.001)
+		assert.Len(t, usages[0].Warnings, 1)
+
+		v, ok := usageDiskUsed.Get(MariaDBService.Name, "instance-a")
+		assert.True(t, ok)
+		assert.Equal(t, used, v)
+		v, ok = usageCPUTime.Get(MariaDBService.Name, "instance-b")
+		assert.True(t, ok)
+		assert.Equal(t, cpu, v)
+		_, ok = usageDiskUsed.Get(MariaDBService.Name, "stopped")
+		assert.False(t, ok)
+	})
+
+	t.Run("ListInstanceUsage with monitor error", func(t *testing.T) {
+		dbAPI.listResult = []sacloud.Database{reconcileTestInstance("instance-a", true)}
+		dbAPI.monitorResult = nil
+		dbAPI.monitorErr = errors.New("dummy")
+
+		usages, err := ListInstanceUsage()
+		assert.NoError(t, err)
+		assert.Len(t, usages, 1)
+		assert.Equal(t, "dummy", usages[0].Error)
+		assert.Nil(t, usages[0].Monitor)
+
+		_, ok := usageDiskUsed.Get(MariaDBService.Name, "instance-a")
+		assert.False(t, ok)
+	})
+
+	t.Run("ShowInstanceUsage", func(t *testing.T) {
+		low := 1 * gib
+		dbAPI.listResult = []sacloud.Database{reconcileTestInstance("instance-a", true)}
+		dbAPI.monitorResult = &iaas.DatabaseMonitor{DiskUsedBytes: &low}
+		dbAPI.monitorErr = nil
+
+		usage, err := ShowInstanceUsage("instance-a")
+		assert.NoError(t, err)
+		assert.InDelta(t, 0.1, *usage.DiskUsageRatio, 0.001)
+		assert.Empty(t, usage.Warnings)
+
+		_, err = ShowInstanceUsage("not-exists")
+		assert.Error(t, err)
+
+		dbAPI.monitorErr = errors.New("dummy")
+		_, err = ShowInstanceUsage("instance-a")
+		assert.Error(t, err)
+	})
+}
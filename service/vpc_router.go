@@ -0,0 +1,493 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/sacloud/libsacloud/api"
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/osb"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/sacloud/open-service-broker-sacloud/util/cmp"
+)
+
+// vpcRouterAttrs implements InstanceState interface.
+// VPC routers are migrating while interfaces and settings are applied after the creation
+type vpcRouterAttrs struct {
+	*sacloud.VPCRouter
+	parameter *params.VPCRouterCreateParameter
+}
+
+func (a *vpcRouterAttrs) IsUp() bool {
+	return a.VPCRouter.IsUp() &&
+		!a.HasTag(iaas.VPCRouterConfiguringMarkerTag) &&
+		!a.HasTag(iaas.VPCRouterFailedMarkerTag) &&
+		!a.HasTag(iaas.DeletingMarkerTag)
+}
+
+func (a *vpcRouterAttrs) IsFailed() bool {
+	if a.HasTag(iaas.DeletingMarkerTag) {
+		return false
+	}
+	return a.HasTag(iaas.VPCRouterFailedMarkerTag) || a.VPCRouter.IsFailed()
+}
+
+func (a *vpcRouterAttrs) IsMigrating() bool {
+	return a.HasTag(iaas.VPCRouterConfiguringMarkerTag) || a.VPCRouter.IsMigrating()
+}
+
+func (a *vpcRouterAttrs) IsDeleting() bool {
+	return a.HasTag(iaas.DeletingMarkerTag)
+}
+
+func (a *vpcRouterAttrs) HasDiff() bool {
+	if a.parameter == nil {
+		return false
+	}
+	return len(vpcRouterDrift(a.VPCRouter, a.parameter)) > 0
+}
+
+// vpcRouterDrift returns names of the parameters which differ from the actual VPC router.
+// Interfaces and the configuration are compared after they are applied
+func vpcRouterDrift(router *sacloud.VPCRouter, p *params.VPCRouterCreateParameter) []string {
+	actual := actualVPCRouterParameter(router)
+
+	values := map[string]cmp.CompareValue{
+		"plan":                   {X: p.PlanID, Y: actual.PlanID},
+		"publicSwitchID":         {X: p.PublicSwitchID, Y: actual.PublicSwitchID},
+		"publicIPAddresses":      {X: emptyToNil(p.PublicIPAddresses), Y: actual.PublicIPAddresses},
+		"publicVirtualIPAddress": {X: p.PublicVirtualIPAddress, Y: actual.PublicVirtualIPAddress},
+		"ipAliases":              {X: emptyToNil(p.IPAliases), Y: actual.IPAliases},
+		"vrid":                   {X: p.VRID, Y: actual.VRID},
+	}
+	if !router.HasTag(iaas.VPCRouterConfiguringMarkerTag) {
+		desired := normalizeVPCRouterConfig(&p.VPCRouterConfigParameter)
+		values["interfaces"] = cmp.CompareValue{X: normalizeVPCRouterInterfaces(p.Interfaces), Y: actual.Interfaces}
+		values["staticNAT"] = cmp.CompareValue{X: desired.StaticNAT, Y: actual.StaticNAT}
+		values["portForwarding"] = cmp.CompareValue{X: desired.PortForwarding, Y: actual.PortForwarding}
+		values["firewall"] = cmp.CompareValue{X: desired.Firewall, Y: actual.Firewall}
+		values["siteToSiteVPN"] = cmp.CompareValue{X: desired.SiteToSiteVPN, Y: actual.SiteToSiteVPN}
+	}
+
+	var drift []string
+	for name, v := range values {
+		if !cmp.Equal(v) {
+			drift = append(drift, name)
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+// actualVPCRouterParameter returns the parameter which values are read from settings of the VPC router
+func actualVPCRouterParameter(router *sacloud.VPCRouter) *params.VPCRouterCreateParameter {
+	p := &params.VPCRouterCreateParameter{
+		PlanID: int(router.GetPlanID()),
+	}
+	if !router.HasSetting() {
+		return p
+	}
+	s := router.Settings.Router
+
+	if !router.IsStandardPlan() {
+		if router.Remark != nil && router.Remark.ApplianceRemarkBase != nil && router.Remark.Switch != nil {
+			p.PublicSwitchID, _ = strconv.ParseInt(router.Remark.Switch.ID, 10, 64)
+		}
+		if len(s.Interfaces) > 0 && s.Interfaces[0] != nil {
+			p.PublicIPAddresses = emptyToNil(s.Interfaces[0].IPAddress)
+			p.PublicVirtualIPAddress = s.Interfaces[0].VirtualIPAddress
+			p.IPAliases = emptyToNil(s.Interfaces[0].IPAliases)
+		}
+		if s.VRID != nil {
+			p.VRID = *s.VRID
+		}
+	}
+
+	for i := 1; i < len(s.Interfaces); i++ {
+		nic := s.Interfaces[i]
+		if nic == nil {
+			continue
+		}
+		actual := params.VPCRouterInterfaceParameter{
+			MaskLen: nic.NetworkMaskLen,
+		}
+		if i < len(router.Interfaces) && router.Interfaces[i].Switch != nil && router.Interfaces[i].Switch.Resource != nil {
+			actual.SwitchID = router.Interfaces[i].Switch.ID
+		}
+		if router.IsStandardPlan() {
+			if len(nic.IPAddress) > 0 {
+				actual.IPAddress = nic.IPAddress[0]
+			}
+		} else {
+			actual.IPAddresses = emptyToNil(nic.IPAddress)
+			actual.VirtualIPAddress = nic.VirtualIPAddress
+		}
+		p.Interfaces = append(p.Interfaces, actual)
+	}
+
+	if s.StaticNAT != nil {
+		for _, nat := range s.StaticNAT.Config {
+			p.StaticNAT = append(p.StaticNAT, params.VPCRouterStaticNATParameter{
+				GlobalAddress:  nat.GlobalAddress,
+				PrivateAddress: nat.PrivateAddress,
+				Description:    nat.Description,
+			})
+		}
+	}
+	if s.PortForwarding != nil {
+		for _, pf := range s.PortForwarding.Config {
+			globalPort, _ := strconv.Atoi(pf.GlobalPort)
+			privatePort, _ := strconv.Atoi(pf.PrivatePort)
+			p.PortForwarding = append(p.PortForwarding, params.VPCRouterPortForwardingParameter{
+				Protocol:       pf.Protocol,
+				GlobalPort:     globalPort,
+				PrivateAddress: pf.PrivateAddress,
+				PrivatePort:    privatePort,
+				Description:    pf.Description,
+			})
+		}
+	}
+	if s.Firewall != nil {
+		for i, conf := range s.Firewall.Config {
+			if conf == nil {
+				continue
+			}
+			for direction, rules := range map[string][]*sacloud.VPCRouterFirewallRule{
+				"receive": conf.Receive,
+				"send":    conf.Send,
+			} {
+				for _, rule := range rules {
+					p.Firewall = append(p.Firewall, params.VPCRouterFirewallRuleParameter{
+						Interface:          i,
+						Direction:          direction,
+						Action:             rule.Action,
+						Protocol:           rule.Protocol,
+						SourceNetwork:      rule.SourceNetwork,
+						SourcePort:         rule.SourcePort,
+						DestinationNetwork: rule.DestinationNetwork,
+						DestinationPort:    rule.DestinationPort,
+						Logging:            rule.Logging == "True",
+						Description:        rule.Description,
+					})
+				}
+			}
+		}
+		sortVPCRouterFirewallRules(p.Firewall)
+	}
+	if s.SiteToSiteIPsecVPN != nil && len(s.SiteToSiteIPsecVPN.Config) > 0 {
+		vpn := s.SiteToSiteIPsecVPN.Config[0]
+		p.SiteToSiteVPN = &params.VPCRouterSiteToSiteVPNParameter{
+			Peer:            vpn.Peer,
+			RemoteID:        vpn.RemoteID,
+			PreSharedSecret: vpn.PreSharedSecret,
+			Routes:          vpn.Routes,
+			LocalPrefix:     vpn.LocalPrefix,
+		}
+	}
+	return p
+}
+
+// normalizeVPCRouterConfig returns the configuration which can be compared with the actual one
+func normalizeVPCRouterConfig(config *params.VPCRouterConfigParameter) *params.VPCRouterConfigParameter {
+	normalized := *config
+	if len(normalized.StaticNAT) == 0 {
+		normalized.StaticNAT = nil
+	}
+	if len(normalized.PortForwarding) == 0 {
+		normalized.PortForwarding = nil
+	}
+	if len(normalized.Firewall) == 0 {
+		normalized.Firewall = nil
+	} else {
+		normalized.Firewall = append([]params.VPCRouterFirewallRuleParameter{}, config.Firewall...)
+		sortVPCRouterFirewallRules(normalized.Firewall)
+	}
+	return &normalized
+}
+
+func normalizeVPCRouterInterfaces(interfaces []params.VPCRouterInterfaceParameter) []params.VPCRouterInterfaceParameter {
+	var normalized []params.VPCRouterInterfaceParameter
+	for _, nic := range interfaces {
+		nic.IPAddresses = emptyToNil(nic.IPAddresses)
+		normalized = append(normalized, nic)
+	}
+	return normalized
+}
+
+// sortVPCRouterFirewallRules sorts rules in order of the interface and the direction, keeping the order of rules
+func sortVPCRouterFirewallRules(rules []params.VPCRouterFirewallRuleParameter) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Interface != rules[j].Interface {
+			return rules[i].Interface < rules[j].Interface
+		}
+		return rules[i].Direction == "receive" && rules[j].Direction == "send"
+	})
+}
+
+func emptyToNil(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// fetchVPCRouter returns the instance with parameters of the actual VPC router except the pre-shared secret.
+// It returns nil if the instance is not found
+func fetchVPCRouter(instanceID string) (*osb.ServiceInstanceResource, error) {
+	router, err := sacloudAPI.VPCRouter().Read(instanceID)
+	if err != nil {
+		if e, ok := err.(api.Error); ok && e.ResponseCode() == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	p := actualVPCRouterParameter(router)
+	if p.SiteToSiteVPN != nil {
+		// the secret is passed only to bindings, drift of it is reported without values
+		p.SiteToSiteVPN.PreSharedSecret = ""
+	}
+	return &osb.ServiceInstanceResource{
+		ServiceID:  VPCRouterServiceID,
+		PlanID:     VPCRouterIDMap.PlanIDMap[p.PlanID],
+		Parameters: p,
+	}, nil
+}
+
+type vpcRouterHandler struct {
+	operation    string
+	serviceID    string
+	planID       string
+	rawParameter []byte
+
+	parameter *params.VPCRouterCreateParameter
+	config    *params.VPCRouterConfigParameter
+	paramErr  error
+}
+
+func newVPCRouterServiceHandler(operation, serviceID, planID string, rawParameter []byte) *vpcRouterHandler {
+	handler := &vpcRouterHandler{
+		operation:    operation,
+		serviceID:    serviceID,
+		planID:       planID,
+		rawParameter: rawParameter,
+	}
+
+	var planNumber int
+	for k, v := range VPCRouterIDMap.PlanIDMap {
+		if v == planID {
+			planNumber = k
+			break
+		}
+	}
+
+	switch operation {
+	case operations.Provisioning:
+		if len(rawParameter) == 0 {
+			handler.paramErr = errors.New("vpcRouterService parameter JSON is empty")
+			return handler
+		}
+
+		var p = params.VPCRouterCreateParameter{}
+		err := json.Unmarshal(rawParameter, &p)
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+		p.PlanID = planNumber
+
+		err = p.Validate()
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+		p.SetDefaults()
+
+		handler.parameter = &p
+	case operations.Updating:
+		// updating takes all of the configuration because it replaces all settings
+		if len(rawParameter) == 0 {
+			handler.paramErr = errors.New("vpcRouterService parameter JSON is empty")
+			return handler
+		}
+
+		var p = params.VPCRouterConfigParameter{}
+		err := json.Unmarshal(rawParameter, &p)
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+
+		// interfaces of firewall rules are validated with the actual VPC router on updating
+		err = p.Validate(planNumber, params.VPCRouterMaxInterfaces)
+		if err != nil {
+			handler.paramErr = err
+			return handler
+		}
+		p.SetDefaults()
+
+		handler.config = &p
+	}
+
+	return handler
+}
+
+func (s *vpcRouterHandler) InstanceState(instanceID string) (InstanceState, error) {
+	router, err := sacloudAPI.VPCRouter().Read(instanceID)
+	if err != nil {
+		if e, ok := err.(api.Error); ok {
+			if e.ResponseCode() != http.StatusNotFound {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	if router == nil {
+		if s.operation == operations.Deprovisioning {
+			knownInstances.remove(instanceID)
+		}
+		return nil, nil
+	}
+	knownInstances.add(instanceID, s.serviceID)
+
+	return &vpcRouterAttrs{
+		VPCRouter: router,
+		parameter: s.parameter,
+	}, nil
+}
+
+// BindingState always returns nil because bindings of VPC routers have no state on the VPC router
+func (s *vpcRouterHandler) BindingState(instanceID, bindingID string) (BindingState, error) {
+	return nil, nil
+}
+
+func (s *vpcRouterHandler) CreateInstance(instanceID string) error {
+	_, err := sacloudAPI.VPCRouter().Create(instanceID, s.parameter)
+	if err != nil {
+		return err
+	}
+	knownInstances.add(instanceID, s.serviceID)
+	return nil
+}
+
+func (s *vpcRouterHandler) UpdateInstance(instanceID string) error {
+	client := sacloudAPI.VPCRouter()
+	router, err := client.Read(instanceID)
+	if err != nil {
+		return err
+	}
+	if router.HasTag(iaas.VPCRouterConfiguringMarkerTag) {
+		return errors.New("the VPC router is being configured")
+	}
+
+	// validate the configuration with the actual interfaces and IP aliases
+	p := actualVPCRouterParameter(router)
+	p.VPCRouterConfigParameter = *s.config
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	// the configuration is applied synchronously, which takes several seconds(see the document of updating)
+	_, err = client.Update(instanceID, s.config)
+	return err
+}
+
+func (s *vpcRouterHandler) DeleteInstance(instanceID string) error {
+	err := sacloudAPI.VPCRouter().Delete(instanceID)
+	if err != nil {
+		return err
+	}
+	knownInstances.markDeleting(instanceID)
+	return nil
+}
+
+func (s *vpcRouterHandler) CreateBinding(instanceID, bindingID string) (*osb.ServiceBinding, error) {
+	client := sacloudAPI.VPCRouter()
+	router, err := client.Read(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if !(&vpcRouterAttrs{VPCRouter: router}).IsUp() {
+		return nil, errors.New("the VPC router is not available")
+	}
+
+	var details *sacloud.SiteToSiteConnectionInfo
+	if router.HasSiteToSiteIPsecVPN() {
+		details, err = client.SiteToSiteConnectionDetails(instanceID)
+		if err != nil {
+			return nil, fmt.Errorf("reading site-to-site VPN parameters is failed: %s", err)
+		}
+	}
+	return vpcRouterBinding(router, details), nil
+}
+
+// vpcRouterBinding returns the binding which contains the public IP address and parameters of the site-to-site VPN
+func vpcRouterBinding(router *sacloud.VPCRouter, details *sacloud.SiteToSiteConnectionInfo) *osb.ServiceBinding {
+	actual := actualVPCRouterParameter(router)
+
+	publicIP := actual.PublicVirtualIPAddress
+	if router.IsStandardPlan() && len(router.Interfaces) > 0 {
+		publicIP = router.Interfaces[0].IPAddress
+	}
+
+	credentials := map[string]interface{}{
+		"vpcRouterID":     router.GetStrID(),
+		"publicIPAddress": publicIP,
+	}
+	if len(actual.IPAliases) > 0 {
+		credentials["ipAliases"] = actual.IPAliases
+	}
+
+	if details != nil && len(details.Details.Config) > 0 {
+		d := details.Details.Config[0]
+		credentials["siteToSiteVPN"] = map[string]interface{}{
+			"localID":         d.VPCRouter.ID,
+			"localAddress":    d.VPCRouter.OutsideIPAddress,
+			"localNetworks":   d.VPCRouter.InsideNetworks,
+			"peerID":          d.Peer.ID,
+			"peerAddress":     d.Peer.OutsideIPAddress,
+			"peerNetworks":    d.Peer.InsideNetworks,
+			"preSharedSecret": d.IKE.PreSharedSecret,
+			"ike": map[string]string{
+				"authenticationProtocol": d.IKE.AuthenticationProtocol,
+				"encryptionProtocol":     d.IKE.EncryptionProtocol,
+				"lifetime":               d.IKE.Lifetime,
+				"mode":                   d.IKE.Mode,
+				"perfectForwardSecrecy":  d.IKE.PerfectForwardSecrecy,
+			},
+			"esp": map[string]string{
+				"authenticationProtocol": d.ESP.AuthenticationProtocol,
+				"dhGroup":                d.ESP.DHGroup,
+				"encryptionProtocol":     d.ESP.EncryptionProtocol,
+				"lifetime":               d.ESP.Lifetime,
+				"mode":                   d.ESP.Mode,
+				"perfectForwardSecrecy":  d.ESP.PerfectForwardSecrecy,
+			},
+		}
+	}
+
+	return &osb.ServiceBinding{
+		Credentials: credentials,
+	}
+}
+
+// DeleteBinding does nothing because bindings of VPC routers have no state on the VPC router
+func (s *vpcRouterHandler) DeleteBinding(instanceID, bindingID string) error {
+	return nil
+}
+
+func (s *vpcRouterHandler) ConfirmProvision(instanceID string) error {
+	return sacloudAPI.VPCRouter().ConfirmProvisioned(instanceID)
+}
+
+func (s *vpcRouterHandler) IsValid() (bool, error) {
+	return s.paramErr == nil, s.paramErr
+}
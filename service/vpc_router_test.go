@@ -0,0 +1,285 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sacloud/libsacloud/sacloud"
+	"github.com/sacloud/open-service-broker-sacloud/broker/operations"
+	"github.com/sacloud/open-service-broker-sacloud/iaas"
+	"github.com/sacloud/open-service-broker-sacloud/service/params"
+	"github.com/stretchr/testify/assert"
+)
+
+type dummyVPCRouterAPI struct {
+	readResult *sacloud.VPCRouter
+	readErr    error
+	listResult []sacloud.VPCRouter
+	createErr  error
+	updateErr  error
+	deleteErr  error
+	details    *sacloud.SiteToSiteConnectionInfo
+
+	created *params.VPCRouterCreateParameter
+	updated *params.VPCRouterConfigParameter
+	deleted bool
+}
+
+func (c *dummyVPCRouterAPI) List() ([]sacloud.VPCRouter, error) {
+	return c.listResult, c.readErr
+}
+
+func (c *dummyVPCRouterAPI) Read(instanceID string) (*sacloud.VPCRouter, error) {
+	return c.readResult, c.readErr
+}
+
+func (c *dummyVPCRouterAPI) Create(instanceID string, param *params.VPCRouterCreateParameter) (*sacloud.VPCRouter, error) {
+	c.created = param
+	return c.readResult, c.createErr
+}
+
+func (c *dummyVPCRouterAPI) Update(instanceID string, config *params.VPCRouterConfigParameter) (*sacloud.VPCRouter, error) {
+	c.updated = config
+	return c.readResult, c.updateErr
+}
+
+func (c *dummyVPCRouterAPI) SiteToSiteConnectionDetails(instanceID string) (*sacloud.SiteToSiteConnectionInfo, error) {
+	return c.details, c.readErr
+}
+
+func (c *dummyVPCRouterAPI) ResumeConfiguring() error {
+	return nil
+}
+
+func (c *dummyVPCRouterAPI) ConfirmProvisioned(instanceID string) error {
+	return nil
+}
+
+func (c *dummyVPCRouterAPI) Delete(instanceID string) error {
+	c.deleted = true
+	return c.deleteErr
+}
+
+const (
+	vpcRouterTestID           = 777777777777
+	vpcRouterTestPublicSwitch = 888888888888
+
+	validVPCRouterStandardParam = `{
+        "interfaces": [{"switchID": 999999999999, "ipaddress": "192.2.0.1", "maskLen": 24}]
+    }`
+
+	validVPCRouterPremiumParam = `{
+        "publicSwitchID": 888888888888,
+        "publicIPAddresses": ["203.0.113.11", "203.0.113.12"],
+        "publicVirtualIPAddress": "203.0.113.10",
+        "ipAliases": ["203.0.113.13"],
+        "vrid": 1,
+        "interfaces": [
+            {"switchID": 999999999999, "ipaddresses": ["192.2.0.2", "192.2.0.3"], "virtualIPAddress": "192.2.0.1", "maskLen": 24}
+        ],
+        "staticNAT": [{"globalAddress": "203.0.113.13", "privateAddress": "192.2.0.11"}],
+        "portForwarding": [{"protocol": "tcp", "globalPort": 2222, "privateAddress": "192.2.0.12", "privatePort": 22}],
+        "firewall": [
+            {"interface": 1, "direction": "send", "action": "allow", "protocol": "ip"},
+            {"interface": 0, "direction": "receive", "action": "allow", "protocol": "tcp", "destinationPort": "2222"},
+            {"interface": 0, "direction": "receive", "action": "deny", "protocol": "ip", "logging": true}
+        ],
+        "siteToSiteVPN": {
+            "peer": "198.51.100.1",
+            "preSharedSecret": "secret",
+            "routes": ["10.0.0.0/8"],
+            "localPrefix": ["192.2.0.0/24"]
+        }
+    }`
+)
+
+// testVPCRouter returns the running premium VPC router which is configured with validVPCRouterPremiumParam
+func testVPCRouter(instanceID string) *sacloud.VPCRouter {
+	router := sacloud.CreateNewVPCRouter()
+	router.Resource = sacloud.NewResource(vpcRouterTestID)
+	router.Name = instanceID
+	router.Tags = []string{"@open-service-broker-sacloud"}
+	router.Instance = &sacloud.Instance{EServerInstanceStatus: &sacloud.EServerInstanceStatus{Status: "up"}}
+	router.SetPremiumPlan("888888888888", "203.0.113.10", "203.0.113.11", "203.0.113.12", 1, []string{"203.0.113.13"})
+
+	public, private := sacloud.Interface{}, sacloud.Interface{}
+	public.Switch = &sacloud.Switch{Resource: sacloud.NewResource(vpcRouterTestPublicSwitch)}
+	private.Switch = &sacloud.Switch{Resource: sacloud.NewResource(int64(mariaDBTestSwitchID))}
+	router.Interfaces = []sacloud.Interface{public, private}
+
+	s := router.Settings.Router
+	s.AddInterface("192.2.0.1", []string{"192.2.0.2", "192.2.0.3"}, 24)
+	s.AddStaticNAT("203.0.113.13", "192.2.0.11", "")
+	s.AddPortForwarding("tcp", "2222", "192.2.0.12", "22", "")
+	s.AddFirewallRuleReceive(0, true, "tcp", "", "", "", "2222", false, "")
+	s.AddFirewallRuleReceive(0, false, "ip", "", "", "", "", true, "")
+	s.AddFirewallRuleSend(1, true, "ip", "", "", "", "", false, "")
+	s.AddSiteToSiteIPsecVPN([]string{"192.2.0.0/24"}, "198.51.100.1", "secret", "198.51.100.1", []string{"10.0.0.0/8"})
+	return router
+}
+
+func TestVPCRouterServiceValidate(t *testing.T) {
+	t.Run("Provisioning", func(t *testing.T) {
+		s := newVPCRouterServiceHandler(operations.Provisioning, VPCRouterServiceID, VPCRouterPlanStandardID, []byte(``))
+		_, err := s.IsValid()
+		assert.Error(t, err)
+
+		s = newVPCRouterServiceHandler(operations.Provisioning, VPCRouterServiceID, VPCRouterPlanStandardID, []byte(validVPCRouterStandardParam))
+		_, err = s.IsValid()
+		assert.NoError(t, err)
+		assert.Equal(t, params.VPCRouterPlanStandard, s.parameter.PlanID)
+
+		// the public network is required on the premium plan
+		s = newVPCRouterServiceHandler(operations.Provisioning, VPCRouterServiceID, VPCRouterPlanPremiumID, []byte(validVPCRouterStandardParam))
+		_, err = s.IsValid()
+		assert.Error(t, err)
+
+		s = newVPCRouterServiceHandler(operations.Provisioning, VPCRouterServiceID, VPCRouterPlanHighSpecID, []byte(validVPCRouterPremiumParam))
+		_, err = s.IsValid()
+		assert.NoError(t, err)
+		assert.Equal(t, params.VPCRouterPlanHighSpec, s.parameter.PlanID)
+		assert.Equal(t, "198.51.100.1", s.parameter.SiteToSiteVPN.RemoteID)
+	})
+
+	t.Run("Updating", func(t *testing.T) {
+		s := newVPCRouterServiceHandler(operations.Updating, VPCRouterServiceID, VPCRouterPlanStandardID, []byte(``))
+		_, err := s.IsValid()
+		assert.Error(t, err)
+
+		s = newVPCRouterServiceHandler(operations.Updating, VPCRouterServiceID, VPCRouterPlanStandardID,
+			[]byte(`{"staticNAT": [{"globalAddress": "203.0.113.13", "privateAddress": "192.2.0.11"}]}`))
+		_, err = s.IsValid()
+		assert.Error(t, err)
+
+		s = newVPCRouterServiceHandler(operations.Updating, VPCRouterServiceID, VPCRouterPlanStandardID,
+			[]byte(`{"portForwarding": [{"protocol": "udp", "globalPort": 53, "privateAddress": "192.2.0.12", "privatePort": 53}]}`))
+		_, err = s.IsValid()
+		assert.NoError(t, err)
+	})
+}
+
+func TestVPCRouterHandler(t *testing.T) {
+	vpcRouterAPI := &dummyVPCRouterAPI{readResult: testVPCRouter(instanceID)}
+	sacloudAPI = &dummyAPI{vpcRouterAPI: vpcRouterAPI}
+	defer func() { sacloudAPI = testAPI }()
+
+	t.Run("InstanceState", func(t *testing.T) {
+		s := newVPCRouterServiceHandler(operations.Provisioning, VPCRouterServiceID, VPCRouterPlanPremiumID, []byte(validVPCRouterPremiumParam))
+		state, err := s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.True(t, state.IsUp())
+		assert.False(t, state.IsMigrating())
+		assert.Empty(t, vpcRouterDrift(vpcRouterAPI.readResult, s.parameter))
+		assert.False(t, state.HasDiff())
+
+		var p map[string]interface{}
+		if err := json.Unmarshal([]byte(validVPCRouterPremiumParam), &p); err != nil {
+			t.Fatal(err)
+		}
+		delete(p, "firewall")
+		changed, _ := json.Marshal(p)
+		s = newVPCRouterServiceHandler(operations.Provisioning, VPCRouterServiceID, VPCRouterPlanPremiumID, changed)
+		assert.Equal(t, []string{"firewall"}, vpcRouterDrift(vpcRouterAPI.readResult, s.parameter))
+
+		s = newVPCRouterServiceHandler(operations.Provisioning, VPCRouterServiceID, VPCRouterPlanHighSpecID, []byte(validVPCRouterPremiumParam))
+		state, err = s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.True(t, state.HasDiff())
+	})
+
+	t.Run("InstanceState while configuring", func(t *testing.T) {
+		vpcRouterAPI.readResult.AppendTag(iaas.VPCRouterConfiguringMarkerTag)
+		defer vpcRouterAPI.readResult.RemoveTag(iaas.VPCRouterConfiguringMarkerTag)
+
+		// the configuration isn't applied yet
+		s := newVPCRouterServiceHandler(operations.Provisioning, VPCRouterServiceID, VPCRouterPlanPremiumID,
+			[]byte(`{
+                "publicSwitchID": 888888888888,
+                "publicIPAddresses": ["203.0.113.11", "203.0.113.12"],
+                "publicVirtualIPAddress": "203.0.113.10",
+                "ipAliases": ["203.0.113.13"],
+                "vrid": 1,
+                "interfaces": [
+                    {"switchID": 111111111111, "ipaddresses": ["192.2.1.2", "192.2.1.3"], "virtualIPAddress": "192.2.1.1", "maskLen": 24}
+                ]
+            }`))
+		state, err := s.InstanceState(instanceID)
+		assert.NoError(t, err)
+		assert.False(t, state.IsUp())
+		assert.False(t, state.IsFailed())
+		assert.True(t, state.IsMigrating())
+		assert.False(t, state.HasDiff())
+	})
+
+	t.Run("fetchVPCRouter", func(t *testing.T) {
+		instance, err := fetchVPCRouter(instanceID)
+		assert.NoError(t, err)
+
+		p := instance.Parameters.(*params.VPCRouterCreateParameter)
+		assert.Equal(t, "198.51.100.1", p.SiteToSiteVPN.Peer)
+		assert.Empty(t, p.SiteToSiteVPN.PreSharedSecret)
+
+		raw, err := json.Marshal(instance.Parameters)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(raw), "secret")
+	})
+
+	t.Run("CreateBinding", func(t *testing.T) {
+		details := &sacloud.SiteToSiteConnectionInfo{}
+		d := sacloud.SiteToSiteConnectionDetail{}
+		d.IKE.PreSharedSecret = "secret"
+		d.IKE.Lifetime = "28800"
+		d.ESP.Lifetime = "1800"
+		d.Peer.ID = "198.51.100.1"
+		d.Peer.OutsideIPAddress = "198.51.100.1"
+		d.Peer.InsideNetworks = []string{"10.0.0.0/8"}
+		d.VPCRouter.ID = "203.0.113.10"
+		d.VPCRouter.OutsideIPAddress = "203.0.113.10"
+		d.VPCRouter.InsideNetworks = []string{"192.2.0.0/24"}
+		details.Details.Config = []sacloud.SiteToSiteConnectionDetail{d}
+		vpcRouterAPI.details = details
+
+		s := newVPCRouterServiceHandler(operations.Binding, VPCRouterServiceID, VPCRouterPlanPremiumID, []byte(``))
+		binding, err := s.CreateBinding(instanceID, bindingID)
+		assert.NoError(t, err)
+
+		credentials := binding.Credentials.(map[string]interface{})
+		assert.Equal(t, "777777777777", credentials["vpcRouterID"])
+		assert.Equal(t, "203.0.113.10", credentials["publicIPAddress"])
+		assert.Equal(t, []string{"203.0.113.13"}, credentials["ipAliases"])
+
+		vpn := credentials["siteToSiteVPN"].(map[string]interface{})
+		assert.Equal(t, "secret", vpn["preSharedSecret"])
+		assert.Equal(t, "198.51.100.1", vpn["peerAddress"])
+		assert.Equal(t, []string{"192.2.0.0/24"}, vpn["localNetworks"])
+		assert.Equal(t, "28800", vpn["ike"].(map[string]string)["lifetime"])
+		assert.Equal(t, "1800", vpn["esp"].(map[string]string)["lifetime"])
+	})
+
+	t.Run("UpdateInstance", func(t *testing.T) {
+		// only a private interface is connected
+		s := newVPCRouterServiceHandler(operations.Updating, VPCRouterServiceID, VPCRouterPlanPremiumID,
+			[]byte(`{"firewall": [{"interface": 2, "direction": "receive", "action": "deny", "protocol": "ip"}]}`))
+		_, err := s.IsValid()
+		assert.NoError(t, err)
+		assert.Error(t, s.UpdateInstance(instanceID))
+		assert.Nil(t, vpcRouterAPI.updated)
+
+		// the global address must be one of IP aliases
+		s = newVPCRouterServiceHandler(operations.Updating, VPCRouterServiceID, VPCRouterPlanPremiumID,
+			[]byte(`{"staticNAT": [{"globalAddress": "203.0.113.14", "privateAddress": "192.2.0.11"}]}`))
+		assert.Error(t, s.UpdateInstance(instanceID))
+		assert.Nil(t, vpcRouterAPI.updated)
+
+		s = newVPCRouterServiceHandler(operations.Updating, VPCRouterServiceID, VPCRouterPlanPremiumID,
+			[]byte(`{"firewall": [{"interface": 1, "direction": "receive", "action": "deny", "protocol": "ip"}]}`))
+		assert.NoError(t, s.UpdateInstance(instanceID))
+		assert.Equal(t, s.config, vpcRouterAPI.updated)
+	})
+
+	t.Run("DeleteInstance", func(t *testing.T) {
+		s := newVPCRouterServiceHandler(operations.Deprovisioning, VPCRouterServiceID, VPCRouterPlanPremiumID, []byte(``))
+		assert.NoError(t, s.DeleteInstance(instanceID))
+		assert.True(t, vpcRouterAPI.deleted)
+	})
+}
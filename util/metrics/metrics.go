@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	registryLock sync.Mutex
+	registry     []*Gauge
+)
+
+// Gauge is a metric that represents numerical values per label set
+type Gauge struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*sample
+}
+
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+// NewGauge creates the gauge and registers it to be exported
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		values:     make(map[string]*sample),
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry = append(registry, g)
+
+	return g
+}
+
+// Set sets the value for the label values. Label values must be passed in the order of label names
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	if len(labelValues) != len(g.labelNames) {
+		panic(fmt.Errorf("metrics %q: expected %d label values, got %d", g.name, len(g.labelNames), len(labelValues)))
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := strings.Join(labelValues, "\xff")
+	g.values[key] = &sample{labelValues: labelValues, value: value}
+}
+
+// Get returns the value for the label values
+func (g *Gauge) Get(labelValues ...string) (float64, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	s, ok := g.values[strings.Join(labelValues, "\xff")]
+	if !ok {
+		return 0, false
+	}
+	return s.value, true
+}
+
+// Reset deletes all values of the gauge
+func (g *Gauge) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.values = make(map[string]*sample)
+}
+
+func (g *Gauge) write(w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	buf := bytes.NewBufferString("")
+	fmt.Fprintf(buf, "# HELP %s %s\n", g.name, g.help)
+	fmt.Fprintf(buf, "# TYPE %s gauge\n", g.name)
+
+	keys := make([]string, 0, len(g.values))
+	for k := range g.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := g.values[k]
+		var labels []string
+		for i, name := range g.labelNames {
+			labels = append(labels, fmt.Sprintf("%s=%q", name, s.labelValues[i]))
+		}
+		if len(labels) > 0 {
+			fmt.Fprintf(buf, "%s{%s} %v\n", g.name, strings.Join(labels, ","), s.value)
+		} else {
+			fmt.Fprintf(buf, "%s %v\n", g.name, s.value)
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Write writes all registered metrics in Prometheus text format
+func Write(w io.Writer) error {
+	registryLock.Lock()
+	gauges := make([]*Gauge, len(registry))
+	copy(gauges, registry)
+	registryLock.Unlock()
+
+	for _, g := range gauges {
+		if err := g.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler returns http.HandlerFunc that exports all registered metrics
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.WriteHeader(http.StatusOK)
+		Write(w) // nolint
+	}
+}
@@ -87,3 +87,51 @@ func ValidIPv4Addr(addr string) bool {
 	}
 	return true
 }
+
+// nonPublicIPv4Networks are IPv4 networks which aren't reachable on the internet(RFC 6890)
+var nonPublicIPv4Networks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",       // this network
+		"10.0.0.0/8",      // private
+		"100.64.0.0/10",   // shared address space
+		"127.0.0.0/8",     // loopback
+		"169.254.0.0/16",  // link local
+		"172.16.0.0/12",   // private
+		"192.0.0.0/24",    // IETF protocol assignments
+		"192.0.2.0/24",    // documentation
+		"192.168.0.0/16",  // private
+		"198.18.0.0/15",   // benchmarking
+		"198.51.100.0/24", // documentation
+		"203.0.113.0/24",  // documentation
+		"224.0.0.0/4",     // multicast
+		"240.0.0.0/4",     // reserved and broadcast
+	} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// PublicIPv4Addr validates that value is ipv4 address reachable on the internet.
+// Private, loopback, link local, multicast and reserved addresses are rejected
+func PublicIPv4Addr(addr string) bool {
+	// if target is empty, return OK(Use Required if necessary)
+	if addr == "" {
+		return true
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil || !strings.Contains(addr, ".") {
+		return false
+	}
+	for _, network := range nonPublicIPv4Networks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}